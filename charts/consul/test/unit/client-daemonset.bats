@@ -465,6 +465,18 @@ load _helpers
   [ "${actualBaz}" = "qux" ]
 }
 
+@test "client/DaemonSet: partition label is set when admin partitions are enabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'global.enableConsulNamespaces=true' \
+      --set 'global.adminPartitions.enabled=true' \
+      --set 'global.adminPartitions.name=default' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.labels."consul.hashicorp.com/partition"' | tee /dev/stderr)
+  [ "${actual}" = "default" ]
+}
+
 
 #--------------------------------------------------------------------
 # annotations
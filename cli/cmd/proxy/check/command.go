@@ -0,0 +1,380 @@
+package check
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul/api"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// These must match the labels and meta set by the endpoints controller.
+	injectedPodSelector   = "consul.hashicorp.com/connect-inject-status=injected,consul.hashicorp.com/connect-inject-managed-by=consul-k8s-endpoints-controller"
+	serviceIgnoreLabel    = "consul.hashicorp.com/service-ignore"
+	kubeServiceAnnotation = "consul.hashicorp.com/kubernetes-service"
+	managedByMetaValue    = "consul-k8s-endpoints-controller"
+
+	consulServerSelector = "app=consul,component=server"
+	consulServerHTTPPort = 8500
+
+	problemUnregistered = "Not registered with Consul"
+	problemStale        = "Registered with Consul but not in the Endpoints"
+)
+
+// CheckCommand is the command struct for the proxy check command.
+type CheckCommand struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+	restConfig *rest.Config
+
+	set *flag.Sets
+
+	flagNamespace       string
+	flagConsulAddress   string
+	flagConsulNamespace string
+
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+// instance identifies a service instance by the Kubernetes service and the
+// Pod it is registered for.
+type instance struct {
+	Service string
+	Pod     string
+}
+
+// discrepancy is a service instance which is registered with Consul but isn't
+// in the Kubernetes Endpoints, or vice versa.
+type discrepancy struct {
+	instance
+	ConsulService string
+	Problem       string
+}
+
+// init sets up flags and help text for the command.
+func (c *CheckCommand) init() {
+	c.set = flag.NewSets()
+
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:    "namespace",
+		Target:  &c.flagNamespace,
+		Usage:   "The namespace to check services in.",
+		Aliases: []string{"n"},
+	})
+
+	f = c.set.NewSet("Consul Options")
+	f.StringVar(&flag.StringVar{
+		Name:   "consul-address",
+		Target: &c.flagConsulAddress,
+		EnvVar: "CONSUL_HTTP_ADDR",
+		Usage: "The address of the Consul HTTP API, e.g. https://consul.example.com:8501. If not set, a port " +
+			"forward is opened to port 8500 of a Consul server Pod. The CONSUL_HTTP_TOKEN and CONSUL_CACERT " +
+			"environment variables are used to authenticate to Consul.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   "consul-namespace",
+		Target: &c.flagConsulNamespace,
+		Usage:  "The Consul Enterprise namespace the services are registered in. Use \"*\" for all namespaces.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:    "kubeconfig",
+		Aliases: []string{"c"},
+		Target:  &c.flagKubeConfig,
+		Default: "",
+		Usage:   "Set the path to kubeconfig file.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    "context",
+		Target:  &c.flagKubeContext,
+		Default: "",
+		Usage:   "Set the Kubernetes context to use.",
+	})
+
+	c.help = c.set.Help()
+}
+
+// Run executes the check command.
+func (c *CheckCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("check")
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.set.Parse(args); err != nil {
+		c.UI.Output("Error parsing arguments: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if err := c.validateFlags(); err != nil {
+		c.UI.Output("Invalid argument: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if err := c.initKubernetes(); err != nil {
+		c.UI.Output("Error initializing Kubernetes client", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	expected, err := c.expectedInstances()
+	if err != nil {
+		c.UI.Output("Error fetching Kubernetes Endpoints:", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	consulClient, closeConsul, err := c.initConsul()
+	if err != nil {
+		c.UI.Output("Error initializing Consul client", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	defer closeConsul()
+
+	registered, err := c.registeredInstances(consulClient)
+	if err != nil {
+		c.UI.Output("Error fetching services from Consul:", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	discrepancies := compareInstances(expected, registered)
+	c.output(len(expected), discrepancies)
+	if len(discrepancies) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Help returns a description of the command and how it is used.
+func (c *CheckCommand) Help() string {
+	c.once.Do(c.init)
+	return fmt.Sprintf("%s\n\nUsage: consul-k8s proxy check [flags]\n\n"+
+		"Compares the Kubernetes Endpoints of injected Pods in a namespace against the service instances "+
+		"registered with Consul, and exits with 1 if they don't match.\n\n%s", c.Synopsis(), c.help)
+}
+
+// Synopsis returns a one-line command summary.
+func (c *CheckCommand) Synopsis() string {
+	return "Check that the services of injected Pods are registered with Consul."
+}
+
+// validateFlags ensures that the flags passed in by the user can be used.
+func (c *CheckCommand) validateFlags() error {
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if errs := validation.ValidateNamespaceName(c.flagNamespace, false); c.flagNamespace != "" && len(errs) > 0 {
+		return fmt.Errorf("invalid namespace name passed for -namespace/-n: %v", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// initKubernetes initializes the Kubernetes client and defaults the namespace
+// to the one of the current context.
+func (c *CheckCommand) initKubernetes() (err error) {
+	settings := helmCLI.New()
+
+	if c.flagKubeConfig != "" {
+		settings.KubeConfig = c.flagKubeConfig
+	}
+
+	if c.flagKubeContext != "" {
+		settings.KubeContext = c.flagKubeContext
+	}
+
+	if c.kubernetes == nil {
+		if c.restConfig, err = settings.RESTClientGetter().ToRESTConfig(); err != nil {
+			return fmt.Errorf("error creating Kubernetes REST config %v", err)
+		}
+		if c.kubernetes, err = kubernetes.NewForConfig(c.restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+
+	if c.flagNamespace == "" {
+		c.flagNamespace = settings.Namespace()
+	}
+
+	return nil
+}
+
+// initConsul creates a Consul client for the -consul-address, or for a port
+// forward to a Consul server Pod if it isn't set. The returned function closes
+// the port forward.
+func (c *CheckCommand) initConsul() (*api.Client, func(), error) {
+	cfg := api.DefaultConfig()
+	closeFunc := func() {}
+
+	if c.flagConsulAddress != "" {
+		cfg.Address = c.flagConsulAddress
+	} else {
+		servers, err := c.kubernetes.CoreV1().Pods("").List(c.Ctx, metav1.ListOptions{
+			LabelSelector: consulServerSelector,
+			FieldSelector: "status.phase=Running",
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("error finding a Consul server Pod: %v", err)
+		}
+		if len(servers.Items) == 0 {
+			return nil, nil, errors.New("no running Consul server Pods were found, use -consul-address to set the address of Consul")
+		}
+
+		pf := &common.PortForward{
+			Namespace:  servers.Items[0].Namespace,
+			PodName:    servers.Items[0].Name,
+			RemotePort: consulServerHTTPPort,
+			KubeClient: c.kubernetes,
+			RestConfig: c.restConfig,
+		}
+		endpoint, err := pf.Open(c.Ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error port forwarding to Consul server Pod %s: %v", pf.PodName, err)
+		}
+		cfg.Address = endpoint
+		cfg.Scheme = "http"
+		closeFunc = pf.Close
+	}
+
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		closeFunc()
+		return nil, nil, err
+	}
+	return client, closeFunc, nil
+}
+
+// expectedInstances returns the service instances that the endpoints
+// controller should have registered for the injected Pods in the namespace.
+func (c *CheckCommand) expectedInstances() (map[instance]bool, error) {
+	pods, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).List(c.Ctx, metav1.ListOptions{
+		LabelSelector: injectedPodSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	injected := make(map[string]v1.Pod, len(pods.Items))
+	for _, pod := range pods.Items {
+		injected[pod.Name] = pod
+	}
+
+	endpointsList, err := c.kubernetes.CoreV1().Endpoints(c.flagNamespace).List(c.Ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	expected := make(map[instance]bool)
+	for _, endpoints := range endpointsList.Items {
+		if endpoints.Labels[serviceIgnoreLabel] == "true" {
+			continue
+		}
+		for _, subset := range endpoints.Subsets {
+			addresses := append(append([]v1.EndpointAddress{}, subset.Addresses...), subset.NotReadyAddresses...)
+			for _, address := range addresses {
+				if address.TargetRef == nil || address.TargetRef.Kind != "Pod" {
+					continue
+				}
+				pod, ok := injected[address.TargetRef.Name]
+				if !ok || pod.DeletionTimestamp != nil {
+					continue
+				}
+				if name, ok := pod.Annotations[kubeServiceAnnotation]; ok && name != endpoints.Name {
+					continue
+				}
+				expected[instance{Service: endpoints.Name, Pod: pod.Name}] = true
+			}
+		}
+	}
+
+	return expected, nil
+}
+
+// registeredInstances returns the service instances registered with Consul by
+// the endpoints controller for the namespace, mapped to the name of the Consul
+// service. Proxy service instances are skipped.
+func (c *CheckCommand) registeredInstances(client *api.Client) (map[instance]string, error) {
+	opts := &api.QueryOptions{Namespace: c.flagConsulNamespace}
+	services, _, err := client.Catalog().Services(opts.WithContext(c.Ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	filterOpts := &api.QueryOptions{
+		Namespace: c.flagConsulNamespace,
+		Filter: fmt.Sprintf(`ServiceMeta["k8s-namespace"] == %q and ServiceMeta["managed-by"] == %q`,
+			c.flagNamespace, managedByMetaValue),
+	}
+	registered := make(map[instance]string)
+	for name := range services {
+		catalogServices, _, err := client.Catalog().Service(name, "", filterOpts.WithContext(c.Ctx))
+		if err != nil {
+			return nil, err
+		}
+		for _, svc := range catalogServices {
+			if svc.ServiceProxy != nil && svc.ServiceProxy.DestinationServiceName != "" {
+				continue
+			}
+			registered[instance{Service: svc.ServiceMeta["k8s-service-name"], Pod: svc.ServiceMeta["pod-name"]}] = svc.ServiceName
+		}
+	}
+
+	return registered, nil
+}
+
+// compareInstances returns the instances which are only expected or only
+// registered, sorted by service and Pod.
+func compareInstances(expected map[instance]bool, registered map[instance]string) []discrepancy {
+	var discrepancies []discrepancy
+	for inst := range expected {
+		if _, ok := registered[inst]; !ok {
+			discrepancies = append(discrepancies, discrepancy{instance: inst, Problem: problemUnregistered})
+		}
+	}
+	for inst, consulService := range registered {
+		if !expected[inst] {
+			discrepancies = append(discrepancies, discrepancy{instance: inst, ConsulService: consulService, Problem: problemStale})
+		}
+	}
+
+	sort.Slice(discrepancies, func(i, j int) bool {
+		if discrepancies[i].Service != discrepancies[j].Service {
+			return discrepancies[i].Service < discrepancies[j].Service
+		}
+		return discrepancies[i].Pod < discrepancies[j].Pod
+	})
+	return discrepancies
+}
+
+// output prints the discrepancies to the terminal.
+func (c *CheckCommand) output(expected int, discrepancies []discrepancy) {
+	if len(discrepancies) == 0 {
+		c.UI.Output("All %d service instances of injected Pods in %s namespace are registered with Consul.",
+			expected, c.flagNamespace, terminal.WithSuccessStyle())
+		return
+	}
+
+	c.UI.Output("Namespace: %s\n", c.flagNamespace)
+
+	tbl := terminal.NewTable("Service", "Pod", "Consul Service", "Problem")
+	for _, d := range discrepancies {
+		tbl.AddRow([]string{d.Service, d.Pod, d.ConsulService, d.Problem}, []string{})
+	}
+	c.UI.Table(tbl)
+
+	c.UI.Output("Found %d service instances which don't match Consul.", len(discrepancies), terminal.WithErrorStyle())
+}
@@ -0,0 +1,206 @@
+package check
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFlagParsing(t *testing.T) {
+	cases := map[string]struct {
+		args []string
+		out  int
+	}{
+		"Nonexistent flag passed, -foo bar": {
+			args: []string{"-foo", "bar"},
+			out:  1,
+		},
+		"Invalid argument passed, -namespace YOLO": {
+			args: []string{"-namespace", "YOLO"},
+			out:  1,
+		},
+		"Non-flag argument passed": {
+			args: []string{"web"},
+			out:  1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := setupCommand(new(bytes.Buffer))
+			c.kubernetes = fake.NewSimpleClientset()
+			out := c.Run(tc.args)
+			require.Equal(t, tc.out, out)
+		})
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	cases := map[string]struct {
+		registered  []string
+		expExitCode int
+		expected    []string
+		notExpected []string
+	}{
+		"All registered": {
+			registered:  []string{"pod1", "pod2"},
+			expExitCode: 0,
+			expected:    []string{"All 2 service instances of injected Pods in default namespace are registered with Consul."},
+		},
+		"Discrepancies": {
+			registered:  []string{"pod1", "pod3"},
+			expExitCode: 1,
+			expected: []string{
+				"Service.*Pod.*Consul Service.*Problem",
+				"web.*pod2.*Not registered with Consul",
+				"web.*pod3.*web-consul.*Registered with Consul but not in the Endpoints",
+				"Found 2 service instances which don't match Consul.",
+			},
+			notExpected: []string{"pod1", "uninjected"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			consulServer := fakeConsul(t, tc.registered)
+			defer consulServer.Close()
+
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+			c.kubernetes = fake.NewSimpleClientset(
+				injectedPod("pod1"),
+				injectedPod("pod2"),
+				&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "uninjected", Namespace: "default"}},
+				&v1.Endpoints{
+					ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+					Subsets: []v1.EndpointSubset{
+						{
+							Addresses: []v1.EndpointAddress{
+								{IP: "1.1.1.1", TargetRef: &v1.ObjectReference{Kind: "Pod", Name: "pod1", Namespace: "default"}},
+								{IP: "3.3.3.3", TargetRef: &v1.ObjectReference{Kind: "Pod", Name: "uninjected", Namespace: "default"}},
+							},
+							NotReadyAddresses: []v1.EndpointAddress{
+								{IP: "2.2.2.2", TargetRef: &v1.ObjectReference{Kind: "Pod", Name: "pod2", Namespace: "default"}},
+							},
+						},
+					},
+				},
+			)
+
+			exitCode := c.Run([]string{"-n", "default", "-consul-address", consulServer.URL})
+			require.Equal(t, tc.expExitCode, exitCode)
+
+			actual := buf.String()
+			for _, expression := range tc.expected {
+				require.Regexp(t, expression, actual)
+			}
+			for _, expression := range tc.notExpected {
+				require.NotRegexp(t, expression, actual)
+			}
+		})
+	}
+}
+
+func TestCompareInstances(t *testing.T) {
+	expected := map[instance]bool{
+		{Service: "web", Pod: "pod1"}: true,
+		{Service: "web", Pod: "pod2"}: true,
+		{Service: "api", Pod: "pod4"}: true,
+	}
+	registered := map[instance]string{
+		{Service: "web", Pod: "pod1"}: "web",
+		{Service: "web", Pod: "pod3"}: "web",
+	}
+
+	require.Equal(t, []discrepancy{
+		{instance: instance{Service: "api", Pod: "pod4"}, Problem: problemUnregistered},
+		{instance: instance{Service: "web", Pod: "pod2"}, Problem: problemUnregistered},
+		{instance: instance{Service: "web", Pod: "pod3"}, ConsulService: "web", Problem: problemStale},
+	}, compareInstances(expected, registered))
+}
+
+// fakeConsul returns a Consul server with an instance of the "web-consul"
+// service and its proxy registered for each of the given Pods.
+func fakeConsul(t *testing.T, pods []string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp interface{}
+		switch {
+		case r.URL.Path == "/v1/catalog/services":
+			resp = map[string][]string{"consul": {}, "web-consul": {}, "web-consul-sidecar-proxy": {}}
+		case strings.HasPrefix(r.URL.Path, "/v1/catalog/service/"):
+			name := strings.TrimPrefix(r.URL.Path, "/v1/catalog/service/")
+			services := []*api.CatalogService{}
+			for _, pod := range pods {
+				if name != "web-consul" && name != "web-consul-sidecar-proxy" {
+					continue
+				}
+				svc := &api.CatalogService{
+					ServiceName: name,
+					ServiceMeta: map[string]string{
+						"k8s-namespace":    "default",
+						"k8s-service-name": "web",
+						"pod-name":         pod,
+						"managed-by":       "consul-k8s-endpoints-controller",
+					},
+				}
+				if name == "web-consul-sidecar-proxy" {
+					svc.ServiceProxy = &api.AgentServiceConnectProxyConfig{DestinationServiceName: "web-consul"}
+				}
+				services = append(services, svc)
+			}
+			resp = services
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+}
+
+func injectedPod(name string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels: map[string]string{
+				"consul.hashicorp.com/connect-inject-status":     "injected",
+				"consul.hashicorp.com/connect-inject-managed-by": "consul-k8s-endpoints-controller",
+			},
+		},
+	}
+}
+
+func setupCommand(buf io.Writer) *CheckCommand {
+	// Log at a test level to standard out.
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "test",
+		Level:  hclog.Debug,
+		Output: os.Stdout,
+	})
+
+	// Setup and initialize the command struct
+	command := &CheckCommand{
+		BaseCommand: &common.BaseCommand{
+			Log: log,
+			UI:  terminal.NewUI(context.Background(), buf),
+		},
+	}
+	command.init()
+
+	return command
+}
@@ -0,0 +1,261 @@
+package logs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// envoySidecarContainer is the name of the Envoy sidecar container added to
+// Pods by the connect injector.
+const envoySidecarContainer = "envoy-sidecar"
+
+// maxLogLineSize is the longest log line that is printed.
+const maxLogLineSize = 1024 * 1024
+
+// LogsCommand is the command struct for the proxy logs command.
+type LogsCommand struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+
+	set *flag.Sets
+
+	// Command Flags
+	flagNamespace string
+	flagPodName   string
+	flagContainer string
+	flagFollow    bool
+	flagTail      int64
+
+	// Global Flags
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+// init sets up flags and help text for the command.
+func (c *LogsCommand) init() {
+	c.set = flag.NewSets()
+
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:    "namespace",
+		Target:  &c.flagNamespace,
+		Usage:   "The namespace where the target Pod can be found.",
+		Aliases: []string{"n"},
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    "container",
+		Target:  &c.flagContainer,
+		Default: envoySidecarContainer,
+		Usage:   "The container of the Pod to print the logs of. Defaults to the Envoy sidecar container.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    "follow",
+		Target:  &c.flagFollow,
+		Default: false,
+		Usage:   "Stream the logs as they are written until the command is interrupted.",
+		Aliases: []string{"f"},
+	})
+	f.Int64Var(&flag.Int64Var{
+		Name:    "tail",
+		Target:  &c.flagTail,
+		Default: -1,
+		Usage:   "The number of most recent log lines to print. By default all log lines are printed.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:    "kubeconfig",
+		Aliases: []string{"c"},
+		Target:  &c.flagKubeConfig,
+		Default: "",
+		Usage:   "Set the path to kubeconfig file.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    "context",
+		Target:  &c.flagKubeContext,
+		Default: "",
+		Usage:   "Set the Kubernetes context to use.",
+	})
+
+	c.help = c.set.Help()
+}
+
+// Run executes the logs command.
+func (c *LogsCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("logs")
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.parseFlags(args); err != nil {
+		c.UI.Output("Error parsing arguments: %v", err.Error(), terminal.WithErrorStyle())
+		c.UI.Output("\n" + c.Help())
+		return 1
+	}
+
+	if err := c.validateFlags(); err != nil {
+		c.UI.Output("Invalid argument: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if err := c.initKubernetes(); err != nil {
+		c.UI.Output("Error initializing Kubernetes client", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if err := c.streamLogs(); err != nil {
+		c.UI.Output("Error reading logs for Pod %s:", c.flagPodName, err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	return 0
+}
+
+// Help returns a description of the command and how it is used.
+func (c *LogsCommand) Help() string {
+	c.once.Do(c.init)
+	return fmt.Sprintf("%s\n\nUsage: consul-k8s proxy logs <pod-name> [flags]\n\n%s", c.Synopsis(), c.help)
+}
+
+// Synopsis returns a one-line command summary.
+func (c *LogsCommand) Synopsis() string {
+	return "Print the logs of the Envoy proxy for a given Pod."
+}
+
+func (c *LogsCommand) parseFlags(args []string) error {
+	// Separate positional arguments from keyed arguments.
+	positional := []string{}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			break
+		}
+		positional = append(positional, arg)
+	}
+	keyed := args[len(positional):]
+
+	if err := c.set.Parse(keyed); err != nil {
+		return err
+	}
+
+	if len(positional) != 1 {
+		return fmt.Errorf("Exactly one positional argument is required: <pod-name>")
+	}
+	c.flagPodName = positional[0]
+
+	return nil
+}
+
+// validateFlags ensures that the flags passed in by the user can be used.
+func (c *LogsCommand) validateFlags() error {
+	if errs := validation.ValidateNamespaceName(c.flagNamespace, false); c.flagNamespace != "" && len(errs) > 0 {
+		return fmt.Errorf("invalid namespace name passed for -namespace/-n: %v", strings.Join(errs, "; "))
+	}
+	if c.flagContainer == "" {
+		return errors.New("-container must not be empty")
+	}
+	if c.flagTail < -1 {
+		return errors.New("-tail must be -1 or greater")
+	}
+	return nil
+}
+
+// initKubernetes initializes the Kubernetes client and defaults the namespace
+// to the one of the current context.
+func (c *LogsCommand) initKubernetes() error {
+	settings := helmCLI.New()
+
+	if c.flagKubeConfig != "" {
+		settings.KubeConfig = c.flagKubeConfig
+	}
+
+	if c.flagKubeContext != "" {
+		settings.KubeContext = c.flagKubeContext
+	}
+
+	if c.kubernetes == nil {
+		restConfig, err := settings.RESTClientGetter().ToRESTConfig()
+		if err != nil {
+			return fmt.Errorf("error creating Kubernetes REST config %v", err)
+		}
+		if c.kubernetes, err = kubernetes.NewForConfig(restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+
+	if c.flagNamespace == "" {
+		c.flagNamespace = settings.Namespace()
+	}
+
+	return nil
+}
+
+// streamLogs prints the logs of the container to the terminal. If -follow is
+// set it keeps printing new log lines until the stream is closed or the
+// command is interrupted.
+func (c *LogsCommand) streamLogs() error {
+	pod, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).Get(c.Ctx, c.flagPodName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if !hasContainer(pod, c.flagContainer) {
+		return fmt.Errorf("container %q not found in Pod %s", c.flagContainer, c.flagPodName)
+	}
+
+	opts := &v1.PodLogOptions{
+		Container: c.flagContainer,
+		Follow:    c.flagFollow,
+	}
+	if c.flagTail >= 0 {
+		opts.TailLines = &c.flagTail
+	}
+
+	stream, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).GetLogs(c.flagPodName, opts).Stream(c.Ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	return c.output(stream)
+}
+
+// output prints each line read from the log stream.
+func (c *LogsCommand) output(stream io.Reader) error {
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+	for scanner.Scan() {
+		c.UI.Output("%s", scanner.Text())
+	}
+
+	// The stream is closed when the command is interrupted while following
+	// the logs, which is not an error.
+	if err := scanner.Err(); err != nil && c.Ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// hasContainer returns true if the Pod has a container with the given name.
+func hasContainer(pod *v1.Pod, name string) bool {
+	for _, container := range pod.Spec.Containers {
+		if container.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFlagParsing(t *testing.T) {
+	cases := map[string]struct {
+		args []string
+		out  int
+	}{
+		"No args": {
+			args: []string{},
+			out:  1,
+		},
+		"Multiple pod names passed": {
+			args: []string{"web", "api"},
+			out:  1,
+		},
+		"Nonexistent flag passed, -foo bar": {
+			args: []string{"web", "-foo", "bar"},
+			out:  1,
+		},
+		"Invalid argument passed, -namespace YOLO": {
+			args: []string{"web", "-namespace", "YOLO"},
+			out:  1,
+		},
+		"Empty container passed": {
+			args: []string{"web", "-container", ""},
+			out:  1,
+		},
+		"Invalid tail passed, -tail -2": {
+			args: []string{"web", "-tail", "-2"},
+			out:  1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := setupCommand(new(bytes.Buffer))
+			c.kubernetes = fake.NewSimpleClientset()
+			out := c.Run(tc.args)
+			require.Equal(t, tc.out, out)
+		})
+	}
+}
+
+func TestLogsCommand(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: "default",
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{Name: "web"},
+				{Name: envoySidecarContainer},
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		args        []string
+		expExitCode int
+		expected    string
+	}{
+		"Envoy sidecar container by default": {
+			args:     []string{"web", "-namespace", "default"},
+			expected: "fake logs",
+		},
+		"Application container": {
+			args:     []string{"web", "-namespace", "default", "-container", "web"},
+			expected: "fake logs",
+		},
+		"Follow": {
+			args:     []string{"web", "-namespace", "default", "-follow", "-tail", "10"},
+			expected: "fake logs",
+		},
+		"Container not in Pod": {
+			args:        []string{"web", "-namespace", "default", "-container", "consul-sidecar"},
+			expExitCode: 1,
+			expected:    "container \"consul-sidecar\" not found in Pod web",
+		},
+		"Pod not found": {
+			args:        []string{"api", "-namespace", "default"},
+			expExitCode: 1,
+			expected:    "Error reading logs for Pod api",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+			c.kubernetes = fake.NewSimpleClientset(pod)
+
+			out := c.Run(tc.args)
+			require.Equal(t, tc.expExitCode, out)
+			require.Contains(t, buf.String(), tc.expected)
+		})
+	}
+}
+
+func TestOutput(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := setupCommand(buf)
+
+	stream := strings.NewReader("[info] envoy started\n[warning] 100% of requests failed\n")
+	require.NoError(t, c.output(stream))
+	require.Equal(t, "[info] envoy started\n[warning] 100% of requests failed\n", buf.String())
+}
+
+func setupCommand(buf io.Writer) *LogsCommand {
+	// Log at a test level to standard out.
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "test",
+		Level:  hclog.Debug,
+		Output: os.Stdout,
+	})
+
+	// Setup and initialize the command struct
+	command := &LogsCommand{
+		BaseCommand: &common.BaseCommand{
+			Ctx: context.Background(),
+			Log: log,
+			UI:  terminal.NewUI(context.Background(), buf),
+		},
+	}
+	command.init()
+
+	return command
+}
@@ -2,11 +2,22 @@ package read
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
+	"github.com/fatih/color"
 	"github.com/hashicorp/consul-k8s/cli/common"
 	"github.com/hashicorp/consul-k8s/cli/common/flag"
 	"github.com/hashicorp/consul-k8s/cli/common/terminal"
@@ -15,12 +26,25 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/utils/strings/slices"
 )
 
 // defaultAdminPort is the port where the Envoy admin API is exposed.
 const defaultAdminPort int = 19000
 
+// defaultFetchAttempts is the number of times fetching the Envoy configuration
+// is attempted when the port forward is reset.
+const defaultFetchAttempts int = 3
+
+// defaultWatchInterval is how often the Envoy configuration is fetched again
+// when -watch is set.
+const defaultWatchInterval = 5 * time.Second
+
+// clearScreen is the ANSI escape sequence which moves the cursor to the top
+// left of the terminal and clears it.
+const clearScreen = "\033[H\033[2J"
+
 const (
 	Table = "table"
 	JSON  = "json"
@@ -35,19 +59,69 @@ type ReadCommand struct {
 	set *flag.Sets
 
 	// Command Flags
-	flagNamespace string
-	flagPodName   string
-	flagOutput    string
+	flagNamespace     string
+	flagAllNamespaces bool
+	flagPodName       string
+	flagService       string
+	flagAdminPort     int
+	flagOutput        string
+
+	// flagMarkdown renders each table as a GitHub-flavored Markdown table.
+	flagMarkdown bool
+
+	// flagExtractStatic outputs the static clusters and listeners as a
+	// minimal Envoy bootstrap configuration in YAML.
+	flagExtractStatic bool
+
+	// flagAdminBindPort is the port Envoy binds its admin API to inside the
+	// Pod when it differs from the default.
+	flagAdminBindPort int
+	flagCounts        bool
+	flagQuiet         bool
+	flagNoColor       bool
+	flagMaxWidth      int
+	flagLimit         int
+	flagFromFile      string
+	flagWatch         bool
+	flagInterval      time.Duration
+
+	// flagShowConfigVersion adds the xDS version_info of each entry to the
+	// tables so that they can be correlated with xDS pushes.
+	flagShowConfigVersion bool
+
+	// flagWithStats adds the runtime stats of each cluster to the clusters
+	// table.
+	flagWithStats bool
+
+	// Envoy Admin API Opts
+	flagTLS           bool
+	flagInsecure      bool
+	flagCAFile        string
+	flagTLSMinVersion string
+
+	// flagAdminUsername and flagAdminPassword are the basic auth credentials
+	// sent to an auth proxy in front of the Envoy admin API.
+	flagAdminUsername string
+	flagAdminPassword string
+
+	// flagFetchAttempts is the number of times to attempt fetching the Envoy
+	// configuration when the port forward is reset.
+	flagFetchAttempts int
 
 	// Output Filtering Opts
-	flagClusters  bool
-	flagListeners bool
-	flagRoutes    bool
-	flagEndpoints bool
-	flagSecrets   bool
-	flagFQDN      string
-	flagAddress   string
-	flagPort      int
+	flagClusters   bool
+	flagListeners  bool
+	flagRoutes     bool
+	flagEndpoints  bool
+	flagSecrets    bool
+	flagConfigOnly bool
+	flagFQDN       string
+	flagAddress    string
+	flagPort       int
+
+	// flagUnhealthyOnly filters the endpoints output to endpoints which are
+	// not healthy.
+	flagUnhealthyOnly bool
 
 	// Global Flags
 	flagKubeConfig  string
@@ -55,15 +129,40 @@ type ReadCommand struct {
 
 	fetchConfig func(context.Context, common.PortForwarder) (*EnvoyConfig, error)
 
+	// fetchClusterStats fetches the runtime stats of the clusters when
+	// -with-stats is set.
+	fetchClusterStats func(context.Context, common.PortForwarder) (map[string]ClusterStats, error)
+
+	// httpClient is the client used to fetch the configuration from the Envoy
+	// admin API.
+	httpClient *http.Client
+
 	restConfig *rest.Config
 
+	// inClusterConfig returns the config for the pod's service account. It is
+	// used to build the Kubernetes client when no kubeconfig is available.
+	inClusterConfig func() (*rest.Config, error)
+
 	once sync.Once
 	help string
 }
 
 func (c *ReadCommand) init() {
+	if c.inClusterConfig == nil {
+		c.inClusterConfig = rest.InClusterConfig
+	}
 	if c.fetchConfig == nil {
-		c.fetchConfig = FetchConfig
+		c.fetchConfig = func(ctx context.Context, pf common.PortForwarder) (*EnvoyConfig, error) {
+			if c.flagConfigOnly {
+				return FetchConfigWithoutEndpoints(ctx, pf, c.httpClient, c.adminScheme())
+			}
+			return FetchConfigWithClient(ctx, pf, c.httpClient, c.adminScheme())
+		}
+	}
+	if c.fetchClusterStats == nil {
+		c.fetchClusterStats = func(ctx context.Context, pf common.PortForwarder) (map[string]ClusterStats, error) {
+			return FetchClusterStats(ctx, pf, c.httpClient, c.adminScheme())
+		}
 	}
 
 	c.set = flag.NewSets()
@@ -74,6 +173,29 @@ func (c *ReadCommand) init() {
 		Usage:   "The namespace where the target Pod can be found.",
 		Aliases: []string{"n"},
 	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    "all-namespaces",
+		Target:  &c.flagAllNamespaces,
+		Usage:   "Search for the target Pod across all namespaces. The Pod's name must be unique across namespaces.",
+		Aliases: []string{"A"},
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   "service",
+		Target: &c.flagService,
+		Usage:  "Only read the configuration of the Envoy proxying the given service in a multiport Pod. The Envoy for the service at index i of the Pod's consul.hashicorp.com/connect-service annotation serves its admin API on port 19000+i, or on -admin-bind-port+i if it is set.",
+	})
+	f.IntVar(&flag.IntVar{
+		Name:   "admin-port",
+		Target: &c.flagAdminPort,
+		Usage:  "Only read the configuration of the Envoy serving its admin API on the given port. May not be used with -service.",
+	})
+	f.IntVar(&flag.IntVar{
+		Name:   "admin-bind-port",
+		Target: &c.flagAdminBindPort,
+		Usage: fmt.Sprintf("The port Envoy binds its admin API to inside the Pod if it was changed from the default of %d, e.g. with -admin-bind. "+
+			"It is the remote port of the port forward, whereas the local port is chosen automatically. In a multiport Pod, "+
+			"the Envoy for the service at index i serves its admin API on this port plus i. May not be used with -admin-port.", defaultAdminPort),
+	})
 	f.StringVar(&flag.StringVar{
 		Name:    "output",
 		Target:  &c.flagOutput,
@@ -81,6 +203,107 @@ func (c *ReadCommand) init() {
 		Default: Table,
 		Aliases: []string{"o"},
 	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   "markdown",
+		Target: &c.flagMarkdown,
+		Usage:  "Render each table as a GitHub-flavored Markdown table, e.g. for pasting into an incident document or ticket. May not be used with -output json or raw.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   "extract-static",
+		Target: &c.flagExtractStatic,
+		Usage:  "Output only the static clusters and listeners as the static_resources of a minimal Envoy bootstrap configuration in YAML. Useful for seeding a test Envoy. May not be used with -output json or raw, -markdown, or -counts.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   "counts",
+		Target: &c.flagCounts,
+		Usage:  "Output only the number of inbound and outbound listeners, clusters, healthy and unhealthy endpoints, and secrets as JSON. Useful for monitoring scripts. Filters are not applied to the counts.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    "quiet",
+		Target:  &c.flagQuiet,
+		Usage:   "Suppress headers and section titles so that only table rows are printed. Useful for piping output into other tools.",
+		Aliases: []string{"q"},
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   "no-color",
+		Target: &c.flagNoColor,
+		Usage:  "Disable colored output. Output is not colored when it is not written to a terminal.",
+	})
+	f.IntVar(&flag.IntVar{
+		Name:   "max-width",
+		Target: &c.flagMaxWidth,
+		Usage:  "Truncate table cells longer than this many characters with an ellipsis. Set to 0 to never truncate. Does not apply to -output json or raw.",
+	})
+	f.IntVar(&flag.IntVar{
+		Name:   "limit",
+		Target: &c.flagLimit,
+		Usage:  "Print at most this many clusters, endpoints, listeners, routes, and secrets in each table, followed by the number which were left out. Set to 0 to print all of them. Does not apply to -output json or raw.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   "show-config-version",
+		Target: &c.flagShowConfigVersion,
+		Usage:  "Add a Version column with the xDS version_info of each cluster, listener, and secret. Useful for correlating the configuration with xDS pushes. Static entries have no version.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   "with-stats",
+		Target: &c.flagWithStats,
+		Usage:  "Add the active connections and healthy hosts of each cluster, fetched from the Envoy admin API's /clusters endpoint, to the clusters output. The configuration is still printed if the stats can't be fetched.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   "from-file",
+		Target: &c.flagFromFile,
+		Usage:  "Read the Envoy configuration from a saved config dump file instead of a running Pod. The <pod-name> argument is optional when this is set.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   "watch",
+		Target: &c.flagWatch,
+		Usage:  "Fetch and print the Envoy configuration again every -interval until interrupted. Useful for watching the configuration converge during a rollout.",
+	})
+	f.DurationVar(&flag.DurationVar{
+		Name:    "interval",
+		Target:  &c.flagInterval,
+		Usage:   "How often the Envoy configuration is fetched when -watch is set.",
+		Default: defaultWatchInterval,
+	})
+
+	f = c.set.NewSet("Envoy Admin API Options")
+	f.BoolVar(&flag.BoolVar{
+		Name:   "tls",
+		Target: &c.flagTLS,
+		Usage:  "Fetch the Envoy configuration over HTTPS. Use this when the Envoy admin API is served over TLS.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   "insecure",
+		Target: &c.flagInsecure,
+		Usage:  "Skip verification of the Envoy admin API's TLS certificate. Only applies when -tls is set.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   "ca-file",
+		Target: &c.flagCAFile,
+		Usage:  "Path to a PEM-encoded CA certificate used to verify the Envoy admin API's TLS certificate. Only applies when -tls is set.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   "tls-min-version",
+		Target: &c.flagTLSMinVersion,
+		Usage:  "The minimum TLS version accepted from the Envoy admin API, either 1.2 or 1.3. Only applies when -tls is set.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   "admin-username",
+		Target: &c.flagAdminUsername,
+		Usage:  "Username sent with basic auth when fetching the Envoy configuration. Use this when the Envoy admin API is behind an authenticating proxy. Requires -admin-password.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   "admin-password",
+		Target: &c.flagAdminPassword,
+		Usage:  "Password sent with basic auth when fetching the Envoy configuration. Requires -admin-username.",
+	})
+	f.IntVar(&flag.IntVar{
+		Name:    "fetch-attempts",
+		Target:  &c.flagFetchAttempts,
+		Usage:   "The number of times to attempt fetching the Envoy configuration when the port forward is reset.",
+		Default: defaultFetchAttempts,
+		Hidden:  true,
+	})
 
 	f = c.set.NewSet("Output Filtering Options")
 	f.BoolVar(&flag.BoolVar{
@@ -108,6 +331,11 @@ func (c *ReadCommand) init() {
 		Target: &c.flagSecrets,
 		Usage:  "Filter output to only show secrets.",
 	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   "config-only",
+		Target: &c.flagConfigOnly,
+		Usage:  "Only show clusters, listeners, and routes. Endpoints are not fetched from Envoy, which is faster for large configurations. May not be combined with -endpoints or -secrets.",
+	})
 	f.StringVar(&flag.StringVar{
 		Name:   "fqdn",
 		Target: &c.flagFQDN,
@@ -124,6 +352,11 @@ func (c *ReadCommand) init() {
 		Usage:   "Filter endpoints and listeners output to addresses with the given port number. May be combined with -fqdn and -address.",
 		Default: -1,
 	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   "unhealthy-only",
+		Target: &c.flagUnhealthyOnly,
+		Usage:  "Filter endpoints output to endpoints with a health status other than HEALTHY. May be combined with -address and -port.",
+	})
 
 	f = c.set.NewSet("GlobalOptions")
 	f.StringVar(&flag.StringVar{
@@ -158,18 +391,51 @@ func (c *ReadCommand) Run(args []string) int {
 		return 1
 	}
 
+	if c.flagFromFile != "" {
+		config, err := c.readConfigFile()
+		if err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+
+		if err := c.outputConfigs(map[string]*EnvoyConfig{c.flagPodName: config}); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		return 0
+	}
+
+	if err := c.initHTTPClient(); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
 	if err := c.initKubernetes(); err != nil {
 		c.UI.Output(err.Error(), terminal.WithErrorStyle())
 		return 1
 	}
 
+	if c.flagAllNamespaces {
+		namespace, err := c.findPodNamespace()
+		if err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		c.flagNamespace = namespace
+	}
+
 	adminPorts, err := c.fetchAdminPorts()
 	if err != nil {
 		c.UI.Output(err.Error(), terminal.WithErrorStyle())
 		return 1
 	}
 
-	configs, err := c.fetchConfigs(adminPorts)
+	portForwards := c.portForwards(adminPorts)
+	if c.flagWatch {
+		return c.watch(portForwards)
+	}
+
+	configs, err := c.fetchConfigs(portForwards)
 	if err != nil {
 		c.UI.Output(err.Error(), terminal.WithErrorStyle())
 		return 1
@@ -204,15 +470,21 @@ func (c *ReadCommand) parseFlags(args []string) error {
 	}
 	keyed := args[len(positional):]
 
+	if err := c.set.Parse(keyed); err != nil {
+		return err
+	}
+
+	// The Pod name is optional when reading the configuration from a file.
+	if c.flagFromFile != "" && len(positional) == 0 {
+		c.flagPodName = strings.TrimSuffix(filepath.Base(c.flagFromFile), filepath.Ext(c.flagFromFile))
+		return nil
+	}
+
 	if len(positional) != 1 {
 		return fmt.Errorf("Exactly one positional argument is required: <pod-name>")
 	}
 	c.flagPodName = positional[0]
 
-	if err := c.set.Parse(keyed); err != nil {
-		return err
-	}
-
 	return nil
 }
 
@@ -220,12 +492,199 @@ func (c *ReadCommand) validateFlags() error {
 	if errs := validation.ValidateNamespaceName(c.flagNamespace, false); c.flagNamespace != "" && len(errs) > 0 {
 		return fmt.Errorf("invalid namespace name passed for -namespace/-n: %v", strings.Join(errs, "; "))
 	}
+	if c.flagAllNamespaces && c.flagNamespace != "" {
+		return fmt.Errorf("-namespace/-n and -all-namespaces/-A may not be used together.")
+	}
+	if c.flagFromFile != "" && (c.flagNamespace != "" || c.flagAllNamespaces) {
+		return fmt.Errorf("-from-file may not be used with -namespace/-n or -all-namespaces/-A.")
+	}
 	if outputs := []string{Table, JSON, Raw}; !slices.Contains(outputs, c.flagOutput) {
 		return fmt.Errorf("-output must be one of %s.", strings.Join(outputs, ", "))
 	}
+	if !c.flagTLS && (c.flagInsecure || c.flagCAFile != "") {
+		return fmt.Errorf("-insecure and -ca-file may only be used with -tls.")
+	}
+	if c.flagInsecure && c.flagCAFile != "" {
+		return fmt.Errorf("-insecure and -ca-file may not be used together.")
+	}
+	if c.flagTLSMinVersion != "" {
+		if !c.flagTLS {
+			return fmt.Errorf("-tls-min-version may only be used with -tls.")
+		}
+		if _, ok := tlsVersions[c.flagTLSMinVersion]; !ok {
+			return fmt.Errorf("-tls-min-version must be one of 1.2, 1.3.")
+		}
+	}
+	if c.flagService != "" && c.flagAdminPort != 0 {
+		return fmt.Errorf("-service and -admin-port may not be used together.")
+	}
+	if c.flagFromFile != "" && (c.flagService != "" || c.flagAdminPort != 0) {
+		return fmt.Errorf("-from-file may not be used with -service or -admin-port.")
+	}
+	if c.flagAdminPort < 0 || c.flagAdminPort > 65535 {
+		return fmt.Errorf("-admin-port must be a valid port number.")
+	}
+	if c.flagAdminBindPort < 0 || c.flagAdminBindPort > 65535 {
+		return fmt.Errorf("-admin-bind-port must be a valid port number.")
+	}
+	if c.flagAdminBindPort != 0 && c.flagAdminPort != 0 {
+		return fmt.Errorf("-admin-bind-port and -admin-port may not be used together.")
+	}
+	if c.flagFromFile != "" && c.flagAdminBindPort != 0 {
+		return fmt.Errorf("-from-file may not be used with -admin-bind-port.")
+	}
+	if (c.flagAdminUsername == "") != (c.flagAdminPassword == "") {
+		return fmt.Errorf("-admin-username and -admin-password must be used together.")
+	}
+	if c.flagWatch && c.flagFromFile != "" {
+		return fmt.Errorf("-watch may not be used with -from-file.")
+	}
+	if c.flagWithStats && c.flagFromFile != "" {
+		return fmt.Errorf("-with-stats may not be used with -from-file.")
+	}
+	if c.flagInterval <= 0 {
+		return fmt.Errorf("-interval must be greater than zero.")
+	}
+	if c.flagMarkdown && c.flagOutput != Table {
+		return fmt.Errorf("-markdown may not be used with -output json or raw.")
+	}
+	if c.flagMarkdown && c.flagCounts {
+		return fmt.Errorf("-markdown may not be used with -counts.")
+	}
+	if c.flagExtractStatic && c.flagOutput != Table {
+		return fmt.Errorf("-extract-static may not be used with -output json or raw.")
+	}
+	if c.flagExtractStatic && (c.flagMarkdown || c.flagCounts) {
+		return fmt.Errorf("-extract-static may not be used with -markdown or -counts.")
+	}
+	if c.flagCounts && c.flagOutput != Table {
+		return fmt.Errorf("-counts may not be used with -output json or raw.")
+	}
+	if c.flagCounts && c.flagConfigOnly {
+		return fmt.Errorf("-counts may not be used with -config-only.")
+	}
+	if c.flagConfigOnly && (c.flagEndpoints || c.flagSecrets) {
+		return fmt.Errorf("-config-only may not be used with -endpoints or -secrets.")
+	}
+	if c.flagConfigOnly && c.flagUnhealthyOnly {
+		return fmt.Errorf("-config-only may not be used with -unhealthy-only.")
+	}
+	if c.flagMaxWidth < 0 {
+		return fmt.Errorf("-max-width must not be negative.")
+	}
+	if c.flagLimit < 0 {
+		return fmt.Errorf("-limit must not be negative.")
+	}
+	if c.flagFetchAttempts < 1 {
+		return fmt.Errorf("-fetch-attempts must be at least 1.")
+	}
 	return nil
 }
 
+// tlsVersions maps the values accepted by -tls-min-version to TLS versions.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// initHTTPClient creates the HTTP client used to fetch the configuration from
+// the Envoy admin API. When -tls is set, the client's transport is configured
+// to verify the admin API's certificate against the given CA or to skip
+// verification entirely, and to require at least -tls-min-version. When admin credentials are set, they are sent with
+// every request using basic auth.
+func (c *ReadCommand) initHTTPClient() error {
+	if c.httpClient != nil {
+		return nil
+	}
+
+	if !c.flagTLS {
+		c.httpClient = c.withAdminAuth(http.DefaultClient)
+		return nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.flagInsecure,
+		MinVersion:         tlsVersions[c.flagTLSMinVersion],
+	}
+	if c.flagCAFile != "" {
+		caCert, err := os.ReadFile(c.flagCAFile)
+		if err != nil {
+			return fmt.Errorf("error reading CA file %s: %v", c.flagCAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return fmt.Errorf("error parsing CA file %s: no PEM-encoded certificates found", c.flagCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	c.httpClient = c.withAdminAuth(&http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	})
+
+	return nil
+}
+
+// withAdminAuth returns a copy of the client which sets the basic auth
+// credentials given by -admin-username and -admin-password on each request.
+// The client is returned unchanged if no credentials are set.
+func (c *ReadCommand) withAdminAuth(client *http.Client) *http.Client {
+	if c.flagAdminUsername == "" {
+		return client
+	}
+
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	authClient := *client
+	authClient.Transport = &basicAuthTransport{
+		username: c.flagAdminUsername,
+		password: c.flagAdminPassword,
+		base:     transport,
+	}
+	return &authClient
+}
+
+// basicAuthTransport sets basic auth credentials on each request before
+// passing it to the base transport.
+type basicAuthTransport struct {
+	username string
+	password string
+	base     http.RoundTripper
+}
+
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the request they are given.
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.username, t.password)
+	return t.base.RoundTrip(req)
+}
+
+// readConfigFile reads and parses the Envoy configuration saved at the path
+// given by -from-file.
+func (c *ReadCommand) readConfigFile() (*EnvoyConfig, error) {
+	data, err := os.ReadFile(c.flagFromFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config dump file %s: %v", c.flagFromFile, err)
+	}
+
+	config, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing config dump file %s: %v", c.flagFromFile, err)
+	}
+	return config, nil
+}
+
+// adminScheme returns the URL scheme used to reach the Envoy admin API.
+func (c *ReadCommand) adminScheme() string {
+	if c.flagTLS {
+		return "https"
+	}
+	return "http"
+}
+
 func (c *ReadCommand) initKubernetes() (err error) {
 	settings := helmCLI.New()
 
@@ -237,7 +696,18 @@ func (c *ReadCommand) initKubernetes() (err error) {
 		settings.KubeContext = c.flagKubeContext
 	}
 
+	if c.restConfig == nil && c.flagKubeContext == "" && !kubeConfigExists(settings) {
+		// Without a kubeconfig, use the pod's service account so that the
+		// command can be run from inside the cluster, e.g. as a debug job.
+		if inClusterConfig, err := c.inClusterConfig(); err == nil {
+			c.restConfig = inClusterConfig
+		}
+	}
+
 	if c.restConfig == nil {
+		if err = validateKubeContext(settings); err != nil {
+			return err
+		}
 		if c.restConfig, err = settings.RESTClientGetter().ToRESTConfig(); err != nil {
 			return fmt.Errorf("error creating Kubernetes REST config %v", err)
 		}
@@ -256,6 +726,81 @@ func (c *ReadCommand) initKubernetes() (err error) {
 	return nil
 }
 
+// kubeConfigExists returns true if a kubeconfig file exists at the path set in
+// the settings or, if no path is set, at any of the default locations.
+func kubeConfigExists(settings *helmCLI.EnvSettings) bool {
+	paths := []string{settings.KubeConfig}
+	if settings.KubeConfig == "" {
+		paths = clientcmd.NewDefaultClientConfigLoadingRules().GetLoadingPrecedence()
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// validateKubeContext returns an error listing the available contexts if the
+// context set in the settings does not exist in the kubeconfig. This gives a
+// clearer error than the one returned when creating the REST config.
+func validateKubeContext(settings *helmCLI.EnvSettings) error {
+	if settings.KubeContext == "" {
+		return nil
+	}
+
+	rawConfig, err := settings.RESTClientGetter().ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return fmt.Errorf("error reading kubeconfig: %v", err)
+	}
+	if _, ok := rawConfig.Contexts[settings.KubeContext]; ok {
+		return nil
+	}
+
+	contexts := make([]string, 0, len(rawConfig.Contexts))
+	for name := range rawConfig.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	if len(contexts) == 0 {
+		return fmt.Errorf("context %q does not exist in the kubeconfig, which has no contexts", settings.KubeContext)
+	}
+	return fmt.Errorf("context %q does not exist in the kubeconfig. Available contexts: %s", settings.KubeContext, strings.Join(contexts, ", "))
+}
+
+// findPodNamespace searches all namespaces for the target Pod and returns the
+// namespace it is in. It returns an error if no Pod or more than one Pod with
+// the target name is found.
+func (c *ReadCommand) findPodNamespace() (string, error) {
+	pods, err := c.kubernetes.CoreV1().Pods("").List(c.Ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("metadata.name=%s", c.flagPodName),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var namespaces []string
+	for _, pod := range pods.Items {
+		if pod.Name == c.flagPodName {
+			namespaces = append(namespaces, pod.Namespace)
+		}
+	}
+
+	switch len(namespaces) {
+	case 0:
+		return "", fmt.Errorf("Pod %s was not found in any namespace.", c.flagPodName)
+	case 1:
+		return namespaces[0], nil
+	default:
+		return "", fmt.Errorf("Pod %s was found in multiple namespaces (%s). Use -namespace to select one.", c.flagPodName, strings.Join(namespaces, ", "))
+	}
+}
+
+// fetchAdminPorts returns the admin API port of each Envoy in the Pod, keyed
+// by the name of the service it proxies. In multiport Pods, the Envoy for the
+// service at index i of the connect-service annotation serves its admin API
+// on port 19000+i. Only the Envoy selected by -service or -admin-port is
+// returned if either is set.
 func (c *ReadCommand) fetchAdminPorts() (map[string]int, error) {
 	adminPorts := make(map[string]int, 0)
 
@@ -264,37 +809,76 @@ func (c *ReadCommand) fetchAdminPorts() (map[string]int, error) {
 		return adminPorts, err
 	}
 
+	if c.flagAdminPort != 0 {
+		adminPorts[c.flagPodName] = c.flagAdminPort
+		return adminPorts, nil
+	}
+
 	connectService, isMultiport := pod.Annotations["consul.hashicorp.com/connect-service"]
 
 	if !isMultiport {
+		if c.flagService != "" {
+			return adminPorts, fmt.Errorf("Pod %s does not have a consul.hashicorp.com/connect-service annotation to select service %s from.", c.flagPodName, c.flagService)
+		}
+
 		// Return the default port configuration.
-		adminPorts[c.flagPodName] = defaultAdminPort
+		adminPorts[c.flagPodName] = c.adminBindPort()
 		return adminPorts, nil
 	}
 
 	for index, service := range strings.Split(connectService, ",") {
-		adminPorts[service] = defaultAdminPort + index
+		adminPorts[service] = c.adminBindPort() + index
+	}
+
+	if c.flagService != "" {
+		adminPort, ok := adminPorts[c.flagService]
+		if !ok {
+			return make(map[string]int, 0), fmt.Errorf("Service %s was not found in the consul.hashicorp.com/connect-service annotation of Pod %s.", c.flagService, c.flagPodName)
+		}
+		return map[string]int{c.flagService: adminPort}, nil
 	}
 
 	return adminPorts, nil
 }
 
-func (c *ReadCommand) fetchConfigs(adminPorts map[string]int) (map[string]*EnvoyConfig, error) {
-	configs := make(map[string]*EnvoyConfig, 0)
+// adminBindPort returns the port the Envoy admin API is bound to inside the
+// Pod, which is the default unless it is set with -admin-bind-port.
+func (c *ReadCommand) adminBindPort() int {
+	if c.flagAdminBindPort != 0 {
+		return c.flagAdminBindPort
+	}
+	return defaultAdminPort
+}
+
+// portForwards returns a port forward to the Envoy admin API of each proxy
+// in the Pod, keyed by the name of the proxy.
+func (c *ReadCommand) portForwards(adminPorts map[string]int) map[string]common.PortForwarder {
+	portForwards := make(map[string]common.PortForwarder, len(adminPorts))
 
 	for name, adminPort := range adminPorts {
-		pf := common.PortForward{
+		portForwards[name] = &common.PortForward{
 			Namespace:  c.flagNamespace,
 			PodName:    c.flagPodName,
 			RemotePort: adminPort,
 			KubeClient: c.kubernetes,
 			RestConfig: c.restConfig,
 		}
+	}
 
-		config, err := c.fetchConfig(c.Ctx, &pf)
+	return portForwards
+}
+
+func (c *ReadCommand) fetchConfigs(portForwards map[string]common.PortForwarder) (map[string]*EnvoyConfig, error) {
+	configs := make(map[string]*EnvoyConfig, 0)
+
+	for name, pf := range portForwards {
+		config, err := c.fetchConfigWithRetry(pf)
 		if err != nil {
 			return configs, err
 		}
+		if c.flagWithStats {
+			c.mergeClusterStats(name, pf, config)
+		}
 
 		configs[name] = config
 	}
@@ -302,7 +886,121 @@ func (c *ReadCommand) fetchConfigs(adminPorts map[string]int) (map[string]*Envoy
 	return configs, nil
 }
 
+// mergeClusterStats fetches the runtime stats of the clusters and merges them
+// into the config. A failure to fetch the stats is only a warning so that the
+// configuration is still printed.
+func (c *ReadCommand) mergeClusterStats(name string, pf common.PortForwarder, config *EnvoyConfig) {
+	stats, err := c.fetchClusterStats(c.Ctx, pf)
+	if err != nil {
+		c.UI.Output("Unable to fetch the cluster stats for %s, they will not be shown: %v", name, err, terminal.WithWarningStyle())
+		return
+	}
+	MergeClusterStats(config, stats)
+}
+
+// fetchConfigWithRetry fetches the Envoy configuration, re-establishing the
+// port forward and trying again if the connection is reset. Any other error
+// is returned immediately.
+func (c *ReadCommand) fetchConfigWithRetry(pf common.PortForwarder) (config *EnvoyConfig, err error) {
+	for attempt := 1; attempt <= c.flagFetchAttempts; attempt++ {
+		config, err = c.fetchConfig(c.Ctx, pf)
+		if err == nil || !isConnectionReset(err) {
+			return config, err
+		}
+		c.Log.Debug("port forward was reset while fetching the Envoy configuration", "attempt", attempt, "error", err)
+		if persistent, ok := pf.(*persistentPortForward); ok {
+			persistent.Reset()
+		}
+	}
+
+	return nil, err
+}
+
+// watch fetches and prints the Envoy configuration every -interval until the
+// command's context is cancelled. The port forwards are opened once and kept
+// open across iterations.
+func (c *ReadCommand) watch(portForwards map[string]common.PortForwarder) int {
+	for name, pf := range portForwards {
+		persistent := &persistentPortForward{PortForwarder: pf}
+		defer persistent.Reset()
+		portForwards[name] = persistent
+	}
+
+	for {
+		configs, err := c.fetchConfigs(portForwards)
+		if err != nil {
+			if c.Ctx.Err() != nil {
+				return 0
+			}
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+
+		c.UI.Output(clearScreen)
+		if err := c.outputConfigs(configs); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+
+		select {
+		case <-c.Ctx.Done():
+			return 0
+		case <-time.After(c.flagInterval):
+		}
+	}
+}
+
+// persistentPortForward keeps a port forward open across fetches of the Envoy
+// configuration. Close does nothing so that the port forward isn't closed
+// after each fetch. Reset closes the port forward so that it is opened again
+// by the next fetch.
+type persistentPortForward struct {
+	common.PortForwarder
+
+	endpoint string
+}
+
+func (p *persistentPortForward) Open(ctx context.Context) (string, error) {
+	if p.endpoint != "" {
+		return p.endpoint, nil
+	}
+
+	endpoint, err := p.PortForwarder.Open(ctx)
+	if err != nil {
+		return "", err
+	}
+	p.endpoint = endpoint
+	return endpoint, nil
+}
+
+func (p *persistentPortForward) Close() {}
+
+func (p *persistentPortForward) Reset() {
+	if p.endpoint == "" {
+		return
+	}
+	p.PortForwarder.Close()
+	p.endpoint = ""
+}
+
+// isConnectionReset returns true if the error was caused by the connection
+// being reset or closed mid-request, which is the case when a port forward
+// drops.
+func isConnectionReset(err error) bool {
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	return strings.Contains(err.Error(), "connection reset by peer")
+}
+
 func (c *ReadCommand) outputConfigs(configs map[string]*EnvoyConfig) error {
+	if c.flagCounts {
+		return c.outputCounts(configs)
+	}
+	if c.flagExtractStatic {
+		return c.outputStaticBootstrap(configs)
+	}
+
 	switch c.flagOutput {
 	case Table:
 		return c.outputTables(configs)
@@ -352,11 +1050,15 @@ func (c *ReadCommand) filterWarnings() []string {
 		warnings = append(warnings, fmt.Sprintf("The filter `-address %s` does not apply to the tables displayed.", c.flagAddress))
 	}
 
+	if c.flagUnhealthyOnly && !c.flagEndpoints {
+		warnings = append(warnings, "The filter `-unhealthy-only` does not apply to the tables displayed.")
+	}
+
 	return warnings
 }
 
 func (c *ReadCommand) outputTables(configs map[string]*EnvoyConfig) error {
-	if c.flagFQDN != "" || c.flagAddress != "" || c.flagPort != -1 {
+	if !c.flagQuiet && (c.flagFQDN != "" || c.flagAddress != "" || c.flagPort != -1 || c.flagUnhealthyOnly) {
 		c.UI.Output("Filters applied", terminal.WithHeaderStyle())
 
 		if c.flagFQDN != "" {
@@ -368,6 +1070,9 @@ func (c *ReadCommand) outputTables(configs map[string]*EnvoyConfig) error {
 		if c.flagPort != -1 {
 			c.UI.Output(fmt.Sprintf("Endpoint addresses with port number: %d", c.flagPort), terminal.WithInfoStyle())
 		}
+		if c.flagUnhealthyOnly {
+			c.UI.Output("Endpoints which are not healthy", terminal.WithInfoStyle())
+		}
 
 		for _, warning := range c.filterWarnings() {
 			c.UI.Output(warning, terminal.WithWarningStyle())
@@ -377,19 +1082,54 @@ func (c *ReadCommand) outputTables(configs map[string]*EnvoyConfig) error {
 	}
 
 	for name, config := range configs {
-		c.UI.Output(fmt.Sprintf("Envoy configuration for %s in namespace %s:", name, c.flagNamespace))
+		if c.flagFromFile != "" {
+			c.outputHeader(fmt.Sprintf("Envoy configuration for %s from %s:", name, c.flagFromFile))
+		} else {
+			c.outputHeader(fmt.Sprintf("Envoy configuration for %s in namespace %s:", name, c.flagNamespace))
+		}
+		if config.NodeID != "" {
+			c.outputHeader(fmt.Sprintf("Node ID: %s", config.NodeID), terminal.WithInfoStyle())
+			c.outputHeader(fmt.Sprintf("Node Cluster: %s", config.NodeCluster), terminal.WithInfoStyle())
+		}
+		if config.EnvoyVersion != "" {
+			c.outputHeader(fmt.Sprintf("Envoy Build: %s", config.EnvoyVersion), terminal.WithInfoStyle())
+		}
+		if config.SPIFFEID != "" {
+			c.outputHeader(fmt.Sprintf("SPIFFE ID: %s", config.SPIFFEID), terminal.WithInfoStyle())
+			c.outputHeader(fmt.Sprintf("Trust Domain: %s", config.TrustDomain), terminal.WithInfoStyle())
+		}
 
 		c.outputClustersTable(FilterClusters(config.Clusters, c.flagFQDN, c.flagAddress, c.flagPort))
-		c.outputEndpointsTable(FilterEndpoints(config.Endpoints, c.flagAddress, c.flagPort))
+		c.outputEndpointsTable(c.filterEndpoints(config.Endpoints))
 		c.outputListenersTable(FilterListeners(config.Listeners, c.flagAddress, c.flagPort))
 		c.outputRoutesTable(config.Routes)
 		c.outputSecretsTable(config.Secrets)
-		c.UI.Output("\n")
+		if !c.flagQuiet {
+			c.UI.Output("\n")
+		}
 	}
 
 	return nil
 }
 
+// filterEndpoints applies the endpoint filters passed by the user.
+func (c *ReadCommand) filterEndpoints(endpoints []Endpoint) []Endpoint {
+	endpoints = FilterEndpoints(endpoints, c.flagAddress, c.flagPort)
+	if c.flagUnhealthyOnly {
+		endpoints = FilterUnhealthyEndpoints(endpoints)
+	}
+	return endpoints
+}
+
+// outputHeader prints a decorative line such as a section title unless the
+// user has requested quiet output.
+func (c *ReadCommand) outputHeader(header string, raw ...interface{}) {
+	if c.flagQuiet {
+		return
+	}
+	c.UI.Output(header, raw...)
+}
+
 func (c *ReadCommand) outputJSON(configs map[string]*EnvoyConfig) error {
 	cfgs := make(map[string]interface{})
 	for name, config := range configs {
@@ -397,8 +1137,8 @@ func (c *ReadCommand) outputJSON(configs map[string]*EnvoyConfig) error {
 		if c.shouldPrintTable(c.flagClusters) {
 			cfg["clusters"] = FilterClusters(config.Clusters, c.flagFQDN, c.flagAddress, c.flagPort)
 		}
-		if c.shouldPrintTable(c.flagEndpoints) {
-			cfg["endpoints"] = FilterEndpoints(config.Endpoints, c.flagAddress, c.flagPort)
+		if c.shouldPrintTable(c.flagEndpoints) && !c.flagConfigOnly {
+			cfg["endpoints"] = c.filterEndpoints(config.Endpoints)
 		}
 		if c.shouldPrintTable(c.flagListeners) {
 			cfg["listeners"] = FilterListeners(config.Listeners, c.flagAddress, c.flagPort)
@@ -406,7 +1146,7 @@ func (c *ReadCommand) outputJSON(configs map[string]*EnvoyConfig) error {
 		if c.shouldPrintTable(c.flagRoutes) {
 			cfg["routes"] = config.Routes
 		}
-		if c.shouldPrintTable(c.flagSecrets) {
+		if c.shouldPrintTable(c.flagSecrets) && !c.flagConfigOnly {
 			cfg["secrets"] = config.Secrets
 		}
 
@@ -426,6 +1166,51 @@ func (c *ReadCommand) outputJSON(configs map[string]*EnvoyConfig) error {
 	return nil
 }
 
+// outputCounts prints the counts of each proxy's configuration as JSON, keyed
+// by the name of the proxy.
+func (c *ReadCommand) outputCounts(configs map[string]*EnvoyConfig) error {
+	counts := make(map[string]Counts, len(configs))
+	for name, config := range configs {
+		counts[name] = CountConfig(config)
+	}
+
+	out, err := json.MarshalIndent(counts, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	c.UI.Output(string(out))
+
+	return nil
+}
+
+// outputStaticBootstrap outputs the static resources of each config as a YAML
+// document. Documents are separated and commented with the name of their proxy
+// when there is more than one.
+func (c *ReadCommand) outputStaticBootstrap(configs map[string]*EnvoyConfig) error {
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		out, err := StaticBootstrap(configs[name])
+		if err != nil {
+			return err
+		}
+		if len(names) > 1 {
+			if i > 0 {
+				c.UI.Output("---")
+			}
+			c.UI.Output(fmt.Sprintf("# %s", name))
+		}
+		c.UI.Output(strings.TrimSuffix(string(out), "\n"))
+	}
+
+	return nil
+}
+
 func (c *ReadCommand) outputRaw(configs map[string]*EnvoyConfig) error {
 	cfgs := make(map[string]interface{}, 0)
 	for name, config := range configs {
@@ -452,23 +1237,60 @@ func (c *ReadCommand) outputClustersTable(clusters []Cluster) {
 		return
 	}
 
-	c.UI.Output(fmt.Sprintf("Clusters (%d)", len(clusters)), terminal.WithHeaderStyle())
-	table := terminal.NewTable("Name", "FQDN", "Endpoints", "Type", "Last Updated")
-	for _, cluster := range clusters {
-		table.AddRow([]string{cluster.Name, cluster.FullyQualifiedDomainName, strings.Join(cluster.Endpoints, ", "),
-			cluster.Type, cluster.LastUpdated}, []string{})
-	}
-	c.UI.Table(table)
-	c.UI.Output("")
+	c.outputHeader(fmt.Sprintf("Clusters (%d)", len(clusters)), terminal.WithHeaderStyle())
+	shown := c.limitEntries(len(clusters))
+	c.outputTable(formatClusters(clusters[:shown], c.flagShowConfigVersion, c.flagWithStats))
+	c.outputMore(shown, len(clusters))
+	c.outputHeader("")
 }
 
 func (c *ReadCommand) outputEndpointsTable(endpoints []Endpoint) {
-	if !c.shouldPrintTable(c.flagEndpoints) {
+	if c.flagConfigOnly || !c.shouldPrintTable(c.flagEndpoints) {
+		return
+	}
+
+	c.outputHeader(fmt.Sprintf("Endpoints (%d)", len(endpoints)), terminal.WithHeaderStyle())
+	shown := c.limitEntries(len(endpoints))
+	table := formatEndpoints(endpoints[:shown])
+	if !c.colorEnabled() {
+		table = withoutColors(table)
+	}
+	c.outputTable(table)
+	c.outputMore(shown, len(endpoints))
+}
+
+// outputTable prints the table, truncating long cell values if -max-width is
+// set. The table is rendered as Markdown if -markdown is set.
+func (c *ReadCommand) outputTable(table *terminal.Table) {
+	table = truncateCells(table, c.flagMaxWidth)
+	if c.flagMarkdown {
+		c.UI.Output(formatMarkdown(table))
 		return
 	}
+	c.UI.Table(table)
+}
+
+// limitEntries returns how many of the total entries of a table are printed,
+// which is all of them unless -limit is set.
+func (c *ReadCommand) limitEntries(total int) int {
+	if c.flagLimit > 0 && total > c.flagLimit {
+		return c.flagLimit
+	}
+	return total
+}
+
+// outputMore prints the number of entries of a table which were left out by
+// -limit, if any.
+func (c *ReadCommand) outputMore(shown, total int) {
+	if shown < total {
+		c.UI.Output(fmt.Sprintf("... %d more", total-shown))
+	}
+}
 
-	c.UI.Output(fmt.Sprintf("Endpoints (%d)", len(endpoints)), terminal.WithHeaderStyle())
-	c.UI.Table(formatEndpoints(endpoints))
+// colorEnabled returns true if table cells should be colored. Colors are
+// disabled by -no-color or when the output is not a terminal.
+func (c *ReadCommand) colorEnabled() bool {
+	return !c.flagNoColor && !color.NoColor
 }
 
 func (c *ReadCommand) outputListenersTable(listeners []Listener) {
@@ -476,8 +1298,10 @@ func (c *ReadCommand) outputListenersTable(listeners []Listener) {
 		return
 	}
 
-	c.UI.Output(fmt.Sprintf("Listeners (%d)", len(listeners)), terminal.WithHeaderStyle())
-	c.UI.Table(formatListeners(listeners))
+	c.outputHeader(fmt.Sprintf("Listeners (%d)", len(listeners)), terminal.WithHeaderStyle())
+	shown := c.limitEntries(len(listeners))
+	c.outputTable(formatListeners(listeners[:shown], c.flagShowConfigVersion))
+	c.outputMore(shown, len(listeners))
 }
 
 func (c *ReadCommand) outputRoutesTable(routes []Route) {
@@ -485,15 +1309,19 @@ func (c *ReadCommand) outputRoutesTable(routes []Route) {
 		return
 	}
 
-	c.UI.Output(fmt.Sprintf("Routes (%d)", len(routes)), terminal.WithHeaderStyle())
-	c.UI.Table(formatRoutes(routes))
+	c.outputHeader(fmt.Sprintf("Routes (%d)", len(routes)), terminal.WithHeaderStyle())
+	shown := c.limitEntries(len(routes))
+	c.outputTable(formatRoutes(routes[:shown]))
+	c.outputMore(shown, len(routes))
 }
 
 func (c *ReadCommand) outputSecretsTable(secrets []Secret) {
-	if !c.shouldPrintTable(c.flagSecrets) {
+	if c.flagConfigOnly || !c.shouldPrintTable(c.flagSecrets) {
 		return
 	}
 
-	c.UI.Output(fmt.Sprintf("Secrets (%d)", len(secrets)), terminal.WithHeaderStyle())
-	c.UI.Table(formatSecrets(secrets))
+	c.outputHeader(fmt.Sprintf("Secrets (%d)", len(secrets)), terminal.WithHeaderStyle())
+	shown := c.limitEntries(len(secrets))
+	c.outputTable(formatSecrets(secrets[:shown], c.flagShowConfigVersion))
+	c.outputMore(shown, len(secrets))
 }
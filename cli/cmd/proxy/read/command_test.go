@@ -3,18 +3,31 @@ package read
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul-k8s/cli/common"
 	"github.com/hashicorp/consul-k8s/cli/common/terminal"
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/require"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
 )
 
 func TestFlagParsing(t *testing.T) {
@@ -62,10 +75,10 @@ func TestReadCommandOutput(t *testing.T) {
 	expectedHeader := fmt.Sprintf("Envoy configuration for %s in namespace default:", podName)
 	expected := map[string][]string{
 		"-clusters": {"==> Clusters \\(5\\)",
-			"Name.*FQDN.*Endpoints.*Type.*Last Updated",
+			"Name.*FQDN.*Endpoints.*Type.*Max Connections.*Max Requests.*Last Updated",
 			"local_agent.*192\\.168\\.79\\.187:8502.*STATIC.*2022-05-13T04:22:39\\.553Z",
 			"local_app.*127\\.0\\.0\\.1:8080.*STATIC.*2022-05-13T04:22:39\\.655Z",
-			"client.*client\\.default\\.dc1\\.internal\\.bc3815c2-1a0f-f3ff-a2e9-20d791f08d00\\.consul.*EDS",
+			"client.*client\\.default\\.dc1\\.internal\\.bc3815c2-1a0f-f3ff-a2e9-20d791f08d00\\.consul.*EDS.*1024.*512",
 			"frontend.*frontend\\.default\\.dc1\\.internal\\.bc3815c2-1a0f-f3ff-a2e9-20d791f08d00\\.consul",
 			"original-destination.*ORIGINAL_DST"},
 
@@ -140,6 +153,38 @@ func TestReadCommandOutput(t *testing.T) {
 	}
 }
 
+func TestReadCommandOutput_Quiet(t *testing.T) {
+	podName := "fakePod"
+
+	fakePod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      podName,
+			Namespace: "default",
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	c := setupCommand(buf)
+	c.kubernetes = fake.NewSimpleClientset(&v1.PodList{Items: []v1.Pod{fakePod}})
+	c.fetchConfig = func(context.Context, common.PortForwarder) (*EnvoyConfig, error) {
+		return testEnvoyConfig, nil
+	}
+
+	out := c.Run([]string{podName, "-quiet", "-port", "20000"})
+	require.Equal(t, 0, out)
+
+	actual := buf.String()
+
+	// Table rows are still present.
+	require.Regexp(t, "client.*192\\.168\\.18\\.110:20000.*EDS", actual)
+	require.Regexp(t, "public_listener.*192\\.168\\.69\\.179:20000.*INBOUND", actual)
+
+	// Decorative headers are absent.
+	require.NotContains(t, actual, "Envoy configuration for")
+	require.NotContains(t, actual, "Filters applied")
+	require.NotContains(t, actual, "==>")
+}
+
 // TestFilterWarnings ensures that a warning is printed if the user applies a
 // field filter (e.g. -fqdn default) and a table filter (e.g. -secrets) where
 // the former does not affect the output of the latter.
@@ -240,6 +285,1331 @@ func TestFilterWarnings(t *testing.T) {
 	}
 }
 
+func TestInitHTTPClient(t *testing.T) {
+	mockServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer mockServer.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mockServer.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, caPEM, 0600))
+
+	notPEMFile := filepath.Join(t.TempDir(), "not-a-ca.pem")
+	require.NoError(t, os.WriteFile(notPEMFile, []byte("not a certificate"), 0600))
+
+	cases := map[string]struct {
+		args      []string
+		expErr    string
+		expScheme string
+		reachable bool
+	}{
+		"plain HTTP by default": {
+			args:      []string{},
+			expScheme: "http",
+		},
+		"TLS with CA verification": {
+			args:      []string{"-tls", "-ca-file", caFile},
+			expScheme: "https",
+			reachable: true,
+		},
+		"TLS with insecure": {
+			args:      []string{"-tls", "-insecure"},
+			expScheme: "https",
+			reachable: true,
+		},
+		"TLS without a CA cannot verify the server": {
+			args:      []string{"-tls"},
+			expScheme: "https",
+		},
+		"CA file is not PEM": {
+			args:   []string{"-tls", "-ca-file", notPEMFile},
+			expErr: "no PEM-encoded certificates found",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := setupCommand(new(bytes.Buffer))
+			require.NoError(t, c.parseFlags(append([]string{"podName"}, tc.args...)))
+			require.NoError(t, c.validateFlags())
+
+			err := c.initHTTPClient()
+			if tc.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expScheme, c.adminScheme())
+
+			if tc.expScheme == "https" {
+				_, err = c.httpClient.Get(mockServer.URL)
+				if tc.reachable {
+					require.NoError(t, err)
+				} else {
+					require.Error(t, err)
+				}
+			}
+		})
+	}
+}
+
+func TestInitHTTPClient_AdminAuth(t *testing.T) {
+	configDump, err := fs.ReadFile(testConfigDump)
+	require.NoError(t, err)
+
+	clusters, err := fs.ReadFile(testClusters)
+	require.NoError(t, err)
+
+	var authorizations []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		if r.URL.Path == "/config_dump" {
+			w.Write(configDump)
+		}
+		if r.URL.Path == "/clusters" {
+			w.Write(clusters)
+		}
+	}))
+	defer mockServer.Close()
+
+	mpf := &mockPortForwarder{
+		openBehavior: func(ctx context.Context) (string, error) {
+			return strings.Replace(mockServer.URL, "http://", "", 1), nil
+		},
+	}
+
+	cases := map[string]struct {
+		args                  []string
+		expectedAuthorization string
+	}{
+		"no credentials": {
+			args:                  []string{},
+			expectedAuthorization: "",
+		},
+		"basic auth": {
+			args:                  []string{"-admin-username", "admin", "-admin-password", "secret"},
+			expectedAuthorization: "Basic YWRtaW46c2VjcmV0",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			authorizations = nil
+			c := setupCommand(new(bytes.Buffer))
+			require.NoError(t, c.parseFlags(append([]string{"podName"}, tc.args...)))
+			require.NoError(t, c.validateFlags())
+			require.NoError(t, c.initHTTPClient())
+
+			_, err := FetchConfigWithClient(context.Background(), mpf, c.httpClient, c.adminScheme())
+			require.NoError(t, err)
+
+			// Both the config dump and the clusters requests carry the credentials.
+			require.Equal(t, []string{tc.expectedAuthorization, tc.expectedAuthorization}, authorizations)
+		})
+	}
+
+	// The shared default client is not modified.
+	require.Nil(t, http.DefaultClient.Transport)
+}
+
+func TestInitHTTPClient_TLSMinVersion(t *testing.T) {
+	cases := map[string]struct {
+		args       []string
+		expVersion uint16
+	}{
+		"default": {
+			args:       []string{"-tls"},
+			expVersion: 0,
+		},
+		"TLS 1.2": {
+			args:       []string{"-tls", "-tls-min-version", "1.2"},
+			expVersion: tls.VersionTLS12,
+		},
+		"TLS 1.3": {
+			args:       []string{"-tls", "-tls-min-version", "1.3"},
+			expVersion: tls.VersionTLS13,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := setupCommand(new(bytes.Buffer))
+			require.NoError(t, c.parseFlags(append([]string{"podName"}, tc.args...)))
+			require.NoError(t, c.validateFlags())
+			require.NoError(t, c.initHTTPClient())
+
+			transport, ok := c.httpClient.Transport.(*http.Transport)
+			require.True(t, ok)
+			require.Equal(t, tc.expVersion, transport.TLSClientConfig.MinVersion)
+		})
+	}
+}
+
+func TestValidateFlags_TLS(t *testing.T) {
+	cases := map[string][]string{
+		"-insecure without -tls":           {"-insecure"},
+		"-ca-file without -tls":            {"-ca-file", "ca.pem"},
+		"-insecure and -ca-file together":  {"-tls", "-insecure", "-ca-file", "ca.pem"},
+		"-tls-min-version without -tls":    {"-tls-min-version", "1.2"},
+		"unsupported -tls-min-version":     {"-tls", "-tls-min-version", "1.1"},
+		"-admin-username without password": {"-admin-username", "admin"},
+		"-admin-password without username": {"-admin-password", "secret"},
+	}
+
+	for name, args := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := setupCommand(new(bytes.Buffer))
+			require.Equal(t, 1, c.Run(append([]string{"podName"}, args...)))
+		})
+	}
+}
+
+func TestReadCommand_RetriesOnConnectionReset(t *testing.T) {
+	podName := "fakePod"
+	connReset := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+
+	cases := map[string]struct {
+		args          []string
+		failures      int
+		failWith      error
+		expectedCalls int
+		expectedOut   int
+	}{
+		"one transient failure then success": {
+			failures:      1,
+			failWith:      connReset,
+			expectedCalls: 2,
+			expectedOut:   0,
+		},
+		"transient failures exhaust attempts": {
+			args:          []string{"-fetch-attempts", "2"},
+			failures:      2,
+			failWith:      connReset,
+			expectedCalls: 2,
+			expectedOut:   1,
+		},
+		"other errors are not retried": {
+			failures:      1,
+			failWith:      fmt.Errorf("pod not found"),
+			expectedCalls: 1,
+			expectedOut:   1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fakePod := v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      podName,
+					Namespace: "default",
+				},
+			}
+
+			c := setupCommand(new(bytes.Buffer))
+			c.kubernetes = fake.NewSimpleClientset(&v1.PodList{Items: []v1.Pod{fakePod}})
+
+			calls := 0
+			c.fetchConfig = func(context.Context, common.PortForwarder) (*EnvoyConfig, error) {
+				calls++
+				if calls <= tc.failures {
+					return nil, tc.failWith
+				}
+				return testEnvoyConfig, nil
+			}
+
+			out := c.Run(append([]string{podName}, tc.args...))
+			require.Equal(t, tc.expectedOut, out)
+			require.Equal(t, tc.expectedCalls, calls)
+		})
+	}
+}
+
+func TestReadCommand_AllNamespaces(t *testing.T) {
+	podName := "fakePod"
+
+	cases := map[string]struct {
+		pods        []v1.Pod
+		args        []string
+		expectedOut int
+		expectedNS  string
+	}{
+		"pod found in a non-default namespace": {
+			pods: []v1.Pod{
+				{ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: "other"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "otherPod", Namespace: "default"}},
+			},
+			args:        []string{"-all-namespaces"},
+			expectedOut: 0,
+			expectedNS:  "other",
+		},
+		"pod found with alias": {
+			pods: []v1.Pod{
+				{ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: "other"}},
+			},
+			args:        []string{"-A"},
+			expectedOut: 0,
+			expectedNS:  "other",
+		},
+		"pod not found": {
+			pods: []v1.Pod{
+				{ObjectMeta: metav1.ObjectMeta{Name: "otherPod", Namespace: "other"}},
+			},
+			args:        []string{"-A"},
+			expectedOut: 1,
+		},
+		"pod found in multiple namespaces": {
+			pods: []v1.Pod{
+				{ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: "default"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: "other"}},
+			},
+			args:        []string{"-A"},
+			expectedOut: 1,
+		},
+		"namespace still selects a pod without -all-namespaces": {
+			pods: []v1.Pod{
+				{ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: "default"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: "other"}},
+			},
+			args:        []string{"-namespace", "other"},
+			expectedOut: 0,
+			expectedNS:  "other",
+		},
+		"namespace and all namespaces together": {
+			pods: []v1.Pod{
+				{ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: "other"}},
+			},
+			args:        []string{"-namespace", "other", "-A"},
+			expectedOut: 1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+			c.kubernetes = fake.NewSimpleClientset(&v1.PodList{Items: tc.pods})
+
+			var fetchedNS string
+			c.fetchConfig = func(_ context.Context, pf common.PortForwarder) (*EnvoyConfig, error) {
+				fetchedNS = pf.(*common.PortForward).Namespace
+				return testEnvoyConfig, nil
+			}
+
+			out := c.Run(append([]string{podName}, tc.args...))
+			require.Equal(t, tc.expectedOut, out)
+			if tc.expectedOut == 0 {
+				require.Equal(t, tc.expectedNS, fetchedNS)
+				require.Contains(t, buf.String(), fmt.Sprintf("Envoy configuration for %s in namespace %s:", podName, tc.expectedNS))
+			}
+		})
+	}
+}
+
+func TestReadCommand_Service(t *testing.T) {
+	podName := "fakePod"
+	multiportPod := v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        podName,
+		Namespace:   "default",
+		Annotations: map[string]string{"consul.hashicorp.com/connect-service": "web,web-admin"},
+	}}
+	pod := v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: "default"}}
+
+	cases := map[string]struct {
+		pod           v1.Pod
+		args          []string
+		expectedOut   int
+		expectedPorts map[string]int
+		expectedErr   string
+	}{
+		"all services": {
+			pod:           multiportPod,
+			expectedPorts: map[string]int{"web": 19000, "web-admin": 19001},
+		},
+		"second service": {
+			pod:           multiportPod,
+			args:          []string{"-service", "web-admin"},
+			expectedPorts: map[string]int{"web-admin": 19001},
+		},
+		"first service": {
+			pod:           multiportPod,
+			args:          []string{"-service", "web"},
+			expectedPorts: map[string]int{"web": 19000},
+		},
+		"explicit admin port": {
+			pod:           multiportPod,
+			args:          []string{"-admin-port", "19005"},
+			expectedPorts: map[string]int{podName: 19005},
+		},
+		"unknown service": {
+			pod:         multiportPod,
+			args:        []string{"-service", "api"},
+			expectedOut: 1,
+			expectedErr: "Service api was not found in the consul.hashicorp.com/connect-service annotation of Pod fakePod.",
+		},
+		"service in a pod without the annotation": {
+			pod:         pod,
+			args:        []string{"-service", "web"},
+			expectedOut: 1,
+			expectedErr: "Pod fakePod does not have a consul.hashicorp.com/connect-service annotation to select service web from.",
+		},
+		"service and admin port together": {
+			pod:         multiportPod,
+			args:        []string{"-service", "web", "-admin-port", "19001"},
+			expectedOut: 1,
+			expectedErr: "-service and -admin-port may not be used together.",
+		},
+		"invalid admin port": {
+			pod:         multiportPod,
+			args:        []string{"-admin-port", "70000"},
+			expectedOut: 1,
+			expectedErr: "-admin-port must be a valid port number.",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+			c.kubernetes = fake.NewSimpleClientset(&v1.PodList{Items: []v1.Pod{tc.pod}})
+
+			fetchedPorts := make(map[int]bool)
+			c.fetchConfig = func(_ context.Context, pf common.PortForwarder) (*EnvoyConfig, error) {
+				fetchedPorts[pf.(*common.PortForward).RemotePort] = true
+				return testEnvoyConfig, nil
+			}
+
+			out := c.Run(append([]string{podName}, tc.args...))
+			require.Equal(t, tc.expectedOut, out)
+			if tc.expectedErr != "" {
+				require.Contains(t, buf.String(), tc.expectedErr)
+				return
+			}
+
+			require.Len(t, fetchedPorts, len(tc.expectedPorts))
+			for name, port := range tc.expectedPorts {
+				require.True(t, fetchedPorts[port])
+				require.Contains(t, buf.String(), fmt.Sprintf("Envoy configuration for %s in namespace default:", name))
+			}
+		})
+	}
+}
+
+func TestReadCommand_AdminBindPort(t *testing.T) {
+	podName := "fakePod"
+	multiportPod := v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        podName,
+		Namespace:   "default",
+		Annotations: map[string]string{"consul.hashicorp.com/connect-service": "web,web-admin"},
+	}}
+	pod := v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: "default"}}
+
+	cases := map[string]struct {
+		pod           v1.Pod
+		args          []string
+		expectedOut   int
+		expectedPorts map[string]int
+		expectedErr   string
+	}{
+		"default bind port": {
+			pod:           pod,
+			expectedPorts: map[string]int{podName: 19000},
+		},
+		"custom bind port": {
+			pod:           pod,
+			args:          []string{"-admin-bind-port", "9901"},
+			expectedPorts: map[string]int{podName: 9901},
+		},
+		"custom bind port in a multiport pod": {
+			pod:           multiportPod,
+			args:          []string{"-admin-bind-port", "9901"},
+			expectedPorts: map[string]int{"web": 9901, "web-admin": 9902},
+		},
+		"custom bind port with service": {
+			pod:           multiportPod,
+			args:          []string{"-admin-bind-port", "9901", "-service", "web-admin"},
+			expectedPorts: map[string]int{"web-admin": 9902},
+		},
+		"bind port and admin port together": {
+			pod:         pod,
+			args:        []string{"-admin-bind-port", "9901", "-admin-port", "19001"},
+			expectedOut: 1,
+			expectedErr: "-admin-bind-port and -admin-port may not be used together.",
+		},
+		"invalid bind port": {
+			pod:         pod,
+			args:        []string{"-admin-bind-port", "70000"},
+			expectedOut: 1,
+			expectedErr: "-admin-bind-port must be a valid port number.",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+			c.kubernetes = fake.NewSimpleClientset(&v1.PodList{Items: []v1.Pod{tc.pod}})
+
+			fetchedPorts := make(map[int]bool)
+			c.fetchConfig = func(_ context.Context, pf common.PortForwarder) (*EnvoyConfig, error) {
+				fetchedPorts[pf.(*common.PortForward).RemotePort] = true
+				return testEnvoyConfig, nil
+			}
+
+			out := c.Run(append([]string{podName}, tc.args...))
+			require.Equal(t, tc.expectedOut, out)
+			if tc.expectedErr != "" {
+				require.Contains(t, buf.String(), tc.expectedErr)
+				return
+			}
+
+			require.Len(t, fetchedPorts, len(tc.expectedPorts))
+			for name, port := range tc.expectedPorts {
+				require.True(t, fetchedPorts[port])
+				require.Contains(t, buf.String(), fmt.Sprintf("Envoy configuration for %s in namespace default:", name))
+			}
+		})
+	}
+}
+
+func TestReadCommand_FromFile(t *testing.T) {
+	dir := t.TempDir()
+	combined := filepath.Join(dir, "combined.json")
+	require.NoError(t, os.WriteFile(combined, rawEnvoyConfig(t), 0600))
+	invalid := filepath.Join(dir, "invalid.json")
+	require.NoError(t, os.WriteFile(invalid, []byte(`{"configs": [`), 0600))
+	notConfigDump := filepath.Join(dir, "not-config-dump.json")
+	require.NoError(t, os.WriteFile(notConfigDump, []byte(`{"foo": "bar"}`), 0600))
+
+	cases := map[string]struct {
+		args        []string
+		expectedOut int
+		expected    []string
+	}{
+		"config dump fixture": {
+			args:        []string{"-from-file", testConfigDump, "-clusters"},
+			expectedOut: 0,
+			expected: []string{
+				"Envoy configuration for test_config_dump from test_config_dump.json:",
+				"==> Clusters \\(5\\)",
+				"client.*client\\.default\\.dc1\\.internal\\.bc3815c2-1a0f-f3ff-a2e9-20d791f08d00\\.consul.*EDS",
+			},
+		},
+		"combined config dump and clusters with pod name": {
+			args:        []string{"fakePod", "-from-file", combined, "-endpoints"},
+			expectedOut: 0,
+			expected: []string{
+				"Envoy configuration for fakePod from .*combined.json:",
+				"==> Endpoints \\(6\\)",
+				"192.168.18.110:20000.*client.*1.00.*HEALTHY",
+			},
+		},
+		"missing file": {
+			args:        []string{"-from-file", filepath.Join(dir, "missing.json")},
+			expectedOut: 1,
+			expected:    []string{"error reading config dump file"},
+		},
+		"invalid JSON": {
+			args:        []string{"-from-file", invalid},
+			expectedOut: 1,
+			expected:    []string{"error parsing config dump file .*: not a valid Envoy config dump"},
+		},
+		"JSON which is not a config dump": {
+			args:        []string{"-from-file", notConfigDump},
+			expectedOut: 1,
+			expected:    []string{"expected a \"configs\" or \"config_dump\" field"},
+		},
+		"used with -namespace": {
+			args:        []string{"-from-file", testConfigDump, "-namespace", "default"},
+			expectedOut: 1,
+			expected:    []string{"-from-file may not be used with -namespace/-n or -all-namespaces/-A."},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+			c.fetchConfig = func(context.Context, common.PortForwarder) (*EnvoyConfig, error) {
+				t.Fatal("the configuration should not be fetched from a Pod")
+				return nil, nil
+			}
+
+			out := c.Run(tc.args)
+			require.Equal(t, tc.expectedOut, out)
+			for _, expression := range tc.expected {
+				require.Regexp(t, expression, buf.String())
+			}
+		})
+	}
+}
+
+func TestReadCommand_MaxWidth(t *testing.T) {
+	fqdn := "client.default.dc1.internal.bc3815c2-1a0f-f3ff-a2e9-20d791f08d00.consul"
+
+	cases := map[string]struct {
+		args        []string
+		expectedOut int
+		expected    []string
+		notExpected []string
+	}{
+		"long values are truncated in tables": {
+			args:        []string{"-from-file", testConfigDump, "-clusters", "-max-width", "20"},
+			expectedOut: 0,
+			expected:    []string{"client\\.default\\.dc1\\.…"},
+			notExpected: []string{fqdn},
+		},
+		"values are not truncated by default": {
+			args:        []string{"-from-file", testConfigDump, "-clusters"},
+			expectedOut: 0,
+			expected:    []string{fqdn},
+		},
+		"values are not truncated in JSON": {
+			args:        []string{"-from-file", testConfigDump, "-clusters", "-max-width", "20", "-output", "json"},
+			expectedOut: 0,
+			expected:    []string{fqdn},
+		},
+		"negative width": {
+			args:        []string{"-from-file", testConfigDump, "-max-width", "-1"},
+			expectedOut: 1,
+			expected:    []string{"-max-width must not be negative."},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+
+			out := c.Run(tc.args)
+			require.Equal(t, tc.expectedOut, out)
+			for _, expression := range tc.expected {
+				require.Regexp(t, expression, buf.String())
+			}
+			for _, value := range tc.notExpected {
+				require.NotContains(t, buf.String(), value)
+			}
+		})
+	}
+}
+
+func TestReadCommand_Limit(t *testing.T) {
+	cases := map[string]struct {
+		args        []string
+		expectedOut int
+		expected    []string
+		notExpected []string
+	}{
+		"all entries by default": {
+			args:        []string{"-from-file", testConfigDump, "-clusters"},
+			expectedOut: 0,
+			expected:    []string{"==> Clusters \\(5\\)", "local_agent", "frontend", "original-destination"},
+			notExpected: []string{"more"},
+		},
+		"clusters are capped": {
+			args:        []string{"-from-file", testConfigDump, "-clusters", "-limit", "2"},
+			expectedOut: 0,
+			expected:    []string{"==> Clusters \\(5\\)", "local_agent", "client", "\\.\\.\\. 3 more"},
+			notExpected: []string{"frontend", "original-destination"},
+		},
+		"listeners are capped": {
+			args:        []string{"-from-file", testConfigDump, "-listeners", "-limit", "1"},
+			expectedOut: 0,
+			expected:    []string{"==> Listeners \\(2\\)", "public_listener", "\\.\\.\\. 1 more"},
+			notExpected: []string{"outbound_listener"},
+		},
+		"limit larger than the table": {
+			args:        []string{"-from-file", testConfigDump, "-routes", "-limit", "10"},
+			expectedOut: 0,
+			expected:    []string{"==> Routes \\(1\\)", "public_listener"},
+			notExpected: []string{"more"},
+		},
+		"negative limit": {
+			args:        []string{"-from-file", testConfigDump, "-limit", "-1"},
+			expectedOut: 1,
+			expected:    []string{"-limit must not be negative."},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+
+			out := c.Run(tc.args)
+			require.Equal(t, tc.expectedOut, out)
+			for _, expression := range tc.expected {
+				require.Regexp(t, expression, buf.String())
+			}
+			for _, value := range tc.notExpected {
+				require.NotContains(t, buf.String(), value)
+			}
+		})
+	}
+}
+
+func TestReadCommand_Markdown(t *testing.T) {
+	cases := map[string]struct {
+		args        []string
+		expectedOut int
+		expected    []string
+	}{
+		"clusters": {
+			args:        []string{"-from-file", testConfigDump, "-clusters", "-markdown"},
+			expectedOut: 0,
+			expected: []string{
+				"==> Clusters \\(5\\)",
+				"(?m)^\\| Name \\| FQDN \\| Endpoints \\| Type \\| Max Connections \\| Max Requests \\| Last Updated \\|$",
+				"(?m)^\\| --- \\| --- \\| --- \\| --- \\| --- \\| --- \\| --- \\|$",
+				"(?m)^\\| local_agent \\| local_agent \\| 192\\.168\\.79\\.187:8502 \\| STATIC \\|.*\\|$",
+			},
+		},
+		"listeners with multiple filter chains": {
+			args:        []string{"-from-file", testConfigDump, "-listeners", "-markdown"},
+			expectedOut: 0,
+			expected:    []string{"(?m)^\\| public_listener \\| 192\\.168\\.69\\.179:20000 \\| INBOUND \\|.*\\|$"},
+		},
+		"with json output": {
+			args:        []string{"-from-file", testConfigDump, "-markdown", "-output", "json"},
+			expectedOut: 1,
+			expected:    []string{"-markdown may not be used with -output json or raw."},
+		},
+		"with counts": {
+			args:        []string{"-from-file", testConfigDump, "-markdown", "-counts"},
+			expectedOut: 1,
+			expected:    []string{"-markdown may not be used with -counts."},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+
+			out := c.Run(tc.args)
+			require.Equal(t, tc.expectedOut, out)
+			for _, expression := range tc.expected {
+				require.Regexp(t, expression, buf.String())
+			}
+		})
+	}
+}
+
+func TestReadCommand_ExtractStatic(t *testing.T) {
+	cases := map[string]struct {
+		args        []string
+		expectedOut int
+		expected    []string
+	}{
+		"static clusters": {
+			args:        []string{"-from-file", testConfigDump, "-extract-static"},
+			expectedOut: 0,
+			expected: []string{
+				"(?m)^static_resources:\n  clusters:\n",
+				"(?m)^    name: local_agent$",
+				"(?m)^    type: STATIC$",
+			},
+		},
+		"with json output": {
+			args:        []string{"-from-file", testConfigDump, "-extract-static", "-output", "json"},
+			expectedOut: 1,
+			expected:    []string{"-extract-static may not be used with -output json or raw."},
+		},
+		"with counts": {
+			args:        []string{"-from-file", testConfigDump, "-extract-static", "-counts"},
+			expectedOut: 1,
+			expected:    []string{"-extract-static may not be used with -markdown or -counts."},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+
+			out := c.Run(tc.args)
+			require.Equal(t, tc.expectedOut, out)
+			for _, expression := range tc.expected {
+				require.Regexp(t, expression, buf.String())
+			}
+			if tc.expectedOut == 0 {
+				require.NotContains(t, buf.String(), "public_listener")
+			}
+		})
+	}
+}
+
+func TestReadCommand_ShowConfigVersion(t *testing.T) {
+	clusterVersion := "2eee24224b508d5e77766867b5ad793bc4555abce4d3fa564da125617c68e46a"
+	listenerVersion := "42e63fea110536be20b84ab28ef1979efb5e20b967a752b8834314c4fcd58358"
+
+	cases := map[string]struct {
+		args        []string
+		expected    []string
+		notExpected []string
+	}{
+		"versions are shown": {
+			args: []string{"-from-file", testConfigDump, "-show-config-version"},
+			expected: []string{
+				"Name.*FQDN.*Last Updated.*Version",
+				"client.*EDS.*" + clusterVersion,
+				"Name.*Address:Port.*Last Updated.*Version",
+				"public_listener.*INBOUND.*" + listenerVersion,
+				// Static clusters and the secrets in the dump have no version_info.
+				"local_agent.*STATIC.*2022-05-13T04:22:39\\.553Z[\t ]+\n",
+				"Name.*Type.*Last Updated.*Version",
+				"default.*Dynamic Active.*2022-05-24T17:41:59\\.078Z[\t ]+\n",
+			},
+		},
+		"versions are not shown by default": {
+			args:        []string{"-from-file", testConfigDump},
+			notExpected: []string{"Version", clusterVersion, listenerVersion},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+
+			out := c.Run(tc.args)
+			require.Equal(t, 0, out)
+			for _, expression := range tc.expected {
+				require.Regexp(t, expression, buf.String())
+			}
+			for _, value := range tc.notExpected {
+				require.NotContains(t, buf.String(), value)
+			}
+		})
+	}
+}
+
+func TestReadCommand_SPIFFEID(t *testing.T) {
+	cases := map[string]struct {
+		args        []string
+		expected    []string
+		notExpected []string
+	}{
+		"identity is shown": {
+			args:     []string{"-from-file", testConfigDump},
+			expected: []string{"SPIFFE ID: spiffe://cluster.local/ns/foo/sa/default", "Trust Domain: cluster.local"},
+		},
+		"identity is hidden with -quiet": {
+			args:        []string{"-from-file", testConfigDump, "-quiet"},
+			notExpected: []string{"SPIFFE ID", "Trust Domain"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+
+			out := c.Run(tc.args)
+			require.Equal(t, 0, out)
+			for _, value := range tc.expected {
+				require.Contains(t, buf.String(), value)
+			}
+			for _, value := range tc.notExpected {
+				require.NotContains(t, buf.String(), value)
+			}
+		})
+	}
+}
+
+func TestReadCommand_NodeInfo(t *testing.T) {
+	// The config dump without its bootstrap section.
+	noBootstrap := filepath.Join(t.TempDir(), "no_bootstrap.json")
+	raw, err := os.ReadFile(testConfigDump)
+	require.NoError(t, err)
+	var dump map[string][]map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &dump))
+	dump["configs"] = dump["configs"][1:]
+	raw, err = json.Marshal(dump)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(noBootstrap, raw, 0600))
+
+	cases := map[string]struct {
+		args        []string
+		expected    []string
+		notExpected []string
+	}{
+		"node is shown": {
+			args: []string{"-from-file", testConfigDump},
+			expected: []string{"Node ID: backend-658b679b45-d5xlb-backend-sidecar-proxy", "Node Cluster: backend",
+				"Envoy Build: 1.22.0"},
+		},
+		"node is hidden with -quiet": {
+			args:        []string{"-from-file", testConfigDump, "-quiet"},
+			notExpected: []string{"Node ID", "Node Cluster", "Envoy Build"},
+		},
+		"config dump without a bootstrap section": {
+			args:        []string{"-from-file", noBootstrap},
+			expected:    []string{"==> Clusters"},
+			notExpected: []string{"Node ID", "Node Cluster", "Envoy Build"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+
+			out := c.Run(tc.args)
+			require.Equal(t, 0, out)
+			for _, value := range tc.expected {
+				require.Contains(t, buf.String(), value)
+			}
+			for _, value := range tc.notExpected {
+				require.NotContains(t, buf.String(), value)
+			}
+		})
+	}
+}
+
+func TestReadCommand_ConfigOnly(t *testing.T) {
+	cases := map[string]struct {
+		args        []string
+		expectedOut int
+		expected    []string
+		notExpected []string
+	}{
+		"tables": {
+			args:        []string{"-from-file", testConfigDump, "-config-only"},
+			expectedOut: 0,
+			expected:    []string{"==> Clusters \\(5\\)", "==> Listeners \\(2\\)", "==> Routes \\(1\\)"},
+			notExpected: []string{"==> Endpoints", "==> Secrets"},
+		},
+		"JSON": {
+			args:        []string{"-from-file", testConfigDump, "-config-only", "-output", "json"},
+			expectedOut: 0,
+			expected:    []string{`"clusters"`, `"listeners"`, `"routes"`},
+			notExpected: []string{`"endpoints"`, `"secrets"`},
+		},
+		"used with -endpoints": {
+			args:        []string{"-from-file", testConfigDump, "-config-only", "-endpoints"},
+			expectedOut: 1,
+			expected:    []string{"-config-only may not be used with -endpoints or -secrets."},
+		},
+		"used with -unhealthy-only": {
+			args:        []string{"-from-file", testConfigDump, "-config-only", "-unhealthy-only"},
+			expectedOut: 1,
+			expected:    []string{"-config-only may not be used with -unhealthy-only."},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+
+			out := c.Run(tc.args)
+			require.Equal(t, tc.expectedOut, out)
+			for _, expression := range tc.expected {
+				require.Regexp(t, expression, buf.String())
+			}
+			for _, value := range tc.notExpected {
+				require.NotContains(t, buf.String(), value)
+			}
+		})
+	}
+}
+
+func TestReadCommand_UnhealthyOnly(t *testing.T) {
+	podName := "fakePod"
+
+	fakePod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      podName,
+			Namespace: "default",
+		},
+	}
+
+	config := &EnvoyConfig{
+		Endpoints: []Endpoint{
+			{Address: "192.168.79.187:8502", Cluster: "local_agent", Weight: 1},
+			{Address: "192.168.18.110:20000", Cluster: "client", Weight: 1, Status: "HEALTHY"},
+			{Address: "192.168.52.101:20000", Cluster: "frontend", Weight: 1, Status: "UNHEALTHY"},
+			{Address: "192.168.65.131:20000", Cluster: "backend", Weight: 1, Status: "DRAINING"},
+		},
+	}
+
+	cases := map[string]struct {
+		args        []string
+		expected    []string
+		notExpected []string
+	}{
+		"all endpoints by default": {
+			args:     []string{podName, "-endpoints"},
+			expected: []string{"192.168.79.187:8502", "192.168.18.110:20000", "192.168.52.101:20000", "192.168.65.131:20000"},
+		},
+		"unhealthy only": {
+			args:        []string{podName, "-endpoints", "-unhealthy-only"},
+			expected:    []string{"Endpoints which are not healthy", "==> Endpoints \\(2\\)", "192.168.52.101:20000", "192.168.65.131:20000"},
+			notExpected: []string{"192.168.79.187:8502", "192.168.18.110:20000"},
+		},
+		"unhealthy only with address": {
+			args:        []string{podName, "-endpoints", "-unhealthy-only", "-address", "192.168.52"},
+			expected:    []string{"192.168.52.101:20000"},
+			notExpected: []string{"192.168.79.187:8502", "192.168.18.110:20000", "192.168.65.131:20000"},
+		},
+		"unhealthy only in JSON": {
+			args:        []string{podName, "-endpoints", "-unhealthy-only", "-output", "json"},
+			expected:    []string{"192.168.52.101:20000", "192.168.65.131:20000"},
+			notExpected: []string{"192.168.79.187:8502", "192.168.18.110:20000"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+			c.kubernetes = fake.NewSimpleClientset(&v1.PodList{Items: []v1.Pod{fakePod}})
+			c.fetchConfig = func(context.Context, common.PortForwarder) (*EnvoyConfig, error) {
+				return config, nil
+			}
+
+			out := c.Run(tc.args)
+			require.Equal(t, 0, out)
+			for _, expression := range tc.expected {
+				require.Regexp(t, expression, buf.String())
+			}
+			for _, value := range tc.notExpected {
+				require.NotContains(t, buf.String(), value)
+			}
+		})
+	}
+}
+
+func TestReadCommand_WithStats(t *testing.T) {
+	podName := "fakePod"
+
+	fakePod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      podName,
+			Namespace: "default",
+		},
+	}
+
+	stats := map[string]ClusterStats{
+		"local_agent": {ActiveConnections: 1, HealthyHosts: 1, Hosts: 1},
+		"client.default.dc1.internal.bc3815c2-1a0f-f3ff-a2e9-20d791f08d00.consul": {HealthyHosts: 2, Hosts: 3},
+	}
+
+	cases := map[string]struct {
+		args        []string
+		statsErr    error
+		expectedOut int
+		expected    []string
+		notExpected []string
+	}{
+		"stats are shown": {
+			args:        []string{podName, "-clusters", "-with-stats"},
+			expectedOut: 0,
+			expected: []string{
+				"Name.*FQDN.*Active Connections.*Healthy Hosts.*Last Updated",
+				"local_agent.*STATIC.*1.*1/1",
+				"client.*EDS.*0.*2/3",
+			},
+		},
+		"stats are shown in JSON": {
+			args:        []string{podName, "-clusters", "-with-stats", "-output", "json"},
+			expectedOut: 0,
+			expected:    []string{`"ActiveConnections": 1`, `"HealthyHosts": 2`},
+		},
+		"stats are not shown by default": {
+			args:        []string{podName, "-clusters"},
+			expectedOut: 0,
+			notExpected: []string{"Active Connections", "Healthy Hosts", "Stats"},
+		},
+		"config is shown when the stats can't be fetched": {
+			args:        []string{podName, "-clusters", "-with-stats"},
+			statsErr:    errors.New("connection refused"),
+			expectedOut: 0,
+			expected: []string{
+				"Unable to fetch the cluster stats for fakePod, they will not be shown: connection refused",
+				"==> Clusters \\(5\\)",
+			},
+		},
+		"used with -from-file": {
+			args:        []string{"-from-file", testConfigDump, "-with-stats"},
+			expectedOut: 1,
+			expected:    []string{"-with-stats may not be used with -from-file."},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+			c.kubernetes = fake.NewSimpleClientset(&v1.PodList{Items: []v1.Pod{fakePod}})
+			c.fetchConfig = func(context.Context, common.PortForwarder) (*EnvoyConfig, error) {
+				return &EnvoyConfig{Clusters: append([]Cluster{}, testEnvoyConfig.Clusters...)}, nil
+			}
+			c.fetchClusterStats = func(context.Context, common.PortForwarder) (map[string]ClusterStats, error) {
+				return stats, tc.statsErr
+			}
+
+			out := c.Run(tc.args)
+			require.Equal(t, tc.expectedOut, out)
+			for _, expression := range tc.expected {
+				require.Regexp(t, expression, buf.String())
+			}
+			for _, value := range tc.notExpected {
+				require.NotContains(t, buf.String(), value)
+			}
+		})
+	}
+}
+
+func TestReadCommand_Counts(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := setupCommand(buf)
+
+	out := c.Run([]string{"-from-file", testConfigDump, "-counts"})
+	require.Equal(t, 0, out)
+
+	var counts map[string]map[string]int
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &counts))
+	require.Equal(t, map[string]map[string]int{
+		"test_config_dump": {
+			"listeners_inbound":   1,
+			"listeners_outbound":  1,
+			"clusters":            5,
+			"endpoints_healthy":   6,
+			"endpoints_unhealthy": 0,
+			"secrets":             2,
+		},
+	}, counts)
+
+	buf.Reset()
+	c = setupCommand(buf)
+	out = c.Run([]string{"-from-file", testConfigDump, "-counts", "-output", "json"})
+	require.Equal(t, 1, out)
+	require.Contains(t, buf.String(), "-counts may not be used with -output json or raw.")
+}
+
+func TestReadCommand_Watch(t *testing.T) {
+	configDump, err := fs.ReadFile(testConfigDump)
+	require.NoError(t, err)
+	clusters, err := fs.ReadFile(testClusters)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel the command, as an interrupt would, once the configuration has
+	// been fetched twice.
+	fetches := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/config_dump" {
+			w.Write(configDump)
+		}
+		if r.URL.Path == "/clusters" {
+			fetches++
+			if fetches == 2 {
+				cancel()
+			}
+			w.Write(clusters)
+		}
+	}))
+	defer mockServer.Close()
+
+	opens, closes := 0, 0
+	mpf := &mockPortForwarder{
+		openBehavior: func(context.Context) (string, error) {
+			opens++
+			return strings.Replace(mockServer.URL, "http://", "", 1), nil
+		},
+		closeBehavior: func() { closes++ },
+	}
+
+	buf := new(bytes.Buffer)
+	c := setupCommand(buf)
+	c.Ctx = ctx
+	c.httpClient = mockServer.Client()
+	c.flagPodName = "fakePod"
+	c.flagNamespace = "default"
+	c.flagClusters = true
+	c.flagInterval = 10 * time.Millisecond
+
+	out := c.watch(map[string]common.PortForwarder{"fakePod": mpf})
+	require.Equal(t, 0, out)
+	require.Equal(t, 2, fetches)
+
+	// The port forward is kept open across iterations and closed at the end.
+	require.Equal(t, 1, opens)
+	require.Equal(t, 1, closes)
+
+	// The screen is cleared and the tables are printed on each iteration.
+	require.Equal(t, 2, strings.Count(buf.String(), clearScreen))
+	require.Equal(t, 2, strings.Count(buf.String(), "==> Clusters (5)"))
+}
+
+func TestValidateFlags_Watch(t *testing.T) {
+	cases := map[string]struct {
+		args     []string
+		expected string
+	}{
+		"watch with from-file": {
+			args:     []string{"-watch", "-from-file", testConfigDump},
+			expected: "-watch may not be used with -from-file.",
+		},
+		"zero interval": {
+			args:     []string{"fakePod", "-watch", "-interval", "0s"},
+			expected: "-interval must be greater than zero.",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+			c.kubernetes = fake.NewSimpleClientset()
+
+			out := c.Run(tc.args)
+			require.Equal(t, 1, out)
+			require.Contains(t, buf.String(), tc.expected)
+		})
+	}
+}
+
+func TestReadCommand_KubeContext(t *testing.T) {
+	kubeConfig := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeConfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: dev
+  cluster:
+    server: https://127.0.0.1:6443
+- name: prod
+  cluster:
+    server: https://127.0.0.1:6444
+contexts:
+- name: prod
+  context:
+    cluster: prod
+    user: admin
+- name: dev
+  context:
+    cluster: dev
+    user: admin
+users:
+- name: admin
+  user:
+    token: test
+current-context: dev
+`), 0600))
+
+	cases := map[string]struct {
+		context  string
+		expected string
+	}{
+		"context exists": {
+			context: "prod",
+		},
+		"no context set": {
+			context: "",
+		},
+		"context does not exist": {
+			context:  "staging",
+			expected: "context \"staging\" does not exist in the kubeconfig. Available contexts: dev, prod",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			settings := helmCLI.New()
+			settings.KubeConfig = kubeConfig
+			settings.KubeContext = tc.context
+
+			err := validateKubeContext(settings)
+			if tc.expected == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.expected)
+		})
+	}
+
+	t.Run("the command fails before port forwarding", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		c := setupCommand(buf)
+
+		out := c.Run([]string{"fakePod", "-kubeconfig", kubeConfig, "-context", "staging"})
+		require.Equal(t, 1, out)
+		require.Contains(t, buf.String(), "Available contexts: dev, prod")
+	})
+}
+
+func TestReadCommand_InClusterConfig(t *testing.T) {
+	kubeConfig := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeConfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: dev
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: admin
+users:
+- name: admin
+  user:
+    token: test
+current-context: dev
+`), 0600))
+	missingKubeConfig := filepath.Join(t.TempDir(), "missing")
+
+	cases := map[string]struct {
+		kubeConfig      string
+		inClusterConfig func() (*rest.Config, error)
+		expectedHost    string
+		expectedErr     bool
+	}{
+		"in-cluster config is used without a kubeconfig": {
+			kubeConfig: missingKubeConfig,
+			inClusterConfig: func() (*rest.Config, error) {
+				return &rest.Config{Host: "https://10.0.0.1:443"}, nil
+			},
+			expectedHost: "https://10.0.0.1:443",
+		},
+		"kubeconfig is preferred over in-cluster config": {
+			kubeConfig: kubeConfig,
+			inClusterConfig: func() (*rest.Config, error) {
+				return &rest.Config{Host: "https://10.0.0.1:443"}, nil
+			},
+			expectedHost: "https://127.0.0.1:6443",
+		},
+		"neither kubeconfig nor in-cluster config is available": {
+			kubeConfig: missingKubeConfig,
+			inClusterConfig: func() (*rest.Config, error) {
+				return nil, rest.ErrNotInCluster
+			},
+			expectedErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := setupCommand(new(bytes.Buffer))
+			c.inClusterConfig = tc.inClusterConfig
+			c.flagKubeConfig = tc.kubeConfig
+
+			err := c.initKubernetes()
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedHost, c.restConfig.Host)
+		})
+	}
+}
+
 func setupCommand(buf io.Writer) *ReadCommand {
 	// Log at a test level to standard out.
 	log := hclog.New(&hclog.LoggerOptions{
@@ -2,14 +2,19 @@ package read
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/hashicorp/consul-k8s/cli/common"
+	"sigs.k8s.io/yaml"
 )
 
 // EnvoyConfig represents the configuration retrieved from a config dump at the
@@ -22,6 +27,16 @@ type EnvoyConfig struct {
 	Listeners []Listener
 	Routes    []Route
 	Secrets   []Secret
+	// SPIFFEID and TrustDomain identify the proxy for mTLS. They are read from
+	// the URI SAN of its leaf certificate and are empty if it can't be found.
+	SPIFFEID    string
+	TrustDomain string
+	// NodeID, NodeCluster, and EnvoyVersion describe the proxy's Envoy node.
+	// They are read from the bootstrap section of the config dump and are
+	// empty if it is missing.
+	NodeID       string
+	NodeCluster  string
+	EnvoyVersion string
 }
 
 // Cluster represents a cluster in the Envoy config.
@@ -30,7 +45,25 @@ type Cluster struct {
 	FullyQualifiedDomainName string
 	Endpoints                []string
 	Type                     string
-	LastUpdated              string
+	// MaxConnections and MaxRequests are the default priority circuit breaker
+	// thresholds for the cluster. They are zero if not set.
+	MaxConnections int
+	MaxRequests    int
+	LastUpdated    string
+	// VersionInfo is the xDS version_info of the cluster. It is empty for
+	// static clusters.
+	VersionInfo string
+	// Stats is the runtime state of the cluster's hosts. It is nil unless
+	// the stats were merged in with MergeClusterStats.
+	Stats *ClusterStats `json:",omitempty"`
+}
+
+// ClusterStats is the runtime state of a cluster's hosts as reported by the
+// admin API's /clusters endpoint.
+type ClusterStats struct {
+	ActiveConnections int
+	HealthyHosts      int
+	Hosts             int
 }
 
 // Endpoint represents an endpoint in the Envoy config.
@@ -48,6 +81,9 @@ type Listener struct {
 	FilterChain []FilterChain
 	Direction   string
 	LastUpdated string
+	// VersionInfo is the xDS version_info of the listener. It is empty for
+	// static listeners.
+	VersionInfo string
 }
 
 type FilterChain struct {
@@ -67,11 +103,75 @@ type Secret struct {
 	Name        string
 	Type        string
 	LastUpdated string
+	// VersionInfo is the xDS version_info of the secret. It is empty for
+	// static secrets.
+	VersionInfo string
+}
+
+// Counts summarizes the shape of the Envoy config. Listeners are counted by
+// traffic direction and endpoints by health status.
+type Counts struct {
+	ListenersInbound   int `json:"listeners_inbound"`
+	ListenersOutbound  int `json:"listeners_outbound"`
+	Clusters           int `json:"clusters"`
+	EndpointsHealthy   int `json:"endpoints_healthy"`
+	EndpointsUnhealthy int `json:"endpoints_unhealthy"`
+	Secrets            int `json:"secrets"`
+}
+
+// CountConfig counts the listeners, clusters, endpoints, and secrets in the
+// Envoy config. Listeners with an unspecified traffic direction are not
+// counted. Endpoints with any status other than HEALTHY are counted as
+// unhealthy.
+func CountConfig(config *EnvoyConfig) Counts {
+	counts := Counts{
+		Clusters: len(config.Clusters),
+		Secrets:  len(config.Secrets),
+	}
+
+	for _, listener := range config.Listeners {
+		switch listener.Direction {
+		case "INBOUND":
+			counts.ListenersInbound++
+		case "OUTBOUND":
+			counts.ListenersOutbound++
+		}
+	}
+
+	for _, endpoint := range config.Endpoints {
+		if endpoint.Status == "HEALTHY" {
+			counts.EndpointsHealthy++
+		} else {
+			counts.EndpointsUnhealthy++
+		}
+	}
+
+	return counts
 }
 
 // FetchConfig opens a port forward to the Envoy admin API and fetches the
 // configuration from the config dump endpoint.
 func FetchConfig(ctx context.Context, portForward common.PortForwarder) (*EnvoyConfig, error) {
+	return FetchConfigWithClient(ctx, portForward, http.DefaultClient, "http")
+}
+
+// FetchConfigWithClient opens a port forward to the Envoy admin API and fetches
+// the configuration from the config dump endpoint using the given HTTP client
+// and URL scheme. This allows the configuration to be fetched from admin
+// endpoints which are served over HTTPS.
+func FetchConfigWithClient(ctx context.Context, portForward common.PortForwarder, client *http.Client, scheme string) (*EnvoyConfig, error) {
+	return fetchConfig(ctx, portForward, client, scheme, true)
+}
+
+// FetchConfigWithoutEndpoints fetches the configuration in the same way as
+// FetchConfigWithClient but leaves the endpoints (EDS) out of the config dump.
+// The endpoints are often the largest part of the config dump so this is
+// faster when only the clusters, listeners and routes are needed.
+func FetchConfigWithoutEndpoints(ctx context.Context, portForward common.PortForwarder, client *http.Client, scheme string) (*EnvoyConfig, error) {
+	return fetchConfig(ctx, portForward, client, scheme, false)
+}
+
+func fetchConfig(ctx context.Context, portForward common.PortForwarder, client *http.Client, scheme string, includeEDS bool) (*EnvoyConfig, error) {
 	endpoint, err := portForward.Open(ctx)
 	if err != nil {
 		return nil, err
@@ -79,39 +179,166 @@ func FetchConfig(ctx context.Context, portForward common.PortForwarder) (*EnvoyC
 	defer portForward.Close()
 
 	// Fetch the config dump
-	response, err := http.Get(fmt.Sprintf("http://%s/config_dump?include_eds", endpoint))
+	configDumpURL := fmt.Sprintf("%s://%s/config_dump", scheme, endpoint)
+	if includeEDS {
+		configDumpURL += "?include_eds"
+	}
+	configDump, err := fetch(client, configDumpURL)
 	if err != nil {
 		return nil, err
 	}
-	configDump, err := io.ReadAll(response.Body)
+
+	// Fetch the clusters mapping
+	clusters, err := fetch(client, fmt.Sprintf("%s://%s/clusters?format=json", scheme, endpoint))
 	if err != nil {
 		return nil, err
 	}
-	if err := response.Body.Close(); err != nil {
+
+	config := fmt.Sprintf("{\n\"config_dump\":%s,\n\"clusters\":%s}", string(configDump), string(clusters))
+
+	envoyConfig := &EnvoyConfig{}
+	err = json.Unmarshal([]byte(config), envoyConfig)
+	if err != nil {
 		return nil, err
 	}
+	return envoyConfig, nil
+}
 
-	// Fetch the clusters mapping
-	response, err = http.Get(fmt.Sprintf("http://%s/clusters?format=json", endpoint))
+// FetchClusterStats opens a port forward to the Envoy admin API and fetches
+// the runtime stats of each cluster from the clusters endpoint. The stats are
+// keyed by the fully qualified domain name of the cluster.
+func FetchClusterStats(ctx context.Context, portForward common.PortForwarder, client *http.Client, scheme string) (map[string]ClusterStats, error) {
+	endpoint, err := portForward.Open(ctx)
 	if err != nil {
 		return nil, err
 	}
-	clusters, err := io.ReadAll(response.Body)
+	defer portForward.Close()
+
+	clusters, err := fetch(client, fmt.Sprintf("%s://%s/clusters?format=json", scheme, endpoint))
 	if err != nil {
 		return nil, err
 	}
-	if err := response.Body.Close(); err != nil {
-		return nil, err
+	return parseClusterStats(clusters)
+}
+
+// parseClusterStats sums the active connections and counts the healthy hosts
+// of each cluster in the output of the clusters endpoint. A host is healthy
+// if its EDS health status is HEALTHY and it hasn't failed active health
+// checking or outlier detection.
+func parseClusterStats(data []byte) (map[string]ClusterStats, error) {
+	var clusterStatuses clusters
+	if err := json.Unmarshal(data, &clusterStatuses); err != nil {
+		return nil, fmt.Errorf("not a valid Envoy clusters response: %w", err)
+	}
+
+	stats := make(map[string]ClusterStats)
+	for _, clusterStatus := range clusterStatuses.ClusterStatuses {
+		var clusterStats ClusterStats
+		for _, host := range clusterStatus.HostStatuses {
+			clusterStats.Hosts++
+			health := host.HealthStatus
+			if health.EDSHealthStatus == "HEALTHY" && !health.FailedActiveHealthCheck && !health.FailedOutlierCheck {
+				clusterStats.HealthyHosts++
+			}
+			for _, stat := range host.Stats {
+				if stat.Name != "cx_active" {
+					continue
+				}
+				// Envoy omits the value of stats which are zero.
+				if active, err := strconv.Atoi(stat.Value); err == nil {
+					clusterStats.ActiveConnections += active
+				}
+			}
+		}
+		stats[clusterStatus.Name] = clusterStats
 	}
+	return stats, nil
+}
 
-	config := fmt.Sprintf("{\n\"config_dump\":%s,\n\"clusters\":%s}", string(configDump), string(clusters))
+// MergeClusterStats sets the runtime stats of each cluster in the config
+// which has stats. Clusters are matched by their fully qualified domain name.
+func MergeClusterStats(config *EnvoyConfig, stats map[string]ClusterStats) {
+	for i, cluster := range config.Clusters {
+		if clusterStats, ok := stats[cluster.FullyQualifiedDomainName]; ok {
+			config.Clusters[i].Stats = &clusterStats
+		}
+	}
+}
+
+// ParseConfig parses an Envoy configuration which was previously saved, such
+// as one attached to a support ticket. The data may either be the output of
+// the admin API's /config_dump endpoint or a config dump combined with the
+// output of the /clusters endpoint as printed by `-output raw`.
+func ParseConfig(data []byte) (*EnvoyConfig, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("not a valid Envoy config dump: %w", err)
+	}
+
+	if _, ok := fields["config_dump"]; !ok {
+		if _, ok := fields["configs"]; !ok {
+			return nil, fmt.Errorf("not a valid Envoy config dump: expected a \"configs\" or \"config_dump\" field")
+		}
+		// Wrap a bare config dump the same way FetchConfig does. There is no
+		// cluster status information available so it is left empty.
+		data = []byte(fmt.Sprintf("{\n\"config_dump\":%s,\n\"clusters\":{}}", string(data)))
+	}
 
 	envoyConfig := &EnvoyConfig{}
-	err = json.Unmarshal([]byte(config), envoyConfig)
+	if err := json.Unmarshal(data, envoyConfig); err != nil {
+		return nil, fmt.Errorf("not a valid Envoy config dump: %w", err)
+	}
+	return envoyConfig, nil
+}
+
+// StaticBootstrap extracts the static clusters and listeners from the config
+// dump and returns them as the static_resources of a minimal Envoy bootstrap
+// configuration in YAML. Their @type fields are removed since they are only
+// meaningful in the config dump. This is useful to seed a test Envoy with the
+// same static resources as a running proxy.
+func StaticBootstrap(config *EnvoyConfig) ([]byte, error) {
+	var root struct {
+		ConfigDump struct {
+			Configs []json.RawMessage `json:"configs"`
+		} `json:"config_dump"`
+	}
+	if err := json.Unmarshal(config.rawCfg, &root); err != nil {
+		return nil, err
+	}
+
+	var resources staticResources
+	for _, raw := range root.ConfigDump.Configs {
+		var static staticConfigDump
+		if err := json.Unmarshal(raw, &static); err != nil {
+			return nil, err
+		}
+		for _, cluster := range static.StaticClusters {
+			delete(cluster.Cluster, "@type")
+			resources.Clusters = append(resources.Clusters, cluster.Cluster)
+		}
+		for _, listener := range static.StaticListeners {
+			delete(listener.Listener, "@type")
+			resources.Listeners = append(resources.Listeners, listener.Listener)
+		}
+	}
+
+	return yaml.Marshal(bootstrap{StaticResources: resources})
+}
+
+// fetch makes a GET request to the given URL and returns the response body.
+func fetch(client *http.Client, url string) ([]byte, error) {
+	response, err := client.Get(url)
 	if err != nil {
 		return nil, err
 	}
-	return envoyConfig, nil
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := response.Body.Close(); err != nil {
+		return nil, err
+	}
+	return body, nil
 }
 
 // JSON returns the original JSON Envoy config dump data which was used to create
@@ -145,6 +372,8 @@ func (c *EnvoyConfig) UnmarshalJSON(b []byte) error {
 	// Dispatch each section to the appropriate parsing function by its type.
 	for _, config := range root.ConfigDump.Configs {
 		switch config["@type"] {
+		case "type.googleapis.com/envoy.admin.v3.BootstrapConfigDump":
+			c.NodeID, c.NodeCluster, c.EnvoyVersion = parseBootstrapNode(config)
 		case "type.googleapis.com/envoy.admin.v3.ClustersConfigDump":
 			clusters, err := parseClusters(config, clusterMapping)
 			if err != nil {
@@ -175,6 +404,7 @@ func (c *EnvoyConfig) UnmarshalJSON(b []byte) error {
 				return err
 			}
 			c.Secrets = secrets
+			c.SPIFFEID, c.TrustDomain = parseSPIFFEID(config)
 		}
 	}
 
@@ -222,12 +452,25 @@ func parseClusters(rawCfg map[string]interface{}, clusterMapping map[string][]st
 			}
 		}
 
+		// Use the circuit breaker thresholds for default priority routing.
+		var maxConnections, maxRequests int
+		for _, threshold := range cluster.Cluster.CircuitBreakers.Thresholds {
+			if threshold.Priority == "" || threshold.Priority == "DEFAULT" {
+				maxConnections = threshold.MaxConnections
+				maxRequests = threshold.MaxRequests
+				break
+			}
+		}
+
 		clusters = append(clusters, Cluster{
 			Name:                     strings.Split(cluster.Cluster.FQDN, ".")[0],
 			FullyQualifiedDomainName: cluster.Cluster.FQDN,
 			Endpoints:                endpoints,
 			Type:                     cluster.Cluster.ClusterType,
+			MaxConnections:           maxConnections,
+			MaxRequests:              maxRequests,
 			LastUpdated:              cluster.LastUpdated,
+			VersionInfo:              cluster.VersionInfo,
 		})
 	}
 
@@ -321,6 +564,7 @@ func parseListeners(rawCfg map[string]interface{}) ([]Listener, error) {
 			FilterChain: filterChain,
 			Direction:   direction,
 			LastUpdated: listener.LastUpdated,
+			VersionInfo: listener.VersionInfo,
 		})
 	}
 
@@ -377,6 +621,7 @@ func parseSecrets(rawCfg map[string]interface{}) ([]Secret, error) {
 			Name:        secret.Name,
 			Type:        "Static",
 			LastUpdated: secret.LastUpdated,
+			VersionInfo: secret.VersionInfo,
 		})
 	}
 
@@ -385,6 +630,7 @@ func parseSecrets(rawCfg map[string]interface{}) ([]Secret, error) {
 			Name:        secret.Name,
 			Type:        "Dynamic Active",
 			LastUpdated: secret.LastUpdated,
+			VersionInfo: secret.VersionInfo,
 		})
 	}
 
@@ -393,12 +639,78 @@ func parseSecrets(rawCfg map[string]interface{}) ([]Secret, error) {
 			Name:        secret.Name,
 			Type:        "Dynamic Warming",
 			LastUpdated: secret.LastUpdated,
+			VersionInfo: secret.VersionInfo,
 		})
 	}
 
 	return secrets, nil
 }
 
+// parseBootstrapNode returns the ID, cluster, and Envoy version of the node
+// in the bootstrap section of the config dump. Empty strings are returned if
+// the node can't be read. The version is empty if Envoy didn't report it.
+func parseBootstrapNode(rawCfg map[string]interface{}) (string, string, string) {
+	raw, err := json.Marshal(rawCfg)
+	if err != nil {
+		return "", "", ""
+	}
+
+	var bootstrapCD bootstrapConfigDump
+	if err = json.Unmarshal(raw, &bootstrapCD); err != nil {
+		return "", "", ""
+	}
+
+	node := bootstrapCD.Bootstrap.Node
+	var version string
+	if v := node.UserAgentBuildVersion.Version; v != (semanticVersion{}) {
+		version = fmt.Sprintf("%d.%d.%d", v.MajorNumber, v.MinorNumber, v.Patch)
+	}
+	return node.ID, node.Cluster, version
+}
+
+// parseSPIFFEID returns the SPIFFE ID and trust domain from the leaf certificate
+// of the first secret with a certificate chain. Empty strings are returned if
+// there is no such secret or its certificate has no SPIFFE ID.
+func parseSPIFFEID(rawCfg map[string]interface{}) (string, string) {
+	raw, err := json.Marshal(rawCfg)
+	if err != nil {
+		return "", ""
+	}
+
+	var secretsCD secretsConfigDump
+	if err = json.Unmarshal(raw, &secretsCD); err != nil {
+		return "", ""
+	}
+
+	for _, secret := range append(secretsCD.StaticSecrets, secretsCD.DynamicActiveSecrets...) {
+		inlineBytes := secret.Secret.TLSCertificate.CertificateChain.InlineBytes
+		if inlineBytes == "" {
+			continue
+		}
+		// Envoy encodes the inline bytes of the PEM certificate chain as base64.
+		chain, err := base64.StdEncoding.DecodeString(inlineBytes)
+		if err != nil {
+			continue
+		}
+		// The _ result below is not an error but the remaining PEM bytes.
+		block, _ := pem.Decode(chain)
+		if block == nil {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		for _, uri := range cert.URIs {
+			if uri.Scheme == "spiffe" {
+				return uri.String(), uri.Host
+			}
+		}
+	}
+
+	return "", ""
+}
+
 func formatFilters(filters []filter) []string {
 	formatted := []string{}
 
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"embed"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
 )
 
 //go:embed test_config_dump.json test_clusters.json
@@ -32,6 +34,11 @@ func TestUnmarshaling(t *testing.T) {
 	require.Equal(t, testEnvoyConfig.Listeners, envoyConfig.Listeners)
 	require.Equal(t, testEnvoyConfig.Routes, envoyConfig.Routes)
 	require.Equal(t, testEnvoyConfig.Secrets, envoyConfig.Secrets)
+	require.Equal(t, testEnvoyConfig.SPIFFEID, envoyConfig.SPIFFEID)
+	require.Equal(t, testEnvoyConfig.TrustDomain, envoyConfig.TrustDomain)
+	require.Equal(t, testEnvoyConfig.NodeID, envoyConfig.NodeID)
+	require.Equal(t, testEnvoyConfig.NodeCluster, envoyConfig.NodeCluster)
+	require.Equal(t, testEnvoyConfig.EnvoyVersion, envoyConfig.EnvoyVersion)
 }
 
 func TestJSON(t *testing.T) {
@@ -82,6 +89,235 @@ func TestFetchConfig(t *testing.T) {
 	require.Equal(t, testEnvoyConfig.Secrets, envoyConfig.Secrets)
 }
 
+func TestFetchConfigWithClient_TLS(t *testing.T) {
+	configDump, err := fs.ReadFile(testConfigDump)
+	require.NoError(t, err)
+
+	clusters, err := fs.ReadFile(testClusters)
+	require.NoError(t, err)
+
+	mockServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/config_dump" {
+			w.Write(configDump)
+		}
+		if r.URL.Path == "/clusters" {
+			w.Write(clusters)
+		}
+	}))
+	defer mockServer.Close()
+
+	mpf := &mockPortForwarder{
+		openBehavior: func(ctx context.Context) (string, error) {
+			return strings.Replace(mockServer.URL, "https://", "", 1), nil
+		},
+	}
+
+	// Fetching over plain HTTP from a TLS server fails.
+	_, err = FetchConfigWithClient(context.Background(), mpf, mockServer.Client(), "http")
+	require.Error(t, err)
+
+	envoyConfig, err := FetchConfigWithClient(context.Background(), mpf, mockServer.Client(), "https")
+	require.NoError(t, err)
+
+	require.Equal(t, testEnvoyConfig.Clusters, envoyConfig.Clusters)
+	require.Equal(t, testEnvoyConfig.Endpoints, envoyConfig.Endpoints)
+	require.Equal(t, testEnvoyConfig.Listeners, envoyConfig.Listeners)
+	require.Equal(t, testEnvoyConfig.Routes, envoyConfig.Routes)
+	require.Equal(t, testEnvoyConfig.Secrets, envoyConfig.Secrets)
+}
+
+func TestFetchConfigWithoutEndpoints(t *testing.T) {
+	configDump, err := fs.ReadFile(testConfigDump)
+	require.NoError(t, err)
+
+	clusters, err := fs.ReadFile(testClusters)
+	require.NoError(t, err)
+
+	var configDumpQuery string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/config_dump" {
+			configDumpQuery = r.URL.RawQuery
+			w.Write(configDump)
+		}
+		if r.URL.Path == "/clusters" {
+			w.Write(clusters)
+		}
+	}))
+	defer mockServer.Close()
+
+	mpf := &mockPortForwarder{
+		openBehavior: func(ctx context.Context) (string, error) {
+			return strings.Replace(mockServer.URL, "http://", "", 1), nil
+		},
+	}
+
+	envoyConfig, err := FetchConfigWithoutEndpoints(context.Background(), mpf, http.DefaultClient, "http")
+	require.NoError(t, err)
+
+	// The endpoints are not requested from Envoy.
+	require.Empty(t, configDumpQuery)
+	require.Equal(t, testEnvoyConfig.Clusters, envoyConfig.Clusters)
+	require.Equal(t, testEnvoyConfig.Listeners, envoyConfig.Listeners)
+	require.Equal(t, testEnvoyConfig.Routes, envoyConfig.Routes)
+
+	_, err = FetchConfigWithClient(context.Background(), mpf, http.DefaultClient, "http")
+	require.NoError(t, err)
+	require.Equal(t, "include_eds", configDumpQuery)
+}
+
+func TestFetchClusterStats(t *testing.T) {
+	clusters, err := fs.ReadFile(testClusters)
+	require.NoError(t, err)
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/clusters" && r.URL.Query().Get("format") == "json" {
+			w.Write(clusters)
+		}
+	}))
+	defer mockServer.Close()
+
+	mpf := &mockPortForwarder{
+		openBehavior: func(ctx context.Context) (string, error) {
+			return strings.Replace(mockServer.URL, "http://", "", 1), nil
+		},
+	}
+
+	stats, err := FetchClusterStats(context.Background(), mpf, mockServer.Client(), "http")
+	require.NoError(t, err)
+
+	require.Equal(t, ClusterStats{ActiveConnections: 1, HealthyHosts: 1, Hosts: 1}, stats["local_agent"])
+	require.Equal(t, ClusterStats{HealthyHosts: 3, Hosts: 3}, stats["client.default.dc1.internal.bc3815c2-1a0f-f3ff-a2e9-20d791f08d00.consul"])
+	require.Equal(t, ClusterStats{}, stats["original-destination"])
+}
+
+func TestMergeClusterStats(t *testing.T) {
+	// A sample response from the /clusters?format=json endpoint with hosts
+	// which have failed outlier detection and active health checking.
+	clusters := `{
+  "cluster_statuses": [
+    {
+      "name": "backend.default.dc1.internal.consul",
+      "host_statuses": [
+        {
+          "address": {"socket_address": {"address": "10.0.0.1", "port_value": 20000}},
+          "stats": [
+            {"name": "cx_total", "value": "12"},
+            {"type": "GAUGE", "name": "cx_active", "value": "3"}
+          ],
+          "health_status": {"eds_health_status": "HEALTHY"}
+        },
+        {
+          "address": {"socket_address": {"address": "10.0.0.2", "port_value": 20000}},
+          "stats": [
+            {"type": "GAUGE", "name": "cx_active", "value": "2"}
+          ],
+          "health_status": {"eds_health_status": "HEALTHY", "failed_outlier_check": true}
+        },
+        {
+          "address": {"socket_address": {"address": "10.0.0.3", "port_value": 20000}},
+          "stats": [
+            {"type": "GAUGE", "name": "cx_active"}
+          ],
+          "health_status": {"eds_health_status": "UNHEALTHY", "failed_active_health_check": true}
+        }
+      ]
+    },
+    {
+      "name": "unknown.default.dc1.internal.consul",
+      "host_statuses": []
+    }
+  ]
+}`
+
+	stats, err := parseClusterStats([]byte(clusters))
+	require.NoError(t, err)
+
+	config := &EnvoyConfig{
+		Clusters: []Cluster{
+			{Name: "backend", FullyQualifiedDomainName: "backend.default.dc1.internal.consul"},
+			{Name: "frontend", FullyQualifiedDomainName: "frontend.default.dc1.internal.consul"},
+		},
+	}
+	MergeClusterStats(config, stats)
+
+	require.Equal(t, &ClusterStats{ActiveConnections: 5, HealthyHosts: 1, Hosts: 3}, config.Clusters[0].Stats)
+	require.Nil(t, config.Clusters[1].Stats)
+}
+
+func TestParseClusterStats_Invalid(t *testing.T) {
+	_, err := parseClusterStats([]byte("not json"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not a valid Envoy clusters response")
+}
+
+func TestCountConfig(t *testing.T) {
+	require.Equal(t, Counts{
+		ListenersInbound:  1,
+		ListenersOutbound: 1,
+		Clusters:          5,
+		EndpointsHealthy:  6,
+		Secrets:           2,
+	}, CountConfig(testEnvoyConfig))
+
+	config := &EnvoyConfig{
+		Endpoints: []Endpoint{
+			{Address: "192.168.18.110:20000", Status: "HEALTHY"},
+			{Address: "192.168.52.101:20000", Status: "UNHEALTHY"},
+			{Address: "192.168.65.131:20000", Status: "DRAINING"},
+		},
+		Listeners: []Listener{
+			{Name: "public_listener", Direction: "INBOUND"},
+			{Name: "upstream_a", Direction: "OUTBOUND"},
+			{Name: "upstream_b", Direction: "OUTBOUND"},
+			{Name: "unspecified", Direction: "UNSPECIFIED"},
+		},
+	}
+	require.Equal(t, Counts{
+		ListenersInbound:   1,
+		ListenersOutbound:  2,
+		EndpointsHealthy:   1,
+		EndpointsUnhealthy: 2,
+	}, CountConfig(config))
+}
+
+func TestStaticBootstrap(t *testing.T) {
+	raw, err := fs.ReadFile(testConfigDump)
+	require.NoError(t, err)
+	config, err := ParseConfig(raw)
+	require.NoError(t, err)
+
+	out, err := StaticBootstrap(config)
+	require.NoError(t, err)
+
+	var actual bootstrap
+	require.NoError(t, yaml.Unmarshal(out, &actual))
+	require.Len(t, actual.StaticResources.Clusters, 1)
+	require.Equal(t, "local_agent", actual.StaticResources.Clusters[0]["name"])
+	require.Equal(t, "STATIC", actual.StaticResources.Clusters[0]["type"])
+	require.NotContains(t, actual.StaticResources.Clusters[0], "@type")
+	require.Empty(t, actual.StaticResources.Listeners)
+
+	// Dynamic resources are left out.
+	config, err = ParseConfig([]byte(`{"configs": [
+		{
+			"@type": "type.googleapis.com/envoy.admin.v3.ClustersConfigDump",
+			"dynamic_active_clusters": [{"cluster": {"@type": "type.googleapis.com/envoy.config.cluster.v3.Cluster", "name": "dynamic"}}]
+		},
+		{
+			"@type": "type.googleapis.com/envoy.admin.v3.ListenersConfigDump",
+			"static_listeners": [{"listener": {"@type": "type.googleapis.com/envoy.config.listener.v3.Listener", "name": "prometheus"}}]
+		}
+	]}`))
+	require.NoError(t, err)
+
+	out, err = StaticBootstrap(config)
+	require.NoError(t, err)
+	require.Equal(t, `static_resources:
+  listeners:
+  - name: prometheus
+`, string(out))
+}
+
 // There are many protobuf types for filter extensions. This test ensures
 // that the different types are formatted correctly.
 func TestFormatFilters(t *testing.T) {
@@ -446,12 +682,106 @@ func TestClusterParsingEndpoints(t *testing.T) {
 	require.Equal(t, expected, actual)
 }
 
+// TestParseSPIFFEID checks that the SPIFFE ID is only read from secrets with a
+// certificate chain that contains one.
+func TestParseSPIFFEID(t *testing.T) {
+	notPEM := base64.StdEncoding.EncodeToString([]byte("not a certificate"))
+
+	cases := map[string]struct {
+		rawCfg         map[string]interface{}
+		expSPIFFEID    string
+		expTrustDomain string
+	}{
+		"no secrets": {
+			rawCfg: map[string]interface{}{},
+		},
+		"secret without a certificate chain": {
+			rawCfg: map[string]interface{}{
+				"dynamic_active_secrets": []map[string]interface{}{
+					{"name": "ROOTCA", "secret": map[string]interface{}{"validation_context": map[string]interface{}{}}},
+				},
+			},
+		},
+		"certificate chain which is not PEM-encoded": {
+			rawCfg: map[string]interface{}{
+				"dynamic_active_secrets": []map[string]interface{}{
+					{"name": "default", "secret": map[string]interface{}{
+						"tls_certificate": map[string]interface{}{
+							"certificate_chain": map[string]interface{}{"inline_bytes": notPEM},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			spiffeID, trustDomain := parseSPIFFEID(tc.rawCfg)
+			require.Equal(t, tc.expSPIFFEID, spiffeID)
+			require.Equal(t, tc.expTrustDomain, trustDomain)
+		})
+	}
+}
+
+func TestParseBootstrapNode(t *testing.T) {
+	cases := map[string]struct {
+		rawCfg     map[string]interface{}
+		expID      string
+		expCluster string
+		expVersion string
+	}{
+		"no node": {
+			rawCfg: map[string]interface{}{"bootstrap": map[string]interface{}{}},
+		},
+		"node without a build version": {
+			rawCfg: map[string]interface{}{
+				"bootstrap": map[string]interface{}{
+					"node": map[string]interface{}{"id": "web-sidecar-proxy", "cluster": "web"},
+				},
+			},
+			expID:      "web-sidecar-proxy",
+			expCluster: "web",
+		},
+		"node with a build version": {
+			rawCfg: map[string]interface{}{
+				"bootstrap": map[string]interface{}{
+					"node": map[string]interface{}{
+						"id":      "web-sidecar-proxy",
+						"cluster": "web",
+						"user_agent_build_version": map[string]interface{}{
+							"version": map[string]interface{}{"major_number": 1, "minor_number": 23, "patch": 1},
+						},
+					},
+				},
+			},
+			expID:      "web-sidecar-proxy",
+			expCluster: "web",
+			expVersion: "1.23.1",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			id, cluster, version := parseBootstrapNode(tc.rawCfg)
+			require.Equal(t, tc.expID, id)
+			require.Equal(t, tc.expCluster, cluster)
+			require.Equal(t, tc.expVersion, version)
+		})
+	}
+}
+
 type mockPortForwarder struct {
-	openBehavior func(context.Context) (string, error)
+	openBehavior  func(context.Context) (string, error)
+	closeBehavior func()
 }
 
 func (m *mockPortForwarder) Open(ctx context.Context) (string, error) { return m.openBehavior(ctx) }
-func (m *mockPortForwarder) Close()                                   {}
+func (m *mockPortForwarder) Close() {
+	if m.closeBehavior != nil {
+		m.closeBehavior()
+	}
+}
 
 func rawEnvoyConfig(t *testing.T) []byte {
 	configDump, err := fs.ReadFile(testConfigDump)
@@ -467,10 +797,10 @@ func rawEnvoyConfig(t *testing.T) []byte {
 var testEnvoyConfig = &EnvoyConfig{
 	Clusters: []Cluster{
 		{Name: "local_agent", FullyQualifiedDomainName: "local_agent", Endpoints: []string{"192.168.79.187:8502"}, Type: "STATIC", LastUpdated: "2022-05-13T04:22:39.553Z"},
-		{Name: "client", FullyQualifiedDomainName: "client.default.dc1.internal.bc3815c2-1a0f-f3ff-a2e9-20d791f08d00.consul", Endpoints: []string{"192.168.18.110:20000", "192.168.52.101:20000", "192.168.65.131:20000"}, Type: "EDS", LastUpdated: "2022-08-10T12:30:32.326Z"},
-		{Name: "frontend", FullyQualifiedDomainName: "frontend.default.dc1.internal.bc3815c2-1a0f-f3ff-a2e9-20d791f08d00.consul", Endpoints: []string{"192.168.63.120:20000"}, Type: "EDS", LastUpdated: "2022-08-10T12:30:32.233Z"},
-		{Name: "local_app", FullyQualifiedDomainName: "local_app", Endpoints: []string{"127.0.0.1:8080"}, Type: "STATIC", LastUpdated: "2022-05-13T04:22:39.655Z"},
-		{Name: "original-destination", FullyQualifiedDomainName: "original-destination", Endpoints: []string{}, Type: "ORIGINAL_DST", LastUpdated: "2022-05-13T04:22:39.743Z"},
+		{Name: "client", FullyQualifiedDomainName: "client.default.dc1.internal.bc3815c2-1a0f-f3ff-a2e9-20d791f08d00.consul", Endpoints: []string{"192.168.18.110:20000", "192.168.52.101:20000", "192.168.65.131:20000"}, Type: "EDS", MaxConnections: 1024, MaxRequests: 512, LastUpdated: "2022-08-10T12:30:32.326Z", VersionInfo: "2eee24224b508d5e77766867b5ad793bc4555abce4d3fa564da125617c68e46a"},
+		{Name: "frontend", FullyQualifiedDomainName: "frontend.default.dc1.internal.bc3815c2-1a0f-f3ff-a2e9-20d791f08d00.consul", Endpoints: []string{"192.168.63.120:20000"}, Type: "EDS", LastUpdated: "2022-08-10T12:30:32.233Z", VersionInfo: "70b55eac9f1c87dfb54a56d1624279740ddaa2ab72ce7f0baf024b089c396acc"},
+		{Name: "local_app", FullyQualifiedDomainName: "local_app", Endpoints: []string{"127.0.0.1:8080"}, Type: "STATIC", LastUpdated: "2022-05-13T04:22:39.655Z", VersionInfo: "642b6322013fd3f776d9644ece515db5c4901fe6a87575ab4ff07498ab5faa47"},
+		{Name: "original-destination", FullyQualifiedDomainName: "original-destination", Endpoints: []string{}, Type: "ORIGINAL_DST", LastUpdated: "2022-05-13T04:22:39.743Z", VersionInfo: "dcd8d0247ba0149bfdc151428353b3f29d0665bf5c12af6a105a0abcc5af40ac"},
 	},
 	Endpoints: []Endpoint{
 		{Address: "192.168.79.187:8502", Cluster: "local_agent", Weight: 1, Status: "HEALTHY"},
@@ -481,12 +811,12 @@ var testEnvoyConfig = &EnvoyConfig{
 		{Address: "127.0.0.1:8080", Cluster: "local_app", Weight: 1, Status: "HEALTHY"},
 	},
 	Listeners: []Listener{
-		{Name: "public_listener", Address: "192.168.69.179:20000", FilterChain: []FilterChain{{Filters: []string{"HTTP: * -> local_app/"}, FilterChainMatch: "Any"}}, Direction: "INBOUND", LastUpdated: "2022-08-10T12:30:47.142Z"},
+		{Name: "public_listener", Address: "192.168.69.179:20000", FilterChain: []FilterChain{{Filters: []string{"HTTP: * -> local_app/"}, FilterChainMatch: "Any"}}, Direction: "INBOUND", LastUpdated: "2022-08-10T12:30:47.142Z", VersionInfo: "42e63fea110536be20b84ab28ef1979efb5e20b967a752b8834314c4fcd58358"},
 		{Name: "outbound_listener", Address: "127.0.0.1:15001", FilterChain: []FilterChain{
 			{Filters: []string{"TCP: -> client"}, FilterChainMatch: "10.100.134.173/32, 240.0.0.3/32"},
 			{Filters: []string{"TCP: -> frontend"}, FilterChainMatch: "10.100.31.2/32, 240.0.0.5/32"},
 			{Filters: []string{"TCP: -> original-destination"}, FilterChainMatch: "Any"},
-		}, Direction: "OUTBOUND", LastUpdated: "2022-07-18T15:31:03.246Z"},
+		}, Direction: "OUTBOUND", LastUpdated: "2022-07-18T15:31:03.246Z", VersionInfo: "2bce2cd7828d5b1adbd820824ce6948fdaa00b4c824cba5ba0932aea95b7f5cd"},
 	},
 	Routes: []Route{
 		{
@@ -507,4 +837,9 @@ var testEnvoyConfig = &EnvoyConfig{
 			LastUpdated: "2022-03-15T05:14:22.868Z",
 		},
 	},
+	SPIFFEID:     "spiffe://cluster.local/ns/foo/sa/default",
+	TrustDomain:  "cluster.local",
+	NodeID:       "backend-658b679b45-d5xlb-backend-sidecar-proxy",
+	NodeCluster:  "backend",
+	EnvoyVersion: "1.22.0",
 }
@@ -24,14 +24,26 @@ type clustersConfigDump struct {
 }
 
 type clusterConfig struct {
+	VersionInfo string      `json:"version_info"`
 	Cluster     clusterMeta `json:"cluster"`
 	LastUpdated string      `json:"last_updated"`
 }
 
 type clusterMeta struct {
-	FQDN           string         `json:"name"`
-	ClusterType    string         `json:"type"`
-	LoadAssignment loadAssignment `json:"load_assignment"`
+	FQDN            string          `json:"name"`
+	ClusterType     string          `json:"type"`
+	LoadAssignment  loadAssignment  `json:"load_assignment"`
+	CircuitBreakers circuitBreakers `json:"circuit_breakers"`
+}
+
+type circuitBreakers struct {
+	Thresholds []threshold `json:"thresholds"`
+}
+
+type threshold struct {
+	Priority       string `json:"priority"`
+	MaxConnections int    `json:"max_connections"`
+	MaxRequests    int    `json:"max_requests"`
 }
 
 type loadAssignment struct {
@@ -89,6 +101,7 @@ type dynamicConfig struct {
 }
 
 type listenerConfig struct {
+	VersionInfo string   `json:"version_info"`
 	Listener    listener `json:"listener"`
 	LastUpdated string   `json:"last_updated"`
 }
@@ -277,6 +290,31 @@ type filterRateLimitServiceConfig struct {
 	GrpcService filterGrpcService `json:"grpc_service"`
 }
 
+type bootstrapConfigDump struct {
+	ConfigType string          `json:"@type"`
+	Bootstrap  bootstrapConfig `json:"bootstrap"`
+}
+
+type bootstrapConfig struct {
+	Node node `json:"node"`
+}
+
+type node struct {
+	ID                    string       `json:"id"`
+	Cluster               string       `json:"cluster"`
+	UserAgentBuildVersion buildVersion `json:"user_agent_build_version"`
+}
+
+type buildVersion struct {
+	Version semanticVersion `json:"version"`
+}
+
+type semanticVersion struct {
+	MajorNumber int `json:"major_number"`
+	MinorNumber int `json:"minor_number"`
+	Patch       int `json:"patch"`
+}
+
 type secretsConfigDump struct {
 	ConfigType            string            `json:"@type"`
 	StaticSecrets         []secretConfigMap `json:"static_secrets"`
@@ -286,6 +324,7 @@ type secretsConfigDump struct {
 
 type secretConfigMap struct {
 	Name        string `json:"name"`
+	VersionInfo string `json:"version_info"`
 	Secret      secret `json:"secret"`
 	LastUpdated string `json:"last_updated"`
 }
@@ -322,5 +361,47 @@ type clusterStatus struct {
 }
 
 type hostStatus struct {
-	Address address `json:"address"`
+	Address      address          `json:"address"`
+	Stats        []hostStat       `json:"stats"`
+	HealthStatus hostHealthStatus `json:"health_status"`
+}
+
+type hostStat struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type hostHealthStatus struct {
+	EDSHealthStatus         string `json:"eds_health_status"`
+	FailedActiveHealthCheck bool   `json:"failed_active_health_check"`
+	FailedOutlierCheck      bool   `json:"failed_outlier_check"`
+}
+
+// staticConfigDump holds the static clusters and listeners of a section of the
+// config dump. They are kept as generic maps so that they can be written back
+// out unchanged as part of a bootstrap configuration.
+type staticConfigDump struct {
+	StaticClusters  []staticClusterConfig  `json:"static_clusters"`
+	StaticListeners []staticListenerConfig `json:"static_listeners"`
+}
+
+type staticClusterConfig struct {
+	Cluster map[string]interface{} `json:"cluster"`
+}
+
+type staticListenerConfig struct {
+	Listener map[string]interface{} `json:"listener"`
+}
+
+// bootstrap is the subset of the Envoy bootstrap configuration written out by
+// StaticBootstrap.
+// https://www.envoyproxy.io/docs/envoy/latest/api-v3/config/bootstrap/v3/bootstrap.proto
+type bootstrap struct {
+	StaticResources staticResources `json:"static_resources"`
+}
+
+type staticResources struct {
+	Listeners []map[string]interface{} `json:"listeners,omitempty"`
+	Clusters  []map[string]interface{} `json:"clusters,omitempty"`
 }
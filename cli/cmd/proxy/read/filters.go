@@ -75,6 +75,20 @@ func FilterEndpoints(endpoints []Endpoint, address string, port int) []Endpoint
 	return filtered
 }
 
+// FilterUnhealthyEndpoints returns only the endpoints which have a health
+// status other than HEALTHY. Endpoints without a health status are not health
+// checked by Envoy and are considered healthy.
+func FilterUnhealthyEndpoints(endpoints []Endpoint) []Endpoint {
+	filtered := make([]Endpoint, 0)
+	for _, endpoint := range endpoints {
+		if endpoint.Status != "" && endpoint.Status != "HEALTHY" {
+			filtered = append(filtered, endpoint)
+		}
+	}
+
+	return filtered
+}
+
 // FilterListeners takes a slice of listeners along with parameters for filtering
 // those endpoints:
 //
@@ -337,3 +337,20 @@ func TestFilterListeners(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterUnhealthyEndpoints(t *testing.T) {
+	given := []Endpoint{
+		{Address: "192.168.79.187:8502"},
+		{Address: "192.168.31.201:20000", Status: "HEALTHY"},
+		{Address: "192.168.47.235:20000", Status: "UNHEALTHY"},
+		{Address: "192.168.71.254:20000", Status: "DEGRADED"},
+	}
+
+	expected := []Endpoint{
+		{Address: "192.168.47.235:20000", Status: "UNHEALTHY"},
+		{Address: "192.168.71.254:20000", Status: "DEGRADED"},
+	}
+
+	require.Equal(t, expected, FilterUnhealthyEndpoints(given))
+	require.Empty(t, FilterUnhealthyEndpoints(given[:2]))
+}
@@ -7,45 +7,179 @@ import (
 	"github.com/hashicorp/consul-k8s/cli/common/terminal"
 )
 
-func formatClusters(clusters []Cluster) *terminal.Table {
-	table := terminal.NewTable("Name", "FQDN", "Endpoints", "Type", "Last Updated")
+func formatClusters(clusters []Cluster, showVersion, showStats bool) *terminal.Table {
+	headers := []string{"Name", "FQDN", "Endpoints", "Type", "Max Connections", "Max Requests"}
+	if showStats {
+		headers = append(headers, "Active Connections", "Healthy Hosts")
+	}
+	table := terminal.NewTable(withVersionHeader(showVersion, append(headers, "Last Updated")...)...)
 	for _, cluster := range clusters {
-		table.AddRow([]string{cluster.Name, cluster.FullyQualifiedDomainName, strings.Join(cluster.Endpoints, ", "),
-			cluster.Type, cluster.LastUpdated}, []string{})
+		row := []string{cluster.Name, cluster.FullyQualifiedDomainName, strings.Join(cluster.Endpoints, ", "),
+			cluster.Type, formatThreshold(cluster.MaxConnections), formatThreshold(cluster.MaxRequests)}
+		if showStats {
+			row = append(row, formatClusterStats(cluster.Stats)...)
+		}
+		row = append(row, cluster.LastUpdated)
+		if showVersion {
+			row = append(row, cluster.VersionInfo)
+		}
+		table.AddRow(row, []string{})
 	}
 
 	return table
 }
 
+// withVersionHeader appends the Version header to the given headers if the
+// xDS version_info of each entry is shown.
+func withVersionHeader(showVersion bool, headers ...string) []string {
+	if showVersion {
+		return append(headers, "Version")
+	}
+	return headers
+}
+
+// formatClusterStats formats the active connections and healthy hosts of a
+// cluster, leaving them blank if the cluster has no stats.
+func formatClusterStats(stats *ClusterStats) []string {
+	if stats == nil {
+		return []string{"", ""}
+	}
+	return []string{fmt.Sprintf("%d", stats.ActiveConnections), fmt.Sprintf("%d/%d", stats.HealthyHosts, stats.Hosts)}
+}
+
+// formatThreshold formats a circuit breaker threshold, leaving it blank if
+// the threshold is not set.
+func formatThreshold(threshold int) string {
+	if threshold == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", threshold)
+}
+
 func formatEndpoints(endpoints []Endpoint) *terminal.Table {
 	table := terminal.NewTable("Address:Port", "Cluster", "Weight", "Status")
 	for _, endpoint := range endpoints {
-		var statusColor string
-		if endpoint.Status == "HEALTHY" {
-			statusColor = "green"
-		} else {
-			statusColor = "red"
-		}
-
 		table.AddRow(
 			[]string{endpoint.Address, endpoint.Cluster, fmt.Sprintf("%.2f", endpoint.Weight), endpoint.Status},
-			[]string{"", "", "", statusColor})
+			[]string{"", "", "", endpointStatusColor(endpoint.Status)})
 	}
 
 	return table
 }
 
-func formatListeners(listeners []Listener) *terminal.Table {
-	table := terminal.NewTable("Name", "Address:Port", "Direction", "Filter Chain Match", "Filters", "Last Updated")
+// endpointStatusColor returns the color used to display an endpoint's health
+// status. Healthy endpoints are green and unhealthy or degraded endpoints are
+// red. Transitional or unknown statuses are yellow.
+func endpointStatusColor(status string) string {
+	switch status {
+	case "HEALTHY":
+		return terminal.Green
+	case "UNHEALTHY", "DEGRADED", "TIMEOUT":
+		return terminal.Red
+	default:
+		return terminal.Yellow
+	}
+}
+
+// withoutColors removes the colors from every cell of the table so that it is
+// rendered as plain text.
+func withoutColors(table *terminal.Table) *terminal.Table {
+	for _, row := range table.Rows {
+		for i := range row {
+			row[i].Color = ""
+		}
+	}
+
+	return table
+}
+
+// ellipsis replaces the end of cell values which are truncated.
+const ellipsis = "…"
+
+// truncateCells shortens every cell value of the table which is longer than
+// maxWidth characters, replacing its end with an ellipsis. Each line of a
+// multi-line cell is truncated separately. A maxWidth of 0 leaves the table
+// unchanged.
+func truncateCells(table *terminal.Table, maxWidth int) *terminal.Table {
+	if maxWidth <= 0 {
+		return table
+	}
+
+	for _, row := range table.Rows {
+		for i := range row {
+			lines := strings.Split(row[i].Value, "\n")
+			for j, line := range lines {
+				lines[j] = truncate(line, maxWidth)
+			}
+			row[i].Value = strings.Join(lines, "\n")
+		}
+	}
+
+	return table
+}
+
+// truncate shortens the value to at most maxWidth characters, ending it with
+// an ellipsis if anything was cut off.
+func truncate(value string, maxWidth int) string {
+	runes := []rune(value)
+	if len(runes) <= maxWidth {
+		return value
+	}
+	if maxWidth == 1 {
+		return ellipsis
+	}
+	return string(runes[:maxWidth-1]) + ellipsis
+}
+
+// formatMarkdown renders the table as a GitHub-flavored Markdown table. Pipes
+// in cell values are escaped and multi-line cells are joined with <br> so that
+// each row stays on a single line. Rows with fewer cells than headers are
+// padded with empty cells.
+func formatMarkdown(table *terminal.Table) string {
+	var b strings.Builder
+	writeMarkdownRow(&b, table.Headers)
+	separators := make([]string, len(table.Headers))
+	for i := range separators {
+		separators[i] = "---"
+	}
+	writeMarkdownRow(&b, separators)
+
+	for _, row := range table.Rows {
+		values := make([]string, len(table.Headers))
+		for i := 0; i < len(row) && i < len(values); i++ {
+			values[i] = row[i].Value
+		}
+		writeMarkdownRow(&b, values)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// markdownEscaper escapes the characters of a cell value which would break
+// the structure of a Markdown table.
+var markdownEscaper = strings.NewReplacer("|", "\\|", "\n", "<br>")
+
+func writeMarkdownRow(b *strings.Builder, values []string) {
+	b.WriteString("|")
+	for _, value := range values {
+		b.WriteString(" " + markdownEscaper.Replace(value) + " |")
+	}
+	b.WriteString("\n")
+}
+
+func formatListeners(listeners []Listener, showVersion bool) *terminal.Table {
+	table := terminal.NewTable(withVersionHeader(showVersion, "Name", "Address:Port", "Direction", "Filter Chain Match", "Filters", "Last Updated")...)
 	for _, listener := range listeners {
 		for index, filter := range listener.FilterChain {
 			// Print each element of the filter chain in a separate line
 			// without repeating the name, address, etc.
 			filters := strings.Join(filter.Filters, "\n")
 			if index == 0 {
-				table.AddRow(
-					[]string{listener.Name, listener.Address, listener.Direction, filter.FilterChainMatch, filters, listener.LastUpdated},
-					[]string{})
+				row := []string{listener.Name, listener.Address, listener.Direction, filter.FilterChainMatch, filters, listener.LastUpdated}
+				if showVersion {
+					row = append(row, listener.VersionInfo)
+				}
+				table.AddRow(row, []string{})
 			} else {
 				table.AddRow(
 					[]string{"", "", "", filter.FilterChainMatch, filters},
@@ -66,10 +200,14 @@ func formatRoutes(routes []Route) *terminal.Table {
 	return table
 }
 
-func formatSecrets(secrets []Secret) *terminal.Table {
-	table := terminal.NewTable("Name", "Type", "Last Updated")
+func formatSecrets(secrets []Secret, showVersion bool) *terminal.Table {
+	table := terminal.NewTable(withVersionHeader(showVersion, "Name", "Type", "Last Updated")...)
 	for _, secret := range secrets {
-		table.AddRow([]string{secret.Name, secret.Type, secret.LastUpdated}, []string{})
+		row := []string{secret.Name, secret.Type, secret.LastUpdated}
+		if showVersion {
+			row = append(row, secret.VersionInfo)
+		}
+		table.AddRow(row, []string{})
 	}
 
 	return table
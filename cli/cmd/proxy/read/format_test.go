@@ -12,10 +12,10 @@ import (
 func TestFormatClusters(t *testing.T) {
 	// These regular expressions must be present in the output.
 	expected := []string{
-		"Name.*FQDN.*Endpoints.*Type.*Last Updated",
+		"Name.*FQDN.*Endpoints.*Type.*Max Connections.*Max Requests.*Last Updated",
 		"local_agent.*local_agent.*192\\.168\\.79\\.187:8502.*STATIC.*2022-05-13T04:22:39\\.553Z",
 		"local_app.*local_app.*127\\.0\\.0\\.1:8080.*STATIC.*2022-05-13T04:22:39\\.655Z",
-		"client.*client\\.default\\.dc1\\.internal\\.bc3815c2-1a0f-f3ff-a2e9-20d791f08d00\\.consul.*EDS.*2022-06-09T00:39:12\\.948Z",
+		"client.*client\\.default\\.dc1\\.internal\\.bc3815c2-1a0f-f3ff-a2e9-20d791f08d00\\.consul.*EDS.*1024.*512.*2022-06-09T00:39:12\\.948Z",
 		"frontend.*frontend\\.default\\.dc1\\.internal\\.bc3815c2-1a0f-f3ff-a2e9-20d791f08d00\\.consul.*EDS.*2022-06-09T00:39:12\\.855Z",
 		"original-destination.*original-destination.*ORIGINAL_DST.*2022-05-13T04:22:39.743Z",
 		"server.*server.default.dc1.internal.bc3815c2-1a0f-f3ff-a2e9-20d791f08d00.consul.*EDS.*2022-06-09T00:39:12\\.754Z",
@@ -34,6 +34,8 @@ func TestFormatClusters(t *testing.T) {
 			FullyQualifiedDomainName: "client.default.dc1.internal.bc3815c2-1a0f-f3ff-a2e9-20d791f08d00.consul",
 			Endpoints:                []string{},
 			Type:                     "EDS",
+			MaxConnections:           1024,
+			MaxRequests:              512,
 			LastUpdated:              "2022-06-09T00:39:12.948Z",
 		},
 		{
@@ -66,9 +68,9 @@ func TestFormatClusters(t *testing.T) {
 		},
 	}
 
-	expectedHeaders := []string{"Name", "FQDN", "Endpoints", "Type", "Last Updated"}
+	expectedHeaders := []string{"Name", "FQDN", "Endpoints", "Type", "Max Connections", "Max Requests", "Last Updated"}
 
-	table := formatClusters(given)
+	table := formatClusters(given, false, false)
 
 	require.Equal(t, expectedHeaders, table.Headers)
 	require.Equal(t, len(given), len(table.Rows))
@@ -163,6 +165,31 @@ func TestFormatEndpoints(t *testing.T) {
 	}
 }
 
+func TestEndpointStatusColor(t *testing.T) {
+	cases := map[string]string{
+		"HEALTHY":   terminal.Green,
+		"UNHEALTHY": terminal.Red,
+		"DEGRADED":  terminal.Red,
+		"TIMEOUT":   terminal.Red,
+		"DRAINING":  terminal.Yellow,
+		"UNKNOWN":   terminal.Yellow,
+	}
+
+	for status, expected := range cases {
+		t.Run(status, func(t *testing.T) {
+			require.Equal(t, expected, endpointStatusColor(status))
+
+			table := formatEndpoints([]Endpoint{{Address: "127.0.0.1:8080", Status: status}})
+			require.Equal(t, expected, table.Rows[0][3].Color)
+
+			table = withoutColors(table)
+			for _, cell := range table.Rows[0] {
+				require.Empty(t, cell.Color)
+			}
+		})
+	}
+}
+
 func TestFormatListeners(t *testing.T) {
 	// These regular expressions must be present in the output.
 	expected := []string{
@@ -223,7 +250,7 @@ func TestFormatListeners(t *testing.T) {
 		expectedRowCount += len(element.FilterChain)
 	}
 
-	table := formatListeners(given)
+	table := formatListeners(given, false)
 
 	require.Equal(t, expectedHeaders, table.Headers)
 	require.Equal(t, expectedRowCount, len(table.Rows))
@@ -297,7 +324,7 @@ func TestFormatSecrets(t *testing.T) {
 
 	expectedHeaders := []string{"Name", "Type", "Last Updated"}
 
-	table := formatSecrets(given)
+	table := formatSecrets(given, false)
 
 	require.Equal(t, expectedHeaders, table.Headers)
 	require.Equal(t, len(given), len(table.Rows))
@@ -310,3 +337,53 @@ func TestFormatSecrets(t *testing.T) {
 		require.Regexp(t, expression, actual)
 	}
 }
+
+func TestTruncateCells(t *testing.T) {
+	cases := map[string]struct {
+		maxWidth int
+		expected []string
+	}{
+		"no truncation": {
+			maxWidth: 0,
+			expected: []string{"short", "client.default.dc1.internal.consul", "filter-one\nfilter-two-is-long"},
+		},
+		"values longer than the width are truncated": {
+			maxWidth: 10,
+			expected: []string{"short", "client.de…", "filter-one\nfilter-tw…"},
+		},
+		"width of one": {
+			maxWidth: 1,
+			expected: []string{"…", "…", "…\n…"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			table := terminal.NewTable("Short", "FQDN", "Filters")
+			table.AddRow([]string{"short", "client.default.dc1.internal.consul", "filter-one\nfilter-two-is-long"}, []string{})
+
+			table = truncateCells(table, tc.maxWidth)
+
+			var actual []string
+			for _, cell := range table.Rows[0] {
+				actual = append(actual, cell.Value)
+			}
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestFormatMarkdown(t *testing.T) {
+	table := terminal.NewTable("Name", "Address:Port", "Filters")
+	table.AddRow([]string{"public_listener", "192.168.69.179:20000", "filter-one\nfilter-two"}, []string{})
+	table.AddRow([]string{"", "", "a|b"}, []string{})
+	table.AddRow([]string{"short"}, []string{})
+
+	expected := `| Name | Address:Port | Filters |
+| --- | --- | --- |
+| public_listener | 192.168.69.179:20000 | filter-one<br>filter-two |
+|  |  | a\|b |
+| short |  |  |`
+
+	require.Equal(t, expected, formatMarkdown(table))
+}
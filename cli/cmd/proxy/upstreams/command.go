@@ -0,0 +1,214 @@
+package upstreams
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	"k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// upstreamsAnnotation lists the upstreams of a Pod's proxy. It must match the
+// annotation read by the endpoints controller.
+const upstreamsAnnotation = "consul.hashicorp.com/connect-service-upstreams"
+
+// UpstreamsCommand is the command struct for the proxy upstreams command.
+type UpstreamsCommand struct {
+	*common.BaseCommand
+
+	kubernetes kubernetes.Interface
+
+	set *flag.Sets
+
+	// Command Flags
+	flagNamespace  string
+	flagPodName    string
+	flagEnterprise bool
+
+	// Global Flags
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+// init sets up flags and help text for the command.
+func (c *UpstreamsCommand) init() {
+	c.set = flag.NewSets()
+
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:    "namespace",
+		Target:  &c.flagNamespace,
+		Usage:   "The namespace where the target Pod can be found.",
+		Aliases: []string{"n"},
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:   "enterprise",
+		Target: &c.flagEnterprise,
+		Usage:  "Parse the Consul namespaces and admin partitions of upstreams, as the injector does when Consul Enterprise namespaces or admin partitions are enabled.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:    "kubeconfig",
+		Aliases: []string{"c"},
+		Target:  &c.flagKubeConfig,
+		Default: "",
+		Usage:   "Set the path to kubeconfig file.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    "context",
+		Target:  &c.flagKubeContext,
+		Default: "",
+		Usage:   "Set the Kubernetes context to use.",
+	})
+
+	c.help = c.set.Help()
+}
+
+// Run executes the upstreams command.
+func (c *UpstreamsCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("upstreams")
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.parseFlags(args); err != nil {
+		c.UI.Output("Error parsing arguments: %v", err.Error(), terminal.WithErrorStyle())
+		c.UI.Output("\n" + c.Help())
+		return 1
+	}
+
+	if err := c.validateFlags(); err != nil {
+		c.UI.Output("Invalid argument: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if err := c.initKubernetes(); err != nil {
+		c.UI.Output("Error initializing Kubernetes client", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if err := c.outputUpstreams(); err != nil {
+		c.UI.Output("Error reading upstreams for Pod %s: %v", c.flagPodName, err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	return 0
+}
+
+// Help returns a description of the command and how it is used.
+func (c *UpstreamsCommand) Help() string {
+	c.once.Do(c.init)
+	return fmt.Sprintf("%s\n\nUsage: consul-k8s proxy upstreams <pod-name> [flags]\n\n"+
+		"Parses the %s annotation of the Pod in the same way as the endpoints controller, which is useful for "+
+		"debugging the annotation. Consul is not contacted, so upstream defaults of the namespace or the injector "+
+		"are not included.\n\n%s", c.Synopsis(), upstreamsAnnotation, c.help)
+}
+
+// Synopsis returns a one-line command summary.
+func (c *UpstreamsCommand) Synopsis() string {
+	return "Print the upstreams parsed from the annotation of a given Pod."
+}
+
+func (c *UpstreamsCommand) parseFlags(args []string) error {
+	// Separate positional arguments from keyed arguments.
+	positional := []string{}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			break
+		}
+		positional = append(positional, arg)
+	}
+	keyed := args[len(positional):]
+
+	if err := c.set.Parse(keyed); err != nil {
+		return err
+	}
+
+	if len(positional) != 1 {
+		return fmt.Errorf("Exactly one positional argument is required: <pod-name>")
+	}
+	c.flagPodName = positional[0]
+
+	return nil
+}
+
+// validateFlags ensures that the flags passed in by the user can be used.
+func (c *UpstreamsCommand) validateFlags() error {
+	if errs := validation.ValidateNamespaceName(c.flagNamespace, false); c.flagNamespace != "" && len(errs) > 0 {
+		return fmt.Errorf("invalid namespace name passed for -namespace/-n: %v", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// initKubernetes initializes the Kubernetes client and defaults the namespace
+// to the one of the current context.
+func (c *UpstreamsCommand) initKubernetes() error {
+	settings := helmCLI.New()
+
+	if c.flagKubeConfig != "" {
+		settings.KubeConfig = c.flagKubeConfig
+	}
+
+	if c.flagKubeContext != "" {
+		settings.KubeContext = c.flagKubeContext
+	}
+
+	if c.kubernetes == nil {
+		restConfig, err := settings.RESTClientGetter().ToRESTConfig()
+		if err != nil {
+			return fmt.Errorf("error creating Kubernetes REST config %v", err)
+		}
+		if c.kubernetes, err = kubernetes.NewForConfig(restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+
+	if c.flagNamespace == "" {
+		c.flagNamespace = settings.Namespace()
+	}
+
+	return nil
+}
+
+// outputUpstreams fetches the Pod and prints a table of the upstreams parsed
+// from its annotation.
+func (c *UpstreamsCommand) outputUpstreams() error {
+	pod, err := c.kubernetes.CoreV1().Pods(c.flagNamespace).Get(c.Ctx, c.flagPodName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	raw := pod.Annotations[upstreamsAnnotation]
+	if strings.TrimSpace(raw) == "" {
+		c.UI.Output("Pod %s has no %s annotation.", c.flagPodName, upstreamsAnnotation, terminal.WithInfoStyle())
+		return nil
+	}
+
+	upstreams, ignored, err := parseUpstreams(pod, raw, c.flagEnterprise)
+	if err != nil {
+		return err
+	}
+
+	tbl := terminal.NewTable("Name", "Type", "Namespace", "Partition", "Peer", "Datacenter", "Local Port")
+	for _, u := range upstreams {
+		tbl.AddRow([]string{u.DestinationName, string(u.DestinationType), u.DestinationNamespace,
+			u.DestinationPartition, u.DestinationPeer, u.Datacenter, strconv.Itoa(u.LocalBindPort)}, []string{})
+	}
+	c.UI.Table(tbl)
+
+	for _, u := range ignored {
+		c.UI.Output("Upstream %q is ignored because its local port can't be resolved.", u, terminal.WithWarningStyle())
+	}
+
+	return nil
+}
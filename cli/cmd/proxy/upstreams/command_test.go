@@ -0,0 +1,279 @@
+package upstreams
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFlagParsing(t *testing.T) {
+	cases := map[string]struct {
+		args []string
+		out  int
+	}{
+		"No args": {
+			args: []string{},
+			out:  1,
+		},
+		"Multiple pod names passed": {
+			args: []string{"web", "api"},
+			out:  1,
+		},
+		"Nonexistent flag passed, -foo bar": {
+			args: []string{"web", "-foo", "bar"},
+			out:  1,
+		},
+		"Invalid argument passed, -namespace YOLO": {
+			args: []string{"web", "-namespace", "YOLO"},
+			out:  1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := setupCommand(new(bytes.Buffer))
+			c.kubernetes = fake.NewSimpleClientset()
+			out := c.Run(tc.args)
+			require.Equal(t, tc.out, out)
+		})
+	}
+}
+
+func TestUpstreamsCommand(t *testing.T) {
+	pod := func(name, upstreams string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "default",
+				Annotations: map[string]string{upstreamsAnnotation: upstreams},
+			},
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{
+					{
+						Name:  "web",
+						Ports: []v1.ContainerPort{{Name: "cache-port", ContainerPort: 6379}},
+					},
+				},
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		args        []string
+		expExitCode int
+		expected    []string
+	}{
+		"Several upstream forms": {
+			args: []string{"web", "-namespace", "default"},
+			expected: []string{
+				`(?m)^\s*api\s+service\s+1234\s*$`,
+				`(?m)^\s*db\s+service\s+dc2\s+5432\s*$`,
+				`(?m)^\s*cache\s+service\s+6379\s*$`,
+				`(?m)^\s*billing\s+service\s+peer1\s+9090\s*$`,
+				`(?m)^\s*geo\s+prepared_query\s+8500\s*$`,
+				`Upstream "unknown:not-a-port" is ignored because its local port can't be resolved.`,
+			},
+		},
+		"Enterprise": {
+			args: []string{"web-ent", "-namespace", "default", "-enterprise"},
+			expected: []string{
+				`(?m)^\s*api\s+service\s+ns1\s+ap1\s+1234\s*$`,
+				`(?m)^\s*db\s+service\s+ns2\s+ap2\s+5432\s*$`,
+				`(?m)^\s*geo\s+prepared_query\s+ns3\s+8500\s*$`,
+			},
+		},
+		"No annotation": {
+			args:     []string{"no-upstreams", "-namespace", "default"},
+			expected: []string{"Pod no-upstreams has no consul.hashicorp.com/connect-service-upstreams annotation."},
+		},
+		"Invalid annotation": {
+			args:        []string{"invalid", "-namespace", "default"},
+			expExitCode: 1,
+			expected:    []string{`upstream "api:1234:dc3" is invalid: the datacenter must be set either in brackets or after the port, not both`},
+		},
+		"Pod not found": {
+			args:        []string{"api", "-namespace", "default"},
+			expExitCode: 1,
+			expected:    []string{"Error reading upstreams for Pod api"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := setupCommand(buf)
+			c.kubernetes = fake.NewSimpleClientset(
+				pod("web", "api:1234, db:5432:dc2, cache:cache-port, billing.svc.peer1.peer:9090, prepared_query:geo:8500, unknown:not-a-port"),
+				pod("web-ent", "api.ns1.ap1:1234, db.svc.ns2.ns.ap2.ap:5432, prepared_query:geo.ns3:8500"),
+				pod("no-upstreams", ""),
+				pod("invalid", "api:1234:dc3[dc2]"),
+			)
+
+			out := c.Run(tc.args)
+			require.Equal(t, tc.expExitCode, out)
+			for _, expression := range tc.expected {
+				require.Regexp(t, expression, buf.String())
+			}
+		})
+	}
+}
+
+func TestParseUpstreams(t *testing.T) {
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:  "web",
+					Ports: []v1.ContainerPort{{Name: "cache-port", ContainerPort: 6379}},
+				},
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		raw        string
+		enterprise bool
+		expected   []api.Upstream
+		expIgnored []string
+		expErr     string
+	}{
+		"unlabeled with datacenter in brackets": {
+			raw: "api:1234[dc2]",
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "api", Datacenter: "dc2", LocalBindPort: 1234},
+			},
+		},
+		"unlabeled ignores namespaces unless enterprise": {
+			raw: "api.ns1:1234",
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "api.ns1", LocalBindPort: 1234},
+			},
+		},
+		"labeled with datacenter": {
+			raw: "api.svc.dc2.dc:1234",
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "api", Datacenter: "dc2", LocalBindPort: 1234},
+			},
+		},
+		"labeled with namespace and peer": {
+			raw:        "api.svc.ns1.ns.peer1.peer:1234",
+			enterprise: true,
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "api", DestinationNamespace: "ns1", DestinationPeer: "peer1", LocalBindPort: 1234},
+			},
+		},
+		"labeled with partition without enterprise": {
+			raw:    "api.svc.ap1.ap:1234",
+			expErr: "upstream structured incorrectly: api.svc.ap1.ap:1234",
+		},
+		"labeled with unknown label": {
+			raw:        "api.svc.ns1.ns.foo.bar:1234",
+			enterprise: true,
+			expErr:     "upstream structured incorrectly: api.svc.ns1.ns.foo.bar:1234",
+		},
+		"labeled with datacenter label and in brackets": {
+			raw:    "api.svc.dc2.dc:1234[dc3]",
+			expErr: `upstream "api.svc.dc2.dc:1234" is invalid: the datacenter in brackets can't be combined with a datacenter or peer label`,
+		},
+		"prepared query with datacenter": {
+			raw:    "prepared_query:geo:8500[dc2]",
+			expErr: `upstream "prepared_query:geo:8500" is invalid: a datacenter can't be set for prepared query upstreams`,
+		},
+		"prepared query with invalid namespace": {
+			raw:        "prepared_query:geo.-ns:8500",
+			enterprise: true,
+			expErr:     `upstream "prepared_query:geo.-ns:8500" is invalid: prepared query namespace "-ns" must be at most 64 alphanumeric characters or dashes and must start and end with an alphanumeric character`,
+		},
+		"missing port": {
+			raw:    "api",
+			expErr: `upstream "api" is invalid: the local port must be set after the service name, e.g. "upstream1:1234"`,
+		},
+		"unresolved port": {
+			raw:        "api:1234, db:db-port",
+			expected:   []api.Upstream{{DestinationType: api.UpstreamDestTypeService, DestinationName: "api", LocalBindPort: 1234}},
+			expIgnored: []string{"db:db-port"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			upstreams, ignored, err := parseUpstreams(pod, tc.raw, tc.enterprise)
+			if tc.expErr != "" {
+				require.EqualError(t, err, tc.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, upstreams)
+			require.Equal(t, tc.expIgnored, ignored)
+		})
+	}
+}
+
+// TestParseUpstreams_controllerFixture tests that parseUpstreams parses the upstreams annotations the endpoints
+// controller's parser is tested against in the same way, since the CLI doesn't depend on the control-plane
+// module and so has its own copy of the parser.
+func TestParseUpstreams_controllerFixture(t *testing.T) {
+	raw, err := os.ReadFile("../../../../control-plane/connect-inject/testdata/upstreams.json")
+	require.NoError(t, err)
+	var fixture struct {
+		ContainerPorts map[string]int32
+		Cases          []struct {
+			Name       string
+			Enterprise bool
+			Upstreams  string
+			Expected   []api.Upstream
+			ExpErr     bool
+		}
+	}
+	require.NoError(t, json.Unmarshal(raw, &fixture))
+
+	pod := &v1.Pod{Spec: v1.PodSpec{Containers: []v1.Container{{Name: "web"}}}}
+	for name, port := range fixture.ContainerPorts {
+		pod.Spec.Containers[0].Ports = append(pod.Spec.Containers[0].Ports, v1.ContainerPort{Name: name, ContainerPort: port})
+	}
+	for _, tc := range fixture.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			upstreams, ignored, err := parseUpstreams(pod, tc.Upstreams, tc.Enterprise)
+			if tc.ExpErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.Expected, upstreams)
+			require.Empty(t, ignored)
+		})
+	}
+}
+
+func setupCommand(buf io.Writer) *UpstreamsCommand {
+	// Log at a test level to standard out.
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "test",
+		Level:  hclog.Debug,
+		Output: os.Stdout,
+	})
+
+	// Setup and initialize the command struct
+	command := &UpstreamsCommand{
+		BaseCommand: &common.BaseCommand{
+			Ctx: context.Background(),
+			Log: log,
+			UI:  terminal.NewUI(context.Background(), buf),
+		},
+	}
+	command.init()
+
+	return command
+}
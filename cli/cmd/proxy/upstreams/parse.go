@@ -0,0 +1,254 @@
+package upstreams
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	v1 "k8s.io/api/core/v1"
+)
+
+// The parser below is a copy of the endpoints controller's upstreams parsing
+// in control-plane/connect-inject, without the Consul lookups. The CLI doesn't
+// depend on the control-plane module, so the two are kept in sync by testing
+// both against control-plane/connect-inject/testdata/upstreams.json. Changes
+// to the annotation format must be made in both places and added there.
+
+// validConsulNamespaceName matches the names the injector accepts for Consul
+// namespaces.
+var validConsulNamespaceName = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,62}[a-zA-Z0-9])?$`)
+
+// parseUpstreams parses the value of the upstreams annotation in the same way
+// as the endpoints controller, without looking anything up in Consul.
+// Namespaces and partitions are only parsed when enterprise is set, matching
+// an injector with Consul Enterprise namespaces or admin partitions enabled.
+// Upstreams whose local port can't be resolved don't get a listener, so they
+// are returned separately.
+func parseUpstreams(pod *v1.Pod, rawUpstreams string, enterprise bool) ([]api.Upstream, []string, error) {
+	var upstreams []api.Upstream
+	var ignored []string
+	for _, raw := range strings.Split(rawUpstreams, ",") {
+		upstream, err := parseUpstream(pod, raw, enterprise)
+		if err != nil {
+			return nil, nil, err
+		}
+		if upstream.LocalBindPort == 0 {
+			ignored = append(ignored, strings.TrimSpace(raw))
+			continue
+		}
+		upstreams = append(upstreams, upstream)
+	}
+	return upstreams, ignored, nil
+}
+
+// parseUpstream parses a single upstream in any of the formats of the
+// upstreams annotation.
+func parseUpstream(pod *v1.Pod, raw string, enterprise bool) (api.Upstream, error) {
+	// Any of the formats may end with the datacenter in brackets, e.g. "upstream1:1234[dc2]".
+	raw, datacenter, err := parseDatacenter(raw)
+	if err != nil {
+		return api.Upstream{}, err
+	}
+
+	parts := strings.SplitN(raw, ":", 3)
+	if len(parts) < 2 {
+		return api.Upstream{}, fmt.Errorf("upstream %q is invalid: the local port must be set after the service name, e.g. \"upstream1:1234\"", strings.TrimSpace(raw))
+	}
+	serviceParts := strings.Split(parts[0], ".")
+
+	switch {
+	case strings.TrimSpace(parts[0]) == "prepared_query":
+		if datacenter != "" {
+			return api.Upstream{}, fmt.Errorf("upstream %q is invalid: a datacenter can't be set for prepared query upstreams", raw)
+		}
+		return parsePreparedQueryUpstream(pod, raw, enterprise)
+	case len(serviceParts) >= 2 && serviceParts[1] == "svc":
+		upstream, err := parseLabeledUpstream(pod, raw, enterprise)
+		if err != nil {
+			return api.Upstream{}, err
+		}
+		if datacenter != "" && upstream.LocalBindPort > 0 {
+			if upstream.Datacenter != "" || upstream.DestinationPeer != "" {
+				return api.Upstream{}, fmt.Errorf("upstream %q is invalid: the datacenter in brackets can't be combined with a datacenter or peer label", raw)
+			}
+			upstream.Datacenter = datacenter
+		}
+		return upstream, nil
+	default:
+		if datacenter != "" {
+			if len(parts) > 2 {
+				return api.Upstream{}, fmt.Errorf("upstream %q is invalid: the datacenter must be set either in brackets or after the port, not both", raw)
+			}
+			raw = fmt.Sprintf("%s:%s", raw, datacenter)
+		}
+		return parseUnlabeledUpstream(pod, raw, enterprise), nil
+	}
+}
+
+// parseDatacenter splits the optional datacenter suffix in brackets off of an
+// upstream and returns the remaining upstream along with the datacenter.
+func parseDatacenter(raw string) (string, string, error) {
+	trimmed := strings.TrimSpace(raw)
+	if !strings.ContainsAny(trimmed, "[]") {
+		return raw, "", nil
+	}
+
+	open := strings.Index(trimmed, "[")
+	if open == -1 || !strings.HasSuffix(trimmed, "]") || strings.Count(trimmed, "[") != 1 || strings.Count(trimmed, "]") != 1 {
+		return "", "", fmt.Errorf("upstream %q is invalid: the datacenter must be a single suffix in brackets, e.g. \"upstream1:1234[dc2]\"", trimmed)
+	}
+	datacenter := strings.TrimSpace(trimmed[open+1 : len(trimmed)-1])
+	if datacenter == "" {
+		return "", "", fmt.Errorf("upstream %q is invalid: the datacenter in brackets must not be empty", trimmed)
+	}
+	return strings.TrimSpace(trimmed[:open]), datacenter, nil
+}
+
+// parsePreparedQueryUpstream parses an upstream in the format
+// prepared_query:[query name]:[port], where the query name may be followed by
+// its namespace if enterprise is set.
+func parsePreparedQueryUpstream(pod *v1.Pod, raw string, enterprise bool) (api.Upstream, error) {
+	parts := strings.SplitN(raw, ":", 3)
+	if len(parts) < 3 {
+		return api.Upstream{}, fmt.Errorf("upstream %q is invalid: prepared query upstreams must be in the format prepared_query:[query name]:[port]", raw)
+	}
+
+	var namespace string
+	preparedQuery := strings.TrimSpace(parts[1])
+	if enterprise {
+		if pieces := strings.SplitN(preparedQuery, ".", 2); len(pieces) == 2 {
+			preparedQuery = strings.TrimSpace(pieces[0])
+			namespace = strings.TrimSpace(pieces[1])
+			if !validConsulNamespaceName.MatchString(namespace) {
+				return api.Upstream{}, fmt.Errorf("upstream %q is invalid: prepared query namespace %q must be at most 64 alphanumeric characters or dashes and must start and end with an alphanumeric character", raw, namespace)
+			}
+		}
+	}
+	if preparedQuery == "" {
+		return api.Upstream{}, fmt.Errorf("upstream %q is invalid: the prepared query name must not be empty", raw)
+	}
+
+	return api.Upstream{
+		DestinationType:      api.UpstreamDestTypePreparedQuery,
+		DestinationNamespace: namespace,
+		DestinationName:      preparedQuery,
+		LocalBindPort:        portValue(pod, parts[2]),
+	}, nil
+}
+
+// parseUnlabeledUpstream parses an upstream in the format
+// [service-name].[service-namespace].[service-partition]:[port]:[optional datacenter].
+func parseUnlabeledUpstream(pod *v1.Pod, raw string, enterprise bool) api.Upstream {
+	var serviceName, namespace, partition, datacenter string
+	parts := strings.SplitN(raw, ":", 3)
+
+	if enterprise {
+		pieces := strings.SplitN(parts[0], ".", 3)
+		switch len(pieces) {
+		case 3:
+			partition = strings.TrimSpace(pieces[2])
+			fallthrough
+		case 2:
+			namespace = strings.TrimSpace(pieces[1])
+			fallthrough
+		default:
+			serviceName = strings.TrimSpace(pieces[0])
+		}
+	} else {
+		serviceName = strings.TrimSpace(parts[0])
+	}
+	if len(parts) > 2 {
+		datacenter = strings.TrimSpace(parts[2])
+	}
+
+	return api.Upstream{
+		DestinationType:      api.UpstreamDestTypeService,
+		DestinationPartition: partition,
+		DestinationNamespace: namespace,
+		DestinationName:      serviceName,
+		Datacenter:           datacenter,
+		LocalBindPort:        portValue(pod, parts[1]),
+	}
+}
+
+// parseLabeledUpstream parses an upstream in the formats
+// [service-name].svc.[service-namespace].ns.[service-peer].peer:[port]
+// [service-name].svc.[service-namespace].ns.[service-partition].ap:[port]
+// [service-name].svc.[service-namespace].ns.[service-datacenter].dc:[port]
+// where the namespace and partition labels may only be used if enterprise is
+// set.
+func parseLabeledUpstream(pod *v1.Pod, raw string, enterprise bool) (api.Upstream, error) {
+	var serviceName, namespace, partition, peer, datacenter string
+	parts := strings.SplitN(raw, ":", 3)
+	pieces := strings.Split(parts[0], ".")
+
+	// labelValue sets the peer, partition or datacenter from the label at the
+	// given index.
+	labelValue := func(i int) error {
+		switch strings.TrimSpace(pieces[i+1]) {
+		case "peer":
+			peer = strings.TrimSpace(pieces[i])
+		case "ap":
+			if !enterprise {
+				return fmt.Errorf("upstream structured incorrectly: %s", raw)
+			}
+			partition = strings.TrimSpace(pieces[i])
+		case "dc":
+			datacenter = strings.TrimSpace(pieces[i])
+		default:
+			return fmt.Errorf("upstream structured incorrectly: %s", raw)
+		}
+		return nil
+	}
+
+	switch {
+	case enterprise && len(pieces) == 6:
+		if err := labelValue(4); err != nil {
+			return api.Upstream{}, err
+		}
+		fallthrough
+	case enterprise && len(pieces) == 4:
+		if strings.TrimSpace(pieces[3]) != "ns" {
+			return api.Upstream{}, fmt.Errorf("upstream structured incorrectly: %s", raw)
+		}
+		namespace = strings.TrimSpace(pieces[2])
+	case !enterprise && len(pieces) == 4:
+		if err := labelValue(2); err != nil {
+			return api.Upstream{}, err
+		}
+	case len(pieces) == 2:
+	default:
+		return api.Upstream{}, fmt.Errorf("upstream structured incorrectly: %s", raw)
+	}
+	serviceName = strings.TrimSpace(pieces[0])
+
+	return api.Upstream{
+		DestinationType:      api.UpstreamDestTypeService,
+		DestinationPartition: partition,
+		DestinationPeer:      peer,
+		DestinationNamespace: namespace,
+		DestinationName:      serviceName,
+		Datacenter:           datacenter,
+		LocalBindPort:        portValue(pod, parts[1]),
+	}, nil
+}
+
+// portValue resolves the port of an upstream, which may be the name of a
+// container port of the Pod. It returns 0 if the port can't be resolved.
+func portValue(pod *v1.Pod, value string) int {
+	value = strings.TrimSpace(value)
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name == value {
+				return int(p.ContainerPort)
+			}
+		}
+	}
+	port, err := strconv.ParseInt(value, 0, 32)
+	if err != nil {
+		return 0
+	}
+	return int(port)
+}
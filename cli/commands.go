@@ -5,8 +5,11 @@ import (
 
 	"github.com/hashicorp/consul-k8s/cli/cmd/install"
 	"github.com/hashicorp/consul-k8s/cli/cmd/proxy"
+	"github.com/hashicorp/consul-k8s/cli/cmd/proxy/check"
 	"github.com/hashicorp/consul-k8s/cli/cmd/proxy/list"
+	"github.com/hashicorp/consul-k8s/cli/cmd/proxy/logs"
 	"github.com/hashicorp/consul-k8s/cli/cmd/proxy/read"
+	"github.com/hashicorp/consul-k8s/cli/cmd/proxy/upstreams"
 	"github.com/hashicorp/consul-k8s/cli/cmd/status"
 	"github.com/hashicorp/consul-k8s/cli/cmd/uninstall"
 	"github.com/hashicorp/consul-k8s/cli/cmd/upgrade"
@@ -68,6 +71,21 @@ func initializeCommands(ctx context.Context, log hclog.Logger) (*common.BaseComm
 				BaseCommand: baseCommand,
 			}, nil
 		},
+		"proxy check": func() (cli.Command, error) {
+			return &check.CheckCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"proxy logs": func() (cli.Command, error) {
+			return &logs.LogsCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"proxy upstreams": func() (cli.Command, error) {
+			return &upstreams.UpstreamsCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
 	}
 
 	return baseCommand, commands
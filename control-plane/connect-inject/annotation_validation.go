@@ -0,0 +1,172 @@
+package connectinject
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// annotationPrefix is the prefix of the annotations which configure injection.
+const annotationPrefix = "consul.hashicorp.com/"
+
+// maxAnnotationTypoDistance is the largest edit distance between an unrecognized annotation and a known
+// annotation for the unrecognized annotation to be considered a typo of the known one.
+const maxAnnotationTypoDistance = 2
+
+// knownAnnotations are the consul.hashicorp.com/ annotations which are read from or added to pods, including the
+// ones which are no longer supported and are rejected by the webhook.
+var knownAnnotations = []string{
+	keyInjectStatus,
+	keyTransparentProxyStatus,
+	keyManagedBy,
+	annotationInject,
+	annotationInjectMountVolumes,
+	annotationService,
+	annotationServiceID,
+	annotationKubeServiceName,
+	annotationConnectServiceNative,
+	annotationRegisterProxy,
+	annotationRegisterProxyWhenNotReady,
+	annotationEnableEnvoyReadyCheck,
+	annotationKubernetesService,
+	annotationPort,
+	annotationServiceSocketPath,
+	annotationProtocol,
+	annotationLocalRequestTimeout,
+	annotationUpstreams,
+	annotationMeshGatewayMode,
+	annotationGatewayKind,
+	annotationProxyAddress,
+	annotationDeregisterCriticalServiceAfter,
+	annotationInitialHealth,
+	annotationCheckSuccessBeforePassing,
+	annotationCheckFailuresBeforeCritical,
+	annotationServiceChecks,
+	annotationExposePaths,
+	annotationServiceCheckNotes,
+	annotationServiceCheckFile,
+	annotationServiceCheckFileTTL,
+	annotationAutoSyncReadiness,
+	annotationTags,
+	annotationConnectTags,
+	annotationProxyTags,
+	annotationSyncPeriod,
+	annotationSidecarProxyCPULimit,
+	annotationSidecarProxyCPURequest,
+	annotationSidecarProxyMemoryLimit,
+	annotationSidecarProxyMemoryRequest,
+	annotationConsulSidecarCPULimit,
+	annotationConsulSidecarCPURequest,
+	annotationConsulSidecarMemoryLimit,
+	annotationConsulSidecarMemoryRequest,
+	annotationConsulSidecarUserVolume,
+	annotationConsulSidecarUserVolumeMount,
+	annotationEnvoyProxyConcurrency,
+	annotationEnableMetrics,
+	annotationEnableMetricsMerging,
+	annotationMergedMetricsPort,
+	annotationPrometheusScrapePort,
+	annotationPrometheusScrapePath,
+	annotationServiceMetricsPort,
+	annotationServiceMetricsPath,
+	annotationPrometheusCAFile,
+	annotationPrometheusCAPath,
+	annotationPrometheusCertFile,
+	annotationPrometheusKeyFile,
+	annotationEnvoyExtraArgs,
+	annotationConsulNamespace,
+	annotationConsulPartition,
+	keyConsulDNS,
+	keyTransparentProxy,
+	annotationConnectInitLogLevel,
+	annotationWaitFor,
+	annotationTProxyExcludeInboundPorts,
+	annotationTProxyExcludeOutboundPorts,
+	annotationTProxyExcludeDNS,
+	annotationTProxyExcludeOutboundCIDRs,
+	annotationTProxyExcludeInboundCIDRs,
+	annotationTProxyNetNS,
+	annotationTProxyExcludeUIDs,
+	annotationTransparentProxyOverwriteProbes,
+	annotationRedirectTraffic,
+	annotationOriginalPod,
+}
+
+// knownAnnotationPrefixes are the prefixes of known annotations whose keys are completed by the user.
+var knownAnnotationPrefixes = []string{
+	annotationMeta,
+}
+
+// validateAnnotations returns a warning for each consul.hashicorp.com/ annotation on the pod which isn't known but
+// is close enough to a known annotation that it is likely a typo. Such annotations are otherwise silently ignored.
+// Unrecognized annotations which aren't close to a known annotation may be used by other tools and aren't warned about.
+func validateAnnotations(pod corev1.Pod) []string {
+	var warnings []string
+	for key := range pod.Annotations {
+		if !strings.HasPrefix(key, annotationPrefix) || isKnownAnnotation(key) {
+			continue
+		}
+		if suggestion, ok := closestKnownAnnotation(key); ok {
+			warnings = append(warnings, fmt.Sprintf("unrecognized annotation %q has no effect, did you mean %q?", key, suggestion))
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+// isKnownAnnotation returns true if the annotation key is a known annotation or starts with a known prefix.
+func isKnownAnnotation(key string) bool {
+	for _, known := range knownAnnotations {
+		if key == known {
+			return true
+		}
+	}
+	for _, prefix := range knownAnnotationPrefixes {
+		if strings.HasPrefix(key, prefix) && len(key) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// closestKnownAnnotation returns the known annotation with the smallest edit distance to the key if the distance is
+// at most maxAnnotationTypoDistance.
+func closestKnownAnnotation(key string) (string, bool) {
+	closest, closestDistance := "", maxAnnotationTypoDistance+1
+	for _, known := range knownAnnotations {
+		if distance := editDistance(key, known); distance < closestDistance {
+			closest, closestDistance = known, distance
+		}
+	}
+	return closest, closest != ""
+}
+
+// editDistance returns the Levenshtein distance between a and b, i.e. the number of single character insertions,
+// deletions and substitutions needed to change a into b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
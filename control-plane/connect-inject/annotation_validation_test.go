@@ -0,0 +1,94 @@
+package connectinject
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateAnnotations(t *testing.T) {
+	cases := map[string]struct {
+		annotations map[string]string
+		expWarnings []string
+	}{
+		"no annotations": {
+			annotations: nil,
+			expWarnings: nil,
+		},
+		"known annotations": {
+			annotations: map[string]string{
+				annotationInject:           "true",
+				annotationService:          "web",
+				annotationUpstreams:        "db:1234",
+				annotationMeta + "version": "2",
+			},
+			expWarnings: nil,
+		},
+		"misspelled annotation": {
+			annotations: map[string]string{
+				"consul.hashicorp.com/connect-injct": "true",
+			},
+			expWarnings: []string{
+				`unrecognized annotation "consul.hashicorp.com/connect-injct" has no effect, did you mean "consul.hashicorp.com/connect-inject"?`,
+			},
+		},
+		"multiple misspelled annotations": {
+			annotations: map[string]string{
+				"consul.hashicorp.com/connect-service-upstream": "db:1234",
+				"consul.hashicorp.com/service-tag":              "v1",
+				annotationService:                               "web",
+			},
+			expWarnings: []string{
+				`unrecognized annotation "consul.hashicorp.com/connect-service-upstream" has no effect, did you mean "consul.hashicorp.com/connect-service-upstreams"?`,
+				`unrecognized annotation "consul.hashicorp.com/service-tag" has no effect, did you mean "consul.hashicorp.com/service-tags"?`,
+			},
+		},
+		"unrecognized annotation which isn't close to a known annotation": {
+			annotations: map[string]string{
+				"consul.hashicorp.com/something-else": "true",
+			},
+			expWarnings: nil,
+		},
+		"annotations with other prefixes": {
+			annotations: map[string]string{
+				"example.com/connect-injct": "true",
+				"connect-injct":             "true",
+			},
+			expWarnings: nil,
+		},
+		"meta prefix without a key": {
+			annotations: map[string]string{
+				annotationMeta: "2",
+			},
+			expWarnings: nil,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: c.annotations}}
+			require.Equal(t, c.expWarnings, validateAnnotations(pod))
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b string
+		exp  int
+	}{
+		{"", "", 0},
+		{"inject", "inject", 0},
+		{"", "inject", 6},
+		{"injct", "inject", 1},
+		{"injetc", "inject", 2},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.exp, editDistance(c.a, c.b), "%q and %q", c.a, c.b)
+		require.Equal(t, c.exp, editDistance(c.b, c.a), "%q and %q", c.b, c.a)
+	}
+}
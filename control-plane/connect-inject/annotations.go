@@ -30,6 +30,40 @@ const (
 	// This defaults to the name of the Kubernetes service associated with the pod.
 	annotationService = "consul.hashicorp.com/connect-service"
 
+	// annotationServiceID overrides the generated ID of the service instance registered
+	// with Consul. The proxy service instance ID is derived from it as `<id>-sidecar-proxy`.
+	// The value must be unique on the Consul client agent and is not supported for multi port Pods.
+	annotationServiceID = "consul.hashicorp.com/service-id"
+
+	// annotationKubeServiceName overrides the Kubernetes service name recorded in the
+	// k8s-service-name meta of the service instances registered for the pod, which otherwise
+	// is the name of the Endpoints object being reconciled. Deregistration looks up instances
+	// by this meta, so they are grouped with, and only deregistered when reconciling, the
+	// Endpoints object of the overriding service. It is not supported for multi port Pods.
+	annotationKubeServiceName = "consul.hashicorp.com/kube-service-name"
+
+	// annotationConnectServiceNative indicates that the service speaks the Connect protocol
+	// natively. The service is registered as Connect native and no sidecar proxy is injected
+	// or registered for it. It is not supported for multi port Pods.
+	annotationConnectServiceNative = "consul.hashicorp.com/connect-service-native"
+
+	// annotationRegisterProxy can be set to "false" to only register the service and not its sidecar
+	// proxy, e.g. while migrating to a proxy which is registered with Consul separately. No sidecar proxy
+	// is injected for it either. It is not supported for multi port Pods.
+	annotationRegisterProxy = "consul.hashicorp.com/register-proxy"
+
+	// annotationRegisterProxyWhenNotReady controls whether the proxy service is registered
+	// with Consul while the pod is not ready. It defaults to true. When set to false, only the
+	// service is registered for not-ready pods so that failing proxy checks aren't reported
+	// while the application is starting up.
+	annotationRegisterProxyWhenNotReady = "consul.hashicorp.com/register-proxy-when-not-ready"
+
+	// annotationEnableEnvoyReadyCheck can be set to "true" to add an HTTP check against the /ready
+	// endpoint of Envoy's admin API to the proxy registration, so that the proxy only passes once
+	// Envoy has received its xDS configuration. Envoy's admin API must be reachable from the Consul
+	// client agent, e.g. by binding it to 0.0.0.0 with -envoy-admin-bind-address.
+	annotationEnableEnvoyReadyCheck = "consul.hashicorp.com/enable-envoy-ready-check"
+
 	// annotationKubernetesService is the name of the Kubernetes service to register.
 	// This allows a pod to specify what Kubernetes service should trigger a Consul
 	// service registration in the case of multiple services referencing a deployment.
@@ -39,22 +73,104 @@ const (
 	// connections to.
 	annotationPort = "consul.hashicorp.com/connect-service-port"
 
+	// annotationServiceSocketPath is the path of the Unix socket the service listens on. When set,
+	// the service is registered with the socket path instead of a port and the sidecar proxy
+	// connects to the service over the socket. It may not be used with the port annotation.
+	annotationServiceSocketPath = "consul.hashicorp.com/service-socket-path"
+
 	// annotationProtocol contains the protocol that should be used for
 	// the service that is being injected. Valid values are "http", "http2",
-	// "grpc" and "tcp".
-	//
-	// Deprecated: This annotation is no longer supported.
+	// "grpc" and "tcp". It is set as protocol in the proxy's config so that
+	// the proxy can apply L7 features to the service's traffic.
 	annotationProtocol = "consul.hashicorp.com/connect-service-protocol"
 
+	// annotationLocalRequestTimeout is how long the sidecar proxy waits for the local
+	// application to respond to a request, e.g. "30s". It is set as local_request_timeout_ms
+	// in the proxy's config. "0s" disables the timeout.
+	annotationLocalRequestTimeout = "consul.hashicorp.com/connect-service-local-request-timeout"
+
 	// annotationUpstreams is a list of upstreams to register with the
 	// proxy in the format of `<service-name>:<local-port>,...`. The
 	// service name should map to a Consul service namd and the local port
 	// is the local port in the pod that the listener will bind to. It can
-	// be a named port.
+	// be a named port. Each upstream may end with the datacenter of the
+	// service in brackets, e.g. `<service-name>:<local-port>[<datacenter>]`.
+	// Prepared queries are set as `prepared_query:<query-name>:<local-port>`,
+	// or `prepared_query:<query-name>.<namespace>:<local-port>` if Consul
+	// namespaces are enabled. It can also be set as an annotation on a namespace to define default
+	// upstreams for connect-injected pods in that namespace. Upstreams set
+	// on the pod take precedence over the namespace's upstreams with the same
+	// destination or local port.
 	annotationUpstreams = "consul.hashicorp.com/connect-service-upstreams"
 
+	// annotationMeshGatewayMode is the default mesh gateway mode of the proxy. It is used to
+	// route traffic to upstreams in other datacenters or partitions through mesh gateways.
+	// Valid values are "local", "remote", and "none".
+	annotationMeshGatewayMode = "consul.hashicorp.com/mesh-gateway-mode"
+
+	// annotationGatewayKind is the kind of the proxy service registered for the pod. Valid values
+	// are "sidecar", "mesh-gateway", "ingress-gateway", and "terminating-gateway". It defaults to
+	// "sidecar", i.e. a Connect proxy for the pod's service. Gateways are registered with the kind as
+	// the suffix of their name and ID instead of "sidecar-proxy", e.g. `<service>-mesh-gateway`.
+	annotationGatewayKind = "consul.hashicorp.com/gateway-kind"
+
+	// annotationProxyAddress is the address the proxy service is registered with and that its public
+	// listener health check connects to. It defaults to the pod IP, but may be set when the routable
+	// address of the pod differs from its pod IP, e.g. with some CNIs.
+	annotationProxyAddress = "consul.hashicorp.com/proxy-address"
+
+	// annotationDeregisterCriticalServiceAfter is the duration, e.g. "30m", after which the
+	// proxy service is deregistered from Consul once its health check has become critical.
+	// It overrides the default of 10 minutes.
+	annotationDeregisterCriticalServiceAfter = "consul.hashicorp.com/deregister-critical-service-after"
+
+	// annotationInitialHealth is the status, "passing", "warning" or "critical", of the proxy's public listener
+	// check when the proxy is registered. Setting it to "critical" registers the instance in the catalog without
+	// sending it traffic until the check first passes, e.g. for blue/green cutovers.
+	annotationInitialHealth = "consul.hashicorp.com/initial-health"
+
+	// annotationCheckSuccessBeforePassing and annotationCheckFailuresBeforeCritical are the number of
+	// consecutive successful or failed checks required before the service's and the proxy's health checks
+	// change to passing or critical. They must be non-negative integers and are useful for flaky services.
+	annotationCheckSuccessBeforePassing   = "consul.hashicorp.com/check-success-before-passing"
+	annotationCheckFailuresBeforeCritical = "consul.hashicorp.com/check-failures-before-critical"
+
+	// annotationServiceChecks is a JSON array of Consul check definitions, e.g.
+	// '[{"Name": "ttl", "TTL": "30s"}]', which are registered with the service in addition to the
+	// built-in health checks. This allows TTL, script, Docker and other checks which don't have their own
+	// annotations.
+	annotationServiceChecks = "consul.hashicorp.com/service-checks"
+
+	// annotationExposePaths is a JSON array of HTTP paths which the proxy exposes without mTLS, e.g.
+	// '[{"path": "/metrics", "local_port": 8080, "listener_port": 21500, "protocol": "http"}]', so that
+	// health checks and metrics scrapers outside the mesh can reach them. The protocol may be http or http2.
+	annotationExposePaths = "consul.hashicorp.com/connect-service-expose-paths"
+
+	// annotationServiceCheckNotes is set as the Notes of the proxy's public listener check so that operators
+	// can add human-readable context to it in the Consul UI. It is purely informational.
+	annotationServiceCheckNotes = "consul.hashicorp.com/service-check-notes"
+
+	// annotationServiceCheckFile is the path of a readiness file written by the application or a sidecar.
+	// Consul can't read files in the pod, so a TTL check with the ID "<service-id>/check-file" is registered
+	// instead, and whatever writes the file must also mark the check as passing through the Consul agent's
+	// /v1/agent/check/pass endpoint at least once every annotationServiceCheckFileTTL.
+	annotationServiceCheckFile = "consul.hashicorp.com/service-check-file"
+
+	// annotationServiceCheckFileTTL is the TTL of the check registered for annotationServiceCheckFile,
+	// e.g. "30s". It defaults to 30s.
+	annotationServiceCheckFileTTL = "consul.hashicorp.com/service-check-file-ttl"
+
+	// annotationAutoSyncReadiness, if true, registers an HTTP check with the service which is derived from the
+	// httpGet readiness probe of the pod's first application container. Pods without an httpGet readiness
+	// probe aren't affected.
+	annotationAutoSyncReadiness = "consul.hashicorp.com/auto-sync-readiness"
+
 	// annotationTags is a list of tags to register with the service
 	// this is specified as a comma separated list e.g. abc,123.
+	// Tags may contain the tokens $(POD_NAME), $(POD_NAMESPACE), $(POD_IP), $(NODE_NAME)
+	// and $(POD_LABEL_<key>), which are replaced with the pod's fields or the value of its
+	// label <key>, e.g. version=$(POD_LABEL_version). A literal "$" is written as "$$".
+	// Unknown tokens and tokens for labels the pod doesn't have are left as is.
 	annotationTags = "consul.hashicorp.com/service-tags"
 
 	// annotationConnectTags is a list of tags to register with the service
@@ -66,6 +182,11 @@ const (
 	// service that gets registered is tagged.
 	annotationConnectTags = "consul.hashicorp.com/connect-service-tags"
 
+	// annotationProxyTags is a list of tags to register with the proxy service only, in addition
+	// to the tags registered with both the service and the proxy. It is specified as a comma
+	// separated list e.g. abc,123 and supports the same tokens as annotationTags.
+	annotationProxyTags = "consul.hashicorp.com/proxy-tags"
+
 	// annotationMeta is a list of metadata key/value pairs to add to the service
 	// registration. This is specified in the format `<key>:<value>`
 	// e.g. consul.hashicorp.com/service-meta-foo:bar.
@@ -122,8 +243,14 @@ const (
 	annotationEnvoyExtraArgs = "consul.hashicorp.com/envoy-extra-args"
 
 	// annotationConsulNamespace is the Consul namespace the service is registered into.
+	// It may be set on a pod to override the namespace derived from the pod's Kubernetes
+	// namespace, for example when pods from different teams share a Kubernetes namespace.
 	annotationConsulNamespace = "consul.hashicorp.com/consul-namespace"
 
+	// annotationConsulPartition is the Consul Admin Partition the service is registered into.
+	// It may be set on a pod to override the partition the controller registers services into.
+	annotationConsulPartition = "consul.hashicorp.com/consul-partition"
+
 	// keyConsulDNS enables or disables Consul DNS for a given pod. It can also be set as a label
 	// on a namespace to define the default behaviour for connect-injected pods which do not otherwise override this setting
 	// with their own annotation.
@@ -136,15 +263,40 @@ const (
 	// This annotation/label takes a boolean value (true/false).
 	keyTransparentProxy = "consul.hashicorp.com/transparent-proxy"
 
+	// annotationConnectInitLogLevel is the log level of the connect-init command run by the init
+	// container. It overrides the default set on the webhook.
+	annotationConnectInitLogLevel = "consul.hashicorp.com/connect-init-log-level"
+
+	// annotationWaitFor is a comma-separated list of Consul service names which the init container
+	// waits to be registered in the catalog before bootstrapping Envoy, for apps which require a
+	// dependency to be available before their sidecar starts. It is not supported in agentless mode.
+	annotationWaitFor = "consul.hashicorp.com/connect-inject-wait-for"
+
 	// annotationTProxyExcludeInboundPorts is a comma-separated list of inbound ports to exclude from traffic redirection.
 	annotationTProxyExcludeInboundPorts = "consul.hashicorp.com/transparent-proxy-exclude-inbound-ports"
 
 	// annotationTProxyExcludeOutboundPorts is a comma-separated list of outbound ports to exclude from traffic redirection.
 	annotationTProxyExcludeOutboundPorts = "consul.hashicorp.com/transparent-proxy-exclude-outbound-ports"
 
+	// annotationTProxyExcludeDNS excludes the cluster DNS port from outbound traffic redirection so that
+	// DNS requests go directly to the cluster DNS service instead of through the proxy.
+	// This annotation takes a boolean value (true/false).
+	annotationTProxyExcludeDNS = "consul.hashicorp.com/transparent-proxy-exclude-dns"
+
 	// annotationTProxyExcludeOutboundCIDRs is a comma-separated list of outbound CIDRs to exclude from traffic redirection.
 	annotationTProxyExcludeOutboundCIDRs = "consul.hashicorp.com/transparent-proxy-exclude-outbound-cidrs"
 
+	// annotationTProxyExcludeInboundCIDRs is a comma-separated list of inbound CIDRs to exclude from traffic
+	// redirection. It requires a version of Consul whose redirect-traffic command supports -exclude-inbound-cidr
+	// and is not applied when traffic redirection is set up by the CNI plugin.
+	annotationTProxyExcludeInboundCIDRs = "consul.hashicorp.com/transparent-proxy-exclude-inbound-cidrs"
+
+	// annotationTProxyNetNS is the path of the network namespace that traffic redirection rules are
+	// applied to, e.g. when chaining CNI plugins that set up the pod's network namespace. It requires
+	// a version of Consul whose redirect-traffic command supports -netns and is not applied when
+	// traffic redirection is set up by the CNI plugin.
+	annotationTProxyNetNS = "consul.hashicorp.com/tproxy-netns"
+
 	// annotationTProxyExcludeUIDs is a comma-separated list of additional user IDs to exclude from traffic redirection.
 	annotationTProxyExcludeUIDs = "consul.hashicorp.com/transparent-proxy-exclude-uids"
 
@@ -168,6 +320,10 @@ const (
 	// registered with Consul.
 	labelServiceIgnore = "consul.hashicorp.com/service-ignore"
 
+	// labelAgentPartition is a label on Consul client agent pods which contains the Admin Partition the
+	// agent belongs to. Agents in other partitions are skipped when deregistering services.
+	labelAgentPartition = "consul.hashicorp.com/partition"
+
 	// labelPeeringToken is a label that can be added to a secret to allow it to be watched
 	// by the peering controllers.
 	labelPeeringToken = "consul.hashicorp.com/peering-token"
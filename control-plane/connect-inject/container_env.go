@@ -16,6 +16,12 @@ func (w *MeshWebhook) containerEnvVars(pod corev1.Pod) []corev1.EnvVar {
 
 	var result []corev1.EnvVar
 	for _, raw := range strings.Split(raw, ",") {
+		// The datacenter doesn't affect the environment variables. Invalid
+		// upstreams are rejected when the proxy is registered.
+		raw, _, err := parseUpstreamDatacenter(raw)
+		if err != nil {
+			continue
+		}
 		parts := strings.SplitN(raw, ":", 3)
 		port, _ := portValue(pod, strings.TrimSpace(parts[1]))
 		if port > 0 {
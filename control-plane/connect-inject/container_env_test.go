@@ -22,6 +22,10 @@ func TestContainerEnvVars(t *testing.T) {
 			"Upstream without datacenter",
 			"static-server:7890",
 		},
+		{
+			"Upstream with datacenter in brackets",
+			"static-server:7890[dc1]",
+		},
 	}
 
 	for _, tt := range cases {
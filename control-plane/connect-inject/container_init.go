@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"path"
+	"regexp"
 	"strconv"
 	"strings"
 	"text/template"
 	"time"
 
+	"github.com/hashicorp/consul/api"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/utils/pointer"
 )
@@ -20,7 +23,14 @@ const (
 	envoyUserAndGroupID          = 5995
 	initContainersUserAndGroupID = 5996
 	netAdminCapability           = "NET_ADMIN"
+	netRawCapability             = "NET_RAW"
 	dnsServiceHostEnvSuffix      = "DNS_SERVICE_HOST"
+
+	// consulBinaryImagePath is the path of the Consul binary in the Consul image.
+	consulBinaryImagePath = "/bin/consul"
+	// consulBinaryCopyPath is the path in the shared volume that the copy container copies
+	// the Consul binary to.
+	consulBinaryCopyPath = "/consul/connect-inject/consul"
 )
 
 type initContainerCommandData struct {
@@ -34,12 +44,23 @@ type initContainerCommandData struct {
 	// ConsulNamespace is the Consul namespace to register the service
 	// and proxy in. An empty string indicates namespaces are not
 	// enabled in Consul (necessary for OSS).
-	ConsulNamespace           string
+	ConsulNamespace string
+	// AuthMethodNamespace is the Consul namespace the auth method is defined in
+	// when namespace mirroring is disabled. It may differ from ConsulNamespace
+	// if the pod overrides the namespace it is registered in.
+	AuthMethodNamespace       string
 	NamespaceMirroringEnabled bool
 
 	// The PEM-encoded CA certificate to use when
 	// communicating with Consul clients
 	ConsulCACert string
+	// ConsulGRPCTLS is true if the gRPC address of the Consul client
+	// uses the https scheme.
+	ConsulGRPCTLS bool
+	// ConsulHTTPProxy is the proxy used to reach Consul. ConsulNoProxy
+	// is the list of hosts reached without it, including the host IP.
+	ConsulHTTPProxy string
+	ConsulNoProxy   string
 	// EnableMetrics adds a listener to Envoy where Prometheus will scrape
 	// metrics from.
 	EnableMetrics bool
@@ -73,10 +94,18 @@ type initContainerCommandData struct {
 	// the consul connect redirect-traffic command.
 	TProxyExcludeOutboundPorts []string
 
+	// TProxyExcludeInboundCIDRs is a list of inbound CIDRs to exclude from traffic redirection via
+	// the consul connect redirect-traffic command.
+	TProxyExcludeInboundCIDRs []string
+
 	// TProxyExcludeOutboundCIDRs is a list of outbound CIDRs to exclude from traffic redirection via
 	// the consul connect redirect-traffic command.
 	TProxyExcludeOutboundCIDRs []string
 
+	// TProxyNetNS is the path of the network namespace to apply traffic redirection rules to via
+	// the consul connect redirect-traffic command.
+	TProxyNetNS string
+
 	// TProxyExcludeUIDs is a list of additional user IDs to exclude from traffic redirection via
 	// the consul connect redirect-traffic command.
 	TProxyExcludeUIDs []string
@@ -92,6 +121,10 @@ type initContainerCommandData struct {
 	// Pod.
 	EnvoyAdminPort int
 
+	// EnvoyAdminBindAddress is the address Envoy's admin API binds to. The -admin-bind
+	// flag is only rendered when it is set.
+	EnvoyAdminBindAddress string
+
 	// BearerTokenFile configures where the service account token can be found. This will be unique per service in a
 	// multi port Pod.
 	BearerTokenFile string
@@ -99,13 +132,52 @@ type initContainerCommandData struct {
 	// ConsulAPITimeout is the duration that the consul API client will
 	// wait for a response from the API before cancelling the request.
 	ConsulAPITimeout time.Duration
+
+	// ConnectInitPollTimeout is how long connect-init waits for the pod's service to be
+	// registered before failing. If zero, connect-init's default is used.
+	ConnectInitPollTimeout time.Duration
+
+	// ConnectInitLogLevel is the log level of connect-init. If empty, connect-init's default is used.
+	ConnectInitLogLevel string
+
+	// ConsulBinaryPath is the path of the Consul binary used to bootstrap Envoy and apply
+	// traffic redirection rules. It is the path the copy container copies the binary to,
+	// unless the copy container is skipped.
+	ConsulBinaryPath string
+
+	// AgentlessMode skips bootstrapping Envoy since the sidecar bootstraps itself, e.g. when
+	// it is consul-dataplane, so connect-init only waits for the service to be registered.
+	AgentlessMode bool
+
+	// GatewayKind is the value of the -gateway flag of consul connect envoy, e.g. "mesh", if the
+	// pod is a gateway. Envoy is then bootstrapped for the gateway registered by the endpoints
+	// controller, whose ID connect-init finds just like a sidecar proxy's.
+	GatewayKind string
+
+	// WaitForServices are the Consul services which must be registered before Envoy is bootstrapped.
+	WaitForServices []string
+
+	// WaitForTimeout is the number of seconds to wait for WaitForServices to be registered before failing.
+	WaitForTimeout int
+
+	// BootstrapFileMode is the file mode set on the Envoy bootstrap and ACL token files. If empty,
+	// their permissions are not changed.
+	BootstrapFileMode string
+}
+
+// envoyGatewayKinds maps the kinds of gateway proxy services to the values of the -gateway
+// flag of consul connect envoy.
+var envoyGatewayKinds = map[api.ServiceKind]string{
+	api.ServiceKindMeshGateway:        "mesh",
+	api.ServiceKindIngressGateway:     "ingress",
+	api.ServiceKindTerminatingGateway: "terminating",
 }
 
 // initCopyContainer returns the init container spec for the copy container which places
 // the consul binary into the shared volume.
 func (w *MeshWebhook) initCopyContainer() corev1.Container {
 	// Copy the Consul binary from the image to the shared volume.
-	cmd := "cp /bin/consul /consul/connect-inject/consul"
+	cmd := fmt.Sprintf("cp %s %s", consulBinaryImagePath, consulBinaryCopyPath)
 	container := corev1.Container{
 		Name:      InjectInitCopyContainerName,
 		Image:     w.ImageConsul,
@@ -131,6 +203,117 @@ func (w *MeshWebhook) initCopyContainer() corev1.Container {
 	return container
 }
 
+// validateExtraVolumeMounts returns an error if the names of the extra init container volume mounts
+// aren't unique or if one of them is the shared connect-inject volume, which is always mounted.
+func validateExtraVolumeMounts(mounts []corev1.VolumeMount) error {
+	names := make(map[string]bool, len(mounts))
+	for _, mount := range mounts {
+		if mount.Name == volumeName {
+			return fmt.Errorf("extra init container volume mount %q collides with the connect-inject volume", mount.Name)
+		}
+		if names[mount.Name] {
+			return fmt.Errorf("extra init container volume mount %q is not unique", mount.Name)
+		}
+		names[mount.Name] = true
+	}
+	return nil
+}
+
+// needsCopyContainer returns true if the copy container must be added to the pod for the connect-init
+// container to use the Consul binary. In agentless mode, the binary is only used to apply traffic
+// redirection rules when they aren't applied by the CNI plugin.
+func (w *MeshWebhook) needsCopyContainer(namespace corev1.Namespace, pod corev1.Pod) (bool, error) {
+	if w.SkipCopyContainer {
+		return false, nil
+	}
+	if !w.AgentlessMode {
+		return true, nil
+	}
+	tproxyEnabled, err := transparentProxyEnabled(namespace, pod, w.EnableTransparentProxy)
+	if err != nil {
+		return false, err
+	}
+	return tproxyEnabled && !w.EnableCNI, nil
+}
+
+// consulBinaryPath returns the path of the Consul binary in the connect-init container. The binary
+// is used from the image directly if the copy container is skipped.
+func (w *MeshWebhook) consulBinaryPath() string {
+	if w.SkipCopyContainer {
+		if w.ConsulBinaryPath != "" {
+			return w.ConsulBinaryPath
+		}
+		return consulBinaryImagePath
+	}
+	return consulBinaryCopyPath
+}
+
+// validWaitForServiceName matches the service names accepted by the wait-for annotation. The names
+// are rendered into the init container's shell script, so they must not contain any shell syntax.
+var validWaitForServiceName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// validBootstrapFileMode matches the octal file modes accepted by chmod for BootstrapFileMode.
+var validBootstrapFileMode = regexp.MustCompile(`^0?[0-7]{3}$`)
+
+// ValidateBootstrapFileMode returns an error if the mode is not an octal file mode such as "0640".
+// An empty mode is valid and leaves the default permissions.
+func ValidateBootstrapFileMode(mode string) error {
+	if mode != "" && !validBootstrapFileMode.MatchString(mode) {
+		return fmt.Errorf("bootstrap file mode %q is invalid: must be an octal file mode such as \"0640\"", mode)
+	}
+	return nil
+}
+
+// waitForServices returns the Consul service names from the pod's wait-for annotation.
+func waitForServices(pod corev1.Pod) ([]string, error) {
+	var services []string
+	for _, service := range splitCommaSeparatedItemsFromAnnotation(annotationWaitFor, pod) {
+		service = strings.TrimSpace(service)
+		if !validWaitForServiceName.MatchString(service) {
+			return nil, fmt.Errorf("%s annotation value %q is invalid: service name %q must contain only alphanumeric characters, dashes or underscores",
+				annotationWaitFor, pod.Annotations[annotationWaitFor], service)
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+// connectInitLogLevels are the log levels supported by connect-init.
+var connectInitLogLevels = []string{"trace", "debug", "info", "warn", "error"}
+
+// connectInitLogLevel returns the log level of connect-init for the pod. The pod's annotation
+// overrides the level set on the webhook.
+func (w *MeshWebhook) connectInitLogLevel(pod corev1.Pod) (string, error) {
+	level := w.ConnectInitLogLevel
+	source := "connect-init log level"
+	if raw, ok := pod.Annotations[annotationConnectInitLogLevel]; ok && raw != "" {
+		level = raw
+		source = fmt.Sprintf("%s annotation value", annotationConnectInitLogLevel)
+	}
+	if level == "" {
+		return "", nil
+	}
+	for _, l := range connectInitLogLevels {
+		if level == l {
+			return level, nil
+		}
+	}
+	return "", fmt.Errorf("%s %q is invalid: must be one of %s", source, level, strings.Join(connectInitLogLevels, ", "))
+}
+
+// tproxyNetNS returns the network namespace path from the pod's annotation. The path must be
+// absolute and clean since it's passed to redirect-traffic as is.
+func tproxyNetNS(pod corev1.Pod) (string, error) {
+	raw, ok := pod.Annotations[annotationTProxyNetNS]
+	if !ok || raw == "" {
+		return "", nil
+	}
+	if !path.IsAbs(raw) || path.Clean(raw) != raw || strings.ContainsAny(raw, "\"` \t\n") {
+		return "", fmt.Errorf("%s annotation value %q is invalid: must be a clean absolute path", annotationTProxyNetNS, raw)
+	}
+	return raw, nil
+}
+
 // containerInit returns the init container spec for connect-init that polls for the service and the connect proxy service to be registered
 // so that it can save the proxy service id to the shared volume and boostrap Envoy with the proxy-id.
 func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod, mpi multiPortInfo) (corev1.Container, error) {
@@ -156,25 +339,82 @@ func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod,
 		}
 	}
 
+	excludeOutboundPorts := splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeOutboundPorts, pod)
+	excludeDNS, err := shouldExcludeDNS(pod)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	if excludeDNS {
+		excludeOutboundPorts = append(excludeOutboundPorts, w.clusterDNSPort())
+	}
+
+	netNS, err := tproxyNetNS(pod)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+
+	connectInitLogLevel, err := w.connectInitLogLevel(pod)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+
+	waitFor, err := waitForServices(pod)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+
+	if err := ValidateBootstrapFileMode(w.BootstrapFileMode); err != nil {
+		return corev1.Container{}, err
+	}
+
 	multiPort := mpi.serviceName != ""
+	if multiPort {
+		if err := w.validateMultiPortInfo(pod, mpi); err != nil {
+			return corev1.Container{}, err
+		}
+	}
+
+	proxyServiceKind, err := getProxyServiceKind(pod)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	gatewayKind := envoyGatewayKinds[proxyServiceKind]
+	if gatewayKind != "" && multiPort {
+		return corev1.Container{}, fmt.Errorf("multi port services are not compatible with %s annotation value %q", annotationGatewayKind, pod.Annotations[annotationGatewayKind])
+	}
 
 	data := initContainerCommandData{
 		AuthMethod:                 w.AuthMethod,
-		ConsulPartition:            w.ConsulPartition,
-		ConsulNamespace:            w.consulNamespace(namespace.Name),
+		ConsulPartition:            w.podConsulPartition(pod),
+		ConsulNamespace:            w.podConsulNamespace(pod, namespace.Name),
+		AuthMethodNamespace:        w.consulNamespace(namespace.Name),
 		NamespaceMirroringEnabled:  w.EnableK8SNSMirroring,
 		ConsulCACert:               w.ConsulCACert,
+		ConsulGRPCTLS:              w.consulGRPCTLS(),
+		ConsulHTTPProxy:            w.ConsulHTTPProxy,
+		ConsulNoProxy:              w.consulNoProxy("${HOST_IP}"),
 		EnableTransparentProxy:     tproxyEnabled,
 		EnableCNI:                  w.EnableCNI,
 		TProxyExcludeInboundPorts:  splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeInboundPorts, pod),
-		TProxyExcludeOutboundPorts: splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeOutboundPorts, pod),
+		TProxyExcludeOutboundPorts: excludeOutboundPorts,
+		TProxyExcludeInboundCIDRs:  splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeInboundCIDRs, pod),
 		TProxyExcludeOutboundCIDRs: splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeOutboundCIDRs, pod),
-		TProxyExcludeUIDs:          splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeUIDs, pod),
+		TProxyNetNS:                netNS,
+		TProxyExcludeUIDs:          mergeExcludeUIDs(w.TProxyDefaultExcludeUIDs, splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeUIDs, pod)),
 		ConsulDNSClusterIP:         consulDNSClusterIP,
 		EnvoyUID:                   envoyUserAndGroupID,
 		MultiPort:                  multiPort,
 		EnvoyAdminPort:             19000 + mpi.serviceIndex,
+		EnvoyAdminBindAddress:      w.envoyAdminBindAddress(multiPort),
 		ConsulAPITimeout:           w.ConsulAPITimeout,
+		ConnectInitPollTimeout:     w.ConnectInitPollTimeout,
+		ConnectInitLogLevel:        connectInitLogLevel,
+		ConsulBinaryPath:           w.consulBinaryPath(),
+		AgentlessMode:              w.AgentlessMode,
+		GatewayKind:                gatewayKind,
+		WaitForServices:            waitFor,
+		WaitForTimeout:             int(w.waitForTimeout().Seconds()),
+		BootstrapFileMode:          w.BootstrapFileMode,
 	}
 
 	// Create expected volume mounts
@@ -191,6 +431,11 @@ func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod,
 		data.ServiceName = pod.Annotations[annotationService]
 	}
 	if w.AuthMethod != "" {
+		// connect-init finds the service name from the service registered for the pod's endpoints when the
+		// annotation isn't set. An empty annotation is ambiguous, so it isn't allowed.
+		if serviceName, ok := pod.Annotations[annotationService]; ok && serviceName == "" && !multiPort {
+			return corev1.Container{}, fmt.Errorf("%s annotation must not be empty when an auth method is set: remove it to use the name of the Kubernetes service", annotationService)
+		}
 		if multiPort {
 			// If multi port then we require that the service account name
 			// matches the service name.
@@ -209,6 +454,11 @@ func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod,
 		volMounts = append(volMounts, saTokenVolumeMount)
 	}
 
+	if err := validateExtraVolumeMounts(w.InitContainerExtraVolumeMounts); err != nil {
+		return corev1.Container{}, err
+	}
+	volMounts = append(volMounts, w.InitContainerExtraVolumeMounts...)
+
 	// This determines how to configure the consul connect envoy command: what
 	// metrics backend to use and what path to expose on the
 	// envoy_prometheus_bind_addr listener for scraping.
@@ -299,10 +549,20 @@ func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod,
 		Command:      []string{"/bin/sh", "-ec", buf.String()},
 	}
 
+	if w.ConsulHTTPProxy != "" {
+		// NO_PROXY refers to HOST_IP using dependent environment variable syntax,
+		// which requires it to be defined after HOST_IP.
+		container.Env = append(container.Env,
+			corev1.EnvVar{Name: "HTTP_PROXY", Value: w.ConsulHTTPProxy},
+			corev1.EnvVar{Name: "HTTPS_PROXY", Value: w.ConsulHTTPProxy},
+			corev1.EnvVar{Name: "NO_PROXY", Value: w.consulNoProxy("$(HOST_IP)")},
+		)
+	}
+
 	if tproxyEnabled {
 		// Running consul connect redirect-traffic with iptables
 		// requires both being a root user and having NET_ADMIN capability.
-		if !w.EnableCNI {
+		if !w.EnableCNI && !w.TProxyNonPrivileged {
 			container.SecurityContext = &corev1.SecurityContext{
 				RunAsUser:  pointer.Int64(rootUserAndGroupID),
 				RunAsGroup: pointer.Int64(rootUserAndGroupID),
@@ -313,6 +573,19 @@ func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod,
 					Add: []corev1.Capability{netAdminCapability},
 				},
 			}
+		} else if !w.EnableCNI {
+			// Without privileged mode, iptables additionally needs NET_RAW to manage the rules.
+			container.SecurityContext = &corev1.SecurityContext{
+				RunAsUser:  pointer.Int64(rootUserAndGroupID),
+				RunAsGroup: pointer.Int64(rootUserAndGroupID),
+				// RunAsNonRoot overrides any setting in the Pod so that we can still run as root here as required.
+				RunAsNonRoot:             pointer.Bool(false),
+				Privileged:               pointer.Bool(false),
+				AllowPrivilegeEscalation: pointer.Bool(false),
+				Capabilities: &corev1.Capabilities{
+					Add: []corev1.Capability{netAdminCapability, netRawCapability},
+				},
+			}
 		} else {
 			container.SecurityContext = &corev1.SecurityContext{
 				RunAsUser:    pointer.Int64(initContainersUserAndGroupID),
@@ -370,6 +643,33 @@ func consulDNSEnabled(namespace corev1.Namespace, pod corev1.Pod, globalEnabled
 	return globalEnabled, nil
 }
 
+// validateMultiPortInfo checks that the multi port service at mpi.serviceIndex is one of
+// the services listed in the pod's service annotation and, when ACLs are enabled, that a
+// service account token volume is mounted for it. Without these checks the init container
+// would be rendered with the wrong service name or service account token.
+func (w *MeshWebhook) validateMultiPortInfo(pod corev1.Pod, mpi multiPortInfo) error {
+	svcNames := w.annotatedServiceNames(pod)
+	if mpi.serviceIndex < 0 || mpi.serviceIndex >= len(svcNames) {
+		return fmt.Errorf("multi port service index %d for service %q is out of range: annotation %q lists %d service(s)",
+			mpi.serviceIndex, mpi.serviceName, annotationService, len(svcNames))
+	}
+	if svcNames[mpi.serviceIndex] != mpi.serviceName {
+		return fmt.Errorf("multi port service %q does not match service %q at index %d of annotation %q",
+			mpi.serviceName, svcNames[mpi.serviceIndex], mpi.serviceIndex, annotationService)
+	}
+	if w.AuthMethod != "" && pod.Spec.ServiceAccountName != mpi.serviceName {
+		volumeName := fmt.Sprintf("%s-service-account", mpi.serviceName)
+		for _, v := range pod.Spec.Volumes {
+			if v.Name == volumeName {
+				return nil
+			}
+		}
+		return fmt.Errorf("multi port service %q requires a service account named %q: volume %q not found on pod",
+			mpi.serviceName, mpi.serviceName, volumeName)
+	}
+	return nil
+}
+
 // splitCommaSeparatedItemsFromAnnotation takes an annotation and a pod
 // and returns the comma-separated value of the annotation as a list of strings.
 func splitCommaSeparatedItemsFromAnnotation(annotation string, pod corev1.Pod) []string {
@@ -381,26 +681,63 @@ func splitCommaSeparatedItemsFromAnnotation(annotation string, pod corev1.Pod) [
 	return items
 }
 
+// mergeExcludeUIDs returns the default UIDs followed by the UIDs from the pod
+// annotation, with any duplicates removed.
+func mergeExcludeUIDs(defaults, fromAnnotation []string) []string {
+	var uids []string
+	seen := make(map[string]bool)
+	for _, uid := range append(append([]string{}, defaults...), fromAnnotation...) {
+		uid = strings.TrimSpace(uid)
+		if uid == "" || seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		uids = append(uids, uid)
+	}
+	return uids
+}
+
 // initContainerCommandTpl is the template for the command executed by
 // the init container.
 const initContainerCommandTpl = `
 {{- if .ConsulCACert}}
 export CONSUL_HTTP_ADDR="https://${HOST_IP}:8501"
+{{- if .ConsulGRPCTLS}}
 export CONSUL_GRPC_ADDR="https://${HOST_IP}:8502"
+{{- else}}
+export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
+{{- end}}
 export CONSUL_CACERT=/consul/connect-inject/consul-ca.pem
 cat <<EOF >/consul/connect-inject/consul-ca.pem
 {{ .ConsulCACert }}
 EOF
 {{- else}}
 export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
+{{- if .ConsulGRPCTLS}}
+export CONSUL_GRPC_ADDR="https://${HOST_IP}:8502"
+{{- else}}
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 {{- end}}
+{{- end}}
+{{- if .ConsulHTTPProxy}}
+export HTTP_PROXY="{{ .ConsulHTTPProxy }}"
+export HTTPS_PROXY="{{ .ConsulHTTPProxy }}"
+export NO_PROXY="{{ .ConsulNoProxy }}"
+{{- end}}
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout={{ .ConsulAPITimeout }} \
+  {{- if .ConnectInitPollTimeout }}
+  -poll-timeout={{ .ConnectInitPollTimeout }} \
+  {{- end }}
+  {{- if .ConnectInitLogLevel }}
+  -log-level={{ .ConnectInitLogLevel }} \
+  {{- end }}
   {{- if .AuthMethod }}
   -acl-auth-method="{{ .AuthMethod }}" \
   -service-account-name="{{ .ServiceAccountName }}" \
+  {{- if .ServiceName }}
   -service-name="{{ .ServiceName }}" \
+  {{- end }}
   -bearer-token-file={{ .BearerTokenFile }} \
   {{- if .MultiPort }}
   -acl-token-sink=/consul/connect-inject/acl-token-{{ .ServiceName }} \
@@ -411,7 +748,7 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
          defined in the default namespace */}}
   -auth-method-namespace="default" \
   {{- else }}
-  -auth-method-namespace="{{ .ConsulNamespace }}" \
+  -auth-method-namespace="{{ .AuthMethodNamespace }}" \
   {{- end }}
   {{- end }}
   {{- end }}
@@ -428,9 +765,45 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
   {{- if .ConsulNamespace }}
   -consul-service-namespace="{{ .ConsulNamespace }}" \
   {{- end }}
+{{- if and .AuthMethod .BootstrapFileMode }}
+
+chmod {{ .BootstrapFileMode }} /consul/connect-inject/acl-token{{ if .MultiPort }}-{{ .ServiceName }}{{ end }}
+{{- end }}
+{{- if not .AgentlessMode }}
+{{- if .WaitForServices }}
+
+# Wait for the services this pod depends on to be registered.
+deadline=$(( $(date +%s) + {{ .WaitForTimeout }} ))
+for service in{{ range .WaitForServices }} {{ . }}{{ end }}; do
+  until {{ .ConsulBinaryPath }} catalog services \
+    {{- if .AuthMethod }}
+    {{- if .MultiPort }}
+    -token-file="/consul/connect-inject/acl-token-{{ .ServiceName }}" \
+    {{- else }}
+    -token-file="/consul/connect-inject/acl-token" \
+    {{- end }}
+    {{- end }}
+    {{- if .ConsulPartition }}
+    -partition="{{ .ConsulPartition }}" \
+    {{- end }}
+    {{- if .ConsulNamespace }}
+    -namespace="{{ .ConsulNamespace }}" \
+    {{- end }}
+    | grep -qx "${service}"; do
+    if [ "$(date +%s)" -ge "${deadline}" ]; then
+      echo "Timed out waiting for service ${service} to be registered" >&2
+      exit 1
+    fi
+    sleep 2
+  done
+done
+{{- end }}
 
 # Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
+{{ .ConsulBinaryPath }} connect envoy \
+  {{- if .GatewayKind }}
+  -gateway={{ .GatewayKind }} \
+  {{- end }}
   {{- if .MultiPort }}
   -proxy-id="$(cat /consul/connect-inject/proxyid-{{.ServiceName}})" \
   {{- else }}
@@ -467,10 +840,14 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
   {{- if .ConsulNamespace }}
   -namespace="{{ .ConsulNamespace }}" \
   {{- end }}
-  {{- if .MultiPort }}
-  -admin-bind=127.0.0.1:{{ .EnvoyAdminPort }} \
+  {{- if .EnvoyAdminBindAddress }}
+  -admin-bind={{ .EnvoyAdminBindAddress }}:{{ .EnvoyAdminPort }} \
   {{- end }}
   -bootstrap > {{ if .MultiPort }}/consul/connect-inject/envoy-bootstrap-{{.ServiceName}}.yaml{{ else }}/consul/connect-inject/envoy-bootstrap.yaml{{ end }}
+{{- if .BootstrapFileMode }}
+chmod {{ .BootstrapFileMode }} {{ if .MultiPort }}/consul/connect-inject/envoy-bootstrap-{{.ServiceName}}.yaml{{ else }}/consul/connect-inject/envoy-bootstrap.yaml{{ end }}
+{{- end }}
+{{- end }}
 
 
 {{- if .EnableTransparentProxy }}
@@ -479,7 +856,7 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
        in the rendered template between this and the previous commands. */}}
 
 # Apply traffic redirection rules.
-/consul/connect-inject/consul connect redirect-traffic \
+{{ .ConsulBinaryPath }} connect redirect-traffic \
   {{- if .AuthMethod }}
   -token-file="/consul/connect-inject/acl-token" \
   {{- end }}
@@ -492,12 +869,18 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
   {{- if .ConsulDNSClusterIP }}
   -consul-dns-ip="{{ .ConsulDNSClusterIP }}" \
   {{- end }}
+  {{- if .TProxyNetNS }}
+  -netns="{{ .TProxyNetNS }}" \
+  {{- end }}
   {{- range .TProxyExcludeInboundPorts }}
   -exclude-inbound-port="{{ . }}" \
   {{- end }}
   {{- range .TProxyExcludeOutboundPorts }}
   -exclude-outbound-port="{{ . }}" \
   {{- end }}
+  {{- range .TProxyExcludeInboundCIDRs }}
+  -exclude-inbound-cidr="{{ . }}" \
+  {{- end }}
   {{- range .TProxyExcludeOutboundCIDRs }}
   -exclude-outbound-cidr="{{ . }}" \
   {{- end }}
@@ -142,6 +142,33 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
 			"",
 			"",
 		},
+		{
+			"When metrics are enabled but merging is disabled by annotation, doesn't configure the merged metrics server",
+			func(pod *corev1.Pod) *corev1.Pod {
+				// Merging is enabled by default and every other condition to
+				// run the merged metrics server holds, so only the annotation
+				// stops -prometheus-backend-port from being rendered.
+				pod.Annotations[annotationService] = "web"
+				pod.Annotations[annotationEnableMetrics] = "true"
+				pod.Annotations[annotationEnableMetricsMerging] = "false"
+				pod.Annotations[annotationServiceMetricsPort] = "1234"
+				return pod
+			},
+			MeshWebhook{
+				ConsulAPITimeout: 5 * time.Second,
+				MetricsConfig: MetricsConfig{
+					DefaultEnableMetrics:        true,
+					DefaultEnableMetricsMerging: true,
+					DefaultMergedMetricsPort:    "20100",
+				},
+			},
+			`# Generate the envoy bootstrap code
+/consul/connect-inject/consul connect envoy \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`,
+			"-prometheus-backend-port",
+			"",
+		},
 		{
 			"When providing Prometheus TLS config, missing CA gives an error",
 			func(pod *corev1.Pod) *corev1.Pod {
@@ -320,6 +347,22 @@ func TestHandlerContainerInit_transparentProxy(t *testing.T) {
   -exclude-outbound-port="9090" \
   -exclude-outbound-port="9091" \
   -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -proxy-uid=5995`,
+			"",
+			nil,
+		},
+		"exclude-dns annotation is provided, cni disabled": {
+			true,
+			false,
+			map[string]string{
+				keyTransparentProxy:                  "true",
+				annotationTProxyExcludeOutboundPorts: "9090",
+				annotationTProxyExcludeDNS:           "true",
+			},
+			`/consul/connect-inject/consul connect redirect-traffic \
+  -exclude-outbound-port="9090" \
+  -exclude-outbound-port="53" \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
   -proxy-uid=5995`,
 			"",
 			nil,
@@ -335,6 +378,21 @@ func TestHandlerContainerInit_transparentProxy(t *testing.T) {
   -exclude-outbound-cidr="1.1.1.1" \
   -exclude-outbound-cidr="2.2.2.2/24" \
   -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -proxy-uid=5995`,
+			"",
+			nil,
+		},
+		"exclude-inbound-cidrs annotation is provided, cni disabled": {
+			true,
+			false,
+			map[string]string{
+				keyTransparentProxy:                 "true",
+				annotationTProxyExcludeInboundCIDRs: "3.3.3.3,4.4.4.4/24",
+			},
+			`/consul/connect-inject/consul connect redirect-traffic \
+  -exclude-inbound-cidr="3.3.3.3" \
+  -exclude-inbound-cidr="4.4.4.4/24" \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
   -proxy-uid=5995`,
 			"",
 			nil,
@@ -354,6 +412,31 @@ func TestHandlerContainerInit_transparentProxy(t *testing.T) {
 			"",
 			nil,
 		},
+		"netns annotation is provided, cni disabled": {
+			true,
+			false,
+			map[string]string{
+				keyTransparentProxy:   "true",
+				annotationTProxyNetNS: "/var/run/netns/cni-1234",
+			},
+			`/consul/connect-inject/consul connect redirect-traffic \
+  -netns="/var/run/netns/cni-1234" \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -proxy-uid=5995`,
+			"",
+			nil,
+		},
+		"netns annotation is provided, cni enabled": {
+			true,
+			true,
+			map[string]string{
+				keyTransparentProxy:   "true",
+				annotationTProxyNetNS: "/var/run/netns/cni-1234",
+			},
+			"",
+			`-netns="/var/run/netns/cni-1234"`,
+			nil,
+		},
 		"disabled globally, ns enabled, annotation not set, cni disabled": {
 			false,
 			false,
@@ -446,6 +529,430 @@ func TestHandlerContainerInit_transparentProxy(t *testing.T) {
 	}
 }
 
+func TestHandlerContainerInit_invalidTProxyNetNS(t *testing.T) {
+	cases := []string{
+		"var/run/netns/cni-1234",
+		"/var/run/netns/../cni-1234",
+		"/var/run/netns/cni-1234/",
+		"/var/run/netns/cni 1234",
+		`/var/run/netns/cni-1234" -proxy-uid="0`,
+	}
+	for _, raw := range cases {
+		t.Run(raw, func(t *testing.T) {
+			w := MeshWebhook{
+				EnableTransparentProxy: true,
+				ConsulAPITimeout:       5 * time.Second,
+			}
+			pod := minimal()
+			pod.Annotations[annotationTProxyNetNS] = raw
+
+			_, err := w.containerInit(testNS, *pod, multiPortInfo{})
+			require.EqualError(t, err, fmt.Sprintf("%s annotation value %q is invalid: must be a clean absolute path", annotationTProxyNetNS, raw))
+		})
+	}
+}
+
+func TestHandlerContainerInit_transparentProxyUnprivileged(t *testing.T) {
+	w := MeshWebhook{
+		EnableTransparentProxy: true,
+		ConsulAPITimeout:       5 * time.Second,
+		TProxyNonPrivileged:    true,
+	}
+	pod := minimal()
+
+	container, err := w.containerInit(testNS, *pod, multiPortInfo{})
+	require.NoError(t, err)
+
+	expectedSecurityContext := &corev1.SecurityContext{
+		RunAsUser:                pointer.Int64(0),
+		RunAsGroup:               pointer.Int64(0),
+		RunAsNonRoot:             pointer.Bool(false),
+		Privileged:               pointer.Bool(false),
+		AllowPrivilegeEscalation: pointer.Bool(false),
+		Capabilities: &corev1.Capabilities{
+			Add: []corev1.Capability{netAdminCapability, netRawCapability},
+		},
+	}
+	require.Equal(t, expectedSecurityContext, container.SecurityContext)
+	require.Contains(t, strings.Join(container.Command, " "), "/consul/connect-inject/consul connect redirect-traffic")
+}
+
+func TestHandlerContainerInit_skipCopyContainer(t *testing.T) {
+	cases := map[string]struct {
+		skipCopyContainer bool
+		consulBinaryPath  string
+		expBinaryPath     string
+	}{
+		"copy container": {
+			skipCopyContainer: false,
+			expBinaryPath:     "/consul/connect-inject/consul",
+		},
+		"copy container ignores custom binary path": {
+			skipCopyContainer: false,
+			consulBinaryPath:  "/usr/local/bin/consul",
+			expBinaryPath:     "/consul/connect-inject/consul",
+		},
+		"skip copy container": {
+			skipCopyContainer: true,
+			expBinaryPath:     "/bin/consul",
+		},
+		"skip copy container with custom binary path": {
+			skipCopyContainer: true,
+			consulBinaryPath:  "/usr/local/bin/consul",
+			expBinaryPath:     "/usr/local/bin/consul",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				EnableTransparentProxy: true,
+				SkipCopyContainer:      c.skipCopyContainer,
+				ConsulBinaryPath:       c.consulBinaryPath,
+				ConsulAPITimeout:       5 * time.Second,
+			}
+			pod := minimal()
+
+			container, err := w.containerInit(testNS, *pod, multiPortInfo{})
+			require.NoError(t, err)
+			actual := strings.Join(container.Command, " ")
+			require.Contains(t, actual, c.expBinaryPath+" connect envoy")
+			require.Contains(t, actual, c.expBinaryPath+" connect redirect-traffic")
+		})
+	}
+}
+
+func TestHandlerContainerInit_agentlessMode(t *testing.T) {
+	render := func(agentless bool) string {
+		w := MeshWebhook{
+			EnableTransparentProxy: true,
+			ConsulAPITimeout:       5 * time.Second,
+			AgentlessMode:          agentless,
+		}
+		pod := minimal()
+
+		container, err := w.containerInit(testNS, *pod, multiPortInfo{})
+		require.NoError(t, err)
+		return strings.Join(container.Command, " ")
+	}
+
+	agentful := render(false)
+	agentless := render(true)
+
+	// Without agents, the sidecar bootstraps Envoy itself, so only the bootstrap step is removed.
+	bootstrap := `
+
+# Generate the envoy bootstrap code
+/consul/connect-inject/consul connect envoy \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`
+	require.Contains(t, agentful, bootstrap)
+	require.NotContains(t, agentless, "connect envoy")
+	require.Equal(t, strings.Replace(agentful, bootstrap, "", 1), agentless)
+	require.Contains(t, agentless, "consul-k8s-control-plane connect-init")
+	require.Contains(t, agentless, "/consul/connect-inject/consul connect redirect-traffic")
+}
+
+func TestHandlerContainerInit_gatewayKind(t *testing.T) {
+	cases := map[string]struct {
+		annotations map[string]string
+		expCmd      string
+		expErr      string
+	}{
+		"sidecar": {
+			annotations: map[string]string{annotationGatewayKind: "sidecar"},
+			expCmd: `
+# Generate the envoy bootstrap code
+/consul/connect-inject/consul connect envoy \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`,
+		},
+		"mesh gateway": {
+			annotations: map[string]string{annotationGatewayKind: "mesh-gateway"},
+			expCmd: `
+# Generate the envoy bootstrap code
+/consul/connect-inject/consul connect envoy \
+  -gateway=mesh \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`,
+		},
+		"mesh gateway with service name": {
+			annotations: map[string]string{annotationGatewayKind: "mesh-gateway", annotationService: "mesh-gateway-dc1"},
+			expCmd: `
+# Generate the envoy bootstrap code
+/consul/connect-inject/consul connect envoy \
+  -gateway=mesh \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`,
+		},
+		"terminating gateway": {
+			annotations: map[string]string{annotationGatewayKind: "terminating-gateway"},
+			expCmd:      "-gateway=terminating \\\n  -proxy-id=\"$(cat /consul/connect-inject/proxyid)\" \\",
+		},
+		"invalid kind": {
+			annotations: map[string]string{annotationGatewayKind: "api-gateway"},
+			expErr:      "consul.hashicorp.com/gateway-kind annotation value \"api-gateway\" is invalid",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				ConsulAPITimeout: 5 * time.Second,
+			}
+			pod := minimal()
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+
+			container, err := w.containerInit(testNS, *pod, multiPortInfo{})
+			if c.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			actual := strings.Join(container.Command, " ")
+			require.Contains(t, actual, c.expCmd)
+			// The gateway is registered by the endpoints controller, so connect-init must find it and
+			// consul connect envoy must not register another instance.
+			require.Contains(t, actual, "consul-k8s-control-plane connect-init")
+			require.NotContains(t, actual, "-register")
+		})
+	}
+}
+
+func TestHandlerNeedsCopyContainer(t *testing.T) {
+	cases := map[string]struct {
+		webhook MeshWebhook
+		exp     bool
+	}{
+		"default": {
+			webhook: MeshWebhook{},
+			exp:     true,
+		},
+		"skip copy container": {
+			webhook: MeshWebhook{SkipCopyContainer: true},
+			exp:     false,
+		},
+		"agentless": {
+			webhook: MeshWebhook{AgentlessMode: true},
+			exp:     false,
+		},
+		"agentless with transparent proxy": {
+			webhook: MeshWebhook{AgentlessMode: true, EnableTransparentProxy: true},
+			exp:     true,
+		},
+		"agentless with transparent proxy and CNI": {
+			webhook: MeshWebhook{AgentlessMode: true, EnableTransparentProxy: true, EnableCNI: true},
+			exp:     false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual, err := c.webhook.needsCopyContainer(testNS, *minimal())
+			require.NoError(t, err)
+			require.Equal(t, c.exp, actual)
+		})
+	}
+}
+
+func TestHandlerContainerInit_connectInitPollTimeout(t *testing.T) {
+	cases := map[string]struct {
+		pollTimeout time.Duration
+		expFlag     string
+	}{
+		"default": {
+			pollTimeout: 0,
+			expFlag:     "",
+		},
+		"poll timeout": {
+			pollTimeout: 30 * time.Second,
+			expFlag:     "-poll-timeout=30s",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				ConsulAPITimeout:       5 * time.Second,
+				ConnectInitPollTimeout: c.pollTimeout,
+			}
+			pod := minimal()
+
+			container, err := w.containerInit(testNS, *pod, multiPortInfo{})
+			require.NoError(t, err)
+			actual := strings.Join(container.Command, " ")
+			if c.expFlag == "" {
+				require.NotContains(t, actual, "-poll-timeout")
+				return
+			}
+			require.Contains(t, actual, `
+consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
+  -consul-api-timeout=5s \
+  `+c.expFlag+` \`)
+		})
+	}
+}
+
+func TestHandlerContainerInit_connectInitLogLevel(t *testing.T) {
+	cases := map[string]struct {
+		logLevel    string
+		annotations map[string]string
+		expFlag     string
+		expErr      string
+	}{
+		"default": {
+			expFlag: "",
+		},
+		"log level set on webhook": {
+			logLevel: "debug",
+			expFlag:  "-log-level=debug",
+		},
+		"log level set by annotation": {
+			annotations: map[string]string{annotationConnectInitLogLevel: "trace"},
+			expFlag:     "-log-level=trace",
+		},
+		"annotation overrides webhook": {
+			logLevel:    "warn",
+			annotations: map[string]string{annotationConnectInitLogLevel: "debug"},
+			expFlag:     "-log-level=debug",
+		},
+		"invalid log level on webhook": {
+			logLevel: "verbose",
+			expErr:   `connect-init log level "verbose" is invalid: must be one of trace, debug, info, warn, error`,
+		},
+		"invalid annotation": {
+			logLevel:    "info",
+			annotations: map[string]string{annotationConnectInitLogLevel: "DEBUG"},
+			expErr:      `consul.hashicorp.com/connect-init-log-level annotation value "DEBUG" is invalid: must be one of trace, debug, info, warn, error`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				ConsulAPITimeout:    5 * time.Second,
+				ConnectInitLogLevel: c.logLevel,
+			}
+			pod := minimal()
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+
+			container, err := w.containerInit(testNS, *pod, multiPortInfo{})
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			actual := strings.Join(container.Command, " ")
+			if c.expFlag == "" {
+				require.NotContains(t, actual, "-log-level")
+				return
+			}
+			require.Contains(t, actual, `
+consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
+  -consul-api-timeout=5s \
+  `+c.expFlag+` \`)
+		})
+	}
+}
+
+func TestHandlerContainerInit_extraVolumeMounts(t *testing.T) {
+	cases := map[string]struct {
+		mounts []corev1.VolumeMount
+		expErr string
+	}{
+		"no extra mounts": {},
+		"extra mounts": {
+			mounts: []corev1.VolumeMount{
+				{Name: "envoy-bootstrap-template", MountPath: "/consul/bootstrap", ReadOnly: true},
+				{Name: "ca-bundle", MountPath: "/etc/ssl/custom"},
+			},
+		},
+		"duplicate names": {
+			mounts: []corev1.VolumeMount{
+				{Name: "ca-bundle", MountPath: "/etc/ssl/custom"},
+				{Name: "ca-bundle", MountPath: "/etc/ssl/other"},
+			},
+			expErr: `extra init container volume mount "ca-bundle" is not unique`,
+		},
+		"shared volume": {
+			mounts: []corev1.VolumeMount{
+				{Name: volumeName, MountPath: "/consul/other"},
+			},
+			expErr: `extra init container volume mount "consul-connect-inject-data" collides with the connect-inject volume`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				ConsulAPITimeout:               5 * time.Second,
+				InitContainerExtraVolumeMounts: c.mounts,
+			}
+			pod := minimal()
+
+			container, err := w.containerInit(testNS, *pod, multiPortInfo{})
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			expMounts := append([]corev1.VolumeMount{{Name: volumeName, MountPath: "/consul/connect-inject"}}, c.mounts...)
+			require.Equal(t, expMounts, container.VolumeMounts)
+		})
+	}
+}
+
+func TestHandlerContainerInit_defaultExcludeUIDs(t *testing.T) {
+	cases := map[string]struct {
+		defaultUIDs []string
+		annotation  string
+		expectedCmd string
+	}{
+		"defaults only": {
+			defaultUIDs: []string{"6000", "7000"},
+			expectedCmd: `/consul/connect-inject/consul connect redirect-traffic \
+  -exclude-uid="6000" \
+  -exclude-uid="7000" \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -proxy-uid=5995`,
+		},
+		"annotation only": {
+			annotation: "8000",
+			expectedCmd: `/consul/connect-inject/consul connect redirect-traffic \
+  -exclude-uid="8000" \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -proxy-uid=5995`,
+		},
+		"defaults and annotation are merged and de-duplicated": {
+			defaultUIDs: []string{"6000", "7000"},
+			annotation:  "7000,8000",
+			expectedCmd: `/consul/connect-inject/consul connect redirect-traffic \
+  -exclude-uid="6000" \
+  -exclude-uid="7000" \
+  -exclude-uid="8000" \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -proxy-uid=5995`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				EnableTransparentProxy:   true,
+				TProxyDefaultExcludeUIDs: c.defaultUIDs,
+				ConsulAPITimeout:         5 * time.Second,
+			}
+			pod := minimal()
+			if c.annotation != "" {
+				pod.Annotations[annotationTProxyExcludeUIDs] = c.annotation
+			}
+
+			container, err := w.containerInit(testNS, *pod, multiPortInfo{})
+			require.NoError(t, err)
+			actualCmd := strings.Join(container.Command, " ")
+			require.Contains(t, actualCmd, c.expectedCmd)
+		})
+	}
+}
+
 func TestHandlerContainerInit_consulDNS(t *testing.T) {
 	cases := map[string]struct {
 		globalEnabled       bool
@@ -705,7 +1212,7 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
 		{
 			"Whole template, auth method, non-default namespace, mirroring disabled, default partition",
 			func(pod *corev1.Pod) *corev1.Pod {
-				pod.Annotations[annotationService] = ""
+				delete(pod.Annotations, annotationService)
 				return pod
 			},
 			MeshWebhook{
@@ -722,7 +1229,6 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
   -consul-api-timeout=5s \
   -acl-auth-method="auth-method" \
   -service-account-name="web" \
-  -service-name="" \
   -bearer-token-file=/var/run/secrets/kubernetes.io/serviceaccount/token \
   -auth-method-namespace="non-default" \
   -partition="default" \
@@ -734,12 +1240,80 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
   -token-file="/consul/connect-inject/acl-token" \
   -partition="default" \
   -namespace="non-default" \
+  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`,
+		},
+		{
+			"Whole template, auth method, namespace overridden by annotation, mirroring disabled, default partition",
+			func(pod *corev1.Pod) *corev1.Pod {
+				delete(pod.Annotations, annotationService)
+				pod.Annotations[annotationConsulNamespace] = "team-a"
+				return pod
+			},
+			MeshWebhook{
+				AuthMethod:                 "auth-method",
+				EnableNamespaces:           true,
+				ConsulDestinationNamespace: "non-default",
+				ConsulPartition:            "default",
+				ConsulAPITimeout:           5 * time.Second,
+			},
+			`/bin/sh -ec 
+export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
+export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
+consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
+  -consul-api-timeout=5s \
+  -acl-auth-method="auth-method" \
+  -service-account-name="web" \
+  -bearer-token-file=/var/run/secrets/kubernetes.io/serviceaccount/token \
+  -auth-method-namespace="non-default" \
+  -partition="default" \
+  -consul-service-namespace="team-a" \
+
+# Generate the envoy bootstrap code
+/consul/connect-inject/consul connect envoy \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -token-file="/consul/connect-inject/acl-token" \
+  -partition="default" \
+  -namespace="team-a" \
+  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`,
+		},
+		{
+			"Whole template, auth method, non-default namespace, partition overridden by annotation",
+			func(pod *corev1.Pod) *corev1.Pod {
+				delete(pod.Annotations, annotationService)
+				pod.Annotations[annotationConsulPartition] = "team-a"
+				return pod
+			},
+			MeshWebhook{
+				AuthMethod:                 "auth-method",
+				EnableNamespaces:           true,
+				ConsulDestinationNamespace: "non-default",
+				ConsulPartition:            "default",
+				ConsulAPITimeout:           5 * time.Second,
+			},
+			`/bin/sh -ec 
+export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
+export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
+consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
+  -consul-api-timeout=5s \
+  -acl-auth-method="auth-method" \
+  -service-account-name="web" \
+  -bearer-token-file=/var/run/secrets/kubernetes.io/serviceaccount/token \
+  -auth-method-namespace="non-default" \
+  -partition="team-a" \
+  -consul-service-namespace="non-default" \
+
+# Generate the envoy bootstrap code
+/consul/connect-inject/consul connect envoy \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -token-file="/consul/connect-inject/acl-token" \
+  -partition="team-a" \
+  -namespace="non-default" \
   -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`,
 		},
 		{
 			"Whole template, auth method, non-default namespace, mirroring enabled, non-default partition",
 			func(pod *corev1.Pod) *corev1.Pod {
-				pod.Annotations[annotationService] = ""
+				delete(pod.Annotations, annotationService)
 				return pod
 			},
 			MeshWebhook{
@@ -757,7 +1331,6 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
   -consul-api-timeout=5s \
   -acl-auth-method="auth-method" \
   -service-account-name="web" \
-  -service-name="" \
   -bearer-token-file=/var/run/secrets/kubernetes.io/serviceaccount/token \
   -auth-method-namespace="default" \
   -partition="non-default" \
@@ -1077,6 +1650,60 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
 	}
 }
 
+func TestHandlerContainerInit_MultiportInvalid(t *testing.T) {
+	pod := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					annotationService: "web,web-admin",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name: "web",
+						VolumeMounts: []corev1.VolumeMount{
+							{
+								Name:      "service-account-secret",
+								MountPath: "/var/run/secrets/kubernetes.io/serviceaccount",
+							},
+						},
+					},
+				},
+				ServiceAccountName: "web",
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		webhook MeshWebhook
+		mpi     multiPortInfo
+		expErr  string
+	}{
+		"service index out of range": {
+			webhook: MeshWebhook{ConsulAPITimeout: 5 * time.Second},
+			mpi:     multiPortInfo{serviceIndex: 2, serviceName: "web-metrics"},
+			expErr:  `multi port service index 2 for service "web-metrics" is out of range: annotation "consul.hashicorp.com/connect-service" lists 2 service(s)`,
+		},
+		"service name does not match index": {
+			webhook: MeshWebhook{ConsulAPITimeout: 5 * time.Second},
+			mpi:     multiPortInfo{serviceIndex: 0, serviceName: "web-admin"},
+			expErr:  `multi port service "web-admin" does not match service "web" at index 0 of annotation "consul.hashicorp.com/connect-service"`,
+		},
+		"service account volume missing with auth method": {
+			webhook: MeshWebhook{AuthMethod: "auth-method", ConsulAPITimeout: 5 * time.Second},
+			mpi:     multiPortInfo{serviceIndex: 1, serviceName: "web-admin"},
+			expErr:  `multi port service "web-admin" requires a service account named "web-admin": volume "web-admin-service-account" not found on pod`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := c.webhook.containerInit(testNS, *pod(), c.mpi)
+			require.EqualError(t, err, c.expErr)
+		})
+	}
+}
+
 func TestHandlerContainerInit_authMethod(t *testing.T) {
 	require := require.New(t)
 	w := MeshWebhook{
@@ -1121,6 +1748,43 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
   -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`)
 }
 
+func TestHandlerContainerInit_authMethodEmptyServiceName(t *testing.T) {
+	w := MeshWebhook{
+		AuthMethod:       "release-name-consul-k8s-auth-method",
+		ConsulAPITimeout: 5 * time.Second,
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				annotationService: "",
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "web",
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "default-token-podid",
+							ReadOnly:  true,
+							MountPath: "/var/run/secrets/kubernetes.io/serviceaccount",
+						},
+					},
+				},
+			},
+			ServiceAccountName: "web",
+		},
+	}
+	_, err := w.containerInit(testNS, *pod, multiPortInfo{})
+	require.EqualError(t, err, "consul.hashicorp.com/connect-service annotation must not be empty when an auth method is set: remove it to use the name of the Kubernetes service")
+
+	// Without the annotation, -service-name isn't passed so that connect-init uses the registered service's name.
+	delete(pod.Annotations, annotationService)
+	container, err := w.containerInit(testNS, *pod, multiPortInfo{})
+	require.NoError(t, err)
+	require.NotContains(t, strings.Join(container.Command, " "), "-service-name")
+}
+
 // If Consul CA cert is set,
 // Consul addresses should use HTTPS
 // and CA cert should be set as env variable.
@@ -1160,6 +1824,387 @@ export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"`)
 }
 
+func TestHandlerContainerInit_ConsulGRPCTLS(t *testing.T) {
+	enabled, disabled := true, false
+	cases := map[string]struct {
+		caCert    string
+		grpcTLS   *bool
+		expHTTP   string
+		expGRPC   string
+		expCACert bool
+	}{
+		"TLS follows HTTP without a CA cert": {
+			expHTTP: `export CONSUL_HTTP_ADDR="${HOST_IP}:8500"`,
+			expGRPC: `export CONSUL_GRPC_ADDR="${HOST_IP}:8502"`,
+		},
+		"TLS follows HTTP with a CA cert": {
+			caCert:    "consul-ca-cert",
+			expHTTP:   `export CONSUL_HTTP_ADDR="https://${HOST_IP}:8501"`,
+			expGRPC:   `export CONSUL_GRPC_ADDR="https://${HOST_IP}:8502"`,
+			expCACert: true,
+		},
+		"HTTPS with plaintext gRPC": {
+			caCert:    "consul-ca-cert",
+			grpcTLS:   &disabled,
+			expHTTP:   `export CONSUL_HTTP_ADDR="https://${HOST_IP}:8501"`,
+			expGRPC:   `export CONSUL_GRPC_ADDR="${HOST_IP}:8502"`,
+			expCACert: true,
+		},
+		"HTTP with gRPC over TLS": {
+			grpcTLS: &enabled,
+			expHTTP: `export CONSUL_HTTP_ADDR="${HOST_IP}:8500"`,
+			expGRPC: `export CONSUL_GRPC_ADDR="https://${HOST_IP}:8502"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				ConsulCACert:     c.caCert,
+				ConsulGRPCTLS:    c.grpcTLS,
+				ConsulAPITimeout: 5 * time.Second,
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotationService: "foo",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+						},
+					},
+				},
+			}
+			container, err := w.containerInit(testNS, *pod, multiPortInfo{})
+			require.NoError(t, err)
+			actual := strings.Join(container.Command, " ")
+			require.Contains(t, actual, c.expHTTP+"\n"+c.expGRPC+"\n")
+			if c.expCACert {
+				require.Contains(t, actual, "export CONSUL_CACERT=/consul/connect-inject/consul-ca.pem")
+			} else {
+				require.NotContains(t, actual, "CONSUL_CACERT")
+			}
+		})
+	}
+}
+
+func TestHandlerContainerInit_HTTPProxy(t *testing.T) {
+	cases := map[string]struct {
+		httpProxy  string
+		noProxy    string
+		expEnv     []corev1.EnvVar
+		expCommand string
+	}{
+		"no proxy": {
+			expEnv: []corev1.EnvVar{},
+		},
+		"proxy": {
+			httpProxy: "http://proxy.example.com:3128",
+			expEnv: []corev1.EnvVar{
+				{Name: "HTTP_PROXY", Value: "http://proxy.example.com:3128"},
+				{Name: "HTTPS_PROXY", Value: "http://proxy.example.com:3128"},
+				{Name: "NO_PROXY", Value: "$(HOST_IP)"},
+			},
+			expCommand: `export HTTP_PROXY="http://proxy.example.com:3128"
+export HTTPS_PROXY="http://proxy.example.com:3128"
+export NO_PROXY="${HOST_IP}"
+`,
+		},
+		"proxy with no proxy list": {
+			httpProxy: "http://proxy.example.com:3128",
+			noProxy:   "10.0.0.0/8, .cluster.local,,",
+			expEnv: []corev1.EnvVar{
+				{Name: "HTTP_PROXY", Value: "http://proxy.example.com:3128"},
+				{Name: "HTTPS_PROXY", Value: "http://proxy.example.com:3128"},
+				{Name: "NO_PROXY", Value: "$(HOST_IP),10.0.0.0/8,.cluster.local"},
+			},
+			expCommand: `export HTTP_PROXY="http://proxy.example.com:3128"
+export HTTPS_PROXY="http://proxy.example.com:3128"
+export NO_PROXY="${HOST_IP},10.0.0.0/8,.cluster.local"
+`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				ConsulHTTPProxy:  c.httpProxy,
+				ConsulNoProxy:    c.noProxy,
+				ConsulAPITimeout: 5 * time.Second,
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotationService: "foo",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+						},
+					},
+				},
+			}
+			container, err := w.containerInit(testNS, *pod, multiPortInfo{})
+			require.NoError(t, err)
+			// HOST_IP must be defined before NO_PROXY refers to it.
+			require.Equal(t, "HOST_IP", container.Env[0].Name)
+			require.Equal(t, c.expEnv, container.Env[4:])
+			actual := strings.Join(container.Command, " ")
+			if c.expCommand != "" {
+				require.Contains(t, actual, c.expCommand)
+			} else {
+				require.NotContains(t, actual, "PROXY")
+			}
+		})
+	}
+}
+
+func TestHandlerContainerInit_EnvoyAdminBindAddress(t *testing.T) {
+	cases := map[string]struct {
+		bindAddress string
+		multiPort   bool
+		expFlag     string
+	}{
+		"single port, default": {},
+		"single port, configured": {
+			bindAddress: "0.0.0.0",
+			expFlag:     "-admin-bind=0.0.0.0:19000",
+		},
+		"multi port, default": {
+			multiPort: true,
+			expFlag:   "-admin-bind=127.0.0.1:19001",
+		},
+		"multi port, configured": {
+			bindAddress: "0.0.0.0",
+			multiPort:   true,
+			expFlag:     "-admin-bind=0.0.0.0:19001",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				EnvoyAdminBindAddress: c.bindAddress,
+				ConsulAPITimeout:      5 * time.Second,
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotationService: "web",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+						},
+					},
+				},
+			}
+			mpi := multiPortInfo{}
+			if c.multiPort {
+				pod.Annotations[annotationService] = "web,web-admin"
+				mpi = multiPortInfo{serviceIndex: 1, serviceName: "web-admin"}
+			}
+			container, err := w.containerInit(testNS, *pod, mpi)
+			require.NoError(t, err)
+			actual := strings.Join(container.Command, " ")
+			if c.expFlag != "" {
+				require.Contains(t, actual, c.expFlag)
+			} else {
+				require.NotContains(t, actual, "-admin-bind")
+			}
+		})
+	}
+}
+
+func TestHandlerContainerInit_BootstrapFileMode(t *testing.T) {
+	cases := map[string]struct {
+		mode       string
+		authMethod string
+		multiPort  bool
+		expLines   []string
+		expErr     string
+	}{
+		"not set": {},
+		"set": {
+			mode:     "0640",
+			expLines: []string{"chmod 0640 /consul/connect-inject/envoy-bootstrap.yaml"},
+		},
+		"set with auth method": {
+			mode:       "640",
+			authMethod: "auth-method",
+			expLines: []string{
+				"chmod 640 /consul/connect-inject/acl-token\n",
+				"chmod 640 /consul/connect-inject/envoy-bootstrap.yaml",
+			},
+		},
+		"set with auth method, multi port": {
+			mode:       "0600",
+			authMethod: "auth-method",
+			multiPort:  true,
+			expLines: []string{
+				"chmod 0600 /consul/connect-inject/acl-token-web-admin\n",
+				"chmod 0600 /consul/connect-inject/envoy-bootstrap-web-admin.yaml",
+			},
+		},
+		"invalid": {
+			mode:   "u+rw",
+			expErr: `bootstrap file mode "u+rw" is invalid: must be an octal file mode such as "0640"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				BootstrapFileMode: c.mode,
+				AuthMethod:        c.authMethod,
+				ConsulAPITimeout:  5 * time.Second,
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotationService: "web",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{
+						{
+							Name: "web-admin-service-account",
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "service-account-secret",
+									MountPath: "/var/run/secrets/kubernetes.io/serviceaccount",
+								},
+							},
+						},
+					},
+					ServiceAccountName: "web",
+				},
+			}
+			mpi := multiPortInfo{}
+			if c.multiPort {
+				pod.Annotations[annotationService] = "web,web-admin"
+				mpi = multiPortInfo{serviceIndex: 1, serviceName: "web-admin"}
+			}
+			container, err := w.containerInit(testNS, *pod, mpi)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			actual := strings.Join(container.Command, " ")
+			if len(c.expLines) == 0 {
+				require.NotContains(t, actual, "chmod")
+			}
+			for _, line := range c.expLines {
+				require.Contains(t, actual, line)
+			}
+		})
+	}
+}
+
+func TestHandlerContainerInit_WaitFor(t *testing.T) {
+	cases := map[string]struct {
+		annotation string
+		authMethod string
+		timeout    time.Duration
+		expCommand string
+		expErr     string
+	}{
+		"not set": {},
+		"services": {
+			annotation: "db, cache",
+			expCommand: `
+# Wait for the services this pod depends on to be registered.
+deadline=$(( $(date +%s) + 300 ))
+for service in db cache; do
+  until /consul/connect-inject/consul catalog services \
+    | grep -qx "${service}"; do
+    if [ "$(date +%s)" -ge "${deadline}" ]; then
+      echo "Timed out waiting for service ${service} to be registered" >&2
+      exit 1
+    fi
+    sleep 2
+  done
+done
+
+# Generate the envoy bootstrap code`,
+		},
+		"services with auth method": {
+			annotation: "db",
+			authMethod: "auth-method",
+			expCommand: `
+for service in db; do
+  until /consul/connect-inject/consul catalog services \
+    -token-file="/consul/connect-inject/acl-token" \
+    | grep -qx "${service}"; do`,
+		},
+		"custom timeout": {
+			annotation: "db",
+			timeout:    30 * time.Second,
+			expCommand: `
+deadline=$(( $(date +%s) + 30 ))
+for service in db; do`,
+		},
+		"invalid service name": {
+			annotation: "db;reboot",
+			expErr:     `consul.hashicorp.com/connect-inject-wait-for annotation value "db;reboot" is invalid: service name "db;reboot" must contain only alphanumeric characters, dashes or underscores`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				AuthMethod:       c.authMethod,
+				ConsulAPITimeout: 5 * time.Second,
+				WaitForTimeout:   c.timeout,
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotationService: "foo",
+					},
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: "foo",
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "sa",
+									MountPath: "/var/run/secrets/kubernetes.io/serviceaccount",
+								},
+							},
+						},
+					},
+				},
+			}
+			if c.annotation != "" {
+				pod.Annotations[annotationWaitFor] = c.annotation
+			}
+			container, err := w.containerInit(testNS, *pod, multiPortInfo{})
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			actual := strings.Join(container.Command, " ")
+			if c.expCommand != "" {
+				require.Contains(t, actual, c.expCommand)
+			} else {
+				require.NotContains(t, actual, "catalog services")
+			}
+		})
+	}
+}
+
 func TestHandlerContainerInit_Resources(t *testing.T) {
 	require := require.New(t)
 	w := MeshWebhook{
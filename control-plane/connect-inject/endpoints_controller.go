@@ -2,14 +2,19 @@ package connectinject
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net"
+	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cenkalti/backoff"
 	mapset "github.com/deckarep/golang-set"
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
@@ -23,10 +28,12 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
@@ -37,9 +44,15 @@ const (
 	MetaKeyKubeServiceName     = "k8s-service-name"
 	MetaKeyKubeNS              = "k8s-namespace"
 	MetaKeyManagedBy           = "managed-by"
+	MetaKeyHostIP              = "k8s-host-ip"
+	MetaKeyZone                = "zone"
+	MetaKeyProxyConfigHash     = "consul-proxy-config-hash"
+	MetaKeyLabelPrefix         = "k8s-label-"
 	TokenMetaPodNameKey        = "pod"
 	kubernetesSuccessReasonMsg = "Kubernetes health checks passing"
 	envoyPrometheusBindAddr    = "envoy_prometheus_bind_addr"
+	envoyLocalRequestTimeoutMs = "local_request_timeout_ms"
+	envoyProtocol              = "protocol"
 	envoySidecarContainer      = "envoy-sidecar"
 
 	// clusterIPTaggedAddressName is the key for the tagged address to store the service's cluster IP and service port
@@ -60,6 +73,25 @@ const (
 
 	// proxyDefaultInboundPort is the default inbound port for the proxy.
 	proxyDefaultInboundPort = 20000
+
+	// proxyDefaultAdminPort is the port of Envoy's admin API. Multi port pods use a consecutive
+	// port for each service.
+	proxyDefaultAdminPort = 19000
+
+	// defaultDeregisterCriticalServiceAfter is how long the proxy's health check may be critical
+	// before the proxy is deregistered, unless overridden by annotation.
+	defaultDeregisterCriticalServiceAfter = "10m"
+
+	// defaultServiceCheckFileTTL is the TTL of the check registered for a readiness file, unless overridden by
+	// annotation.
+	defaultServiceCheckFileTTL = "30s"
+
+	// upstreamDefaultsConfigMapKey is the key of the UpstreamDefaultsConfigMap that holds the default upstreams.
+	upstreamDefaultsConfigMapKey = "upstreams"
+
+	// labelTopologyZone is the well-known Kubernetes label that contains the zone of a node. It may also be
+	// propagated onto pods so that the zone can be determined without looking up the node.
+	labelTopologyZone = "topology.kubernetes.io/zone"
 )
 
 type EndpointsController struct {
@@ -69,9 +101,11 @@ type EndpointsController struct {
 	// ConsulClientCfg is the client config used by the ConsulClient when calling NewClient().
 	ConsulClientCfg *api.Config
 	// ConsulScheme is the scheme to use when making API calls to Consul,
-	// i.e. "http" or "https".
+	// i.e. "http" or "https". If empty, it is detected from the CONSUL_HTTP_ADDR
+	// and CONSUL_HTTP_SSL environment variables and defaults to "http".
 	ConsulScheme string
-	// ConsulPort is the port to make HTTP API calls to Consul agents on.
+	// ConsulPort is the port to make HTTP API calls to Consul agents on. If empty,
+	// it is detected from the CONSUL_HTTP_ADDR environment variable and defaults to "8500".
 	ConsulPort string
 	// Only endpoints in the AllowK8sNamespacesSet are reconciled.
 	AllowK8sNamespacesSet mapset.Set
@@ -80,6 +114,10 @@ type EndpointsController struct {
 	// EnableConsulPartitions indicates that a user is running Consul Enterprise
 	// with version 1.11+ which supports Admin Partitions.
 	EnableConsulPartitions bool
+	// ConsulPartition is the name of the Admin Partition to register services into.
+	// It is an enterprise feature requiring Consul Enterprise 1.11+.
+	// Its value is an empty string if partitions aren't enabled.
+	ConsulPartition string
 	// EnableConsulNamespaces indicates that a user is running Consul Enterprise
 	// with version 1.7+ which supports namespaces.
 	EnableConsulNamespaces bool
@@ -117,6 +155,40 @@ type EndpointsController struct {
 	// ConsulAPITimeout is the duration that the consul API client will
 	// wait for a response from the API before cancelling the request.
 	ConsulAPITimeout time.Duration
+	// NormalizeServiceName transforms the service name derived from the Kubernetes
+	// service before it is registered with Consul. If nil, the name is lowercased.
+	// Names set explicitly by the connect-service annotation are not transformed.
+	NormalizeServiceName func(name string) string
+	// WarnOnMissingUpstreams causes the controller to look up each upstream service in the
+	// Consul catalog and log a warning if it has no registered instances. It is disabled by
+	// default because it adds a catalog query per upstream to every registration.
+	WarnOnMissingUpstreams bool
+	// CopyAllLabelsToMeta causes all of the pod's labels to be copied into the meta of its service and proxy
+	// registrations. The keys are prefixed with MetaKeyLabelPrefix so that they don't collide with other meta.
+	CopyAllLabelsToMeta bool
+	// UpstreamDefaultsConfigMap optionally references a ConfigMap whose "upstreams" key holds upstreams in the
+	// format of the upstreams annotation. They are added to the upstreams of every pod, with the pod's own
+	// upstreams taking precedence. It is unset if its name is empty.
+	UpstreamDefaultsConfigMap types.NamespacedName
+	// RequeueAfterChange is how long to wait before reconciling an Endpoints object again after service instances
+	// were registered, deregistered or changed health for it. Re-registering an unchanged instance doesn't count.
+	// Reconciling again once bursty updates to the Endpoints have settled catches instances that drifted in the
+	// meantime. It is disabled if zero.
+	RequeueAfterChange time.Duration
+	// AgentConnectRetries is how many times to retry reaching the Consul agent local to a pod before registering
+	// its services, so that an agent that just restarted doesn't fail the reconcile. The agent is not checked
+	// before registering if zero.
+	AgentConnectRetries int
+	// AgentConnectRetryInterval is how long to wait between attempts to reach the Consul agent.
+	AgentConnectRetryInterval time.Duration
+	// ReconcileTimeout bounds how long a single reconcile may take, including its calls to Consul and
+	// Kubernetes, so that a stuck call can't hold a worker indefinitely. A reconcile that times out
+	// returns an error so that the Endpoints object is requeued. There is no timeout if zero.
+	ReconcileTimeout time.Duration
+	// GarbageCollectOnStartup causes the controller to deregister, once it starts, every service instance it
+	// registered whose pod no longer exists. This cleans up instances orphaned while the controller wasn't running,
+	// e.g. when an Endpoints object was deleted along with its pods.
+	GarbageCollectOnStartup bool
 
 	MetricsConfig MetricsConfig
 	Log           logr.Logger
@@ -128,6 +200,21 @@ type EndpointsController struct {
 // Reconcile reads the state of an Endpoints object for a Kubernetes Service and reconciles Consul services which
 // correspond to the Kubernetes Service. These events are driven by changes to the Pods backing the Kube service.
 func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if r.ReconcileTimeout <= 0 {
+		return r.reconcile(ctx, req)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.ReconcileTimeout)
+	defer cancel()
+	result, err := r.reconcile(ctx, req)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		r.Log.Error(err, "timed out reconciling Endpoints", "name", req.Name, "ns", req.Namespace, "timeout", r.ReconcileTimeout)
+		return ctrl.Result{}, fmt.Errorf("reconciling Endpoints %s timed out after %s: %w", req.NamespacedName, r.ReconcileTimeout, err)
+	}
+	return result, err
+}
+
+func (r *EndpointsController) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	var errs error
 	var serviceEndpoints corev1.Endpoints
 
@@ -148,8 +235,8 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 	if k8serrors.IsNotFound(err) {
 		// Deregister all instances in Consul for this service. The function deregisterServiceOnAllAgents handles
 		// the case where the Consul service name is different from the Kubernetes service name.
-		err = r.deregisterServiceOnAllAgents(ctx, req.Name, req.Namespace, nil)
-		return ctrl.Result{}, err
+		deregistered, err := r.deregisterServiceOnAllAgents(ctx, req.Name, req.Namespace, nil)
+		return r.requeueResult(deregistered), err
 	} else if err != nil {
 		r.Log.Error(err, "failed to get Endpoints", "name", req.Name, "ns", req.Namespace)
 		return ctrl.Result{}, err
@@ -162,17 +249,20 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 	if isLabeledIgnore(serviceEndpoints.Labels) {
 		// We always deregister the service to handle the case where a user has registered the service, then added the label later.
 		r.Log.Info("Ignoring endpoint labeled with `consul.hashicorp.com/service-ignore: \"true\"`", "name", req.Name, "namespace", req.Namespace)
-		err = r.deregisterServiceOnAllAgents(ctx, req.Name, req.Namespace, nil)
-		return ctrl.Result{}, err
+		deregistered, err := r.deregisterServiceOnAllAgents(ctx, req.Name, req.Namespace, nil)
+		return r.requeueResult(deregistered), err
 	}
 
 	// endpointAddressMap stores every IP that corresponds to a Pod in the Endpoints object. It is used to compare
 	// against service instances in Consul to deregister them if they are not in the map.
 	endpointAddressMap := map[string]bool{}
+	// changed records whether any service instance was newly registered or changed health.
+	var changed bool
 
 	// Register all addresses of this Endpoints object as service instances in Consul.
 	for _, subset := range serviceEndpoints.Subsets {
-		for address, healthStatus := range mapAddresses(subset) {
+		for _, subsetAddr := range subsetAddresses(subset) {
+			address := subsetAddr.address
 			if address.TargetRef != nil && address.TargetRef.Kind == "Pod" {
 				var pod corev1.Pod
 				objectKey := types.NamespacedName{Name: address.TargetRef.Name, Namespace: address.TargetRef.Namespace}
@@ -192,10 +282,20 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 
 				if hasBeenInjected(pod) {
 					endpointPods.Add(address.TargetRef.Name)
-					if err := r.registerServicesAndHealthCheck(pod, serviceEndpoints, healthStatus, endpointAddressMap); err != nil {
+					if pod.DeletionTimestamp != nil {
+						// The pod is shutting down so it shouldn't receive new traffic even though Kubernetes may
+						// not have removed it from the Endpoints yet. Its address isn't added to the
+						// endpointAddressMap so that it's deregistered below and isn't registered again while
+						// it terminates.
+						r.Log.Info("deregistering terminating pod", "name", pod.Name, "ns", pod.Namespace)
+						continue
+					}
+					registered, err := r.registerServicesAndHealthCheck(ctx, pod, serviceEndpoints, subsetAddr.ready, endpointAddressMap)
+					if err != nil {
 						r.Log.Error(err, "failed to register services or health check", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
 						errs = multierror.Append(errs, err)
 					}
+					changed = changed || registered
 				}
 			}
 		}
@@ -204,12 +304,34 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 	// Compare service instances in Consul with addresses in Endpoints. If an address is not in Endpoints, deregister
 	// from Consul. This uses endpointAddressMap which is populated with the addresses in the Endpoints object during
 	// the registration codepath.
-	if err = r.deregisterServiceOnAllAgents(ctx, serviceEndpoints.Name, serviceEndpoints.Namespace, endpointAddressMap); err != nil {
+	deregistered, err := r.deregisterServiceOnAllAgents(ctx, serviceEndpoints.Name, serviceEndpoints.Namespace, endpointAddressMap)
+	if err != nil {
 		r.Log.Error(err, "failed to deregister endpoints on all agents", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
 		errs = multierror.Append(errs, err)
 	}
 
-	return ctrl.Result{}, errs
+	return r.requeueResult(changed || deregistered), errs
+}
+
+// waitForAgent checks that the Consul agent the client points at is reachable, retrying up to
+// AgentConnectRetries times. It does nothing if AgentConnectRetries is zero.
+func (r *EndpointsController) waitForAgent(ctx context.Context, client *api.Client) error {
+	if r.AgentConnectRetries <= 0 {
+		return nil
+	}
+	return backoff.Retry(func() error {
+		_, err := client.Agent().Self()
+		return err
+	}, backoff.WithContext(backoff.WithMaxRetries(backoff.NewConstantBackOff(r.AgentConnectRetryInterval), uint64(r.AgentConnectRetries)), ctx))
+}
+
+// requeueResult returns the result of a reconcile. If service instances were registered, deregistered or changed
+// health and RequeueAfterChange is set, the Endpoints object is requeued after that duration.
+func (r *EndpointsController) requeueResult(changed bool) ctrl.Result {
+	if !changed || r.RequeueAfterChange <= 0 {
+		return ctrl.Result{}
+	}
+	return ctrl.Result{RequeueAfter: r.RequeueAfterChange}
 }
 
 func (r *EndpointsController) Logger(name types.NamespacedName) logr.Logger {
@@ -217,6 +339,20 @@ func (r *EndpointsController) Logger(name types.NamespacedName) logr.Logger {
 }
 
 func (r *EndpointsController) SetupWithManager(mgr ctrl.Manager) error {
+	if r.GarbageCollectOnStartup {
+		err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			// Failing to garbage collect shouldn't stop the controller, the orphaned
+			// instances are collected on the next startup instead.
+			if err := r.garbageCollectOrphanedServices(ctx); err != nil {
+				r.Log.Error(err, "failed to garbage collect orphaned service instances")
+			}
+			return nil
+		}))
+		if err != nil {
+			return err
+		}
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&corev1.Endpoints{}).
 		Watches(
@@ -227,18 +363,32 @@ func (r *EndpointsController) SetupWithManager(mgr ctrl.Manager) error {
 }
 
 // registerServicesAndHealthCheck creates Consul registrations for the service and proxy and registers them with Consul.
-// It also upserts a Kubernetes health check for the service based on whether the endpoint address is ready.
-func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, serviceEndpoints corev1.Endpoints, healthStatus string, endpointAddressMap map[string]bool) error {
+// It also upserts a Kubernetes health check for the service based on whether the endpoint address is ready. It returns
+// whether the service instance is new or its health changed.
+func (r *EndpointsController) registerServicesAndHealthCheck(ctx context.Context, pod corev1.Pod, serviceEndpoints corev1.Endpoints, ready bool, endpointAddressMap map[string]bool) (bool, error) {
 	podHostIP := pod.Status.HostIP
+	healthStatus := api.HealthCritical
+	if ready {
+		healthStatus = api.HealthPassing
+	}
 
 	if hasBeenInjected(pod) {
 		// Build the endpointAddressMap up for deregistering service instances later.
 		endpointAddressMap[pod.Status.PodIP] = true
+		consulNS, err := r.podConsulNamespace(pod)
+		if err != nil {
+			r.Log.Error(err, "failed to determine Consul namespace for pod", "name", pod.Name, "ns", pod.Namespace)
+			return false, err
+		}
 		// Create client for Consul agent local to the pod.
-		client, err := r.remoteConsulClient(podHostIP, r.consulNamespace(pod.Namespace))
+		client, err := r.remoteConsulClient(podHostIP, consulNS)
 		if err != nil {
 			r.Log.Error(err, "failed to create a new Consul client", "address", podHostIP)
-			return err
+			return false, err
+		}
+		if err := r.waitForAgent(ctx, client); err != nil {
+			r.Log.Error(err, "failed to reach Consul agent", "address", podHostIP)
+			return false, err
 		}
 
 		var managedByEndpointsController bool
@@ -248,30 +398,61 @@ func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, ser
 		// For pods managed by this controller, create and register the service instance.
 		if managedByEndpointsController {
 			// Get information from the pod to create service instance registrations.
-			serviceRegistration, proxyServiceRegistration, err := r.createServiceRegistrations(pod, serviceEndpoints)
+			registrations, err := r.newServiceRegistrations(ctx, pod, serviceEndpoints, ready)
 			if err != nil {
 				r.Log.Error(err, "failed to create service registrations for endpoints", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
-				return err
+				return false, err
+			}
+			serviceRegistration, proxyServiceRegistration := registrations.service, registrations.proxy
+			// The proxy may be registered with an address other than the pod IP, so it must not be deregistered
+			// for not matching an Endpoints address.
+			if proxyServiceRegistration != nil {
+				endpointAddressMap[proxyServiceRegistration.Address] = true
 			}
 
 			// Register the service instance with the local agent.
 			// Note: the order of how we register services is important,
 			// and the connect-proxy service should come after the "main" service
 			// because its alias health check depends on the main service existing.
-			r.Log.Info("registering service with Consul", "name", serviceRegistration.Name,
-				"id", serviceRegistration.ID, "agentIP", podHostIP)
-			err = client.Agent().ServiceRegister(serviceRegistration)
+			r.Log.Info("registering service with Consul", "name", registrations.serviceName,
+				"id", serviceRegistration.ID, "namespace", registrations.namespace, "native", registrations.native,
+				"gateway", registrations.gateway, "ready", registrations.ready, "agentIP", podHostIP)
+			err = client.Agent().ServiceRegisterOpts(serviceRegistration, api.ServiceRegisterOpts{}.WithContext(ctx))
 			if err != nil {
 				r.Log.Error(err, "failed to register service", "name", serviceRegistration.Name)
-				return err
+				return false, err
 			}
 
 			// Register the proxy service instance with the local agent.
-			r.Log.Info("registering proxy service with Consul", "name", proxyServiceRegistration.Name)
-			err = client.Agent().ServiceRegister(proxyServiceRegistration)
-			if err != nil {
-				r.Log.Error(err, "failed to register proxy service", "name", proxyServiceRegistration.Name)
-				return err
+			// Connect native and service-only pods don't have a proxy service registration.
+			if proxyServiceRegistration == nil {
+				// The proxy may have been registered before the pod stopped registering it, so remove it.
+				err = deregisterServiceIfExists(ctx, client, getProxyServiceID(pod, registrations.serviceName), serviceRegistration.Partition)
+				if err != nil {
+					r.Log.Error(err, "failed to deregister proxy service", "name", serviceRegistration.Name)
+					return false, err
+				}
+			} else {
+				registerProxy, err := shouldRegisterProxy(pod, ready)
+				if err != nil {
+					r.Log.Error(err, "failed to determine if proxy service should be registered", "name", proxyServiceRegistration.Name)
+					return false, err
+				}
+				if registerProxy {
+					r.Log.Info("registering proxy service with Consul", "name", proxyServiceRegistration.Name)
+					err = client.Agent().ServiceRegisterOpts(proxyServiceRegistration, api.ServiceRegisterOpts{}.WithContext(ctx))
+					if err != nil {
+						r.Log.Error(err, "failed to register proxy service", "name", proxyServiceRegistration.Name)
+						return false, err
+					}
+				} else {
+					// The proxy may have been registered while the pod was ready, so remove it until the pod is ready again.
+					err = deregisterServiceIfExists(ctx, client, proxyServiceRegistration.ID, proxyServiceRegistration.Partition)
+					if err != nil {
+						r.Log.Error(err, "failed to deregister proxy service for not ready pod", "name", proxyServiceRegistration.Name)
+						return false, err
+					}
+				}
 			}
 		}
 
@@ -281,23 +462,54 @@ func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, ser
 		// newer services idempotently since the service health check is not added as part of the service
 		// registration.
 		reason := getHealthCheckStatusReason(healthStatus, pod.Name, pod.Namespace)
-		serviceName := getServiceName(pod, serviceEndpoints)
+		serviceName, err := r.consulServiceName(pod, serviceEndpoints)
+		if err != nil {
+			r.Log.Error(err, "failed to determine Consul service name", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+			return false, err
+		}
 		r.Log.Info("updating health check status for service", "name", serviceName, "reason", reason, "status", healthStatus)
-		serviceID := getServiceID(pod, serviceEndpoints)
+		serviceID := getServiceID(pod, serviceName)
 		healthCheckID := getConsulHealthCheckID(pod, serviceID)
-		err = r.upsertHealthCheck(pod, client, serviceID, healthCheckID, healthStatus)
+		changed, err := r.upsertHealthCheck(ctx, pod, client, serviceID, healthCheckID, healthStatus)
 		if err != nil {
 			r.Log.Error(err, "failed to update health check status for service", "name", serviceName)
-			return err
+			return false, err
 		}
+		return changed, nil
 	}
-	return nil
+	return false, nil
+}
+
+// shouldRegisterProxy returns false if the pod is not ready and has opted out of registering its proxy
+// service until it is ready. Proxies are always registered while the pod is pending, since the
+// init container waits for the proxy service to be registered before the application can start.
+func shouldRegisterProxy(pod corev1.Pod, ready bool) (bool, error) {
+	if ready || pod.Status.Phase == corev1.PodPending {
+		return true, nil
+	}
+	if raw, ok := pod.Annotations[annotationRegisterProxyWhenNotReady]; ok {
+		return strconv.ParseBool(raw)
+	}
+	return true, nil
+}
+
+// deregisterServiceIfExists deregisters the service instance with the given ID from the agent if it is registered.
+func deregisterServiceIfExists(ctx context.Context, client *api.Client, serviceID, partition string) error {
+	opts := (&api.QueryOptions{Partition: partition}).WithContext(ctx)
+	svcs, err := client.Agent().ServicesWithFilterOpts(fmt.Sprintf("ID == %q", serviceID), opts)
+	if err != nil {
+		return err
+	}
+	if _, ok := svcs[serviceID]; !ok {
+		return nil
+	}
+	return client.Agent().ServiceDeregisterOpts(serviceID, opts)
 }
 
 // getServiceCheck will return the health check for this pod and service if it exists.
-func getServiceCheck(client *api.Client, healthCheckID string) (*api.AgentCheck, error) {
+func getServiceCheck(ctx context.Context, client *api.Client, healthCheckID string) (*api.AgentCheck, error) {
 	filter := fmt.Sprintf("CheckID == `%s`", healthCheckID)
-	checks, err := client.Agent().ChecksWithFilter(filter)
+	checks, err := client.Agent().ChecksWithFilterOpts(filter, (&api.QueryOptions{}).WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -308,7 +520,15 @@ func getServiceCheck(client *api.Client, healthCheckID string) (*api.AgentCheck,
 // registerConsulHealthCheck registers a TTL health check for the service on this Agent local to the Pod. This will add
 // the Pod's readiness status, which will mark the service instance healthy/unhealthy for Consul service mesh
 // traffic.
-func registerConsulHealthCheck(client *api.Client, consulHealthCheckID, serviceID, status string) error {
+func registerConsulHealthCheck(client *api.Client, consulHealthCheckID, serviceID, status string, successBeforePassing, failuresBeforeCritical int) error {
+	// Status changes take effect immediately unless thresholds have been set on the pod.
+	if successBeforePassing == 0 {
+		successBeforePassing = 1
+	}
+	if failuresBeforeCritical == 0 {
+		failuresBeforeCritical = 1
+	}
+
 	// Create a TTL health check in Consul associated with this service and pod.
 	// The TTL time is 100000h which should ensure that the check never fails due to timeout
 	// of the TTL check.
@@ -319,8 +539,8 @@ func registerConsulHealthCheck(client *api.Client, consulHealthCheckID, serviceI
 		AgentServiceCheck: api.AgentServiceCheck{
 			TTL:                    "100000h",
 			Status:                 status,
-			SuccessBeforePassing:   1,
-			FailuresBeforeCritical: 1,
+			SuccessBeforePassing:   successBeforePassing,
+			FailuresBeforeCritical: failuresBeforeCritical,
 		},
 	})
 	if err != nil {
@@ -336,9 +556,9 @@ func registerConsulHealthCheck(client *api.Client, consulHealthCheckID, serviceI
 }
 
 // updateConsulHealthCheckStatus updates the consul health check status.
-func (r *EndpointsController) updateConsulHealthCheckStatus(client *api.Client, consulHealthCheckID, status, reason string) error {
+func (r *EndpointsController) updateConsulHealthCheckStatus(ctx context.Context, client *api.Client, consulHealthCheckID, status, reason string) error {
 	r.Log.Info("updating health check", "id", consulHealthCheckID)
-	err := client.Agent().UpdateTTL(consulHealthCheckID, reason, status)
+	err := client.Agent().UpdateTTLOpts(consulHealthCheckID, reason, status, (&api.QueryOptions{}).WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("error updating health check: %w", err)
 	}
@@ -346,36 +566,48 @@ func (r *EndpointsController) updateConsulHealthCheckStatus(client *api.Client,
 }
 
 // upsertHealthCheck checks if the healthcheck exists for the service, and creates it if it doesn't exist, or updates it
-// if it does.
-func (r *EndpointsController) upsertHealthCheck(pod corev1.Pod, client *api.Client, serviceID, healthCheckID, status string) error {
+// if its status changed. It returns whether the health check was created or its status changed.
+func (r *EndpointsController) upsertHealthCheck(ctx context.Context, pod corev1.Pod, client *api.Client, serviceID, healthCheckID, status string) (bool, error) {
 	reason := getHealthCheckStatusReason(status, pod.Name, pod.Namespace)
 	// Retrieve the health check that would exist if the service had one registered for this pod.
-	serviceCheck, err := getServiceCheck(client, healthCheckID)
+	serviceCheck, err := getServiceCheck(ctx, client, healthCheckID)
 	if err != nil {
-		return fmt.Errorf("unable to get agent health checks: serviceID=%s, checkID=%s, %s", serviceID, healthCheckID, err)
+		return false, fmt.Errorf("unable to get agent health checks: serviceID=%s, checkID=%s, %s", serviceID, healthCheckID, err)
 	}
 	if serviceCheck == nil {
+		successBeforePassing, failuresBeforeCritical, err := checkThresholds(pod)
+		if err != nil {
+			return false, err
+		}
+
 		// Create a new health check.
-		err = registerConsulHealthCheck(client, healthCheckID, serviceID, status)
+		err = registerConsulHealthCheck(client, healthCheckID, serviceID, status, successBeforePassing, failuresBeforeCritical)
 		if err != nil {
-			return err
+			return false, err
 		}
 
 		// Also update it, the reason this is separate is there is no way to set the Output field of the health check
 		// at creation time, and this is what is displayed on the UI as opposed to the Notes field.
-		err = r.updateConsulHealthCheckStatus(client, healthCheckID, status, reason)
+		err = r.updateConsulHealthCheckStatus(ctx, client, healthCheckID, status, reason)
 		if err != nil {
-			return err
+			return false, err
 		}
+		return true, nil
 	} else if serviceCheck.Status != status {
-		err = r.updateConsulHealthCheckStatus(client, healthCheckID, status, reason)
+		err = r.updateConsulHealthCheckStatus(ctx, client, healthCheckID, status, reason)
 		if err != nil {
-			return err
+			return false, err
 		}
+		return true, nil
 	}
-	return nil
+	return false, nil
 }
 
+// validConsulServiceName matches service names that are valid DNS labels so that they can be resolved through Consul DNS.
+var validConsulServiceName = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`)
+
+const validConsulServiceNameMsg = "must contain only alphanumeric characters or dashes and must start and end with an alphanumeric character"
+
 // getServiceName computes the service name to register with Consul from the pod and endpoints object. In a single port
 // service, it defaults to the endpoints name, but can be overridden by a pod annotation. In a multi port service, the
 // endpoints name is always used since the pod annotation will have multiple service names listed (one per port).
@@ -389,23 +621,177 @@ func getServiceName(pod corev1.Pod, serviceEndpoints corev1.Endpoints) string {
 	return serviceName
 }
 
-func getServiceID(pod corev1.Pod, serviceEndpoints corev1.Endpoints) string {
-	return fmt.Sprintf("%s-%s", pod.Name, getServiceName(pod, serviceEndpoints))
+// consulServiceName returns the name to register the service with in Consul. A name derived from the Kubernetes
+// service is normalized first and an error is returned if the result is not a valid Consul service name. A name set
+// with the connect-service annotation is the escape hatch for such services and is used as is.
+func (r *EndpointsController) consulServiceName(pod corev1.Pod, serviceEndpoints corev1.Endpoints) (string, error) {
+	serviceName := getServiceName(pod, serviceEndpoints)
+	// A name that differs from the Endpoints name was set explicitly with the annotation.
+	if serviceName != serviceEndpoints.Name {
+		return serviceName, nil
+	}
+
+	normalize := strings.ToLower
+	if r.NormalizeServiceName != nil {
+		normalize = r.NormalizeServiceName
+	}
+	normalized := normalize(serviceName)
+	if !validConsulServiceName.MatchString(normalized) {
+		return "", fmt.Errorf("service name %q derived from Kubernetes service %q is not a valid Consul service name: %s; set the %s annotation to provide a valid name",
+			normalized, serviceEndpoints.Name, validConsulServiceNameMsg, annotationService)
+	}
+	return normalized, nil
 }
 
-func getProxyServiceName(pod corev1.Pod, serviceEndpoints corev1.Endpoints) string {
-	serviceName := getServiceName(pod, serviceEndpoints)
-	return fmt.Sprintf("%s-sidecar-proxy", serviceName)
+// getServiceID computes the service ID to register with Consul from the Consul service name. It defaults to
+// "<pod name>-<service name>", but can be overridden by a pod annotation for single port services. Unless the service
+// name is set by annotation, a pod that backs several Kubernetes services is registered with a distinct ID for each
+// of them.
+func getServiceID(pod corev1.Pod, serviceName string) string {
+	if serviceID, ok := serviceIDFromAnnotation(pod); ok {
+		return serviceID
+	}
+	return fmt.Sprintf("%s-%s", pod.Name, serviceName)
+}
+
+// serviceIDFromAnnotation returns the service ID override set on the pod, if any.
+// The annotation is ignored for multi port Pods since a single ID can't be shared by multiple services.
+func serviceIDFromAnnotation(pod corev1.Pod) (string, bool) {
+	serviceID, ok := pod.Annotations[annotationServiceID]
+	if !ok || serviceID == "" || strings.Contains(pod.Annotations[annotationService], ",") {
+		return "", false
+	}
+	return serviceID, true
+}
+
+// validateServiceIDAnnotation returns an error if the service ID annotation is set on the pod
+// with a value that can't be used as a unique service ID.
+func validateServiceIDAnnotation(pod corev1.Pod) error {
+	raw, ok := pod.Annotations[annotationServiceID]
+	if !ok {
+		return nil
+	}
+	if strings.Contains(pod.Annotations[annotationService], ",") {
+		return fmt.Errorf("%s annotation is not supported for multi port pods", annotationServiceID)
+	}
+	if strings.TrimSpace(raw) == "" || strings.ContainsAny(raw, " /") {
+		return fmt.Errorf("%s annotation value %q is invalid: must be non-empty and must not contain spaces or slashes", annotationServiceID, raw)
+	}
+	if strings.HasSuffix(raw, "-sidecar-proxy") {
+		return fmt.Errorf("%s annotation value %q is invalid: must not end with \"-sidecar-proxy\" since it would conflict with proxy service IDs", annotationServiceID, raw)
+	}
+	return nil
+}
+
+// validateServiceIDAnnotationUnique returns an error if the service ID annotation is set on a pod that backs
+// Kubernetes services other than the one of serviceEndpoints, since the services would all be registered with
+// the same ID and overwrite each other.
+func (r *EndpointsController) validateServiceIDAnnotationUnique(ctx context.Context, pod corev1.Pod, serviceEndpoints corev1.Endpoints) error {
+	if _, ok := serviceIDFromAnnotation(pod); !ok {
+		return nil
+	}
+
+	// Match the pod's labels against the selectors of the services in its namespace rather than listing
+	// every Endpoints object, since the services are served from the cache and are much smaller.
+	var serviceList corev1.ServiceList
+	if err := r.Client.List(ctx, &serviceList, client.InNamespace(pod.Namespace)); err != nil {
+		return err
+	}
+	for _, svc := range serviceList.Items {
+		// Services without a selector don't have their endpoints managed by Kubernetes.
+		if svc.Name == serviceEndpoints.Name || len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		if labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			return fmt.Errorf("%s annotation is not supported for pods that back more than one Kubernetes service: pod %s backs services %s and %s",
+				annotationServiceID, pod.Name, serviceEndpoints.Name, svc.Name)
+		}
+	}
+	return nil
+}
+
+// proxyConfigHash returns the hex-encoded SHA-256 hash of the proxy config. The config is hashed in its JSON
+// encoding, which orders map keys, so the hash is stable across map iteration order.
+func proxyConfigHash(proxyConfig *api.AgentServiceConnectProxyConfig) (string, error) {
+	raw, err := json.Marshal(proxyConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash proxy config: %w", err)
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// getKubeServiceName returns the Kubernetes service name recorded in the k8s-service-name meta of the pod's
+// service instances. It is the name of the Endpoints object unless overridden by the kube-service-name annotation.
+func getKubeServiceName(pod corev1.Pod, serviceEndpoints corev1.Endpoints) (string, error) {
+	raw, ok := pod.Annotations[annotationKubeServiceName]
+	if !ok {
+		return serviceEndpoints.Name, nil
+	}
+	if strings.Contains(pod.Annotations[annotationService], ",") {
+		return "", fmt.Errorf("%s annotation is not supported for multi port pods", annotationKubeServiceName)
+	}
+	if errs := validation.IsDNS1035Label(raw); len(errs) > 0 {
+		return "", fmt.Errorf("%s annotation value %q is invalid: %s", annotationKubeServiceName, raw, strings.Join(errs, ", "))
+	}
+	return raw, nil
+}
+
+// getProxyServiceID computes the proxy service ID to register with Consul from the Consul service name.
+func getProxyServiceID(pod corev1.Pod, serviceName string) string {
+	return fmt.Sprintf("%s-sidecar-proxy", getServiceID(pod, serviceName))
+}
+
+// serviceRegistrations holds the service and proxy service instance registrations for a pod along with
+// details computed while creating them, so that callers don't need to derive them from the pod again.
+type serviceRegistrations struct {
+	service *api.AgentServiceRegistration
+	// proxy is nil for Connect native and service-only pods.
+	proxy *api.AgentServiceRegistration
+
+	// serviceName is the name the service is registered with in Consul.
+	serviceName string
+	// namespace is the Consul namespace the services are registered in. It is empty
+	// when Consul namespaces are not enabled.
+	namespace string
+	// native is true if the service speaks the Connect protocol natively.
+	native bool
+	// gateway is true if the proxy is registered as a gateway rather than a sidecar proxy.
+	gateway bool
+	// ready is true if the pod's address is ready in the Endpoints object.
+	ready bool
 }
 
-func getProxyServiceID(pod corev1.Pod, serviceEndpoints corev1.Endpoints) string {
-	proxyServiceName := getProxyServiceName(pod, serviceEndpoints)
-	return fmt.Sprintf("%s-%s", pod.Name, proxyServiceName)
+// newServiceRegistrations creates the service and proxy service instance registrations for the pod
+// and records the details of the registrations along with whether the pod's address is ready.
+func (r *EndpointsController) newServiceRegistrations(ctx context.Context, pod corev1.Pod, serviceEndpoints corev1.Endpoints, ready bool) (serviceRegistrations, error) {
+	service, proxy, err := r.createServiceRegistrations(ctx, pod, serviceEndpoints)
+	if err != nil {
+		return serviceRegistrations{}, err
+	}
+	return serviceRegistrations{
+		service:     service,
+		proxy:       proxy,
+		serviceName: service.Name,
+		namespace:   service.Namespace,
+		native:      service.Connect != nil && service.Connect.Native,
+		gateway:     proxy != nil && proxy.Kind != api.ServiceKindConnectProxy,
+		ready:       ready,
+	}, nil
 }
 
 // createServiceRegistrations creates the service and proxy service instance registrations with the information from the
-// Pod.
-func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, serviceEndpoints corev1.Endpoints) (*api.AgentServiceRegistration, *api.AgentServiceRegistration, error) {
+// Pod. The proxy service registration is nil for Connect native services.
+func (r *EndpointsController) createServiceRegistrations(ctx context.Context, pod corev1.Pod, serviceEndpoints corev1.Endpoints) (*api.AgentServiceRegistration, *api.AgentServiceRegistration, error) {
+	// We only want that annotation to be present when explicitly overriding the consul svc name
+	// Otherwise, the Consul service name should equal the Kubernetes Service name.
+	// The service name in Consul defaults to the Endpoints object name, and is overridden by the pod
+	// annotation consul.hashicorp.com/connect-service..
+	serviceName, err := r.consulServiceName(pod, serviceEndpoints)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// If a port is specified, then we determine the value of that port
 	// and register that port for the host service.
 	// The meshWebhook will always set the port annotation if one is not provided on the pod.
@@ -414,7 +800,12 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 		if multiPort := strings.Split(raw, ","); len(multiPort) > 1 {
 			// Figure out which index of the ports annotation to use by
 			// finding the index of the service names annotation.
-			raw = multiPort[getMultiPortIdx(pod, serviceEndpoints)]
+			idx := getMultiPortIdx(pod, serviceName)
+			if idx < 0 || idx >= len(multiPort) {
+				return nil, nil, fmt.Errorf("service %q has no port in the %s annotation of multi port pod %s: it must be listed in the %s annotation %q",
+					serviceName, annotationPort, pod.Name, annotationService, pod.Annotations[annotationService])
+			}
+			raw = multiPort[idx]
 		}
 		if port, err := portValue(pod, raw); port > 0 {
 			if err != nil {
@@ -424,20 +815,58 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 		}
 	}
 
-	// We only want that annotation to be present when explicitly overriding the consul svc name
-	// Otherwise, the Consul service name should equal the Kubernetes Service name.
-	// The service name in Consul defaults to the Endpoints object name, and is overridden by the pod
-	// annotation consul.hashicorp.com/connect-service..
-	serviceName := getServiceName(pod, serviceEndpoints)
+	// The service may listen on a Unix socket instead of a port.
+	socketPath, err := serviceSocketPath(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := validateServiceIDAnnotation(pod); err != nil {
+		return nil, nil, err
+	}
+	if err := r.validateServiceIDAnnotationUnique(ctx, pod, serviceEndpoints); err != nil {
+		return nil, nil, err
+	}
+	serviceID := getServiceID(pod, serviceName)
+
+	kubeServiceName, err := getKubeServiceName(pod, serviceEndpoints)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	serviceID := getServiceID(pod, serviceEndpoints)
+	consulNS, err := r.podConsulNamespace(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	consulPartition, err := r.podConsulPartition(pod)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	meta := map[string]string{
 		MetaKeyPodName:         pod.Name,
-		MetaKeyKubeServiceName: serviceEndpoints.Name,
+		MetaKeyKubeServiceName: kubeServiceName,
 		MetaKeyKubeNS:          serviceEndpoints.Namespace,
 		MetaKeyManagedBy:       managedByValue,
 	}
+	// Record the IP of the node the pod is running on. Since services are registered with the
+	// agent local to the pod, this identifies the agent that the instance is registered with.
+	if pod.Status.HostIP != "" {
+		meta[MetaKeyHostIP] = pod.Status.HostIP
+	}
+	// Record the zone of the pod so that same-zone instances can be preferred for topology-aware routing.
+	zone, err := r.podZone(ctx, pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	if zone != "" {
+		meta[MetaKeyZone] = zone
+	}
+	if r.CopyAllLabelsToMeta {
+		for k, v := range pod.Labels {
+			meta[labelMetaKey(k)] = v
+		}
+	}
 	for k, v := range pod.Annotations {
 		if strings.HasPrefix(k, annotationMeta) && strings.TrimPrefix(k, annotationMeta) != "" {
 			if v == "$POD_NAME" {
@@ -450,17 +879,64 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 	tags := consulTags(pod)
 
 	service := &api.AgentServiceRegistration{
-		ID:        serviceID,
-		Name:      serviceName,
-		Port:      consulServicePort,
-		Address:   pod.Status.PodIP,
-		Meta:      meta,
-		Namespace: r.consulNamespace(pod.Namespace),
-		Tags:      tags,
+		ID:         serviceID,
+		Name:       serviceName,
+		Port:       consulServicePort,
+		SocketPath: socketPath,
+		Address:    pod.Status.PodIP,
+		Meta:       meta,
+		Namespace:  consulNS,
+		Partition:  consulPartition,
+		Tags:       tags,
+	}
+
+	serviceChecks, err := serviceChecksFromAnnotation(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	service.Checks = append(service.Checks, serviceChecks...)
+
+	checkFileCheck, err := serviceCheckFileCheck(pod, serviceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if checkFileCheck != nil {
+		service.Checks = append(service.Checks, checkFileCheck)
+	}
+
+	readinessCheck, err := readinessProbeCheck(pod, serviceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if readinessCheck != nil {
+		service.Checks = append(service.Checks, readinessCheck)
+	}
+
+	// Connect native services handle Connect themselves, so only the service is registered
+	// and the proxy service registration is skipped.
+	connectNative, err := connectNativeEnabled(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	if connectNative {
+		service.Connect = &api.AgentServiceConnect{Native: true}
+		return service, nil, nil
 	}
 
-	proxyServiceName := getProxyServiceName(pod, serviceEndpoints)
-	proxyServiceID := getProxyServiceID(pod, serviceEndpoints)
+	registerProxy, err := registerProxyEnabled(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !registerProxy {
+		return service, nil, nil
+	}
+
+	proxyServiceKind, err := getProxyServiceKind(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	proxyServiceName := fmt.Sprintf("%s-%s", serviceName, proxyServiceSuffix(proxyServiceKind))
+	proxyServiceID := fmt.Sprintf("%s-%s", serviceID, proxyServiceSuffix(proxyServiceKind))
 	proxyConfig := &api.AgentServiceConnectProxyConfig{
 		DestinationServiceName: serviceName,
 		DestinationServiceID:   serviceID,
@@ -485,51 +961,126 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 		proxyConfig.Config[envoyPrometheusBindAddr] = prometheusScrapeListener
 	}
 
-	if consulServicePort > 0 {
+	if raw, ok := pod.Annotations[annotationLocalRequestTimeout]; ok && raw != "" {
+		timeoutMs, err := localRequestTimeoutMs(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		proxyConfig.Config[envoyLocalRequestTimeoutMs] = timeoutMs
+	}
+
+	protocol, err := serviceProtocol(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	if protocol != "" {
+		proxyConfig.Config[envoyProtocol] = protocol
+	}
+
+	if socketPath != "" {
+		proxyConfig.LocalServiceSocketPath = socketPath
+	} else if consulServicePort > 0 {
 		proxyConfig.LocalServiceAddress = "127.0.0.1"
 		proxyConfig.LocalServicePort = consulServicePort
 	}
 
-	upstreams, err := r.processUpstreams(pod, serviceEndpoints)
+	// A user can enable/disable tproxy and set default upstreams for an entire namespace.
+	var ns corev1.Namespace
+	err = r.Client.Get(ctx, types.NamespacedName{Name: pod.Namespace, Namespace: ""}, &ns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	upstreams, err := r.processUpstreams(ctx, ns, pod, serviceEndpoints)
 	if err != nil {
 		return nil, nil, err
 	}
 	proxyConfig.Upstreams = upstreams
 
-	proxyPort := proxyDefaultInboundPort
-	if idx := getMultiPortIdx(pod, serviceEndpoints); idx >= 0 {
+	if raw, ok := pod.Annotations[annotationMeshGatewayMode]; ok {
+		meshGatewayConfig, err := meshGatewayConfigFromAnnotation(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		proxyConfig.MeshGateway = meshGatewayConfig
+	}
+
+	exposePaths, err := exposePathsFromAnnotation(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	proxyConfig.Expose.Paths = exposePaths
+
+	proxyPort, adminPort := proxyDefaultInboundPort, proxyDefaultAdminPort
+	if idx := getMultiPortIdx(pod, serviceName); idx >= 0 {
 		proxyPort += idx
+		adminPort += idx
+	}
+	deregisterAfter, err := deregisterCriticalServiceAfter(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	successBeforePassing, failuresBeforeCritical, err := checkThresholds(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	initialStatus, err := initialHealth(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	proxyAddr, err := proxyAddress(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	proxyServiceTags := proxyTags(pod, tags)
+	proxyMeta := make(map[string]string, len(meta)+1)
+	for k, v := range meta {
+		proxyMeta[k] = v
 	}
 	proxyService := &api.AgentServiceRegistration{
-		Kind:      api.ServiceKindConnectProxy,
+		Kind:      proxyServiceKind,
 		ID:        proxyServiceID,
 		Name:      proxyServiceName,
 		Port:      proxyPort,
-		Address:   pod.Status.PodIP,
-		Meta:      meta,
-		Namespace: r.consulNamespace(pod.Namespace),
+		Address:   proxyAddr,
+		Meta:      proxyMeta,
+		Namespace: consulNS,
+		Partition: consulPartition,
 		Proxy:     proxyConfig,
 		Checks: api.AgentServiceChecks{
 			{
 				Name:                           "Proxy Public Listener",
-				TCP:                            fmt.Sprintf("%s:%d", pod.Status.PodIP, proxyPort),
+				TCP:                            fmt.Sprintf("%s:%d", proxyAddr, proxyPort),
 				Interval:                       "10s",
-				DeregisterCriticalServiceAfter: "10m",
+				Notes:                          pod.Annotations[annotationServiceCheckNotes],
+				Status:                         initialStatus,
+				DeregisterCriticalServiceAfter: deregisterAfter,
+				SuccessBeforePassing:           successBeforePassing,
+				FailuresBeforeCritical:         failuresBeforeCritical,
 			},
 			{
 				Name:         "Destination Alias",
 				AliasService: serviceID,
 			},
 		},
-		Tags: tags,
+		Tags: proxyServiceTags,
 	}
 
-	// A user can enable/disable tproxy for an entire namespace.
-	var ns corev1.Namespace
-	err = r.Client.Get(r.Context, types.NamespacedName{Name: pod.Namespace, Namespace: ""}, &ns)
+	envoyReadyCheck, err := envoyReadyCheckEnabled(pod)
 	if err != nil {
 		return nil, nil, err
 	}
+	if envoyReadyCheck {
+		proxyService.Checks = append(proxyService.Checks, &api.AgentServiceCheck{
+			Name:                           "Proxy Ready",
+			HTTP:                           fmt.Sprintf("http://%s:%d/ready", proxyAddr, adminPort),
+			Interval:                       "10s",
+			Status:                         initialStatus,
+			DeregisterCriticalServiceAfter: deregisterAfter,
+			SuccessBeforePassing:           successBeforePassing,
+			FailuresBeforeCritical:         failuresBeforeCritical,
+		})
+	}
 
 	tproxyEnabled, err := transparentProxyEnabled(ns, pod, r.EnableTransparentProxy)
 	if err != nil {
@@ -539,7 +1090,7 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 	if tproxyEnabled {
 		var k8sService corev1.Service
 
-		err := r.Client.Get(r.Context, types.NamespacedName{Name: serviceEndpoints.Name, Namespace: serviceEndpoints.Namespace}, &k8sService)
+		err := r.Client.Get(ctx, types.NamespacedName{Name: serviceEndpoints.Name, Namespace: serviceEndpoints.Namespace}, &k8sService)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -582,6 +1133,11 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 			proxyService.TaggedAddresses = taggedAddresses
 
 			proxyService.Proxy.Mode = api.ProxyModeTransparent
+		} else if k8sService.Spec.ClusterIP == corev1.ClusterIPNone {
+			// Headless services don't have a virtual IP, so clients dial each instance directly
+			// using the pod IP that the service is registered with.
+			r.Log.Info("allowing proxy to be dialed directly for headless service", "name", k8sService.Name, "ns", k8sService.Namespace)
+			proxyConfig.TransparentProxy = &api.TransparentProxyConfig{DialedDirectly: true}
 		} else {
 			r.Log.Info("skipping syncing service cluster IP to Consul", "name", k8sService.Name, "ns", k8sService.Namespace, "ip", k8sService.Spec.ClusterIP)
 		}
@@ -639,65 +1195,474 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 			}
 		}
 	}
-	return service, proxyService, nil
-}
 
-// portValueFromIntOrString returns the integer port value from the port that can be
-// a named port, an integer string (e.g. "80"), or an integer. If the port is a named port,
-// this function will attempt to find the value from the containers of the pod.
-func portValueFromIntOrString(pod corev1.Pod, port intstr.IntOrString) (int, error) {
-	if port.Type == intstr.Int {
-		return port.IntValue(), nil
+	// Gateways aren't sidecars for the service, so they are registered without the configuration that only applies
+	// to sidecars and without the check that aliases the service.
+	if proxyServiceKind != api.ServiceKindConnectProxy {
+		proxyService.Proxy = &api.AgentServiceConnectProxyConfig{
+			Config:      proxyConfig.Config,
+			MeshGateway: proxyConfig.MeshGateway,
+		}
+		var gatewayChecks api.AgentServiceChecks
+		for _, check := range proxyService.Checks {
+			if check.AliasService == "" {
+				gatewayChecks = append(gatewayChecks, check)
+			}
+		}
+		proxyService.Checks = gatewayChecks
 	}
 
-	// Otherwise, find named port or try to parse the string as an int.
-	portVal, err := portValue(pod, port.StrVal)
+	// Record a hash of the proxy config on the proxy registration only so that tooling can detect drift. It is
+	// computed last so that it covers the config exactly as it is registered.
+	configHash, err := proxyConfigHash(proxyService.Proxy)
 	if err != nil {
-		return 0, err
+		return nil, nil, err
 	}
-	return int(portVal), nil
+	proxyService.Meta[MetaKeyProxyConfigHash] = configHash
+	return service, proxyService, nil
 }
 
-// getConsulHealthCheckID deterministically generates a health check ID that will be unique to the Agent
-// where the health check is registered and deregistered.
-func getConsulHealthCheckID(pod corev1.Pod, serviceID string) string {
-	return fmt.Sprintf("%s/%s/kubernetes-health-check", pod.Namespace, serviceID)
+// proxyServiceKinds maps the values of the gateway kind annotation to the kind of the proxy service registered
+// with Consul.
+var proxyServiceKinds = map[string]api.ServiceKind{
+	"sidecar":             api.ServiceKindConnectProxy,
+	"mesh-gateway":        api.ServiceKindMeshGateway,
+	"ingress-gateway":     api.ServiceKindIngressGateway,
+	"terminating-gateway": api.ServiceKindTerminatingGateway,
 }
 
-// getHealthCheckStatusReason takes an Consul's health check status (either passing or critical)
-// as well as pod name and namespace and returns the reason message.
-func getHealthCheckStatusReason(healthCheckStatus, podName, podNamespace string) string {
-	if healthCheckStatus == api.HealthPassing {
-		return kubernetesSuccessReasonMsg
+// proxyServiceSuffix returns the suffix of the name and ID of the proxy service registered for a service. Sidecar
+// proxies are suffixed with "sidecar-proxy" and gateways with their kind, e.g. "mesh-gateway".
+func proxyServiceSuffix(kind api.ServiceKind) string {
+	if kind == api.ServiceKindConnectProxy {
+		return "sidecar-proxy"
 	}
+	return string(kind)
+}
 
-	return fmt.Sprintf("Pod \"%s/%s\" is not ready", podNamespace, podName)
+// getProxyServiceKind returns the kind of the proxy service to register for the pod. It defaults to a sidecar proxy
+// and can be overridden with the gateway kind annotation.
+func getProxyServiceKind(pod corev1.Pod) (api.ServiceKind, error) {
+	raw, ok := pod.Annotations[annotationGatewayKind]
+	if !ok || raw == "" {
+		return api.ServiceKindConnectProxy, nil
+	}
+	kind, ok := proxyServiceKinds[raw]
+	if !ok {
+		kinds := make([]string, 0, len(proxyServiceKinds))
+		for k := range proxyServiceKinds {
+			kinds = append(kinds, fmt.Sprintf("%q", k))
+		}
+		sort.Strings(kinds)
+		return "", fmt.Errorf("%s annotation value %q is invalid: must be one of %s", annotationGatewayKind, raw, strings.Join(kinds, ", "))
+	}
+	return kind, nil
 }
 
-// deregisterServiceOnAllAgents queries all agents for service instances that have the metadata
+// meshGatewayConfigFromAnnotation parses the value of the mesh gateway mode annotation into a mesh gateway config.
+func meshGatewayConfigFromAnnotation(raw string) (api.MeshGatewayConfig, error) {
+	mode := api.MeshGatewayMode(raw)
+	switch mode {
+	case api.MeshGatewayModeLocal, api.MeshGatewayModeRemote, api.MeshGatewayModeNone:
+		return api.MeshGatewayConfig{Mode: mode}, nil
+	default:
+		return api.MeshGatewayConfig{}, fmt.Errorf("%s annotation value %q is invalid: must be one of %q, %q, or %q",
+			annotationMeshGatewayMode, raw, api.MeshGatewayModeLocal, api.MeshGatewayModeRemote, api.MeshGatewayModeNone)
+	}
+}
+
+// deregisterCriticalServiceAfter returns how long the proxy's health check may be critical before the proxy is
+// deregistered. The default can be overridden with the deregister-critical-service-after annotation.
+func deregisterCriticalServiceAfter(pod corev1.Pod) (string, error) {
+	raw, ok := pod.Annotations[annotationDeregisterCriticalServiceAfter]
+	if !ok || raw == "" {
+		return defaultDeregisterCriticalServiceAfter, nil
+	}
+	duration, err := time.ParseDuration(raw)
+	if err != nil {
+		return "", fmt.Errorf("%s annotation value %q is invalid: %s", annotationDeregisterCriticalServiceAfter, raw, err)
+	}
+	if duration <= 0 {
+		return "", fmt.Errorf("%s annotation value %q is invalid: must be greater than zero", annotationDeregisterCriticalServiceAfter, raw)
+	}
+	return raw, nil
+}
+
+// initialHealth returns the status of the proxy's public listener check when it is registered. It is empty, i.e.
+// Consul's default, unless set by the initial health annotation.
+func initialHealth(pod corev1.Pod) (string, error) {
+	raw, ok := pod.Annotations[annotationInitialHealth]
+	if !ok || raw == "" {
+		return "", nil
+	}
+	switch raw {
+	case api.HealthPassing, api.HealthWarning, api.HealthCritical:
+		return raw, nil
+	}
+	return "", fmt.Errorf("%s annotation value %q is invalid: must be one of %s, %s, %s", annotationInitialHealth, raw,
+		api.HealthPassing, api.HealthWarning, api.HealthCritical)
+}
+
+// localRequestTimeoutMs parses the value of the local request timeout annotation into milliseconds.
+func localRequestTimeoutMs(raw string) (int64, error) {
+	duration, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s annotation value %q is invalid: %s", annotationLocalRequestTimeout, raw, err)
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("%s annotation value %q is invalid: must not be negative", annotationLocalRequestTimeout, raw)
+	}
+	if duration%time.Millisecond != 0 {
+		return 0, fmt.Errorf("%s annotation value %q is invalid: must be a whole number of milliseconds", annotationLocalRequestTimeout, raw)
+	}
+	return duration.Milliseconds(), nil
+}
+
+// proxyAddress returns the address to register the proxy service with. It is the pod IP unless it is overridden
+// by annotation.
+func proxyAddress(pod corev1.Pod) (string, error) {
+	raw, ok := pod.Annotations[annotationProxyAddress]
+	if !ok || raw == "" {
+		return pod.Status.PodIP, nil
+	}
+	if net.ParseIP(raw) == nil {
+		return "", fmt.Errorf("%s annotation value %q is invalid: must be an IP address", annotationProxyAddress, raw)
+	}
+	return raw, nil
+}
+
+// checkThresholds returns the number of consecutive successful and failed checks required before the health checks
+// of the pod's service and proxy change status. They are zero, i.e. Consul's default, unless set by annotation.
+func checkThresholds(pod corev1.Pod) (int, int, error) {
+	successBeforePassing, err := checkThreshold(pod, annotationCheckSuccessBeforePassing)
+	if err != nil {
+		return 0, 0, err
+	}
+	failuresBeforeCritical, err := checkThreshold(pod, annotationCheckFailuresBeforeCritical)
+	if err != nil {
+		return 0, 0, err
+	}
+	return successBeforePassing, failuresBeforeCritical, nil
+}
+
+// checkThreshold parses a health check threshold annotation, which must be a non-negative integer.
+func checkThreshold(pod corev1.Pod, annotation string) (int, error) {
+	raw, ok := pod.Annotations[annotation]
+	if !ok || raw == "" {
+		return 0, nil
+	}
+	threshold, err := strconv.Atoi(raw)
+	if err != nil || threshold < 0 {
+		return 0, fmt.Errorf("%s annotation value %q is invalid: must be a non-negative integer", annotation, raw)
+	}
+	return threshold, nil
+}
+
+// registerProxyEnabled returns false if the register proxy annotation is set to false, in which case only the service
+// is registered for the pod.
+func registerProxyEnabled(pod corev1.Pod) (bool, error) {
+	raw, ok := pod.Annotations[annotationRegisterProxy]
+	if !ok || raw == "" {
+		return true, nil
+	}
+	enabled, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("%s annotation value %q is invalid: must be a boolean", annotationRegisterProxy, raw)
+	}
+	return enabled, nil
+}
+
+// envoyReadyCheckEnabled returns whether the enable-envoy-ready-check annotation is set to true on the pod.
+func envoyReadyCheckEnabled(pod corev1.Pod) (bool, error) {
+	raw, ok := pod.Annotations[annotationEnableEnvoyReadyCheck]
+	if !ok || raw == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("%s annotation value %q is invalid: must be a boolean", annotationEnableEnvoyReadyCheck, raw)
+	}
+	return enabled, nil
+}
+
+// serviceChecksFromAnnotation parses the Consul check definitions in the service checks annotation. Each check must
+// be a JSON object which defines how the check is run, e.g. with a TTL or an HTTP endpoint.
+func serviceChecksFromAnnotation(pod corev1.Pod) (api.AgentServiceChecks, error) {
+	raw, ok := pod.Annotations[annotationServiceChecks]
+	if !ok || raw == "" {
+		return nil, nil
+	}
+
+	var checks api.AgentServiceChecks
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&checks); err != nil {
+		return nil, fmt.Errorf("%s annotation value is invalid: must be a JSON array of checks: %s", annotationServiceChecks, err)
+	}
+	for i, check := range checks {
+		if check == nil {
+			return nil, fmt.Errorf("%s annotation value is invalid: check %d must be a JSON object", annotationServiceChecks, i)
+		}
+		if !checkHasType(*check) {
+			return nil, fmt.Errorf("%s annotation value is invalid: check %d must set one of ScriptArgs, DockerContainerID, TTL, HTTP, TCP, UDP, GRPC, H2PING, AliasNode or AliasService", annotationServiceChecks, i)
+		}
+	}
+	return checks, nil
+}
+
+// exposePath is an entry of the expose paths annotation.
+type exposePath struct {
+	Path         string `json:"path"`
+	LocalPort    int    `json:"local_port"`
+	ListenerPort int    `json:"listener_port"`
+	Protocol     string `json:"protocol"`
+}
+
+// exposePathsFromAnnotation parses the HTTP paths in the expose paths annotation which the proxy exposes without
+// mTLS. Each path must be absolute and have valid local and listener ports, and the protocol must be http or http2 if
+// it is set.
+func exposePathsFromAnnotation(pod corev1.Pod) ([]api.ExposePath, error) {
+	raw, ok := pod.Annotations[annotationExposePaths]
+	if !ok || raw == "" {
+		return nil, nil
+	}
+
+	var entries []exposePath
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("%s annotation value is invalid: must be a JSON array of expose paths: %s", annotationExposePaths, err)
+	}
+
+	var paths []api.ExposePath
+	for i, entry := range entries {
+		if !strings.HasPrefix(entry.Path, "/") {
+			return nil, fmt.Errorf("%s annotation value is invalid: expose path %d must have a path starting with /", annotationExposePaths, i)
+		}
+		if entry.LocalPort < 1 || entry.LocalPort > 65535 {
+			return nil, fmt.Errorf("%s annotation value is invalid: expose path %d must have a local_port between 1 and 65535", annotationExposePaths, i)
+		}
+		if entry.ListenerPort < 1 || entry.ListenerPort > 65535 {
+			return nil, fmt.Errorf("%s annotation value is invalid: expose path %d must have a listener_port between 1 and 65535", annotationExposePaths, i)
+		}
+		if entry.Protocol != "" && entry.Protocol != "http" && entry.Protocol != "http2" {
+			return nil, fmt.Errorf("%s annotation value is invalid: expose path %d must have a protocol of http or http2", annotationExposePaths, i)
+		}
+		paths = append(paths, api.ExposePath{
+			Path:          entry.Path,
+			LocalPathPort: entry.LocalPort,
+			ListenerPort:  entry.ListenerPort,
+			Protocol:      entry.Protocol,
+		})
+	}
+	return paths, nil
+}
+
+// serviceCheckFileCheck returns the TTL check registered for the readiness file set by the service check file
+// annotation, or nil if the annotation isn't set. Like all new checks, it is critical until it is first updated.
+func serviceCheckFileCheck(pod corev1.Pod, serviceID string) (*api.AgentServiceCheck, error) {
+	file, ok := pod.Annotations[annotationServiceCheckFile]
+	if !ok || file == "" {
+		return nil, nil
+	}
+
+	ttl := defaultServiceCheckFileTTL
+	if raw, ok := pod.Annotations[annotationServiceCheckFileTTL]; ok && raw != "" {
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s annotation value %q is invalid: %s", annotationServiceCheckFileTTL, raw, err)
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("%s annotation value %q is invalid: must be greater than zero", annotationServiceCheckFileTTL, raw)
+		}
+		ttl = raw
+	}
+
+	return &api.AgentServiceCheck{
+		CheckID: fmt.Sprintf("%s/check-file", serviceID),
+		Name:    "Readiness File",
+		Notes:   fmt.Sprintf("Must be marked as passing at least every %s while %s exists.", ttl, file),
+		TTL:     ttl,
+	}, nil
+}
+
+// readinessProbeCheck returns an HTTP check derived from the httpGet readiness probe of the pod's first application
+// container if the auto sync readiness annotation is true, or nil otherwise. Containers added by the injector and
+// probes which aren't HTTP are skipped. Like the kubelet, the check doesn't verify the certificate of HTTPS probes.
+func readinessProbeCheck(pod corev1.Pod, serviceID string) (*api.AgentServiceCheck, error) {
+	raw, ok := pod.Annotations[annotationAutoSyncReadiness]
+	if !ok || raw == "" {
+		return nil, nil
+	}
+	enabled, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s annotation value %q is invalid: must be a boolean", annotationAutoSyncReadiness, raw)
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	for _, container := range pod.Spec.Containers {
+		if strings.HasPrefix(container.Name, envoySidecarContainer) || container.Name == "consul-sidecar" {
+			continue
+		}
+		if container.ReadinessProbe == nil || container.ReadinessProbe.HTTPGet == nil {
+			continue
+		}
+		probe := container.ReadinessProbe
+		port, err := portValueFromIntOrString(pod, probe.HTTPGet.Port)
+		if err != nil {
+			return nil, err
+		}
+		host := probe.HTTPGet.Host
+		if host == "" {
+			host = pod.Status.PodIP
+		}
+		scheme := strings.ToLower(string(probe.HTTPGet.Scheme))
+		if scheme == "" {
+			scheme = "http"
+		}
+		path := probe.HTTPGet.Path
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		var headers map[string][]string
+		for _, header := range probe.HTTPGet.HTTPHeaders {
+			if headers == nil {
+				headers = make(map[string][]string)
+			}
+			headers[header.Name] = append(headers[header.Name], header.Value)
+		}
+		// The kubelet defaults the period to 10s and the timeout to 1s.
+		period, timeout := probe.PeriodSeconds, probe.TimeoutSeconds
+		if period == 0 {
+			period = 10
+		}
+		if timeout == 0 {
+			timeout = 1
+		}
+
+		return &api.AgentServiceCheck{
+			CheckID:       fmt.Sprintf("%s/readiness-probe", serviceID),
+			Name:          "Kubernetes Readiness Probe",
+			Notes:         fmt.Sprintf("Derived from the readiness probe of container %s.", container.Name),
+			HTTP:          fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(host, strconv.Itoa(port)), path),
+			Header:        headers,
+			Interval:      fmt.Sprintf("%ds", period),
+			Timeout:       fmt.Sprintf("%ds", timeout),
+			TLSSkipVerify: scheme == "https",
+		}, nil
+	}
+	return nil, nil
+}
+
+// checkHasType returns true if the check defines how it is run.
+func checkHasType(check api.AgentServiceCheck) bool {
+	return len(check.Args) > 0 || check.DockerContainerID != "" || check.TTL != "" || check.HTTP != "" ||
+		check.TCP != "" || check.UDP != "" || check.GRPC != "" || check.H2PING != "" || check.AliasNode != "" ||
+		check.AliasService != ""
+}
+
+// invalidMetaKeyChars matches the characters which aren't allowed in Consul meta keys.
+var invalidMetaKeyChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// maxMetaKeyLength is the maximum length of a Consul meta key.
+const maxMetaKeyLength = 128
+
+// labelMetaKey returns the meta key that the pod label with the given key is copied to. The label key is prefixed
+// with MetaKeyLabelPrefix, characters which aren't valid in meta keys such as the "." and "/" in
+// "app.kubernetes.io/name" are replaced with underscores, and the result is truncated to the maximum meta key length.
+func labelMetaKey(labelKey string) string {
+	key := MetaKeyLabelPrefix + invalidMetaKeyChars.ReplaceAllString(labelKey, "_")
+	if len(key) > maxMetaKeyLength {
+		key = key[:maxMetaKeyLength]
+	}
+	return key
+}
+
+// podZone returns the zone the pod is running in. The zone is read from the topology.kubernetes.io/zone label on the
+// pod if it has been propagated there, otherwise it is read from the same label on the pod's node. An empty string is
+// returned if the zone can't be determined.
+func (r *EndpointsController) podZone(ctx context.Context, pod corev1.Pod) (string, error) {
+	if zone, ok := pod.Labels[labelTopologyZone]; ok && zone != "" {
+		return zone, nil
+	}
+	if pod.Spec.NodeName == "" {
+		return "", nil
+	}
+
+	var node corev1.Node
+	err := r.Client.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, &node)
+	if k8serrors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return node.Labels[labelTopologyZone], nil
+}
+
+// portValueFromIntOrString returns the integer port value from the port that can be
+// a named port, an integer string (e.g. "80"), or an integer. If the port is a named port,
+// this function will attempt to find the value from the containers of the pod.
+func portValueFromIntOrString(pod corev1.Pod, port intstr.IntOrString) (int, error) {
+	if port.Type == intstr.Int {
+		return port.IntValue(), nil
+	}
+
+	// Otherwise, find named port or try to parse the string as an int.
+	portVal, err := portValue(pod, port.StrVal)
+	if err != nil {
+		return 0, err
+	}
+	return int(portVal), nil
+}
+
+// getConsulHealthCheckID deterministically generates a health check ID that will be unique to the Agent
+// where the health check is registered and deregistered.
+func getConsulHealthCheckID(pod corev1.Pod, serviceID string) string {
+	return fmt.Sprintf("%s/%s/kubernetes-health-check", pod.Namespace, serviceID)
+}
+
+// getHealthCheckStatusReason takes an Consul's health check status (either passing or critical)
+// as well as pod name and namespace and returns the reason message.
+func getHealthCheckStatusReason(healthCheckStatus, podName, podNamespace string) string {
+	if healthCheckStatus == api.HealthPassing {
+		return kubernetesSuccessReasonMsg
+	}
+
+	return fmt.Sprintf("Pod \"%s/%s\" is not ready", podNamespace, podName)
+}
+
+// deregisterServiceOnAllAgents queries all agents for service instances that have the metadata
 // "k8s-service-name"=k8sSvcName and "k8s-namespace"=k8sSvcNamespace. The k8s service name may or may not match the
 // consul service name, but the k8s service name will always match the metadata on the Consul service
 // "k8s-service-name". So, we query Consul services by "k8s-service-name" metadata, which is only exposed on the agent
 // API. Therefore, we need to query all agents who have services matching that metadata, and deregister each service
 // instance. When querying by the k8s service name and namespace, the request will return service instances and
-// associated proxy service instances.
+// associated proxy service instances. Pods may override the "k8s-service-name" metadata with the kube-service-name
+// annotation, in which case their instances are deregistered when reconciling the overriding service instead.
 // The argument endpointsAddressesMap decides whether to deregister *all* service instances or selectively deregister
 // them only if they are not in endpointsAddressesMap. If the map is nil, it will deregister all instances. If the map
-// has addresses, it will only deregister instances not in the map.
-func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context, k8sSvcName, k8sSvcNamespace string, endpointsAddressesMap map[string]bool) error {
-	// Get all agents by getting pods with label component=client, app=consul and release=<ReleaseName>
-	agents := corev1.PodList{}
-	listOptions := client.ListOptions{
-		Namespace: r.ReleaseNamespace,
-		LabelSelector: labels.SelectorFromSet(map[string]string{
-			"component": "client",
-			"app":       "consul",
-			"release":   r.ReleaseName,
-		}),
-	}
-	if err := r.Client.List(ctx, &agents, &listOptions); err != nil {
+// has addresses, it will only deregister instances not in the map. It returns true if any service instance was
+// deregistered.
+func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context, k8sSvcName, k8sSvcNamespace string, endpointsAddressesMap map[string]bool) (bool, error) {
+	var deregistered bool
+
+	agents, err := r.consulClientAgents(ctx)
+	if err != nil {
 		r.Log.Error(err, "failed to get Consul client agent pods")
-		return err
+		return deregistered, err
+	}
+
+	consulNamespaces, err := r.consulNamespacesForK8SNamespace(ctx, k8sSvcNamespace)
+	if err != nil {
+		r.Log.Error(err, "failed to get Consul namespaces for Kubernetes namespace", "ns", k8sSvcNamespace)
+		return deregistered, err
+	}
+	consulPartitions, err := r.consulPartitionsForK8SNamespace(ctx, k8sSvcNamespace)
+	if err != nil {
+		r.Log.Error(err, "failed to get Consul partitions for Kubernetes namespace", "ns", k8sSvcNamespace)
+		return deregistered, err
 	}
 
 	// On each agent, we need to get services matching "k8s-service-name" and "k8s-namespace" metadata.
@@ -714,46 +1679,200 @@ func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context,
 			r.Log.Info("Consul client agent is not ready, skipping deregistration", "consul-agent", agent.Name, "svc", k8sSvcName)
 			continue
 		}
+		// Services are only registered with the agents in their partition.
+		var agentPartitions []string
+		for _, partition := range consulPartitions {
+			if r.agentInPartition(agent, partition) {
+				agentPartitions = append(agentPartitions, partition)
+			}
+		}
+		if len(agentPartitions) == 0 {
+			continue
+		}
 		client, err := r.remoteConsulClient(agent.Status.PodIP, r.consulNamespace(k8sSvcNamespace))
 		if err != nil {
 			r.Log.Error(err, "failed to create a new Consul client", "address", agent.Status.PodIP)
-			return err
+			return deregistered, err
+		}
+
+		// Pods may override the partition their services are registered in, so query each of them.
+		for _, partition := range agentPartitions {
+			// Services may be registered in more than one Consul namespace, so query each of them.
+			for _, consulNS := range consulNamespaces {
+				// Get services matching metadata.
+				svcs, err := serviceInstancesForK8SServiceNameAndNamespace(ctx, k8sSvcName, k8sSvcNamespace, consulNS, partition, client)
+				if err != nil {
+					r.Log.Error(err, "failed to get service instances", "name", k8sSvcName, "consul-ns", consulNS, "partition", partition)
+					return deregistered, err
+				}
+
+				// Deregister each service instance that matches the metadata.
+				for svcID, serviceRegistration := range svcs {
+					// If we selectively deregister, only deregister if the address is not in the map. Otherwise, deregister
+					// every service instance.
+					var serviceDeregistered bool
+					if endpointsAddressesMap != nil {
+						if _, ok := endpointsAddressesMap[serviceRegistration.Address]; !ok {
+							// If the service address is not in the Endpoints addresses, deregister it.
+							r.Log.Info("deregistering service from consul", "svc", svcID)
+							if err = client.Agent().ServiceDeregisterOpts(svcID, (&api.QueryOptions{Namespace: consulNS, Partition: partition}).WithContext(ctx)); err != nil {
+								r.Log.Error(err, "failed to deregister service instance", "id", svcID)
+								return deregistered, err
+							}
+							serviceDeregistered = true
+						}
+					} else {
+						r.Log.Info("deregistering service from consul", "svc", svcID)
+						if err = client.Agent().ServiceDeregisterOpts(svcID, (&api.QueryOptions{Namespace: consulNS, Partition: partition}).WithContext(ctx)); err != nil {
+							r.Log.Error(err, "failed to deregister service instance", "id", svcID)
+							return deregistered, err
+						}
+						serviceDeregistered = true
+					}
+
+					if serviceDeregistered {
+						deregistered = true
+					}
+
+					if r.AuthMethod != "" && serviceDeregistered {
+						r.Log.Info("reconciling ACL tokens for service", "svc", serviceRegistration.Service)
+						err = r.deleteACLTokensForServiceInstance(ctx, client, serviceRegistration.Service, k8sSvcNamespace, serviceRegistration.Meta[MetaKeyPodName])
+						if err != nil {
+							r.Log.Error(err, "failed to reconcile ACL tokens for service", "svc", serviceRegistration.Service)
+							return deregistered, err
+						}
+					}
+				}
+			}
 		}
+	}
+
+	return deregistered, nil
+}
+
+// DeregisterConsulService deregisters every instance of the Consul service consulName in the Consul namespace
+// from all Consul client agents, along with the instances of its sidecar proxy. Unlike deregisterServiceOnAllAgents,
+// it matches services by their Consul service name rather than the "k8s-service-name" metadata, so it can be used
+// to clean up a single Consul service. Only services registered by this controller are deregistered.
+func (r *EndpointsController) DeregisterConsulService(ctx context.Context, consulName, namespace string) error {
+	agents, err := r.consulClientAgents(ctx)
+	if err != nil {
+		r.Log.Error(err, "failed to get Consul client agent pods")
+		return err
+	}
 
-		// Get services matching metadata.
-		svcs, err := serviceInstancesForK8SServiceNameAndNamespace(k8sSvcName, k8sSvcNamespace, client)
+	filter := fmt.Sprintf(`(Service == %q or Proxy.DestinationServiceName == %q) and Meta[%q] == %q`,
+		consulName, consulName, MetaKeyManagedBy, managedByValue)
+	for _, agent := range agents.Items {
+		ready := false
+		for _, status := range agent.Status.Conditions {
+			if status.Type == corev1.PodReady {
+				ready = status.Status == corev1.ConditionTrue
+			}
+		}
+		if !ready {
+			r.Log.Info("Consul client agent is not ready, skipping deregistration", "consul-agent", agent.Name, "svc", consulName)
+			continue
+		}
+		if !r.agentInPartition(agent, r.ConsulPartition) {
+			continue
+		}
+		client, err := r.remoteConsulClient(agent.Status.PodIP, namespace)
 		if err != nil {
-			r.Log.Error(err, "failed to get service instances", "name", k8sSvcName)
+			r.Log.Error(err, "failed to create a new Consul client", "address", agent.Status.PodIP)
 			return err
 		}
 
-		// Deregister each service instance that matches the metadata.
+		opts := (&api.QueryOptions{Namespace: namespace, Partition: r.ConsulPartition}).WithContext(ctx)
+		svcs, err := client.Agent().ServicesWithFilterOpts(filter, opts)
+		if err != nil {
+			r.Log.Error(err, "failed to get service instances", "name", consulName, "consul-ns", namespace)
+			return err
+		}
 		for svcID, serviceRegistration := range svcs {
-			// If we selectively deregister, only deregister if the address is not in the map. Otherwise, deregister
-			// every service instance.
-			var serviceDeregistered bool
-			if endpointsAddressesMap != nil {
-				if _, ok := endpointsAddressesMap[serviceRegistration.Address]; !ok {
-					// If the service address is not in the Endpoints addresses, deregister it.
-					r.Log.Info("deregistering service from consul", "svc", svcID)
-					if err = client.Agent().ServiceDeregister(svcID); err != nil {
-						r.Log.Error(err, "failed to deregister service instance", "id", svcID)
-						return err
-					}
-					serviceDeregistered = true
-				}
-			} else {
-				r.Log.Info("deregistering service from consul", "svc", svcID)
-				if err = client.Agent().ServiceDeregister(svcID); err != nil {
-					r.Log.Error(err, "failed to deregister service instance", "id", svcID)
+			r.Log.Info("deregistering service from consul", "svc", svcID)
+			if err = client.Agent().ServiceDeregisterOpts(svcID, opts); err != nil {
+				r.Log.Error(err, "failed to deregister service instance", "id", svcID)
+				return err
+			}
+			if r.AuthMethod != "" {
+				r.Log.Info("reconciling ACL tokens for service", "svc", serviceRegistration.Service)
+				err = r.deleteACLTokensForServiceInstance(ctx, client, serviceRegistration.Service, serviceRegistration.Meta[MetaKeyKubeNS], serviceRegistration.Meta[MetaKeyPodName])
+				if err != nil {
+					r.Log.Error(err, "failed to reconcile ACL tokens for service", "svc", serviceRegistration.Service)
 					return err
 				}
-				serviceDeregistered = true
 			}
+		}
+	}
+
+	return nil
+}
+
+// garbageCollectOrphanedServices deregisters the service instances registered by this controller on all Consul
+// client agents whose pod, named by their "pod-name" and "k8s-namespace" metadata, no longer exists.
+func (r *EndpointsController) garbageCollectOrphanedServices(ctx context.Context) error {
+	agents, err := r.consulClientAgents(ctx)
+	if err != nil {
+		r.Log.Error(err, "failed to get Consul client agent pods")
+		return err
+	}
 
-			if r.AuthMethod != "" && serviceDeregistered {
+	namespace := ""
+	if r.EnableConsulNamespaces {
+		namespace = "*"
+	}
+	filter := fmt.Sprintf(`Meta[%q] != "" and Meta[%q] == %q`, MetaKeyKubeServiceName, MetaKeyManagedBy, managedByValue)
+	for _, agent := range agents.Items {
+		ready := false
+		for _, status := range agent.Status.Conditions {
+			if status.Type == corev1.PodReady {
+				ready = status.Status == corev1.ConditionTrue
+			}
+		}
+		if !ready {
+			r.Log.Info("Consul client agent is not ready, skipping garbage collection", "consul-agent", agent.Name)
+			continue
+		}
+		if !r.agentInPartition(agent, r.ConsulPartition) {
+			continue
+		}
+		client, err := r.remoteConsulClient(agent.Status.PodIP, namespace)
+		if err != nil {
+			r.Log.Error(err, "failed to create a new Consul client", "address", agent.Status.PodIP)
+			return err
+		}
+
+		svcs, err := client.Agent().ServicesWithFilterOpts(filter, (&api.QueryOptions{Namespace: namespace, Partition: r.ConsulPartition}).WithContext(ctx))
+		if err != nil {
+			r.Log.Error(err, "failed to get service instances", "consul-agent", agent.Name)
+			return err
+		}
+		for svcID, serviceRegistration := range svcs {
+			podName := serviceRegistration.Meta[MetaKeyPodName]
+			k8sNS := serviceRegistration.Meta[MetaKeyKubeNS]
+			if podName == "" {
+				continue
+			}
+			var pod corev1.Pod
+			err = r.Client.Get(ctx, types.NamespacedName{Name: podName, Namespace: k8sNS}, &pod)
+			if err == nil {
+				continue
+			}
+			if !k8serrors.IsNotFound(err) {
+				r.Log.Error(err, "failed to get pod", "name", podName, "ns", k8sNS)
+				return err
+			}
+
+			r.Log.Info("deregistering orphaned service from consul", "svc", svcID, "pod", podName, "ns", k8sNS)
+			opts := (&api.QueryOptions{Namespace: serviceRegistration.Namespace, Partition: r.ConsulPartition}).WithContext(ctx)
+			if err = client.Agent().ServiceDeregisterOpts(svcID, opts); err != nil {
+				r.Log.Error(err, "failed to deregister service instance", "id", svcID)
+				return err
+			}
+			if r.AuthMethod != "" {
 				r.Log.Info("reconciling ACL tokens for service", "svc", serviceRegistration.Service)
-				err = r.deleteACLTokensForServiceInstance(client, serviceRegistration.Service, k8sSvcNamespace, serviceRegistration.Meta[MetaKeyPodName])
+				err = r.deleteACLTokensForServiceInstance(ctx, client, serviceRegistration.Service, k8sNS, podName)
 				if err != nil {
 					r.Log.Error(err, "failed to reconcile ACL tokens for service", "svc", serviceRegistration.Service)
 					return err
@@ -765,16 +1884,46 @@ func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context,
 	return nil
 }
 
+// consulClientAgents returns the Consul client agent pods, i.e. the pods with the labels component=client,
+// app=consul and release=<ReleaseName>.
+func (r *EndpointsController) consulClientAgents(ctx context.Context) (corev1.PodList, error) {
+	agents := corev1.PodList{}
+	listOptions := client.ListOptions{
+		Namespace: r.ReleaseNamespace,
+		LabelSelector: labels.SelectorFromSet(map[string]string{
+			"component": "client",
+			"app":       "consul",
+			"release":   r.ReleaseName,
+		}),
+	}
+	err := r.Client.List(ctx, &agents, &listOptions)
+	return agents, err
+}
+
+// agentInPartition returns true if the Consul client agent pod belongs to the provided Admin Partition. Agents
+// without the partition label are assumed to belong to the controller's partition. All agents belong to every
+// partition if partitions aren't enabled.
+func (r *EndpointsController) agentInPartition(agent corev1.Pod, partition string) bool {
+	if !r.EnableConsulPartitions || partition == "" {
+		return true
+	}
+	agentPartition, ok := agent.Labels[labelAgentPartition]
+	if !ok {
+		agentPartition = r.ConsulPartition
+	}
+	return agentPartition == partition
+}
+
 // deleteACLTokensForServiceInstance finds the ACL tokens that belongs to the service instance and deletes it from Consul.
 // It will only check for ACL tokens that have been created with the auth method this controller
 // has been configured with and will only delete tokens for the provided podName.
-func (r *EndpointsController) deleteACLTokensForServiceInstance(client *api.Client, serviceName, k8sNS, podName string) error {
+func (r *EndpointsController) deleteACLTokensForServiceInstance(ctx context.Context, client *api.Client, serviceName, k8sNS, podName string) error {
 	// Skip if podName is empty.
 	if podName == "" {
 		return nil
 	}
 
-	tokens, _, err := client.ACL().TokenList(nil)
+	tokens, _, err := client.ACL().TokenList((&api.QueryOptions{}).WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to get a list of tokens from Consul: %s", err)
 	}
@@ -796,7 +1945,7 @@ func (r *EndpointsController) deleteACLTokensForServiceInstance(client *api.Clie
 			// If we can't find token's pod, delete it.
 			if tokenPodName == podName {
 				r.Log.Info("deleting ACL token for pod", "name", podName)
-				_, err = client.ACL().TokenDelete(token.AccessorID, nil)
+				_, err = client.ACL().TokenDelete(token.AccessorID, (&api.WriteOptions{}).WithContext(ctx))
 				if err != nil {
 					return fmt.Errorf("failed to delete token from Consul: %s", err)
 				}
@@ -810,61 +1959,236 @@ func (r *EndpointsController) deleteACLTokensForServiceInstance(client *api.Clie
 }
 
 // processUpstreams reads the list of upstreams from the Pod annotation and converts them into a list of api.Upstream
-// objects.
-func (r *EndpointsController) processUpstreams(pod corev1.Pod, endpoints corev1.Endpoints) ([]api.Upstream, error) {
+// objects, followed by any upstreams from the namespace annotation and then the UpstreamDefaultsConfigMap that the
+// pod doesn't override.
+func (r *EndpointsController) processUpstreams(ctx context.Context, namespace corev1.Namespace, pod corev1.Pod, endpoints corev1.Endpoints) ([]api.Upstream, error) {
 	// In a multiport pod, only the first service's proxy should have upstreams configured. This skips configuring
 	// upstreams on additional services on the pod.
-	mpIdx := getMultiPortIdx(pod, endpoints)
+	serviceName, err := r.consulServiceName(pod, endpoints)
+	if err != nil {
+		return nil, err
+	}
+	mpIdx := getMultiPortIdx(pod, serviceName)
 	if mpIdx > 0 {
 		return []api.Upstream{}, nil
 	}
 
 	var upstreams []api.Upstream
 	if raw, ok := pod.Annotations[annotationUpstreams]; ok && raw != "" {
-		for _, raw := range strings.Split(raw, ",") {
-			var upstream api.Upstream
-
-			// parts separates out the port, and determines whether it's a prepared query or not, since parts[0] would
-			// be "prepared_query" if it is.
-			parts := strings.SplitN(raw, ":", 3)
-
-			// serviceParts helps determine which format of upstream we're processing,
-			// [service-name].[service-namespace].[service-partition]:[port]:[optional datacenter]
-			// or
-			// [service-name].svc.[service-namespace].ns.[service-peer].peer:[port]
-			// [service-name].svc.[service-namespace].ns.[service-partition].ap:[port]
-			// [service-name].svc.[service-namespace].ns.[service-datacenter].dc:[port]
-			labeledFormat := false
-			serviceParts := strings.Split(parts[0], ".")
-			if len(serviceParts) >= 2 {
-				if serviceParts[1] == "svc" {
-					labeledFormat = true
-				}
+		var err error
+		upstreams, err = r.parseUpstreams(ctx, pod, raw)
+		if err != nil {
+			return []api.Upstream{}, err
+		}
+	}
+
+	// Upstreams annotated on the namespace are defaults for all of its pods.
+	if raw, ok := namespace.Annotations[annotationUpstreams]; ok && raw != "" {
+		namespaceUpstreams, err := r.parseUpstreams(ctx, pod, raw)
+		if err != nil {
+			return []api.Upstream{}, fmt.Errorf("upstreams annotation on namespace %s is invalid: %w", namespace.Name, err)
+		}
+		upstreams = mergeUpstreams(upstreams, namespaceUpstreams)
+	}
+
+	defaults, err := r.upstreamDefaults(ctx, pod)
+	if err != nil {
+		return []api.Upstream{}, err
+	}
+
+	return mergeUpstreams(upstreams, defaults), nil
+}
+
+// upstreamDefaults returns the upstreams from the UpstreamDefaultsConfigMap, parsed as if they were set on the pod.
+// A missing ConfigMap is logged and treated as having no upstreams so that it doesn't block registrations.
+func (r *EndpointsController) upstreamDefaults(ctx context.Context, pod corev1.Pod) ([]api.Upstream, error) {
+	if r.UpstreamDefaultsConfigMap.Name == "" {
+		return nil, nil
+	}
+
+	var configMap corev1.ConfigMap
+	err := r.Client.Get(ctx, r.UpstreamDefaultsConfigMap, &configMap)
+	if k8serrors.IsNotFound(err) {
+		r.Log.Info("upstream defaults ConfigMap not found", "name", r.UpstreamDefaultsConfigMap.Name,
+			"ns", r.UpstreamDefaultsConfigMap.Namespace)
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get upstream defaults ConfigMap %s: %w", r.UpstreamDefaultsConfigMap, err)
+	}
+
+	raw := configMap.Data[upstreamDefaultsConfigMapKey]
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	upstreams, err := r.parseUpstreams(ctx, pod, raw)
+	if err != nil {
+		return nil, fmt.Errorf("upstream defaults ConfigMap %s is invalid: %w", r.UpstreamDefaultsConfigMap, err)
+	}
+	return upstreams, nil
+}
+
+// mergeUpstreams appends the default upstreams to the pod's upstreams, skipping any default with the same
+// destination or local bind port as one of the pod's upstreams.
+func mergeUpstreams(upstreams, defaults []api.Upstream) []api.Upstream {
+	type destination struct {
+		destType, name, namespace, partition, peer, datacenter string
+	}
+	key := func(u api.Upstream) destination {
+		return destination{string(u.DestinationType), u.DestinationName, u.DestinationNamespace,
+			u.DestinationPartition, u.DestinationPeer, u.Datacenter}
+	}
+
+	destinations := make(map[destination]bool)
+	ports := make(map[int]bool)
+	for _, u := range upstreams {
+		destinations[key(u)] = true
+		if u.LocalBindPort > 0 {
+			ports[u.LocalBindPort] = true
+		}
+	}
+	for _, u := range defaults {
+		if destinations[key(u)] || (u.LocalBindPort > 0 && ports[u.LocalBindPort]) {
+			continue
+		}
+		upstreams = append(upstreams, u)
+	}
+	return upstreams
+}
+
+// parseUpstreams converts a comma separated list of upstreams in the format of the upstreams annotation into a
+// list of api.Upstream objects. The CLI's proxy upstreams command has a copy of this parsing, so changes to the
+// format must be made there too and covered by testdata/upstreams.json, which both are tested against.
+func (r *EndpointsController) parseUpstreams(ctx context.Context, pod corev1.Pod, rawUpstreams string) ([]api.Upstream, error) {
+	var upstreams []api.Upstream
+	for _, raw := range strings.Split(rawUpstreams, ",") {
+		var upstream api.Upstream
+
+		// Any of the formats below may end with the datacenter in brackets, e.g. "upstream1:1234[dc2]".
+		raw, datacenter, err := parseUpstreamDatacenter(raw)
+		if err != nil {
+			return []api.Upstream{}, err
+		}
+
+		// parts separates out the port, and determines whether it's a prepared query or not, since parts[0] would
+		// be "prepared_query" if it is.
+		parts := strings.SplitN(raw, ":", 3)
+
+		// serviceParts helps determine which format of upstream we're processing,
+		// [service-name].[service-namespace].[service-partition]:[port]:[optional datacenter]
+		// or
+		// [service-name].svc.[service-namespace].ns.[service-peer].peer:[port]
+		// [service-name].svc.[service-namespace].ns.[service-partition].ap:[port]
+		// [service-name].svc.[service-namespace].ns.[service-datacenter].dc:[port]
+		labeledFormat := false
+		serviceParts := strings.Split(parts[0], ".")
+		if len(serviceParts) >= 2 {
+			if serviceParts[1] == "svc" {
+				labeledFormat = true
 			}
+		}
 
-			if strings.TrimSpace(parts[0]) == "prepared_query" {
-				upstream = processPreparedQueryUpstream(pod, raw)
-			} else if labeledFormat {
-				var err error
-				upstream, err = r.processLabeledUpstream(pod, raw)
-				if err != nil {
-					return []api.Upstream{}, err
+		if strings.TrimSpace(parts[0]) == "prepared_query" {
+			if datacenter != "" {
+				return []api.Upstream{}, fmt.Errorf("upstream %q is invalid: a datacenter can't be set for prepared query upstreams", raw)
+			}
+			upstream, err = r.processPreparedQueryUpstream(ctx, pod, raw)
+			if err != nil {
+				return []api.Upstream{}, err
+			}
+		} else if labeledFormat {
+			upstream, err = r.processLabeledUpstream(ctx, pod, raw)
+			if err != nil {
+				return []api.Upstream{}, err
+			}
+			if datacenter != "" {
+				if upstream.LocalBindPort == 0 {
+					return []api.Upstream{}, fmt.Errorf("upstream %q is invalid: the datacenter in brackets requires a valid port", raw)
 				}
-			} else {
-				var err error
-				upstream, err = r.processUnlabeledUpstream(pod, raw)
-				if err != nil {
-					return []api.Upstream{}, err
+				if upstream.Datacenter != "" || upstream.DestinationPeer != "" {
+					return []api.Upstream{}, fmt.Errorf("upstream %q is invalid: the datacenter in brackets can't be combined with a datacenter or peer label", raw)
+				}
+				upstream.Datacenter = datacenter
+			}
+		} else {
+			if datacenter != "" {
+				if len(parts) > 2 {
+					return []api.Upstream{}, fmt.Errorf("upstream %q is invalid: the datacenter must be set either in brackets or after the port, not both", raw)
 				}
+				// Bracketed datacenters are equivalent to the datacenter after the port.
+				raw = fmt.Sprintf("%s:%s", raw, datacenter)
 			}
+			upstream, err = r.processUnlabeledUpstream(ctx, pod, raw)
+			if err != nil {
+				return []api.Upstream{}, err
+			}
+		}
 
-			upstreams = append(upstreams, upstream)
+		if r.WarnOnMissingUpstreams && upstream.DestinationType == api.UpstreamDestTypeService && upstream.LocalBindPort > 0 {
+			r.warnIfUpstreamMissing(ctx, pod, upstream)
 		}
+
+		upstreams = append(upstreams, upstream)
 	}
 
 	return upstreams, nil
 }
 
+// warnIfUpstreamMissing logs a warning if the upstream service has no instances registered in the
+// Consul catalog. This is most often caused by a typo in the upstreams annotation. It never fails the
+// registration since the upstream service may simply not have been deployed yet.
+func (r *EndpointsController) warnIfUpstreamMissing(ctx context.Context, pod corev1.Pod, upstream api.Upstream) {
+	// Peered services are imported into the catalog asynchronously so we can't reliably look them up.
+	if upstream.DestinationPeer != "" {
+		return
+	}
+	found, err := r.upstreamHasInstances(ctx, upstream)
+	if err != nil {
+		r.Log.Error(err, "failed to look up upstream service in the Consul catalog", "name", pod.Name, "ns", pod.Namespace,
+			"upstream", upstream.DestinationName)
+		return
+	}
+	if !found {
+		r.Log.Info("upstream service has no instances registered in the Consul catalog", "name", pod.Name,
+			"ns", pod.Namespace, "upstream", upstream.DestinationName, "upstream-namespace", upstream.DestinationNamespace,
+			"upstream-partition", upstream.DestinationPartition, "upstream-datacenter", upstream.Datacenter)
+	}
+}
+
+// upstreamHasInstances returns whether the Consul catalog has at least one instance of the upstream service
+// in the upstream's namespace, partition and datacenter.
+func (r *EndpointsController) upstreamHasInstances(ctx context.Context, upstream api.Upstream) (bool, error) {
+	instances, _, err := r.ConsulClient.Catalog().Service(upstream.DestinationName, "", (&api.QueryOptions{
+		Namespace:  upstream.DestinationNamespace,
+		Partition:  upstream.DestinationPartition,
+		Datacenter: upstream.Datacenter,
+	}).WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	return len(instances) > 0, nil
+}
+
+// parseUpstreamDatacenter splits the optional datacenter suffix in brackets off of an upstream, e.g. "upstream1:1234[dc2]",
+// and returns the remaining upstream along with the datacenter. Since the datacenter is delimited by brackets rather
+// than ":" or ".", it can't be confused with the namespace, partition or port of the upstream. Upstreams without
+// brackets are returned unchanged with an empty datacenter.
+func parseUpstreamDatacenter(rawUpstream string) (string, string, error) {
+	trimmed := strings.TrimSpace(rawUpstream)
+	if !strings.ContainsAny(trimmed, "[]") {
+		return rawUpstream, "", nil
+	}
+
+	open := strings.Index(trimmed, "[")
+	if open == -1 || !strings.HasSuffix(trimmed, "]") || strings.Count(trimmed, "[") != 1 || strings.Count(trimmed, "]") != 1 {
+		return "", "", fmt.Errorf("upstream %q is invalid: the datacenter must be a single suffix in brackets, e.g. \"upstream1:1234[dc2]\"", trimmed)
+	}
+	datacenter := strings.TrimSpace(trimmed[open+1 : len(trimmed)-1])
+	if datacenter == "" {
+		return "", "", fmt.Errorf("upstream %q is invalid: the datacenter in brackets must not be empty", trimmed)
+	}
+	return strings.TrimSpace(trimmed[:open]), datacenter, nil
+}
+
 // getTokenMetaFromDescription parses JSON metadata from token's description.
 func getTokenMetaFromDescription(description string) (map[string]string, error) {
 	re := regexp.MustCompile(`.*({.+})`)
@@ -886,35 +2210,59 @@ func getTokenMetaFromDescription(description string) (map[string]string, error)
 
 // serviceInstancesForK8SServiceNameAndNamespace calls Consul's ServicesWithFilter to get the list
 // of services instances that have the provided k8sServiceName and k8sServiceNamespace in their metadata.
-func serviceInstancesForK8SServiceNameAndNamespace(k8sServiceName, k8sServiceNamespace string, client *api.Client) (map[string]*api.AgentService, error) {
-	return client.Agent().ServicesWithFilter(
+// The query is scoped to the provided Consul namespace and Admin Partition. If either is empty, the
+// client's default is used.
+func serviceInstancesForK8SServiceNameAndNamespace(ctx context.Context, k8sServiceName, k8sServiceNamespace, consulNS, partition string, client *api.Client) (map[string]*api.AgentService, error) {
+	return client.Agent().ServicesWithFilterOpts(
 		fmt.Sprintf(`Meta[%q] == %q and Meta[%q] == %q and Meta[%q] == %q`,
-			MetaKeyKubeServiceName, k8sServiceName, MetaKeyKubeNS, k8sServiceNamespace, MetaKeyManagedBy, managedByValue))
+			MetaKeyKubeServiceName, k8sServiceName, MetaKeyKubeNS, k8sServiceNamespace, MetaKeyManagedBy, managedByValue),
+		(&api.QueryOptions{Namespace: consulNS, Partition: partition}).WithContext(ctx))
 }
 
 // processPreparedQueryUpstream processes an upstream in the format:
-// prepared_query:[query name]:[port].
-func processPreparedQueryUpstream(pod corev1.Pod, rawUpstream string) api.Upstream {
-	var preparedQuery string
+// prepared_query:[query name]:[port]
+// or, if Consul Namespaces are enabled,
+// prepared_query:[query name].[query namespace]:[port].
+func (r *EndpointsController) processPreparedQueryUpstream(ctx context.Context, pod corev1.Pod, rawUpstream string) (api.Upstream, error) {
+	var preparedQuery, namespace string
 	var port int32
 	parts := strings.SplitN(rawUpstream, ":", 3)
+	if len(parts) < 3 {
+		return api.Upstream{}, fmt.Errorf("upstream %q is invalid: prepared query upstreams must be in the format prepared_query:[query name]:[port]", rawUpstream)
+	}
 
 	port, _ = portValue(pod, strings.TrimSpace(parts[2]))
 	preparedQuery = strings.TrimSpace(parts[1])
+
+	// If Consul Namespaces are enabled, the query name may be followed by the namespace it is defined in.
+	if r.EnableConsulNamespaces {
+		if pieces := strings.SplitN(preparedQuery, ".", 2); len(pieces) == 2 {
+			preparedQuery = strings.TrimSpace(pieces[0])
+			namespace = strings.TrimSpace(pieces[1])
+			if !validConsulNamespaceName.MatchString(namespace) {
+				return api.Upstream{}, fmt.Errorf("upstream %q is invalid: prepared query namespace %q must be at most 64 alphanumeric characters or dashes and must start and end with an alphanumeric character", rawUpstream, namespace)
+			}
+		}
+	}
+	if preparedQuery == "" {
+		return api.Upstream{}, fmt.Errorf("upstream %q is invalid: the prepared query name must not be empty", rawUpstream)
+	}
+
 	var upstream api.Upstream
 	if port > 0 {
 		upstream = api.Upstream{
-			DestinationType: api.UpstreamDestTypePreparedQuery,
-			DestinationName: preparedQuery,
-			LocalBindPort:   int(port),
+			DestinationType:      api.UpstreamDestTypePreparedQuery,
+			DestinationNamespace: namespace,
+			DestinationName:      preparedQuery,
+			LocalBindPort:        int(port),
 		}
 	}
-	return upstream
+	return upstream, nil
 }
 
 // processUnlabeledUpstream processes an upstream in the format:
 // [service-name].[service-namespace].[service-partition]:[port]:[optional datacenter].
-func (r *EndpointsController) processUnlabeledUpstream(pod corev1.Pod, rawUpstream string) (api.Upstream, error) {
+func (r *EndpointsController) processUnlabeledUpstream(ctx context.Context, pod corev1.Pod, rawUpstream string) (api.Upstream, error) {
 	var datacenter, serviceName, namespace, partition, peer string
 	var port int32
 	var upstream api.Upstream
@@ -950,7 +2298,7 @@ func (r *EndpointsController) processUnlabeledUpstream(pod corev1.Pod, rawUpstre
 		// accidentally forgetting to set a mesh gateway mode
 		// and then being confused as to why their traffic isn't
 		// routing.
-		entry, _, err := r.ConsulClient.ConfigEntries().Get(api.ProxyDefaults, api.ProxyConfigGlobal, nil)
+		entry, _, err := r.ConsulClient.ConfigEntries().Get(api.ProxyDefaults, api.ProxyConfigGlobal, (&api.QueryOptions{}).WithContext(ctx))
 		if err != nil && strings.Contains(err.Error(), "Unexpected response code: 404") {
 			return api.Upstream{}, fmt.Errorf("upstream %q is invalid: there is no ProxyDefaults config to set mesh gateway mode", rawUpstream)
 		} else if err == nil {
@@ -981,7 +2329,7 @@ func (r *EndpointsController) processUnlabeledUpstream(pod corev1.Pod, rawUpstre
 // [service-name].svc.[service-namespace].ns.[service-peer].peer:[port]
 // [service-name].svc.[service-namespace].ns.[service-partition].ap:[port]
 // [service-name].svc.[service-namespace].ns.[service-datacenter].dc:[port].
-func (r *EndpointsController) processLabeledUpstream(pod corev1.Pod, rawUpstream string) (api.Upstream, error) {
+func (r *EndpointsController) processLabeledUpstream(ctx context.Context, pod corev1.Pod, rawUpstream string) (api.Upstream, error) {
 	var datacenter, serviceName, namespace, partition, peer string
 	var port int32
 	var upstream api.Upstream
@@ -1059,13 +2407,54 @@ func (r *EndpointsController) processLabeledUpstream(pod corev1.Pod, rawUpstream
 
 // remoteConsulClient returns an *api.Client that points at the consul agent local to the pod for a provided namespace.
 func (r *EndpointsController) remoteConsulClient(ip string, namespace string) (*api.Client, error) {
-	newAddr := fmt.Sprintf("%s://%s:%s", r.ConsulScheme, ip, r.ConsulPort)
+	scheme, port := r.consulSchemeAndPort()
+	newAddr := fmt.Sprintf("%s://%s:%s", scheme, ip, port)
 	localConfig := r.ConsulClientCfg
 	localConfig.Address = newAddr
 	localConfig.Namespace = namespace
 	return consul.NewClient(localConfig, r.ConsulAPITimeout)
 }
 
+// consulSchemeAndPort returns the scheme and port used to make API calls to the Consul agents local to pods.
+// ConsulScheme and ConsulPort take precedence if set. Otherwise, they are detected from the standard
+// CONSUL_HTTP_ADDR and CONSUL_HTTP_SSL environment variables, falling back to "http" and "8500".
+func (r *EndpointsController) consulSchemeAndPort() (string, string) {
+	scheme, port := r.ConsulScheme, r.ConsulPort
+
+	var envScheme, envPort string
+	if addr := os.Getenv(api.HTTPAddrEnvName); addr != "" {
+		if parts := strings.SplitN(addr, "://", 2); len(parts) == 2 {
+			// Other schemes, e.g. unix sockets, can't be used to reach the agents on other nodes.
+			if parts[0] == "http" || parts[0] == "https" {
+				envScheme = parts[0]
+			}
+			addr = parts[1]
+		}
+		if _, p, err := net.SplitHostPort(addr); err == nil {
+			envPort = p
+		}
+	}
+	if envScheme == "" {
+		if ssl, err := strconv.ParseBool(os.Getenv(api.HTTPSSLEnvName)); err == nil && ssl {
+			envScheme = "https"
+		}
+	}
+
+	if scheme == "" {
+		scheme = envScheme
+	}
+	if scheme == "" {
+		scheme = "http"
+	}
+	if port == "" {
+		port = envPort
+	}
+	if port == "" {
+		port = "8500"
+	}
+	return scheme, port
+}
+
 // shouldIgnore ignores namespaces where we don't connect-inject.
 func shouldIgnore(namespace string, denySet, allowSet mapset.Set) bool {
 	// Ignores system namespaces.
@@ -1178,6 +2567,99 @@ func (r *EndpointsController) consulNamespace(namespace string) string {
 	return namespaces.ConsulNamespace(namespace, r.EnableConsulNamespaces, r.ConsulDestinationNamespace, r.EnableNSMirroring, r.NSMirroringPrefix)
 }
 
+// podConsulNamespace returns the Consul namespace the pod's services are registered in. This is the namespace
+// derived from the pod's Kubernetes namespace unless it is overridden by the consul.hashicorp.com/consul-namespace
+// annotation. It returns an empty string if Consul namespaces aren't enabled.
+func (r *EndpointsController) podConsulNamespace(pod corev1.Pod) (string, error) {
+	if !r.EnableConsulNamespaces {
+		return "", nil
+	}
+	if raw, ok := pod.Annotations[annotationConsulNamespace]; ok && raw != "" {
+		if err := validateConsulNamespaceAnnotation(raw); err != nil {
+			return "", err
+		}
+		return raw, nil
+	}
+	return r.consulNamespace(pod.Namespace), nil
+}
+
+// podConsulPartition returns the Consul Admin Partition the pod's services are registered in. This is the
+// controller's partition unless it is overridden by the consul.hashicorp.com/consul-partition annotation.
+func (r *EndpointsController) podConsulPartition(pod corev1.Pod) (string, error) {
+	if !r.EnableConsulPartitions {
+		return r.ConsulPartition, nil
+	}
+	if raw, ok := pod.Annotations[annotationConsulPartition]; ok && raw != "" {
+		if err := validateConsulPartitionAnnotation(raw); err != nil {
+			return "", err
+		}
+		return raw, nil
+	}
+	return r.ConsulPartition, nil
+}
+
+// consulNamespacesForK8SNamespace returns the Consul namespaces that services from the provided Kubernetes namespace
+// may be registered in: the namespace derived from the Kubernetes namespace and any namespaces that pods managed by
+// this controller override with the consul.hashicorp.com/consul-namespace annotation.
+func (r *EndpointsController) consulNamespacesForK8SNamespace(ctx context.Context, k8sNS string) ([]string, error) {
+	consulNamespaces := []string{r.consulNamespace(k8sNS)}
+	if !r.EnableConsulNamespaces {
+		return consulNamespaces, nil
+	}
+
+	var pods corev1.PodList
+	listOptions := client.ListOptions{
+		Namespace:     k8sNS,
+		LabelSelector: labels.SelectorFromSet(map[string]string{keyManagedBy: managedByValue}),
+	}
+	if err := r.Client.List(ctx, &pods, &listOptions); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{consulNamespaces[0]: true}
+	for _, pod := range pods.Items {
+		// Pods with an invalid namespace annotation are never registered, so they can be skipped.
+		consulNS, err := r.podConsulNamespace(pod)
+		if err != nil || seen[consulNS] {
+			continue
+		}
+		seen[consulNS] = true
+		consulNamespaces = append(consulNamespaces, consulNS)
+	}
+	return consulNamespaces, nil
+}
+
+// consulPartitionsForK8SNamespace returns the Consul Admin Partitions that services from the provided Kubernetes
+// namespace may be registered in: the controller's partition and any partitions that pods managed by this controller
+// override with the consul.hashicorp.com/consul-partition annotation.
+func (r *EndpointsController) consulPartitionsForK8SNamespace(ctx context.Context, k8sNS string) ([]string, error) {
+	consulPartitions := []string{r.ConsulPartition}
+	if !r.EnableConsulPartitions {
+		return consulPartitions, nil
+	}
+
+	var pods corev1.PodList
+	listOptions := client.ListOptions{
+		Namespace:     k8sNS,
+		LabelSelector: labels.SelectorFromSet(map[string]string{keyManagedBy: managedByValue}),
+	}
+	if err := r.Client.List(ctx, &pods, &listOptions); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{consulPartitions[0]: true}
+	for _, pod := range pods.Items {
+		// Pods with an invalid partition annotation are never registered, so they can be skipped.
+		partition, err := r.podConsulPartition(pod)
+		if err != nil || seen[partition] {
+			continue
+		}
+		seen[partition] = true
+		consulPartitions = append(consulPartitions, partition)
+	}
+	return consulPartitions, nil
+}
+
 // hasBeenInjected checks the value of the status annotation and returns true if the Pod has been injected.
 func hasBeenInjected(pod corev1.Pod) bool {
 	if anno, ok := pod.Annotations[keyInjectStatus]; ok && anno == injected {
@@ -1186,18 +2668,24 @@ func hasBeenInjected(pod corev1.Pod) bool {
 	return false
 }
 
-// mapAddresses combines all addresses to a mapping of address to its health status.
-func mapAddresses(addresses corev1.EndpointSubset) map[corev1.EndpointAddress]string {
-	m := make(map[corev1.EndpointAddress]string)
-	for _, readyAddress := range addresses.Addresses {
-		m[readyAddress] = api.HealthPassing
+// subsetAddress is an address of an Endpoints subset along with whether the address is ready.
+type subsetAddress struct {
+	address corev1.EndpointAddress
+	ready   bool
+}
+
+// subsetAddresses returns the ready addresses of the subset followed by its not ready addresses.
+func subsetAddresses(subset corev1.EndpointSubset) []subsetAddress {
+	addresses := make([]subsetAddress, 0, len(subset.Addresses)+len(subset.NotReadyAddresses))
+	for _, readyAddress := range subset.Addresses {
+		addresses = append(addresses, subsetAddress{address: readyAddress, ready: true})
 	}
 
-	for _, notReadyAddress := range addresses.NotReadyAddresses {
-		m[notReadyAddress] = api.HealthCritical
+	for _, notReadyAddress := range subset.NotReadyAddresses {
+		addresses = append(addresses, subsetAddress{address: notReadyAddress, ready: false})
 	}
 
-	return m
+	return addresses
 }
 
 // isLabeledIgnore checks the value of the label `consul.hashicorp.com/service-ignore` and returns true if the
@@ -1220,6 +2708,21 @@ func consulTags(pod corev1.Pod) []string {
 		tags = append(tags, strings.Split(raw, ",")...)
 	}
 
+	return interpolateTags(pod, tags)
+}
+
+// proxyTags returns the tags that should be added to the proxy registration: the tags shared with the
+// service followed by the tags from the proxy tags annotation.
+func proxyTags(pod corev1.Pod, serviceTags []string) []string {
+	raw, ok := pod.Annotations[annotationProxyTags]
+	if !ok || raw == "" {
+		return serviceTags
+	}
+	return append(append([]string{}, serviceTags...), interpolateTags(pod, strings.Split(raw, ","))...)
+}
+
+// interpolateTags replaces the tokens in each of the tags with the values of the pod's fields and labels.
+func interpolateTags(pod corev1.Pod, tags []string) []string {
 	var interpolatedTags []string
 	for _, t := range tags {
 		// Support light interpolation to preserve backwards compatibility where tags could
@@ -1229,15 +2732,75 @@ func consulTags(pod corev1.Pod) []string {
 		if t == "$POD_NAME" {
 			t = pod.Name
 		}
-		interpolatedTags = append(interpolatedTags, t)
+		interpolatedTags = append(interpolatedTags, interpolateTag(pod, t))
 	}
 
 	return interpolatedTags
 }
 
-func getMultiPortIdx(pod corev1.Pod, serviceEndpoints corev1.Endpoints) int {
+// interpolateTag replaces the $(TOKEN) tokens in the tag with the values of the pod's fields and labels. See
+// tagTokenValue for the supported tokens. "$$" is replaced with a literal "$". Any other "$", unknown tokens,
+// tokens for labels the pod doesn't have and tokens missing their closing parenthesis are left as is so that
+// tags which happen to contain "$(" keep registering unchanged.
+func interpolateTag(pod corev1.Pod, tag string) string {
+	var b strings.Builder
+	for i := 0; i < len(tag); i++ {
+		if tag[i] != '$' || i+1 == len(tag) {
+			b.WriteByte(tag[i])
+			continue
+		}
+
+		switch tag[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '(':
+			end := strings.IndexByte(tag[i+2:], ')')
+			if end < 0 {
+				b.WriteByte('$')
+				continue
+			}
+			if value, ok := tagTokenValue(pod, tag[i+2:i+2+end]); ok {
+				b.WriteString(value)
+			} else {
+				b.WriteString(tag[i : i+3+end])
+			}
+			// Skip past the closing parenthesis.
+			i += 2 + end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String()
+}
+
+// tagTokenValue returns the value of a service tag token. The supported tokens are POD_NAME, POD_NAMESPACE,
+// POD_IP, NODE_NAME and POD_LABEL_<key>, which is the value of the pod's label <key>. It returns false if the
+// token isn't supported or the pod doesn't have the label.
+func tagTokenValue(pod corev1.Pod, token string) (string, bool) {
+	switch token {
+	case "POD_NAME":
+		return pod.Name, true
+	case "POD_NAMESPACE":
+		return pod.Namespace, true
+	case "POD_IP":
+		return pod.Status.PodIP, true
+	case "NODE_NAME":
+		return pod.Spec.NodeName, true
+	}
+
+	if key := strings.TrimPrefix(token, "POD_LABEL_"); key != token {
+		value, ok := pod.Labels[key]
+		return value, ok
+	}
+	return "", false
+}
+
+// getMultiPortIdx returns the index of the Consul service name in the service annotation of a multi port pod, or -1
+// if it isn't listed.
+func getMultiPortIdx(pod corev1.Pod, serviceName string) int {
 	for i, name := range strings.Split(pod.Annotations[annotationService], ",") {
-		if name == getServiceName(pod, serviceEndpoints) {
+		if name == serviceName {
 			return i
 		}
 	}
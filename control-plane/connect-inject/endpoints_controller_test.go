@@ -2,9 +2,16 @@ package connectinject
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 	logrtest "github.com/go-logr/logr/testing"
@@ -168,7 +175,7 @@ func TestProcessUpstreamsTLSandACLs(t *testing.T) {
 	pod := createPod("pod1", "1.2.3.4", true, true)
 	pod.Annotations[annotationUpstreams] = "upstream1:1234:dc1"
 
-	upstreams, err := ep.processUpstreams(*pod, corev1.Endpoints{
+	upstreams, err := ep.processUpstreams(context.Background(), corev1.Namespace{}, *pod, corev1.Endpoints{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        "svcname",
 			Namespace:   "default",
@@ -189,6 +196,361 @@ func TestProcessUpstreamsTLSandACLs(t *testing.T) {
 	require.Equal(t, expected, upstreams)
 }
 
+func TestParseUpstreamDatacenter(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		raw           string
+		expUpstream   string
+		expDatacenter string
+		expErr        string
+	}{
+		"no brackets": {
+			raw:         "upstream1:1234",
+			expUpstream: "upstream1:1234",
+		},
+		"legacy datacenter after the port": {
+			raw:         "upstream1:1234:dc1",
+			expUpstream: "upstream1:1234:dc1",
+		},
+		"datacenter in brackets": {
+			raw:           "upstream1:1234[dc1]",
+			expUpstream:   "upstream1:1234",
+			expDatacenter: "dc1",
+		},
+		"namespace and partition with datacenter in brackets": {
+			raw:           "upstream1.ns1.part1:1234[dc1]",
+			expUpstream:   "upstream1.ns1.part1:1234",
+			expDatacenter: "dc1",
+		},
+		"labeled upstream with datacenter in brackets": {
+			raw:           "upstream1.svc.ns1.ns.part1.ap:1234[dc1]",
+			expUpstream:   "upstream1.svc.ns1.ns.part1.ap:1234",
+			expDatacenter: "dc1",
+		},
+		"surrounding whitespace": {
+			raw:           " upstream1:1234[ dc1 ] ",
+			expUpstream:   "upstream1:1234",
+			expDatacenter: "dc1",
+		},
+		"empty brackets": {
+			raw:    "upstream1:1234[]",
+			expErr: `upstream "upstream1:1234[]" is invalid: the datacenter in brackets must not be empty`,
+		},
+		"unterminated brackets": {
+			raw:    "upstream1:1234[dc1",
+			expErr: `upstream "upstream1:1234[dc1" is invalid: the datacenter must be a single suffix in brackets, e.g. "upstream1:1234[dc2]"`,
+		},
+		"unopened brackets": {
+			raw:    "upstream1:1234dc1]",
+			expErr: `upstream "upstream1:1234dc1]" is invalid: the datacenter must be a single suffix in brackets, e.g. "upstream1:1234[dc2]"`,
+		},
+		"brackets before the port": {
+			raw:    "upstream1[dc1]:1234",
+			expErr: `upstream "upstream1[dc1]:1234" is invalid: the datacenter must be a single suffix in brackets, e.g. "upstream1:1234[dc2]"`,
+		},
+		"multiple brackets": {
+			raw:    "upstream1:1234[dc1][dc2]",
+			expErr: `upstream "upstream1:1234[dc1][dc2]" is invalid: the datacenter must be a single suffix in brackets, e.g. "upstream1:1234[dc2]"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			upstream, datacenter, err := parseUpstreamDatacenter(c.raw)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expUpstream, upstream)
+			require.Equal(t, c.expDatacenter, datacenter)
+		})
+	}
+}
+
+// TestProcessUpstreams_WarnOnMissingUpstreams tests that upstreams are only looked up in the
+// Consul catalog when enabled and that an upstream with no instances doesn't fail registration.
+func TestProcessUpstreams_WarnOnMissingUpstreams(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		warnOnMissingUpstreams bool
+		catalogResponse        string
+		expCatalogQueries      []string
+		expHasInstances        bool
+	}{
+		"disabled": {
+			warnOnMissingUpstreams: false,
+			catalogResponse:        "[]",
+			expCatalogQueries:      nil,
+		},
+		"enabled with no instances": {
+			warnOnMissingUpstreams: true,
+			catalogResponse:        "[]",
+			expCatalogQueries:      []string{"/v1/catalog/service/upstream1", "/v1/catalog/service/upstream2"},
+			expHasInstances:        false,
+		},
+		"enabled with instances": {
+			warnOnMissingUpstreams: true,
+			catalogResponse:        `[{"ServiceName": "upstream1"}]`,
+			expCatalogQueries:      []string{"/v1/catalog/service/upstream1", "/v1/catalog/service/upstream2"},
+			expHasInstances:        true,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			// The test server handles requests concurrently so the queries are guarded by a mutex.
+			var catalogQueriesLock sync.Mutex
+			var catalogQueries []string
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r != nil && strings.HasPrefix(r.URL.Path, "/v1/catalog/service/") && r.Method == "GET" {
+					catalogQueriesLock.Lock()
+					catalogQueries = append(catalogQueries, r.URL.Path)
+					catalogQueriesLock.Unlock()
+					w.Write([]byte(c.catalogResponse))
+				}
+			}))
+			defer consulServer.Close()
+
+			consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+			require.NoError(t, err)
+
+			ep := &EndpointsController{
+				Log:                    logrtest.TestLogger{T: t},
+				ConsulClient:           consulClient,
+				WarnOnMissingUpstreams: c.warnOnMissingUpstreams,
+			}
+
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			// Prepared query upstreams should never be looked up in the catalog.
+			pod.Annotations[annotationUpstreams] = "upstream1:1234, upstream2:2345, prepared_query:query1:3456"
+
+			upstreams, err := ep.processUpstreams(context.Background(), corev1.Namespace{}, *pod, corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "svcname",
+					Namespace: "default",
+				},
+			})
+			require.NoError(t, err)
+			require.Len(t, upstreams, 3)
+			catalogQueriesLock.Lock()
+			actualCatalogQueries := catalogQueries
+			catalogQueriesLock.Unlock()
+			require.Equal(t, c.expCatalogQueries, actualCatalogQueries)
+
+			hasInstances, err := ep.upstreamHasInstances(context.Background(), upstreams[0])
+			require.NoError(t, err)
+			require.Equal(t, c.expHasInstances, hasInstances)
+		})
+	}
+}
+
+// TestProcessUpstreams_UpstreamDefaultsConfigMap tests that the upstreams from the defaults ConfigMap are merged with
+// the pod's upstreams, and that the pod's upstreams take precedence.
+func TestProcessUpstreams_UpstreamDefaultsConfigMap(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		configMap    *corev1.ConfigMap
+		expUpstreams []api.Upstream
+		expErr       string
+	}{
+		"no ConfigMap": {
+			expUpstreams: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "db", LocalBindPort: 5678},
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "web", LocalBindPort: 3456},
+			},
+		},
+		"merged with pod upstreams": {
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "upstream-defaults", Namespace: "consul"},
+				Data: map[string]string{
+					// db is overridden by the pod and metrics uses the same port as one of the pod's upstreams.
+					"upstreams": "db:1234, cache:2345, metrics:3456",
+				},
+			},
+			expUpstreams: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "db", LocalBindPort: 5678},
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "web", LocalBindPort: 3456},
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "cache", LocalBindPort: 2345},
+			},
+		},
+		"invalid ConfigMap upstreams": {
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "upstream-defaults", Namespace: "consul"},
+				Data:       map[string]string{"upstreams": "cache:2345[]"},
+			},
+			expErr: "upstream defaults ConfigMap consul/upstream-defaults is invalid: upstream \"cache:2345[]\" is invalid: the datacenter in brackets must not be empty",
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			pod.Annotations[annotationUpstreams] = "db:5678, web:3456"
+
+			clientBuilder := fake.NewClientBuilder()
+			if c.configMap != nil {
+				clientBuilder.WithRuntimeObjects(c.configMap)
+			}
+			ep := &EndpointsController{
+				Client:                    clientBuilder.Build(),
+				Log:                       logrtest.TestLogger{T: t},
+				Context:                   context.Background(),
+				UpstreamDefaultsConfigMap: types.NamespacedName{Name: "upstream-defaults", Namespace: "consul"},
+			}
+
+			upstreams, err := ep.processUpstreams(context.Background(), corev1.Namespace{}, *pod, corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "svcname",
+					Namespace: "default",
+				},
+			})
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expUpstreams, upstreams)
+		})
+	}
+}
+
+// TestProcessUpstreams_NamespaceUpstreams tests that the upstreams annotated on the pod's namespace are merged with
+// the pod's upstreams, and that the pod's upstreams take precedence.
+func TestProcessUpstreams_NamespaceUpstreams(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		podUpstreams       string
+		namespaceUpstreams string
+		expUpstreams       []api.Upstream
+		expErr             string
+	}{
+		"no namespace upstreams": {
+			podUpstreams: "db:5678",
+			expUpstreams: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "db", LocalBindPort: 5678},
+			},
+		},
+		"namespace upstreams without pod upstreams": {
+			namespaceUpstreams: "db:1234, cache:2345",
+			expUpstreams: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "db", LocalBindPort: 1234},
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "cache", LocalBindPort: 2345},
+			},
+		},
+		"merged with pod upstreams": {
+			podUpstreams: "db:5678, web:3456",
+			// db is overridden by the pod and metrics uses the same port as one of the pod's upstreams.
+			namespaceUpstreams: "db:1234, cache:2345, metrics:3456",
+			expUpstreams: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "db", LocalBindPort: 5678},
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "web", LocalBindPort: 3456},
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "cache", LocalBindPort: 2345},
+			},
+		},
+		"invalid namespace upstreams": {
+			podUpstreams:       "db:5678",
+			namespaceUpstreams: "cache:2345[]",
+			expErr:             "upstreams annotation on namespace default is invalid: upstream \"cache:2345[]\" is invalid: the datacenter in brackets must not be empty",
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			if c.podUpstreams != "" {
+				pod.Annotations[annotationUpstreams] = c.podUpstreams
+			}
+			ns := corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "default",
+					Annotations: map[string]string{annotationUpstreams: c.namespaceUpstreams},
+				},
+			}
+			ep := &EndpointsController{
+				Log: logrtest.TestLogger{T: t},
+			}
+
+			upstreams, err := ep.processUpstreams(context.Background(), ns, *pod, corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "svcname",
+					Namespace: "default",
+				},
+			})
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expUpstreams, upstreams)
+		})
+	}
+}
+
+// upstreamsFixture holds upstreams annotations along with the upstreams they are parsed into. It is shared with
+// the CLI's proxy upstreams command, which parses the annotation without a Consul client, so that the two
+// parsers are tested against the same cases.
+type upstreamsFixture struct {
+	// ContainerPorts are the named ports of the pod the upstreams are parsed for.
+	ContainerPorts map[string]int32
+	Cases          []struct {
+		Name string
+		// Enterprise enables Consul namespaces and admin partitions.
+		Enterprise bool
+		Upstreams  string
+		Expected   []api.Upstream
+		ExpErr     bool
+	}
+}
+
+// TestParseUpstreams_fixture tests that the upstreams in testdata/upstreams.json are parsed as expected. The CLI
+// tests its own parser against the same file.
+func TestParseUpstreams_fixture(t *testing.T) {
+	t.Parallel()
+	raw, err := os.ReadFile("testdata/upstreams.json")
+	require.NoError(t, err)
+	var fixture upstreamsFixture
+	require.NoError(t, json.Unmarshal(raw, &fixture))
+
+	// Upstreams with a datacenter look up the mesh gateway mode in the proxy defaults.
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&api.ProxyConfigEntry{
+			Kind:        api.ProxyDefaults,
+			Name:        api.ProxyConfigGlobal,
+			MeshGateway: api.MeshGatewayConfig{Mode: api.MeshGatewayModeLocal},
+		})
+	}))
+	defer consulServer.Close()
+	consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	pod.Spec.Containers = []corev1.Container{{Name: "web"}}
+	for name, port := range fixture.ContainerPorts {
+		pod.Spec.Containers[0].Ports = append(pod.Spec.Containers[0].Ports, corev1.ContainerPort{Name: name, ContainerPort: port})
+	}
+	for _, c := range fixture.Cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			ep := EndpointsController{
+				Log:                    logrtest.TestLogger{T: t},
+				ConsulClient:           consulClient,
+				EnableConsulNamespaces: c.Enterprise,
+				EnableConsulPartitions: c.Enterprise,
+			}
+			upstreams, err := ep.parseUpstreams(context.Background(), *pod, c.Upstreams)
+			if c.ExpErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.Expected, upstreams)
+		})
+	}
+}
+
 func TestProcessUpstreams(t *testing.T) {
 	t.Parallel()
 	nodeName := "test-node"
@@ -500,6 +862,107 @@ func TestProcessUpstreams(t *testing.T) {
 			consulNamespacesEnabled: false,
 			consulPartitionsEnabled: false,
 		},
+		{
+			name: "upstream with datacenter in brackets",
+			pod: func() *corev1.Pod {
+				pod1 := createPod("pod1", "1.2.3.4", true, true)
+				pod1.Annotations[annotationUpstreams] = "upstream1:1234[dc1]"
+				return pod1
+			},
+			expected: []api.Upstream{
+				{
+					DestinationType: api.UpstreamDestTypeService,
+					DestinationName: "upstream1",
+					Datacenter:      "dc1",
+					LocalBindPort:   1234,
+				},
+			},
+			configEntry: func() api.ConfigEntry {
+				ce, _ := api.MakeConfigEntry(api.ProxyDefaults, "pd")
+				pd := ce.(*api.ProxyConfigEntry)
+				pd.MeshGateway.Mode = api.MeshGatewayModeLocal
+				return pd
+			},
+			consulNamespacesEnabled: false,
+			consulPartitionsEnabled: false,
+		},
+		{
+			name: "upstream with namespace, partition and datacenter in brackets",
+			pod: func() *corev1.Pod {
+				pod1 := createPod("pod1", "1.2.3.4", true, true)
+				pod1.Annotations[annotationUpstreams] = "upstream1.ns1.part1:1234[dc1], upstream2.svc.ns2.ns:2234[dc2]"
+				return pod1
+			},
+			expected: []api.Upstream{
+				{
+					DestinationType:      api.UpstreamDestTypeService,
+					DestinationPartition: "part1",
+					DestinationNamespace: "ns1",
+					DestinationName:      "upstream1",
+					Datacenter:           "dc1",
+					LocalBindPort:        1234,
+				},
+				{
+					DestinationType:      api.UpstreamDestTypeService,
+					DestinationNamespace: "ns2",
+					DestinationName:      "upstream2",
+					Datacenter:           "dc2",
+					LocalBindPort:        2234,
+				},
+			},
+			configEntry: func() api.ConfigEntry {
+				ce, _ := api.MakeConfigEntry(api.ProxyDefaults, "pd")
+				pd := ce.(*api.ProxyConfigEntry)
+				pd.MeshGateway.Mode = api.MeshGatewayModeLocal
+				return pd
+			},
+			consulNamespacesEnabled: true,
+			consulPartitionsEnabled: true,
+		},
+		{
+			name: "upstream with datacenter both in brackets and after the port",
+			pod: func() *corev1.Pod {
+				pod1 := createPod("pod1", "1.2.3.4", true, true)
+				pod1.Annotations[annotationUpstreams] = "upstream1:1234:dc1[dc2]"
+				return pod1
+			},
+			expErr:                  "upstream \"upstream1:1234:dc1\" is invalid: the datacenter must be set either in brackets or after the port, not both",
+			consulNamespacesEnabled: false,
+			consulPartitionsEnabled: false,
+		},
+		{
+			name: "labeled upstream with datacenter both in brackets and as a label",
+			pod: func() *corev1.Pod {
+				pod1 := createPod("pod1", "1.2.3.4", true, true)
+				pod1.Annotations[annotationUpstreams] = "upstream1.svc.dc1.dc:1234[dc2]"
+				return pod1
+			},
+			expErr:                  "upstream \"upstream1.svc.dc1.dc:1234\" is invalid: the datacenter in brackets can't be combined with a datacenter or peer label",
+			consulNamespacesEnabled: false,
+			consulPartitionsEnabled: false,
+		},
+		{
+			name: "labeled upstream with datacenter in brackets and an invalid port",
+			pod: func() *corev1.Pod {
+				pod1 := createPod("pod1", "1.2.3.4", true, true)
+				pod1.Annotations[annotationUpstreams] = "upstream1.svc.ns1.ns:notaport[dc2]"
+				return pod1
+			},
+			expErr:                  "upstream \"upstream1.svc.ns1.ns:notaport\" is invalid: the datacenter in brackets requires a valid port",
+			consulNamespacesEnabled: true,
+			consulPartitionsEnabled: false,
+		},
+		{
+			name: "prepared query upstream with datacenter in brackets",
+			pod: func() *corev1.Pod {
+				pod1 := createPod("pod1", "1.2.3.4", true, true)
+				pod1.Annotations[annotationUpstreams] = "prepared_query:queryname:1234[dc1]"
+				return pod1
+			},
+			expErr:                  "upstream \"prepared_query:queryname:1234\" is invalid: a datacenter can't be set for prepared query upstreams",
+			consulNamespacesEnabled: false,
+			consulPartitionsEnabled: false,
+		},
 		{
 			name: "upstream with datacenter with ProxyDefaults and mesh gateway in remote mode",
 			pod: func() *corev1.Pod {
@@ -713,6 +1176,63 @@ func TestProcessUpstreams(t *testing.T) {
 			consulNamespacesEnabled: false,
 			consulPartitionsEnabled: false,
 		},
+		{
+			name: "prepared query upstream with namespace",
+			pod: func() *corev1.Pod {
+				pod1 := createPod("pod1", "1.2.3.4", true, true)
+				pod1.Annotations[annotationUpstreams] = "prepared_query:queryname.ns1:1234"
+				return pod1
+			},
+			expected: []api.Upstream{
+				{
+					DestinationType:      api.UpstreamDestTypePreparedQuery,
+					DestinationNamespace: "ns1",
+					DestinationName:      "queryname",
+					LocalBindPort:        1234,
+				},
+			},
+			consulNamespacesEnabled: true,
+			consulPartitionsEnabled: false,
+		},
+		{
+			name: "prepared query upstream with namespace when namespaces are disabled",
+			pod: func() *corev1.Pod {
+				pod1 := createPod("pod1", "1.2.3.4", true, true)
+				pod1.Annotations[annotationUpstreams] = "prepared_query:queryname.ns1:1234"
+				return pod1
+			},
+			expected: []api.Upstream{
+				{
+					DestinationType: api.UpstreamDestTypePreparedQuery,
+					DestinationName: "queryname.ns1",
+					LocalBindPort:   1234,
+				},
+			},
+			consulNamespacesEnabled: false,
+			consulPartitionsEnabled: false,
+		},
+		{
+			name: "prepared query upstream with invalid namespace",
+			pod: func() *corev1.Pod {
+				pod1 := createPod("pod1", "1.2.3.4", true, true)
+				pod1.Annotations[annotationUpstreams] = "prepared_query:queryname.ns_1:1234"
+				return pod1
+			},
+			expErr:                  "upstream \"prepared_query:queryname.ns_1:1234\" is invalid: prepared query namespace \"ns_1\" must be at most 64 alphanumeric characters or dashes and must start and end with an alphanumeric character",
+			consulNamespacesEnabled: true,
+			consulPartitionsEnabled: false,
+		},
+		{
+			name: "prepared query upstream without a port",
+			pod: func() *corev1.Pod {
+				pod1 := createPod("pod1", "1.2.3.4", true, true)
+				pod1.Annotations[annotationUpstreams] = "prepared_query:queryname"
+				return pod1
+			},
+			expErr:                  "upstream \"prepared_query:queryname\" is invalid: prepared query upstreams must be in the format prepared_query:[query name]:[port]",
+			consulNamespacesEnabled: false,
+			consulPartitionsEnabled: false,
+		},
 		{
 			name: "prepared query and non-query upstreams and annotated non-query upstreams",
 			pod: func() *corev1.Pod {
@@ -782,7 +1302,7 @@ func TestProcessUpstreams(t *testing.T) {
 				EnableConsulPartitions: tt.consulPartitionsEnabled,
 			}
 
-			upstreams, err := ep.processUpstreams(*tt.pod(), corev1.Endpoints{
+			upstreams, err := ep.processUpstreams(context.Background(), corev1.Namespace{}, *tt.pod(), corev1.Endpoints{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:        "svcname",
 					Namespace:   "default",
@@ -823,42 +1343,2822 @@ func TestGetServiceName(t *testing.T) {
 			},
 			expSvcName: "web",
 		},
-		{
-			name: "single port, without annotation",
-			pod: func() *corev1.Pod {
-				pod1 := createPod("pod1", "1.2.3.4", true, true)
-				return pod1
-			},
-			endpoint: &corev1.Endpoints{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "ep-name",
-					Namespace: "default",
-				},
-			},
-			expSvcName: "ep-name",
+		{
+			name: "single port, without annotation",
+			pod: func() *corev1.Pod {
+				pod1 := createPod("pod1", "1.2.3.4", true, true)
+				return pod1
+			},
+			endpoint: &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ep-name",
+					Namespace: "default",
+				},
+			},
+			expSvcName: "ep-name",
+		},
+		{
+			name: "multi port, with annotation",
+			pod: func() *corev1.Pod {
+				pod1 := createPod("pod1", "1.2.3.4", true, true)
+				pod1.Annotations[annotationService] = "web,web-admin"
+				return pod1
+			},
+			endpoint: &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ep-name-multiport",
+					Namespace: "default",
+				},
+			},
+			expSvcName: "ep-name-multiport",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+
+			svcName := getServiceName(*tt.pod(), *tt.endpoint)
+			require.Equal(t, tt.expSvcName, svcName)
+
+		})
+	}
+}
+
+func TestGetMultiPortIdx(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotation  string
+		serviceName string
+		expIdx      int
+	}{
+		"single port": {
+			annotation:  "web",
+			serviceName: "web",
+			expIdx:      0,
+		},
+		"first service of a multi port pod": {
+			annotation:  "web,web-admin",
+			serviceName: "web",
+			expIdx:      0,
+		},
+		"second service of a multi port pod": {
+			annotation:  "web,web-admin",
+			serviceName: "web-admin",
+			expIdx:      1,
+		},
+		"service not in the annotation": {
+			annotation:  "web,web-admin",
+			serviceName: "api",
+			expIdx:      -1,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			pod.Annotations[annotationService] = c.annotation
+			require.Equal(t, c.expIdx, getMultiPortIdx(*pod, c.serviceName))
+		})
+	}
+}
+
+// TestCreateServiceRegistrations_multiPortServiceNotListed tests that an error is returned instead of
+// a panic when the Consul service name of a multi port pod isn't listed in its service annotation.
+func TestCreateServiceRegistrations_multiPortServiceNotListed(t *testing.T) {
+	t.Parallel()
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	pod.Annotations[annotationService] = "web,web-admin"
+	pod.Annotations[annotationPort] = "8080,9090"
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "api",
+			Namespace: "default",
+		},
+	}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	epCtrl := EndpointsController{
+		Client: fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+		Log:    logrtest.TestLogger{T: t},
+	}
+
+	_, _, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+	require.EqualError(t, err, `service "api" has no port in the consul.hashicorp.com/connect-service-port annotation of multi port pod pod1: it must be listed in the consul.hashicorp.com/connect-service annotation "web,web-admin"`)
+}
+
+func TestConsulServiceName(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotations   map[string]string
+		endpointsName string
+		expName       string
+		expErr        string
+	}{
+		"defaults to the endpoints name": {
+			endpointsName: "web",
+			expName:       "web",
+		},
+		"endpoints name is normalized": {
+			endpointsName: "Web",
+			expName:       "web",
+		},
+		"annotation overrides the endpoints name": {
+			annotations:   map[string]string{annotationService: "Web-Override"},
+			endpointsName: "web",
+			expName:       "Web-Override",
+		},
+		"empty annotation uses the endpoints name": {
+			annotations:   map[string]string{annotationService: ""},
+			endpointsName: "web",
+			expName:       "web",
+		},
+		"multi port annotation uses the endpoints name": {
+			annotations:   map[string]string{annotationService: "web,web-admin"},
+			endpointsName: "web-admin",
+			expName:       "web-admin",
+		},
+		"annotation is used as is": {
+			annotations:   map[string]string{annotationService: "web_v2"},
+			endpointsName: "web",
+			expName:       "web_v2",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+			endpoints := corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: c.endpointsName, Namespace: "default"}}
+			epCtrl := EndpointsController{}
+
+			actual, err := epCtrl.consulServiceName(*pod, endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expName, actual)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_serviceIDAnnotation(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotations  map[string]string
+		otherService bool
+		expServiceID string
+		expProxyID   string
+		expErr       string
+	}{
+		"no annotation": {
+			annotations:  map[string]string{},
+			expServiceID: "pod1-web",
+			expProxyID:   "pod1-web-sidecar-proxy",
+		},
+		"no annotation on a pod behind two services": {
+			annotations:  map[string]string{},
+			otherService: true,
+			expServiceID: "pod1-web",
+			expProxyID:   "pod1-web-sidecar-proxy",
+		},
+		"annotation overrides the generated IDs": {
+			annotations:  map[string]string{annotationServiceID: "legacy-web-1"},
+			expServiceID: "legacy-web-1",
+			expProxyID:   "legacy-web-1-sidecar-proxy",
+		},
+		"empty annotation": {
+			annotations: map[string]string{annotationServiceID: ""},
+			expErr:      "consul.hashicorp.com/service-id annotation value \"\" is invalid: must be non-empty and must not contain spaces or slashes",
+		},
+		"annotation with a slash": {
+			annotations: map[string]string{annotationServiceID: "ns/web"},
+			expErr:      "consul.hashicorp.com/service-id annotation value \"ns/web\" is invalid: must be non-empty and must not contain spaces or slashes",
+		},
+		"annotation conflicting with proxy IDs": {
+			annotations: map[string]string{annotationServiceID: "web-sidecar-proxy"},
+			expErr:      "consul.hashicorp.com/service-id annotation value \"web-sidecar-proxy\" is invalid: must not end with \"-sidecar-proxy\" since it would conflict with proxy service IDs",
+		},
+		"annotation on a multi port pod": {
+			annotations: map[string]string{annotationServiceID: "legacy-web-1", annotationService: "web,web-admin"},
+			expErr:      "consul.hashicorp.com/service-id annotation is not supported for multi port pods",
+		},
+		"annotation on a pod behind two services": {
+			annotations:  map[string]string{annotationServiceID: "legacy-web-1"},
+			otherService: true,
+			expErr:       "consul.hashicorp.com/service-id annotation is not supported for pods that back more than one Kubernetes service: pod pod1 backs services web and web-admin",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+			subsets := []corev1.EndpointSubset{
+				{
+					Addresses: []corev1.EndpointAddress{
+						{
+							IP: "1.2.3.4",
+							TargetRef: &corev1.ObjectReference{
+								Kind:      "Pod",
+								Name:      "pod1",
+								Namespace: "default",
+							},
+						},
+					},
+				},
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+				Subsets: subsets,
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			objs := []runtime.Object{pod, endpoints, &ns}
+			pod.Labels["app"] = "web"
+			service := func(name string, selector map[string]string) *corev1.Service {
+				return &corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: "default",
+					},
+					Spec: corev1.ServiceSpec{Selector: selector},
+				}
+			}
+			objs = append(objs,
+				service("web", map[string]string{"app": "web"}),
+				// Services that don't select the pod don't conflict with the annotation.
+				service("api", map[string]string{"app": "api"}),
+				service("external", nil),
+			)
+			if c.otherService {
+				objs = append(objs, service("web-admin", map[string]string{"app": "web"}))
+			}
+			epCtrl := EndpointsController{
+				Client: fake.NewClientBuilder().WithRuntimeObjects(objs...).Build(),
+				Log:    logrtest.TestLogger{T: t},
+			}
+
+			serviceRegistration, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expServiceID, serviceRegistration.ID)
+			require.Equal(t, c.expProxyID, proxyServiceRegistration.ID)
+			require.Equal(t, c.expServiceID, proxyServiceRegistration.Proxy.DestinationServiceID)
+			require.Equal(t, c.expServiceID, proxyServiceRegistration.Checks[1].AliasService)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_kubeServiceNameAnnotation(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotations        map[string]string
+		expKubeServiceName string
+		expErr             string
+	}{
+		"no annotation": {
+			annotations:        map[string]string{},
+			expKubeServiceName: "web",
+		},
+		"annotation overrides the meta": {
+			annotations:        map[string]string{annotationKubeServiceName: "web-legacy"},
+			expKubeServiceName: "web-legacy",
+		},
+		"invalid annotation": {
+			annotations: map[string]string{annotationKubeServiceName: "Web_Legacy"},
+			expErr:      "consul.hashicorp.com/kube-service-name annotation value \"Web_Legacy\" is invalid: a DNS-1035 label must consist of lower case alphanumeric characters or '-', start with an alphabetic character, and end with an alphanumeric character (e.g. 'my-name',  or 'abc-123', regex used for validation is '[a-z]([-a-z0-9]*[a-z0-9])?')",
+		},
+		"annotation on a multi port pod": {
+			annotations: map[string]string{annotationKubeServiceName: "web-legacy", annotationService: "web,web-admin"},
+			expErr:      "consul.hashicorp.com/kube-service-name annotation is not supported for multi port pods",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client: fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:    logrtest.TestLogger{T: t},
+			}
+
+			serviceRegistration, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			// The Consul service name is still derived from the Endpoints object.
+			require.Equal(t, "web", serviceRegistration.Name)
+			require.Equal(t, c.expKubeServiceName, serviceRegistration.Meta[MetaKeyKubeServiceName])
+			require.Equal(t, c.expKubeServiceName, proxyServiceRegistration.Meta[MetaKeyKubeServiceName])
+		})
+	}
+}
+
+func TestProxyConfigHash(t *testing.T) {
+	t.Parallel()
+	newConfig := func() *api.AgentServiceConnectProxyConfig {
+		return &api.AgentServiceConnectProxyConfig{
+			DestinationServiceName: "web",
+			DestinationServiceID:   "pod1-web",
+			LocalServiceAddress:    "127.0.0.1",
+			LocalServicePort:       8080,
+			Config: map[string]interface{}{
+				envoyPrometheusBindAddr:    "0.0.0.0:20200",
+				envoyLocalRequestTimeoutMs: 1500,
+				"protocol":                 "http",
+			},
+		}
+	}
+
+	hash, err := proxyConfigHash(newConfig())
+	require.NoError(t, err)
+	require.Len(t, hash, 64)
+
+	// The same config yields the same hash regardless of the order the map was populated in.
+	reordered := newConfig()
+	reordered.Config = map[string]interface{}{}
+	reordered.Config["protocol"] = "http"
+	reordered.Config[envoyLocalRequestTimeoutMs] = 1500
+	reordered.Config[envoyPrometheusBindAddr] = "0.0.0.0:20200"
+	for i := 0; i < 10; i++ {
+		reorderedHash, err := proxyConfigHash(reordered)
+		require.NoError(t, err)
+		require.Equal(t, hash, reorderedHash)
+	}
+
+	changed := newConfig()
+	changed.Config["protocol"] = "grpc"
+	changedHash, err := proxyConfigHash(changed)
+	require.NoError(t, err)
+	require.NotEqual(t, hash, changedHash)
+
+	// The hash is only recorded on the proxy registration.
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: "default",
+		},
+	}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	epCtrl := EndpointsController{
+		Client: fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+		Log:    logrtest.TestLogger{T: t},
+	}
+	service, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+	require.NoError(t, err)
+	require.NotContains(t, service.Meta, MetaKeyProxyConfigHash)
+	expHash, err := proxyConfigHash(proxy.Proxy)
+	require.NoError(t, err)
+	require.Equal(t, expHash, proxy.Meta[MetaKeyProxyConfigHash])
+}
+
+// TestCreateServiceRegistrations_proxyConfigHashTransparentProxy tests that the proxy config hash
+// covers the changes made to the config after it is first built, e.g. the transparent proxy mode.
+func TestCreateServiceRegistrations_proxyConfigHashTransparentProxy(t *testing.T) {
+	t.Parallel()
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: "default",
+		},
+	}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: "default",
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: "10.0.0.1",
+			Ports:     []corev1.ServicePort{{Port: 8080}},
+		},
+	}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+
+	hashes := make(map[bool]string)
+	for _, tproxy := range []bool{false, true} {
+		epCtrl := EndpointsController{
+			Client:                 fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, service, &ns).Build(),
+			Log:                    logrtest.TestLogger{T: t},
+			EnableTransparentProxy: tproxy,
+		}
+		_, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+		require.NoError(t, err)
+		expHash, err := proxyConfigHash(proxy.Proxy)
+		require.NoError(t, err)
+		require.Equal(t, expHash, proxy.Meta[MetaKeyProxyConfigHash])
+		hashes[tproxy] = proxy.Meta[MetaKeyProxyConfigHash]
+	}
+	require.NotEqual(t, hashes[false], hashes[true])
+}
+
+func TestCreateServiceRegistrations_hostIPMeta(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		hostIP    string
+		expMeta   string
+		expExists bool
+	}{
+		"host IP is set": {
+			hostIP:    "10.1.2.3",
+			expMeta:   "10.1.2.3",
+			expExists: true,
+		},
+		"host IP is not set": {
+			hostIP:    "",
+			expExists: false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			pod.Status.HostIP = c.hostIP
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client: fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:    logrtest.TestLogger{T: t},
+			}
+
+			serviceRegistration, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			require.NoError(t, err)
+			for _, registration := range []*api.AgentServiceRegistration{serviceRegistration, proxyServiceRegistration} {
+				hostIP, ok := registration.Meta[MetaKeyHostIP]
+				require.Equal(t, c.expExists, ok)
+				require.Equal(t, c.expMeta, hostIP)
+			}
+		})
+	}
+}
+
+func TestNewServiceRegistrations(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotations      map[string]string
+		enableNamespaces bool
+		expServiceName   string
+		expNamespace     string
+		expProxy         bool
+		expNative        bool
+		ready            bool
+		expGateway       bool
+	}{
+		"sidecar proxy": {
+			ready:          true,
+			expServiceName: "web",
+			expProxy:       true,
+		},
+		"service name from annotation": {
+			annotations:    map[string]string{annotationService: "web-api"},
+			expServiceName: "web-api",
+			expProxy:       true,
+		},
+		"connect native": {
+			annotations:    map[string]string{annotationConnectServiceNative: "true"},
+			expServiceName: "web",
+			expNative:      true,
+		},
+		"service only": {
+			annotations:    map[string]string{annotationRegisterProxy: "false"},
+			expServiceName: "web",
+		},
+		"mesh gateway": {
+			annotations:    map[string]string{annotationGatewayKind: "mesh-gateway"},
+			expServiceName: "web",
+			expProxy:       true,
+			expGateway:     true,
+		},
+		"consul namespace": {
+			annotations:      map[string]string{annotationConsulNamespace: "team-a"},
+			enableNamespaces: true,
+			expServiceName:   "web",
+			expNamespace:     "team-a",
+			expProxy:         true,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:                 fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				EnableConsulNamespaces: c.enableNamespaces,
+				Log:                    logrtest.TestLogger{T: t},
+			}
+
+			registrations, err := epCtrl.newServiceRegistrations(context.Background(), *pod, *endpoints, c.ready)
+			require.NoError(t, err)
+			require.Equal(t, c.ready, registrations.ready)
+			require.NotNil(t, registrations.service)
+			require.Equal(t, c.expServiceName, registrations.serviceName)
+			require.Equal(t, c.expServiceName, registrations.service.Name)
+			require.Equal(t, c.expNamespace, registrations.namespace)
+			require.Equal(t, c.expProxy, registrations.proxy != nil)
+			require.Equal(t, c.expNative, registrations.native)
+			require.Equal(t, c.expGateway, registrations.gateway)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_zoneMeta(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		podLabels map[string]string
+		nodeName  string
+		node      *corev1.Node
+		expZone   string
+	}{
+		"zone label on the pod": {
+			podLabels: map[string]string{labelTopologyZone: "us-east-1a"},
+			expZone:   "us-east-1a",
+		},
+		"zone label on the node": {
+			nodeName: "node1",
+			node: &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{labelTopologyZone: "us-east-1b"}},
+			},
+			expZone: "us-east-1b",
+		},
+		"zone label on the pod takes precedence over the node": {
+			podLabels: map[string]string{labelTopologyZone: "us-east-1a"},
+			nodeName:  "node1",
+			node: &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{labelTopologyZone: "us-east-1b"}},
+			},
+			expZone: "us-east-1a",
+		},
+		"node without a zone label": {
+			nodeName: "node1",
+			node:     &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
+		},
+		"node does not exist": {
+			nodeName: "node1",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			for k, v := range c.podLabels {
+				pod.Labels[k] = v
+			}
+			pod.Spec.NodeName = c.nodeName
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			k8sObjects := []runtime.Object{pod, endpoints, &ns}
+			if c.node != nil {
+				k8sObjects = append(k8sObjects, c.node)
+			}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(k8sObjects...).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			serviceRegistration, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			require.NoError(t, err)
+			require.Equal(t, c.expZone, serviceRegistration.Meta[MetaKeyZone])
+			require.Equal(t, c.expZone, proxyServiceRegistration.Meta[MetaKeyZone])
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_connectNative(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotation string
+		expNative  bool
+		expErr     string
+	}{
+		"annotation not set": {},
+		"annotation is false": {
+			annotation: "false",
+		},
+		"annotation is true": {
+			annotation: "true",
+			expNative:  true,
+		},
+		"annotation is invalid": {
+			annotation: "not-a-bool",
+			expErr:     `strconv.ParseBool: parsing "not-a-bool": invalid syntax`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			if c.annotation != "" {
+				pod.Annotations[annotationConnectServiceNative] = c.annotation
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			serviceRegistration, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "pod1-web", serviceRegistration.ID)
+			if c.expNative {
+				require.Equal(t, &api.AgentServiceConnect{Native: true}, serviceRegistration.Connect)
+				require.Nil(t, proxyServiceRegistration)
+			} else {
+				require.Nil(t, serviceRegistration.Connect)
+				require.NotNil(t, proxyServiceRegistration)
+				require.Equal(t, "pod1-web-sidecar-proxy", proxyServiceRegistration.ID)
+			}
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_partition(t *testing.T) {
+	t.Parallel()
+	cases := map[string]string{
+		"partitions disabled": "",
+		"partition set":       "foo",
+	}
+	for name, partition := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:          fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:             logrtest.TestLogger{T: t},
+				Context:         context.Background(),
+				ConsulPartition: partition,
+			}
+
+			serviceRegistration, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			require.NoError(t, err)
+			require.Equal(t, partition, serviceRegistration.Partition)
+			require.Equal(t, partition, proxyServiceRegistration.Partition)
+		})
+	}
+}
+
+func TestShouldRegisterProxy(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotation string
+		phase      corev1.PodPhase
+		ready      bool
+		exp        bool
+		expErr     string
+	}{
+		"ready, annotation not set": {
+			phase: corev1.PodRunning,
+			ready: true,
+			exp:   true,
+		},
+		"ready, annotation false": {
+			annotation: "false",
+			phase:      corev1.PodRunning,
+			ready:      true,
+			exp:        true,
+		},
+		"not ready, annotation not set": {
+			phase: corev1.PodRunning,
+			exp:   true,
+		},
+		"not ready, annotation true": {
+			annotation: "true",
+			phase:      corev1.PodRunning,
+			exp:        true,
+		},
+		"not ready, annotation false": {
+			annotation: "false",
+			phase:      corev1.PodRunning,
+			exp:        false,
+		},
+		"not ready and pending, annotation false": {
+			annotation: "false",
+			phase:      corev1.PodPending,
+			exp:        true,
+		},
+		"not ready, annotation invalid": {
+			annotation: "not-a-bool",
+			phase:      corev1.PodRunning,
+			expErr:     `strconv.ParseBool: parsing "not-a-bool": invalid syntax`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			pod.Status.Phase = c.phase
+			if c.annotation != "" {
+				pod.Annotations[annotationRegisterProxyWhenNotReady] = c.annotation
+			}
+
+			actual, err := shouldRegisterProxy(*pod, c.ready)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, actual)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_meshGatewayMode(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotation string
+		expConfig  api.MeshGatewayConfig
+		expErr     string
+	}{
+		"annotation not set": {},
+		"local": {
+			annotation: "local",
+			expConfig:  api.MeshGatewayConfig{Mode: api.MeshGatewayModeLocal},
+		},
+		"remote": {
+			annotation: "remote",
+			expConfig:  api.MeshGatewayConfig{Mode: api.MeshGatewayModeRemote},
+		},
+		"none": {
+			annotation: "none",
+			expConfig:  api.MeshGatewayConfig{Mode: api.MeshGatewayModeNone},
+		},
+		"invalid": {
+			annotation: "nearby",
+			expErr:     `consul.hashicorp.com/mesh-gateway-mode annotation value "nearby" is invalid: must be one of "local", "remote", or "none"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			if c.annotation != "" {
+				pod.Annotations[annotationMeshGatewayMode] = c.annotation
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			_, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expConfig, proxyServiceRegistration.Proxy.MeshGateway)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_serviceName(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		endpointsName string
+		annotation    string
+		normalize     func(string) string
+		expName       string
+		expErr        string
+	}{
+		"name is lowercased": {
+			endpointsName: "Web",
+			expName:       "web",
+		},
+		"invalid name derived from Kubernetes service": {
+			endpointsName: "web.v2",
+			expErr:        `service name "web.v2" derived from Kubernetes service "web.v2" is not a valid Consul service name: must contain only alphanumeric characters or dashes and must start and end with an alphanumeric character; set the consul.hashicorp.com/connect-service annotation to provide a valid name`,
+		},
+		"annotation provides a valid name": {
+			endpointsName: "web.v2",
+			annotation:    "Web-V2",
+			expName:       "Web-V2",
+		},
+		"name from annotation is used as is": {
+			endpointsName: "web",
+			annotation:    "web_v2",
+			expName:       "web_v2",
+		},
+		"custom normalization": {
+			endpointsName: "web.v2",
+			normalize: func(name string) string {
+				return strings.ReplaceAll(name, ".", "-")
+			},
+			expName: "web-v2",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			if c.annotation != "" {
+				pod.Annotations[annotationService] = c.annotation
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      c.endpointsName,
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:               fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:                  logrtest.TestLogger{T: t},
+				Context:              context.Background(),
+				NormalizeServiceName: c.normalize,
+			}
+
+			serviceRegistration, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expName, serviceRegistration.Name)
+			require.Equal(t, "pod1-"+c.expName, serviceRegistration.ID)
+			require.Equal(t, c.expName+"-sidecar-proxy", proxyServiceRegistration.Name)
+			require.Equal(t, "pod1-"+c.expName+"-sidecar-proxy", proxyServiceRegistration.ID)
+			require.Equal(t, c.expName, proxyServiceRegistration.Proxy.DestinationServiceName)
+			require.Equal(t, "pod1-"+c.expName, proxyServiceRegistration.Proxy.DestinationServiceID)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_deregisterCriticalServiceAfter(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotation string
+		expErr     string
+	}{
+		"valid duration": {
+			annotation: "30m",
+		},
+		"invalid duration": {
+			annotation: "thirty minutes",
+			expErr:     `consul.hashicorp.com/deregister-critical-service-after annotation value "thirty minutes" is invalid: time: invalid duration "thirty minutes"`,
+		},
+		"negative duration": {
+			annotation: "-1m",
+			expErr:     `consul.hashicorp.com/deregister-critical-service-after annotation value "-1m" is invalid: must be greater than zero`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			annotatedPod := createPod("pod1", "1.2.3.4", true, true)
+			annotatedPod.Annotations[annotationDeregisterCriticalServiceAfter] = c.annotation
+			otherPod := createPod("pod2", "2.2.3.4", true, true)
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(annotatedPod, otherPod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			_, annotatedProxy, err := epCtrl.createServiceRegistrations(context.Background(), *annotatedPod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.annotation, annotatedProxy.Checks[0].DeregisterCriticalServiceAfter)
+			require.Empty(t, annotatedProxy.Checks[1].DeregisterCriticalServiceAfter)
+
+			// The override only applies to the annotated pod's proxy.
+			_, otherProxy, err := epCtrl.createServiceRegistrations(context.Background(), *otherPod, *endpoints)
+			require.NoError(t, err)
+			require.Equal(t, "10m", otherProxy.Checks[0].DeregisterCriticalServiceAfter)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_initialHealth(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotation string
+		expStatus  string
+		expErr     string
+	}{
+		"not set": {
+			annotation: "",
+			expStatus:  "",
+		},
+		"critical": {
+			annotation: "critical",
+			expStatus:  api.HealthCritical,
+		},
+		"passing": {
+			annotation: "passing",
+			expStatus:  api.HealthPassing,
+		},
+		"warning": {
+			annotation: "warning",
+			expStatus:  api.HealthWarning,
+		},
+		"invalid": {
+			annotation: "unhealthy",
+			expErr:     `consul.hashicorp.com/initial-health annotation value "unhealthy" is invalid: must be one of passing, warning, critical`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			if c.annotation != "" {
+				pod.Annotations[annotationInitialHealth] = c.annotation
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			_, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expStatus, proxy.Checks[0].Status)
+			// Only the public listener check is affected.
+			require.Empty(t, proxy.Checks[1].Status)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_localRequestTimeout(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotation string
+		expTimeout interface{}
+		expErr     string
+	}{
+		"not set": {
+			annotation: "",
+			expTimeout: nil,
+		},
+		"seconds": {
+			annotation: "30s",
+			expTimeout: int64(30000),
+		},
+		"minutes and milliseconds": {
+			annotation: "1m500ms",
+			expTimeout: int64(60500),
+		},
+		"disabled": {
+			annotation: "0s",
+			expTimeout: int64(0),
+		},
+		"invalid duration": {
+			annotation: "thirty seconds",
+			expErr:     `consul.hashicorp.com/connect-service-local-request-timeout annotation value "thirty seconds" is invalid: time: invalid duration "thirty seconds"`,
+		},
+		"negative duration": {
+			annotation: "-1s",
+			expErr:     `consul.hashicorp.com/connect-service-local-request-timeout annotation value "-1s" is invalid: must not be negative`,
+		},
+		"fractional milliseconds": {
+			annotation: "1500us",
+			expErr:     `consul.hashicorp.com/connect-service-local-request-timeout annotation value "1500us" is invalid: must be a whole number of milliseconds`,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			pod.Annotations[annotationLocalRequestTimeout] = c.annotation
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			_, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			timeout, ok := proxy.Proxy.Config[envoyLocalRequestTimeoutMs]
+			require.Equal(t, c.expTimeout != nil, ok)
+			require.Equal(t, c.expTimeout, timeout)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_socketPath(t *testing.T) {
+	t.Parallel()
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	pod.Annotations[annotationServiceSocketPath] = "/var/run/web.sock"
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: "default",
+		},
+	}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	epCtrl := EndpointsController{
+		Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+		Log:     logrtest.TestLogger{T: t},
+		Context: context.Background(),
+	}
+
+	service, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+	require.NoError(t, err)
+	require.Equal(t, "/var/run/web.sock", service.SocketPath)
+	require.Zero(t, service.Port)
+	require.Equal(t, "/var/run/web.sock", proxy.Proxy.LocalServiceSocketPath)
+	require.Empty(t, proxy.Proxy.LocalServiceAddress)
+	require.Zero(t, proxy.Proxy.LocalServicePort)
+
+	// The socket path may not be used with a port.
+	pod.Annotations[annotationPort] = "8080"
+	_, _, err = epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+	require.EqualError(t, err, "consul.hashicorp.com/service-socket-path and consul.hashicorp.com/connect-service-port annotations may not be used together")
+}
+
+func TestCreateServiceRegistrations_proxyAddress(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotation string
+		expAddress string
+		expErr     string
+	}{
+		"no annotation": {
+			expAddress: "1.2.3.4",
+		},
+		"IPv4 address": {
+			annotation: "10.0.0.5",
+			expAddress: "10.0.0.5",
+		},
+		"invalid address": {
+			annotation: "not-an-ip",
+			expErr:     `consul.hashicorp.com/proxy-address annotation value "not-an-ip" is invalid: must be an IP address`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			if c.annotation != "" {
+				pod.Annotations[annotationProxyAddress] = c.annotation
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			service, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expAddress, proxy.Address)
+			require.Equal(t, c.expAddress+":20000", proxy.Checks[0].TCP)
+			// The service itself is always registered with the pod IP.
+			require.Equal(t, "1.2.3.4", service.Address)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_serviceCheckNotes(t *testing.T) {
+	t.Parallel()
+	annotatedPod := createPod("pod1", "1.2.3.4", true, true)
+	annotatedPod.Annotations[annotationServiceCheckNotes] = "Owned by the payments team, see the runbook."
+	otherPod := createPod("pod2", "2.2.3.4", true, true)
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: "default",
+		},
+	}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	epCtrl := EndpointsController{
+		Client:  fake.NewClientBuilder().WithRuntimeObjects(annotatedPod, otherPod, endpoints, &ns).Build(),
+		Log:     logrtest.TestLogger{T: t},
+		Context: context.Background(),
+	}
+
+	_, annotatedProxy, err := epCtrl.createServiceRegistrations(context.Background(), *annotatedPod, *endpoints)
+	require.NoError(t, err)
+	require.Equal(t, "Owned by the payments team, see the runbook.", annotatedProxy.Checks[0].Notes)
+	require.Empty(t, annotatedProxy.Checks[1].Notes)
+
+	_, otherProxy, err := epCtrl.createServiceRegistrations(context.Background(), *otherPod, *endpoints)
+	require.NoError(t, err)
+	require.Empty(t, otherProxy.Checks[0].Notes)
+}
+
+// TestCreateServiceRegistrations_proxyCheckPort tests that the proxy's public listener check targets the port the
+// proxy is registered with rather than the default inbound port.
+func TestCreateServiceRegistrations_proxyCheckPort(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		endpointsName string
+		expPort       int
+	}{
+		"first service": {
+			endpointsName: "web",
+			expPort:       20000,
+		},
+		"second service": {
+			endpointsName: "web-admin",
+			expPort:       20001,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			pod.Annotations[annotationService] = "web,web-admin"
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      c.endpointsName,
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			_, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			require.NoError(t, err)
+			require.Equal(t, c.expPort, proxy.Port)
+			require.Equal(t, fmt.Sprintf("1.2.3.4:%d", c.expPort), proxy.Checks[0].TCP)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_envoyReadyCheck(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotations   map[string]string
+		endpointsName string
+		expHTTP       string
+		expErr        string
+	}{
+		"no annotation": {
+			annotations:   map[string]string{},
+			endpointsName: "web",
+		},
+		"disabled": {
+			annotations:   map[string]string{annotationEnableEnvoyReadyCheck: "false"},
+			endpointsName: "web",
+		},
+		"enabled": {
+			annotations:   map[string]string{annotationEnableEnvoyReadyCheck: "true"},
+			endpointsName: "web",
+			expHTTP:       "http://1.2.3.4:19000/ready",
+		},
+		"enabled, second service of a multi port pod": {
+			annotations:   map[string]string{annotationEnableEnvoyReadyCheck: "true", annotationService: "web,web-admin"},
+			endpointsName: "web-admin",
+			expHTTP:       "http://1.2.3.4:19001/ready",
+		},
+		"invalid": {
+			annotations:   map[string]string{annotationEnableEnvoyReadyCheck: "yes please"},
+			endpointsName: "web",
+			expErr:        "consul.hashicorp.com/enable-envoy-ready-check annotation value \"yes please\" is invalid: must be a boolean",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      c.endpointsName,
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			_, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			if c.expHTTP == "" {
+				require.Len(t, proxy.Checks, 2)
+				return
+			}
+			require.Len(t, proxy.Checks, 3)
+			check := proxy.Checks[2]
+			require.Equal(t, "Proxy Ready", check.Name)
+			require.Equal(t, c.expHTTP, check.HTTP)
+			require.Equal(t, "10s", check.Interval)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_protocolAnnotation(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotations map[string]string
+		expProtocol interface{}
+		expErr      string
+	}{
+		"no annotation": {
+			annotations: map[string]string{},
+		},
+		"http2": {
+			annotations: map[string]string{annotationProtocol: "http2"},
+			expProtocol: "http2",
+		},
+		"tcp": {
+			annotations: map[string]string{annotationProtocol: "tcp"},
+			expProtocol: "tcp",
+		},
+		"invalid": {
+			annotations: map[string]string{annotationProtocol: "HTTP"},
+			expErr:      `consul.hashicorp.com/connect-service-protocol annotation value "HTTP" is invalid: must be one of "http", "http2", "grpc", or "tcp"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			_, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expProtocol, proxy.Proxy.Config[envoyProtocol])
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_checkThresholds(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotations               map[string]string
+		expSuccessBeforePassing   int
+		expFailuresBeforeCritical int
+		expErr                    string
+	}{
+		"no annotations": {
+			annotations: map[string]string{},
+		},
+		"both thresholds": {
+			annotations: map[string]string{
+				annotationCheckSuccessBeforePassing:   "2",
+				annotationCheckFailuresBeforeCritical: "3",
+			},
+			expSuccessBeforePassing:   2,
+			expFailuresBeforeCritical: 3,
+		},
+		"only failures before critical": {
+			annotations:               map[string]string{annotationCheckFailuresBeforeCritical: "5"},
+			expFailuresBeforeCritical: 5,
+		},
+		"negative threshold": {
+			annotations: map[string]string{annotationCheckSuccessBeforePassing: "-1"},
+			expErr:      `consul.hashicorp.com/check-success-before-passing annotation value "-1" is invalid: must be a non-negative integer`,
+		},
+		"non-integer threshold": {
+			annotations: map[string]string{annotationCheckFailuresBeforeCritical: "three"},
+			expErr:      `consul.hashicorp.com/check-failures-before-critical annotation value "three" is invalid: must be a non-negative integer`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			_, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expSuccessBeforePassing, proxy.Checks[0].SuccessBeforePassing)
+			require.Equal(t, c.expFailuresBeforeCritical, proxy.Checks[0].FailuresBeforeCritical)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_tagInterpolation(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		tags    string
+		expTags []string
+	}{
+		"pod label": {
+			tags:    "version=$(POD_LABEL_version),abc",
+			expTags: []string{"version=v2", "abc"},
+		},
+		"pod fields": {
+			tags:    "$(POD_NAME).$(POD_NAMESPACE),ip-$(POD_IP),node-$(NODE_NAME)",
+			expTags: []string{"pod1.default", "ip-1.2.3.4", "node-node-a"},
+		},
+		"label key with a prefix": {
+			tags:    "app=$(POD_LABEL_app.kubernetes.io/name)",
+			expTags: []string{"app=web"},
+		},
+		"escaped dollar": {
+			tags:    "cost=$$5,$$(POD_NAME)",
+			expTags: []string{"cost=$5", "$(POD_NAME)"},
+		},
+		"dollar without a token": {
+			tags:    "$POD_NAME,$FOO,price$",
+			expTags: []string{"pod1", "$FOO", "price$"},
+		},
+		"missing label": {
+			tags:    "team=$(POD_LABEL_team),version=$(POD_LABEL_version)",
+			expTags: []string{"team=$(POD_LABEL_team)", "version=v2"},
+		},
+		"unknown token": {
+			tags:    "$(POD_UID),$(HOSTNAME)-$(POD_NAME)",
+			expTags: []string{"$(POD_UID)", "$(HOSTNAME)-pod1"},
+		},
+		"unterminated token": {
+			tags:    "version=$(POD_LABEL_version",
+			expTags: []string{"version=$(POD_LABEL_version"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			pod.Spec.NodeName = "node-a"
+			pod.Labels = map[string]string{
+				"version":                "v2",
+				"app.kubernetes.io/name": "web",
+			}
+			pod.Annotations[annotationTags] = c.tags
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			service, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			require.NoError(t, err)
+			require.Equal(t, c.expTags, service.Tags)
+			require.Equal(t, c.expTags, proxy.Tags)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_proxyTags(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		tags           string
+		proxyTags      string
+		expServiceTags []string
+		expProxyTags   []string
+	}{
+		"no proxy tags": {
+			tags:           "abc,123",
+			expServiceTags: []string{"abc", "123"},
+			expProxyTags:   []string{"abc", "123"},
+		},
+		"proxy tags only": {
+			proxyTags:    "gateway-route",
+			expProxyTags: []string{"gateway-route"},
+		},
+		"proxy tags merged with common tags": {
+			tags:           "abc,123",
+			proxyTags:      "gateway-route,pod=$(POD_NAME)",
+			expServiceTags: []string{"abc", "123"},
+			expProxyTags:   []string{"abc", "123", "gateway-route", "pod=pod1"},
+		},
+		"unterminated proxy tag": {
+			proxyTags:    "version=$(POD_LABEL_version",
+			expProxyTags: []string{"version=$(POD_LABEL_version"},
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			pod.Annotations[annotationTags] = c.tags
+			pod.Annotations[annotationProxyTags] = c.proxyTags
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			service, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			require.NoError(t, err)
+			require.Equal(t, c.expServiceTags, service.Tags)
+			require.Equal(t, c.expProxyTags, proxy.Tags)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_copyAllLabelsToMeta(t *testing.T) {
+	t.Parallel()
+	longKey := "example.com/" + strings.Repeat("a", 130)
+	cases := map[string]struct {
+		copyAllLabelsToMeta bool
+		expMeta             map[string]string
+	}{
+		"disabled": {
+			copyAllLabelsToMeta: false,
+			expMeta:             map[string]string{},
+		},
+		"enabled": {
+			copyAllLabelsToMeta: true,
+			expMeta: map[string]string{
+				"k8s-label-app":                    "web",
+				"k8s-label-app_kubernetes_io_name": "web",
+				"k8s-label-team":                   "payments",
+				("k8s-label-example_com_" + strings.Repeat("a", 130))[:128]: "long",
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			pod.Labels = map[string]string{
+				"app":                    "web",
+				"app.kubernetes.io/name": "web",
+				"team":                   "payments",
+				longKey:                  "long",
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:              fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:                 logrtest.TestLogger{T: t},
+				Context:             context.Background(),
+				CopyAllLabelsToMeta: c.copyAllLabelsToMeta,
+			}
+
+			service, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			require.NoError(t, err)
+
+			for _, meta := range []map[string]string{service.Meta, proxy.Meta} {
+				labelMeta := make(map[string]string)
+				for k, v := range meta {
+					if strings.HasPrefix(k, MetaKeyLabelPrefix) {
+						labelMeta[k] = v
+					}
+				}
+				require.Equal(t, c.expMeta, labelMeta)
+				// Labels don't override the built-in meta.
+				require.Equal(t, "pod1", meta[MetaKeyPodName])
+				require.Equal(t, managedByValue, meta[MetaKeyManagedBy])
+			}
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_serviceChecks(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotation string
+		expChecks  api.AgentServiceChecks
+		expErr     string
+	}{
+		"no annotation": {},
+		"TTL and HTTP checks": {
+			annotation: `[{"Name": "ttl", "TTL": "30s"}, {"CheckID": "http", "HTTP": "http://localhost:8080/health", "Interval": "10s", "Timeout": "1s"}]`,
+			expChecks: api.AgentServiceChecks{
+				{Name: "ttl", TTL: "30s"},
+				{CheckID: "http", HTTP: "http://localhost:8080/health", Interval: "10s", Timeout: "1s"},
+			},
+		},
+		"script check": {
+			annotation: `[{"Name": "script", "ScriptArgs": ["/bin/check"], "Interval": "10s"}]`,
+			expChecks: api.AgentServiceChecks{
+				{Name: "script", Args: []string{"/bin/check"}, Interval: "10s"},
+			},
+		},
+		"malformed JSON": {
+			annotation: `[{"Name": "ttl", "TTL": "30s"`,
+			expErr:     "consul.hashicorp.com/service-checks annotation value is invalid: must be a JSON array of checks: unexpected EOF",
+		},
+		"not an array": {
+			annotation: `{"Name": "ttl", "TTL": "30s"}`,
+			expErr:     "consul.hashicorp.com/service-checks annotation value is invalid: must be a JSON array of checks: json: cannot unmarshal object into Go value of type api.AgentServiceChecks",
+		},
+		"unknown field": {
+			annotation: `[{"Name": "ttl", "TTL": "30s", "Bogus": true}]`,
+			expErr:     `consul.hashicorp.com/service-checks annotation value is invalid: must be a JSON array of checks: json: unknown field "Bogus"`,
+		},
+		"null check": {
+			annotation: `[null]`,
+			expErr:     "consul.hashicorp.com/service-checks annotation value is invalid: check 0 must be a JSON object",
+		},
+		"check without a type": {
+			annotation: `[{"Name": "ttl", "TTL": "30s"}, {"Name": "nothing"}]`,
+			expErr:     "consul.hashicorp.com/service-checks annotation value is invalid: check 1 must set one of ScriptArgs, DockerContainerID, TTL, HTTP, TCP, UDP, GRPC, H2PING, AliasNode or AliasService",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			if c.annotation != "" {
+				pod.Annotations[annotationServiceChecks] = c.annotation
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			service, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expChecks, service.Checks)
+			// The built-in proxy checks are still registered.
+			require.Len(t, proxy.Checks, 2)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_exposePaths(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotation string
+		expPaths   []api.ExposePath
+		expErr     string
+	}{
+		"no annotation": {},
+		"health and metrics paths": {
+			annotation: `[{"path": "/health", "local_port": 8080, "listener_port": 21500}, {"path": "/metrics", "local_port": 9090, "listener_port": 21501, "protocol": "http2"}]`,
+			expPaths: []api.ExposePath{
+				{Path: "/health", LocalPathPort: 8080, ListenerPort: 21500},
+				{Path: "/metrics", LocalPathPort: 9090, ListenerPort: 21501, Protocol: "http2"},
+			},
+		},
+		"malformed JSON": {
+			annotation: `[{"path": "/health"`,
+			expErr:     "consul.hashicorp.com/connect-service-expose-paths annotation value is invalid: must be a JSON array of expose paths: unexpected EOF",
+		},
+		"unknown field": {
+			annotation: `[{"path": "/health", "local_port": 8080, "listener_port": 21500, "port": 80}]`,
+			expErr:     `consul.hashicorp.com/connect-service-expose-paths annotation value is invalid: must be a JSON array of expose paths: json: unknown field "port"`,
+		},
+		"relative path": {
+			annotation: `[{"path": "health", "local_port": 8080, "listener_port": 21500}]`,
+			expErr:     "consul.hashicorp.com/connect-service-expose-paths annotation value is invalid: expose path 0 must have a path starting with /",
+		},
+		"missing local port": {
+			annotation: `[{"path": "/health", "local_port": 8080, "listener_port": 21500}, {"path": "/metrics", "listener_port": 21501}]`,
+			expErr:     "consul.hashicorp.com/connect-service-expose-paths annotation value is invalid: expose path 1 must have a local_port between 1 and 65535",
+		},
+		"invalid listener port": {
+			annotation: `[{"path": "/health", "local_port": 8080, "listener_port": 70000}]`,
+			expErr:     "consul.hashicorp.com/connect-service-expose-paths annotation value is invalid: expose path 0 must have a listener_port between 1 and 65535",
+		},
+		"invalid protocol": {
+			annotation: `[{"path": "/health", "local_port": 8080, "listener_port": 21500, "protocol": "tcp"}]`,
+			expErr:     "consul.hashicorp.com/connect-service-expose-paths annotation value is invalid: expose path 0 must have a protocol of http or http2",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			if c.annotation != "" {
+				pod.Annotations[annotationExposePaths] = c.annotation
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			_, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expPaths, proxy.Proxy.Expose.Paths)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_serviceCheckFile(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotations map[string]string
+		expCheck    *api.AgentServiceCheck
+		expErr      string
+	}{
+		"no annotation": {
+			annotations: map[string]string{annotationServiceCheckFileTTL: "1m"},
+		},
+		"default TTL": {
+			annotations: map[string]string{annotationServiceCheckFile: "/tmp/ready"},
+			expCheck: &api.AgentServiceCheck{
+				CheckID: "pod1-web/check-file",
+				Name:    "Readiness File",
+				Notes:   "Must be marked as passing at least every 30s while /tmp/ready exists.",
+				TTL:     "30s",
+			},
+		},
+		"TTL from annotation": {
+			annotations: map[string]string{
+				annotationServiceCheckFile:    "/tmp/ready",
+				annotationServiceCheckFileTTL: "1m",
+			},
+			expCheck: &api.AgentServiceCheck{
+				CheckID: "pod1-web/check-file",
+				Name:    "Readiness File",
+				Notes:   "Must be marked as passing at least every 1m while /tmp/ready exists.",
+				TTL:     "1m",
+			},
+		},
+		"invalid TTL": {
+			annotations: map[string]string{
+				annotationServiceCheckFile:    "/tmp/ready",
+				annotationServiceCheckFileTTL: "soon",
+			},
+			expErr: `consul.hashicorp.com/service-check-file-ttl annotation value "soon" is invalid: time: invalid duration "soon"`,
+		},
+		"zero TTL": {
+			annotations: map[string]string{
+				annotationServiceCheckFile:    "/tmp/ready",
+				annotationServiceCheckFileTTL: "0s",
+			},
+			expErr: `consul.hashicorp.com/service-check-file-ttl annotation value "0s" is invalid: must be greater than zero`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			service, _, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			if c.expCheck == nil {
+				require.Empty(t, service.Checks)
+				return
+			}
+			require.Equal(t, api.AgentServiceChecks{c.expCheck}, service.Checks)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_autoSyncReadiness(t *testing.T) {
+	t.Parallel()
+	httpProbe := &corev1.Probe{
+		Handler: corev1.Handler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path: "/ready",
+				Port: intstr.FromString("http"),
+				HTTPHeaders: []corev1.HTTPHeader{
+					{Name: "X-Probe", Value: "consul"},
+				},
+			},
+		},
+		PeriodSeconds:  5,
+		TimeoutSeconds: 2,
+	}
+	appContainer := corev1.Container{
+		Name:           "web",
+		Ports:          []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+		ReadinessProbe: httpProbe,
+	}
+	envoyContainer := corev1.Container{
+		Name: envoySidecarContainer,
+		ReadinessProbe: &corev1.Probe{
+			Handler: corev1.Handler{
+				HTTPGet: &corev1.HTTPGetAction{Path: "/envoy", Port: intstr.FromInt(21000)},
+			},
+		},
+	}
+	cases := map[string]struct {
+		annotation string
+		containers []corev1.Container
+		expCheck   *api.AgentServiceCheck
+		expErr     string
+	}{
+		"no annotation": {
+			containers: []corev1.Container{appContainer},
+		},
+		"annotation false": {
+			annotation: "false",
+			containers: []corev1.Container{appContainer},
+		},
+		"httpGet readiness probe": {
+			annotation: "true",
+			containers: []corev1.Container{envoyContainer, appContainer},
+			expCheck: &api.AgentServiceCheck{
+				CheckID:  "pod1-web/readiness-probe",
+				Name:     "Kubernetes Readiness Probe",
+				Notes:    "Derived from the readiness probe of container web.",
+				HTTP:     "http://1.2.3.4:8080/ready",
+				Header:   map[string][]string{"X-Probe": {"consul"}},
+				Interval: "5s",
+				Timeout:  "2s",
+			},
+		},
+		"HTTPS probe with host and default timings": {
+			annotation: "true",
+			containers: []corev1.Container{
+				{
+					Name: "web",
+					ReadinessProbe: &corev1.Probe{
+						Handler: corev1.Handler{
+							HTTPGet: &corev1.HTTPGetAction{
+								Host:   "10.0.0.1",
+								Path:   "healthz",
+								Port:   intstr.FromInt(8443),
+								Scheme: corev1.URISchemeHTTPS,
+							},
+						},
+					},
+				},
+			},
+			expCheck: &api.AgentServiceCheck{
+				CheckID:       "pod1-web/readiness-probe",
+				Name:          "Kubernetes Readiness Probe",
+				Notes:         "Derived from the readiness probe of container web.",
+				HTTP:          "https://10.0.0.1:8443/healthz",
+				Interval:      "10s",
+				Timeout:       "1s",
+				TLSSkipVerify: true,
+			},
+		},
+		"exec readiness probe": {
+			annotation: "true",
+			containers: []corev1.Container{
+				{
+					Name: "web",
+					ReadinessProbe: &corev1.Probe{
+						Handler: corev1.Handler{
+							Exec: &corev1.ExecAction{Command: []string{"/bin/ready"}},
+						},
+					},
+				},
+			},
+		},
+		"invalid annotation": {
+			annotation: "yes please",
+			containers: []corev1.Container{appContainer},
+			expErr:     `consul.hashicorp.com/auto-sync-readiness annotation value "yes please" is invalid: must be a boolean`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			pod.Spec.Containers = c.containers
+			if c.annotation != "" {
+				pod.Annotations[annotationAutoSyncReadiness] = c.annotation
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			service, _, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			if c.expCheck == nil {
+				require.Empty(t, service.Checks)
+				return
+			}
+			require.Equal(t, api.AgentServiceChecks{c.expCheck}, service.Checks)
+		})
+	}
+}
+
+// TestCreateServiceRegistrations_registerProxy tests that only the service is registered when the register proxy
+// annotation is set to false.
+func TestCreateServiceRegistrations_registerProxy(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotation string
+		expProxy   bool
+		expErr     string
+	}{
+		"no annotation": {
+			expProxy: true,
+		},
+		"true": {
+			annotation: "true",
+			expProxy:   true,
+		},
+		"false": {
+			annotation: "false",
+			expProxy:   false,
+		},
+		"invalid": {
+			annotation: "nope",
+			expErr:     `consul.hashicorp.com/register-proxy annotation value "nope" is invalid: must be a boolean`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			if c.annotation != "" {
+				pod.Annotations[annotationRegisterProxy] = c.annotation
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			service, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "pod1-web", service.ID)
+			if c.expProxy {
+				require.NotNil(t, proxy)
+				require.Equal(t, "pod1-web-sidecar-proxy", proxy.ID)
+			} else {
+				require.Nil(t, proxy)
+			}
+		})
+	}
+}
+
+// TestRegisterConsulHealthCheck_thresholds tests that the thresholds are set on the service's TTL health check and
+// that status changes take effect immediately if they aren't set.
+func TestRegisterConsulHealthCheck_thresholds(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		successBeforePassing      int
+		failuresBeforeCritical    int
+		expSuccessBeforePassing   int
+		expFailuresBeforeCritical int
+	}{
+		"no thresholds": {
+			expSuccessBeforePassing:   1,
+			expFailuresBeforeCritical: 1,
+		},
+		"thresholds": {
+			successBeforePassing:      2,
+			failuresBeforeCritical:    3,
+			expSuccessBeforePassing:   2,
+			expFailuresBeforeCritical: 3,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var registration api.AgentCheckRegistration
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r != nil && r.URL.Path == "/v1/agent/check/register" && r.Method == "PUT" {
+					require.NoError(t, json.NewDecoder(r.Body).Decode(&registration))
+				}
+			}))
+			defer consulServer.Close()
+
+			consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+			require.NoError(t, err)
+
+			err = registerConsulHealthCheck(consulClient, "check-id", "pod1-web", api.HealthPassing, c.successBeforePassing, c.failuresBeforeCritical)
+			require.NoError(t, err)
+			require.Equal(t, "pod1-web", registration.ServiceID)
+			require.Equal(t, c.expSuccessBeforePassing, registration.SuccessBeforePassing)
+			require.Equal(t, c.expFailuresBeforeCritical, registration.FailuresBeforeCritical)
+		})
+	}
+}
+
+// TestDeregisterServiceOnAllAgents_partition tests that services are only deregistered from the agents in the
+// controller's partition and that the partition is passed to the agent.
+func TestDeregisterServiceOnAllAgents_partition(t *testing.T) {
+	t.Parallel()
+	var partitions, deregistered []string
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/agent/services":
+			partitions = append(partitions, r.URL.Query().Get("partition"))
+			json.NewEncoder(w).Encode(map[string]*api.AgentService{
+				"pod1-web": {ID: "pod1-web", Service: "web", Address: "1.2.3.4"},
+			})
+		case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+			deregistered = append(deregistered, strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/"))
+		}
+	}))
+	defer consulServer.Close()
+	serverURL, err := url.Parse(consulServer.URL)
+	require.NoError(t, err)
+
+	agentLabels := map[string]string{"component": "client", "app": "consul", "release": "consul"}
+	// The agent in the controller's partition is served by the test server.
+	agentInPartition := createPod("agent-in-partition", "127.0.0.1", false, true)
+	agentInPartition.Labels = map[string]string{labelAgentPartition: "foo"}
+	// The agent in another partition and the one without the label are unreachable, so contacting them fails.
+	agentOtherPartition := createPod("agent-other-partition", "127.0.0.2", false, true)
+	agentOtherPartition.Labels = map[string]string{labelAgentPartition: "bar"}
+	for k, v := range agentLabels {
+		agentInPartition.Labels[k] = v
+		agentOtherPartition.Labels[k] = v
+	}
+
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	epCtrl := EndpointsController{
+		Client:                 fake.NewClientBuilder().WithRuntimeObjects(agentInPartition, agentOtherPartition, &ns).Build(),
+		ConsulClientCfg:        &api.Config{},
+		ConsulScheme:           "http",
+		ConsulPort:             serverURL.Port(),
+		EnableConsulPartitions: true,
+		ConsulPartition:        "foo",
+		ReleaseName:            "consul",
+		ReleaseNamespace:       "default",
+		Log:                    logrtest.TestLogger{T: t},
+		Context:                context.Background(),
+	}
+
+	anyDeregistered, err := epCtrl.deregisterServiceOnAllAgents(context.Background(), "web", "default", nil)
+	require.NoError(t, err)
+	require.True(t, anyDeregistered)
+	require.Equal(t, []string{"foo"}, partitions)
+	require.Equal(t, []string{"pod1-web"}, deregistered)
+}
+
+// TestGarbageCollectOrphanedServices tests that only the service instances whose pod no longer exists are
+// deregistered.
+func TestGarbageCollectOrphanedServices(t *testing.T) {
+	t.Parallel()
+	var filters, deregistered []string
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/agent/services":
+			filters = append(filters, r.URL.Query().Get("filter"))
+			json.NewEncoder(w).Encode(map[string]*api.AgentService{
+				"pod1-web": {
+					ID:      "pod1-web",
+					Service: "web",
+					Address: "1.2.3.4",
+					Meta:    map[string]string{MetaKeyKubeServiceName: "web", MetaKeyKubeNS: "default", MetaKeyPodName: "pod1"},
+				},
+				"orphan-web": {
+					ID:      "orphan-web",
+					Service: "web",
+					Address: "2.2.2.2",
+					Meta:    map[string]string{MetaKeyKubeServiceName: "web", MetaKeyKubeNS: "default", MetaKeyPodName: "orphan"},
+				},
+			})
+		case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+			deregistered = append(deregistered, strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/"))
+		}
+	}))
+	defer consulServer.Close()
+	serverURL, err := url.Parse(consulServer.URL)
+	require.NoError(t, err)
+
+	agent := createPod("consul-client", "127.0.0.1", false, true)
+	agent.Labels = map[string]string{"component": "client", "app": "consul", "release": "consul"}
+	pod1 := createPod("pod1", "1.2.3.4", true, true)
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	epCtrl := EndpointsController{
+		Client:           fake.NewClientBuilder().WithRuntimeObjects(agent, pod1, &ns).Build(),
+		ConsulClientCfg:  &api.Config{},
+		ConsulScheme:     "http",
+		ConsulPort:       serverURL.Port(),
+		ReleaseName:      "consul",
+		ReleaseNamespace: "default",
+		Log:              logrtest.TestLogger{T: t},
+		Context:          context.Background(),
+	}
+
+	err = epCtrl.garbageCollectOrphanedServices(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{fmt.Sprintf(`Meta[%q] != "" and Meta[%q] == %q`, MetaKeyKubeServiceName, MetaKeyManagedBy, managedByValue)}, filters)
+	require.Equal(t, []string{"orphan-web"}, deregistered)
+}
+
+// TestReconcile_requeueAfterChange tests that the Endpoints are requeued after the configured duration only
+// when service instances were registered, deregistered or changed health.
+func TestReconcile_requeueAfterChange(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		requeueAfter time.Duration
+		registeredIP string
+		// injected makes the endpoints point at an injected pod which is registered with Consul.
+		injected bool
+		// checkStatus is the status of the pod's existing health check. The pod has no health check if empty.
+		checkStatus     string
+		expRequeueAfter time.Duration
+		expDeregistered []string
+	}{
+		"not configured": {
+			registeredIP:    "2.2.2.2",
+			expDeregistered: []string{"pod2-web"},
+		},
+		"configured and instance deregistered": {
+			requeueAfter:    10 * time.Second,
+			registeredIP:    "2.2.2.2",
+			expRequeueAfter: 10 * time.Second,
+			expDeregistered: []string{"pod2-web"},
+		},
+		"configured and nothing deregistered": {
+			requeueAfter: 10 * time.Second,
+		},
+		"configured and new instance registered": {
+			requeueAfter:    10 * time.Second,
+			injected:        true,
+			expRequeueAfter: 10 * time.Second,
+		},
+		"configured and instance health changed": {
+			requeueAfter:    10 * time.Second,
+			injected:        true,
+			checkStatus:     api.HealthCritical,
+			expRequeueAfter: 10 * time.Second,
+		},
+		"configured and instance registered again unchanged": {
+			requeueAfter: 10 * time.Second,
+			injected:     true,
+			checkStatus:  api.HealthPassing,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var deregistered []string
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/v1/agent/services":
+					services := map[string]*api.AgentService{}
+					if c.registeredIP != "" {
+						services["pod2-web"] = &api.AgentService{ID: "pod2-web", Service: "web", Address: c.registeredIP}
+					}
+					json.NewEncoder(w).Encode(services)
+				case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+					deregistered = append(deregistered, strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/"))
+				case r.URL.Path == "/v1/agent/checks":
+					checks := map[string]*api.AgentCheck{}
+					if c.checkStatus != "" {
+						checkID := "default/pod1-web/kubernetes-health-check"
+						checks[checkID] = &api.AgentCheck{CheckID: checkID, ServiceID: "pod1-web", Status: c.checkStatus}
+					}
+					json.NewEncoder(w).Encode(checks)
+				}
+			}))
+			defer consulServer.Close()
+			serverURL, err := url.Parse(consulServer.URL)
+			require.NoError(t, err)
+
+			agent := createPod("consul-client", "127.0.0.1", false, true)
+			agent.Labels = map[string]string{"component": "client", "app": "consul", "release": "consul"}
+			// Unless it's injected, the endpoints point at a pod that only deregistration talks to Consul for.
+			pod := createPod("pod1", "1.2.3.4", c.injected, c.injected)
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+				Subsets: []corev1.EndpointSubset{
+					{
+						Addresses: []corev1.EndpointAddress{
+							{
+								IP:        "1.2.3.4",
+								TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "pod1", Namespace: "default"},
+							},
+						},
+					},
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:                fake.NewClientBuilder().WithRuntimeObjects(agent, pod, endpoints, &ns).Build(),
+				ConsulClientCfg:       &api.Config{},
+				ConsulScheme:          "http",
+				ConsulPort:            serverURL.Port(),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSetWith(),
+				ReleaseName:           "consul",
+				ReleaseNamespace:      "default",
+				RequeueAfterChange:    c.requeueAfter,
+				Log:                   logrtest.TestLogger{T: t},
+				Context:               context.Background(),
+			}
+
+			resp, err := epCtrl.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: "web", Namespace: "default"},
+			})
+			require.NoError(t, err)
+			require.Equal(t, c.expRequeueAfter, resp.RequeueAfter)
+			require.Equal(t, c.expDeregistered, deregistered)
+		})
+	}
+}
+
+// TestReconcile_reconcileTimeout tests that a reconcile whose call to Consul outlives the ReconcileTimeout is
+// cancelled and returns an error so that it's requeued.
+func TestReconcile_reconcileTimeout(t *testing.T) {
+	t.Parallel()
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/agent/services" {
+			// Simulate a stuck Consul call which only returns once the client gives up on it.
+			select {
+			case <-r.Context().Done():
+			case <-time.After(10 * time.Second):
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]*api.AgentService{})
+	}))
+	defer consulServer.Close()
+	serverURL, err := url.Parse(consulServer.URL)
+	require.NoError(t, err)
+
+	agent := createPod("consul-client", "127.0.0.1", false, true)
+	agent.Labels = map[string]string{"component": "client", "app": "consul", "release": "consul"}
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: "default",
+		},
+	}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	epCtrl := EndpointsController{
+		Client:                fake.NewClientBuilder().WithRuntimeObjects(agent, endpoints, &ns).Build(),
+		ConsulClientCfg:       &api.Config{},
+		ConsulScheme:          "http",
+		ConsulPort:            serverURL.Port(),
+		AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:  mapset.NewSetWith(),
+		ReleaseName:           "consul",
+		ReleaseNamespace:      "default",
+		ReconcileTimeout:      100 * time.Millisecond,
+		Log:                   logrtest.TestLogger{T: t},
+		Context:               context.Background(),
+	}
+
+	start := time.Now()
+	_, err = epCtrl.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "web", Namespace: "default"},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "reconciling Endpoints default/web timed out after 100ms")
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestReconcile_agentConnectRetries(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		retries       int
+		agentFailures int
+		expSelfCalls  int
+		expRegistered bool
+		expErr        string
+	}{
+		"not configured": {
+			agentFailures: 1,
+			expSelfCalls:  0,
+			expRegistered: true,
+		},
+		"agent fails once": {
+			retries:       2,
+			agentFailures: 1,
+			expSelfCalls:  2,
+			expRegistered: true,
+		},
+		"agent stays unavailable": {
+			retries:       1,
+			agentFailures: 5,
+			expSelfCalls:  2,
+			expErr:        "Unexpected response code: 500 (agent unavailable)",
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var selfCalls int
+			var registered bool
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/agent/self":
+					selfCalls++
+					if selfCalls <= c.agentFailures {
+						w.WriteHeader(http.StatusInternalServerError)
+						w.Write([]byte("agent unavailable"))
+						return
+					}
+					w.Write([]byte("{}"))
+				case "/v1/agent/service/register":
+					registered = true
+				case "/v1/agent/services", "/v1/agent/checks":
+					w.Write([]byte("{}"))
+				}
+			}))
+			defer consulServer.Close()
+			serverURL, err := url.Parse(consulServer.URL)
+			require.NoError(t, err)
+
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			pod.Status.HostIP = "127.0.0.1"
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+				Subsets: []corev1.EndpointSubset{
+					{
+						Addresses: []corev1.EndpointAddress{
+							{
+								IP:        "1.2.3.4",
+								TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "pod1", Namespace: "default"},
+							},
+						},
+					},
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:                    fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				ConsulClientCfg:           &api.Config{},
+				ConsulScheme:              "http",
+				ConsulPort:                serverURL.Port(),
+				AllowK8sNamespacesSet:     mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:      mapset.NewSetWith(),
+				ReleaseName:               "consul",
+				ReleaseNamespace:          "default",
+				AgentConnectRetries:       c.retries,
+				AgentConnectRetryInterval: time.Millisecond,
+				Log:                       logrtest.TestLogger{T: t},
+				Context:                   context.Background(),
+			}
+
+			_, err = epCtrl.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: "web", Namespace: "default"},
+			})
+			if c.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, c.expSelfCalls, selfCalls)
+			require.Equal(t, c.expRegistered, registered)
+		})
+	}
+}
+
+func TestAgentInPartition(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		partitionsEnabled bool
+		partition         string
+		podPartition      string
+		agentLabels       map[string]string
+		exp               bool
+	}{
+		"partitions disabled": {
+			partitionsEnabled: false,
+			agentLabels:       map[string]string{labelAgentPartition: "bar"},
+			exp:               true,
+		},
+		"agent in partition": {
+			partitionsEnabled: true,
+			partition:         "foo",
+			agentLabels:       map[string]string{labelAgentPartition: "foo"},
+			exp:               true,
+		},
+		"agent in other partition": {
+			partitionsEnabled: true,
+			partition:         "foo",
+			agentLabels:       map[string]string{labelAgentPartition: "bar"},
+			exp:               false,
+		},
+		"agent without partition label": {
+			partitionsEnabled: true,
+			partition:         "foo",
+			exp:               true,
+		},
+		"agent in partition overridden by pod": {
+			partitionsEnabled: true,
+			partition:         "foo",
+			podPartition:      "bar",
+			agentLabels:       map[string]string{labelAgentPartition: "bar"},
+			exp:               true,
+		},
+		"agent without partition label and partition overridden by pod": {
+			partitionsEnabled: true,
+			partition:         "foo",
+			podPartition:      "bar",
+			exp:               false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			epCtrl := EndpointsController{
+				EnableConsulPartitions: c.partitionsEnabled,
+				ConsulPartition:        c.partition,
+			}
+			agent := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: c.agentLabels}}
+			partition := c.partition
+			if c.podPartition != "" {
+				partition = c.podPartition
+			}
+			require.Equal(t, c.exp, epCtrl.agentInPartition(agent, partition))
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_gatewayKind(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotation      string
+		envoyReadyCheck bool
+		expKind         api.ServiceKind
+		expName         string
+		expID           string
+		expErr          string
+	}{
+		"no annotation": {
+			expKind: api.ServiceKindConnectProxy,
+			expName: "web-sidecar-proxy",
+			expID:   "pod1-web-sidecar-proxy",
+		},
+		"sidecar": {
+			annotation: "sidecar",
+			expKind:    api.ServiceKindConnectProxy,
+			expName:    "web-sidecar-proxy",
+			expID:      "pod1-web-sidecar-proxy",
+		},
+		"mesh gateway": {
+			annotation: "mesh-gateway",
+			expKind:    api.ServiceKindMeshGateway,
+			expName:    "web-mesh-gateway",
+			expID:      "pod1-web-mesh-gateway",
+		},
+		"ingress gateway": {
+			annotation: "ingress-gateway",
+			expKind:    api.ServiceKindIngressGateway,
+			expName:    "web-ingress-gateway",
+			expID:      "pod1-web-ingress-gateway",
+		},
+		"terminating gateway": {
+			annotation: "terminating-gateway",
+			expKind:    api.ServiceKindTerminatingGateway,
+			expName:    "web-terminating-gateway",
+			expID:      "pod1-web-terminating-gateway",
+		},
+		"mesh gateway with the Envoy ready check": {
+			annotation:      "mesh-gateway",
+			envoyReadyCheck: true,
+			expKind:         api.ServiceKindMeshGateway,
+			expName:         "web-mesh-gateway",
+			expID:           "pod1-web-mesh-gateway",
+		},
+		"unknown kind": {
+			annotation: "api-gateway",
+			expErr:     `consul.hashicorp.com/gateway-kind annotation value "api-gateway" is invalid: must be one of "ingress-gateway", "mesh-gateway", "sidecar", "terminating-gateway"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			pod.Annotations[annotationPort] = "8080"
+			pod.Annotations[annotationUpstreams] = "upstream1:1234"
+			if c.annotation != "" {
+				pod.Annotations[annotationGatewayKind] = c.annotation
+			}
+			if c.envoyReadyCheck {
+				pod.Annotations[annotationEnableEnvoyReadyCheck] = "true"
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:     logrtest.TestLogger{T: t},
+				Context: context.Background(),
+			}
+
+			_, proxy, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expKind, proxy.Kind)
+			require.Equal(t, c.expName, proxy.Name)
+			require.Equal(t, c.expID, proxy.ID)
+			if c.expKind == api.ServiceKindConnectProxy {
+				require.Equal(t, "pod1-web", proxy.Proxy.DestinationServiceID)
+				require.Equal(t, 8080, proxy.Proxy.LocalServicePort)
+				require.Len(t, proxy.Proxy.Upstreams, 1)
+				require.Len(t, proxy.Checks, 2)
+			} else {
+				// Gateways are registered without the sidecar configuration.
+				require.Empty(t, proxy.Proxy.DestinationServiceName)
+				require.Empty(t, proxy.Proxy.DestinationServiceID)
+				require.Zero(t, proxy.Proxy.LocalServicePort)
+				require.Empty(t, proxy.Proxy.Upstreams)
+				expChecks := []string{"Proxy Public Listener"}
+				if c.envoyReadyCheck {
+					expChecks = append(expChecks, "Proxy Ready")
+				}
+				var checks []string
+				for _, check := range proxy.Checks {
+					require.Empty(t, check.AliasService)
+					checks = append(checks, check.Name)
+				}
+				require.Equal(t, expChecks, checks)
+			}
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_consulNamespaceOverride(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		namespacesEnabled bool
+		annotation        string
+		expNamespace      string
+		expErr            string
+	}{
+		"namespaces disabled": {
+			annotation:   "team-a",
+			expNamespace: "",
+		},
+		"annotation not set": {
+			namespacesEnabled: true,
+			expNamespace:      "prefix-default",
+		},
+		"annotation overrides mirrored namespace": {
+			namespacesEnabled: true,
+			annotation:        "team-a",
+			expNamespace:      "team-a",
+		},
+		"invalid namespace name": {
+			namespacesEnabled: true,
+			annotation:        "team_a",
+			expErr:            `consul.hashicorp.com/consul-namespace annotation value "team_a" is invalid: must be at most 64 alphanumeric characters or dashes and must start and end with an alphanumeric character`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			if c.annotation != "" {
+				pod.Annotations[annotationConsulNamespace] = c.annotation
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:                 fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:                    logrtest.TestLogger{T: t},
+				Context:                context.Background(),
+				EnableConsulNamespaces: c.namespacesEnabled,
+				EnableNSMirroring:      true,
+				NSMirroringPrefix:      "prefix-",
+			}
+
+			serviceRegistration, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expNamespace, serviceRegistration.Namespace)
+			require.Equal(t, c.expNamespace, proxyServiceRegistration.Namespace)
+		})
+	}
+}
+
+func TestConsulNamespacesForK8SNamespace(t *testing.T) {
+	t.Parallel()
+	overridden := createPod("pod1", "1.2.3.4", true, true)
+	overridden.Annotations[annotationConsulNamespace] = "team-a"
+	duplicate := createPod("pod2", "2.2.3.4", true, true)
+	duplicate.Annotations[annotationConsulNamespace] = "team-a"
+	invalid := createPod("pod3", "3.2.3.4", true, true)
+	invalid.Annotations[annotationConsulNamespace] = "team_b"
+	unmanaged := createPod("pod4", "4.2.3.4", true, false)
+	unmanaged.Annotations[annotationConsulNamespace] = "team-c"
+	notOverridden := createPod("pod5", "5.2.3.4", true, true)
+
+	cases := map[string]struct {
+		namespacesEnabled bool
+		expNamespaces     []string
+	}{
+		"namespaces disabled": {
+			expNamespaces: []string{""},
+		},
+		"namespaces enabled": {
+			namespacesEnabled: true,
+			expNamespaces:     []string{"prefix-default", "team-a"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			epCtrl := EndpointsController{
+				Client:                 fake.NewClientBuilder().WithRuntimeObjects(overridden, duplicate, invalid, unmanaged, notOverridden).Build(),
+				Log:                    logrtest.TestLogger{T: t},
+				Context:                context.Background(),
+				EnableConsulNamespaces: c.namespacesEnabled,
+				EnableNSMirroring:      true,
+				NSMirroringPrefix:      "prefix-",
+			}
+
+			consulNamespaces, err := epCtrl.consulNamespacesForK8SNamespace(context.Background(), "default")
+			require.NoError(t, err)
+			require.Equal(t, c.expNamespaces, consulNamespaces)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_consulPartitionOverride(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		partitionsEnabled bool
+		annotation        string
+		expPartition      string
+		expErr            string
+	}{
+		"partitions disabled": {
+			annotation:   "team-a",
+			expPartition: "",
+		},
+		"annotation not set": {
+			partitionsEnabled: true,
+			expPartition:      "default",
+		},
+		"annotation overrides controller partition": {
+			partitionsEnabled: true,
+			annotation:        "team-a",
+			expPartition:      "team-a",
+		},
+		"invalid partition name": {
+			partitionsEnabled: true,
+			annotation:        "team_a",
+			expErr:            `consul.hashicorp.com/consul-partition annotation value "team_a" is invalid: must be at most 64 alphanumeric characters or dashes and must start and end with an alphanumeric character`,
 		},
-		{
-			name: "multi port, with annotation",
-			pod: func() *corev1.Pod {
-				pod1 := createPod("pod1", "1.2.3.4", true, true)
-				pod1.Annotations[annotationService] = "web,web-admin"
-				return pod1
-			},
-			endpoint: &corev1.Endpoints{
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			if c.annotation != "" {
+				pod.Annotations[annotationConsulPartition] = c.annotation
+			}
+			endpoints := &corev1.Endpoints{
 				ObjectMeta: metav1.ObjectMeta{
-					Name:      "ep-name-multiport",
+					Name:      "web",
 					Namespace: "default",
 				},
-			},
-			expSvcName: "ep-name-multiport",
-		},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := EndpointsController{
+				Client:                 fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build(),
+				Log:                    logrtest.TestLogger{T: t},
+				Context:                context.Background(),
+				EnableConsulPartitions: c.partitionsEnabled,
+			}
+			if c.partitionsEnabled {
+				epCtrl.ConsulPartition = "default"
+			}
+
+			serviceRegistration, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expPartition, serviceRegistration.Partition)
+			require.Equal(t, c.expPartition, proxyServiceRegistration.Partition)
+		})
 	}
-	for _, tt := range cases {
-		t.Run(tt.name, func(t *testing.T) {
+}
 
-			svcName := getServiceName(*tt.pod(), *tt.endpoint)
-			require.Equal(t, tt.expSvcName, svcName)
+func TestConsulPartitionsForK8SNamespace(t *testing.T) {
+	t.Parallel()
+	overridden := createPod("pod1", "1.2.3.4", true, true)
+	overridden.Annotations[annotationConsulPartition] = "team-a"
+	duplicate := createPod("pod2", "2.2.3.4", true, true)
+	duplicate.Annotations[annotationConsulPartition] = "team-a"
+	invalid := createPod("pod3", "3.2.3.4", true, true)
+	invalid.Annotations[annotationConsulPartition] = "team_b"
+	unmanaged := createPod("pod4", "4.2.3.4", true, false)
+	unmanaged.Annotations[annotationConsulPartition] = "team-c"
+	notOverridden := createPod("pod5", "5.2.3.4", true, true)
 
+	cases := map[string]struct {
+		partitionsEnabled bool
+		partition         string
+		expPartitions     []string
+	}{
+		"partitions disabled": {
+			expPartitions: []string{""},
+		},
+		"partitions enabled": {
+			partitionsEnabled: true,
+			partition:         "default",
+			expPartitions:     []string{"default", "team-a"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			epCtrl := EndpointsController{
+				Client:                 fake.NewClientBuilder().WithRuntimeObjects(overridden, duplicate, invalid, unmanaged, notOverridden).Build(),
+				Log:                    logrtest.TestLogger{T: t},
+				Context:                context.Background(),
+				EnableConsulPartitions: c.partitionsEnabled,
+				ConsulPartition:        c.partition,
+			}
+
+			consulPartitions, err := epCtrl.consulPartitionsForK8SNamespace(context.Background(), "default")
+			require.NoError(t, err)
+			require.Equal(t, c.expPartitions, consulPartitions)
 		})
 	}
 }
@@ -942,6 +4242,7 @@ func TestReconcileCreateEndpoint_MultiportService(t *testing.T) {
 							MetaKeyKubeServiceName: "web",
 							MetaKeyKubeNS:          "default",
 							MetaKeyManagedBy:       managedByValue,
+							MetaKeyHostIP:          "127.0.0.1",
 						},
 						ServiceTags: []string{},
 					},
@@ -957,6 +4258,7 @@ func TestReconcileCreateEndpoint_MultiportService(t *testing.T) {
 							MetaKeyKubeServiceName: "web-admin",
 							MetaKeyKubeNS:          "default",
 							MetaKeyManagedBy:       managedByValue,
+							MetaKeyHostIP:          "127.0.0.1",
 						},
 						ServiceTags: []string{},
 					},
@@ -987,6 +4289,7 @@ func TestReconcileCreateEndpoint_MultiportService(t *testing.T) {
 							MetaKeyKubeServiceName: "web",
 							MetaKeyKubeNS:          "default",
 							MetaKeyManagedBy:       managedByValue,
+							MetaKeyHostIP:          "127.0.0.1",
 						},
 						ServiceTags: []string{},
 					},
@@ -1008,6 +4311,7 @@ func TestReconcileCreateEndpoint_MultiportService(t *testing.T) {
 							MetaKeyKubeServiceName: "web-admin",
 							MetaKeyKubeNS:          "default",
 							MetaKeyManagedBy:       managedByValue,
+							MetaKeyHostIP:          "127.0.0.1",
 						},
 						ServiceTags: []string{},
 					},
@@ -1128,6 +4432,9 @@ func TestReconcileCreateEndpoint_MultiportService(t *testing.T) {
 					require.Equal(t, tt.expectedProxySvcInstancesMap[service][i].ServiceName, instance.ServiceName)
 					require.Equal(t, tt.expectedProxySvcInstancesMap[service][i].ServiceAddress, instance.ServiceAddress)
 					require.Equal(t, tt.expectedProxySvcInstancesMap[service][i].ServicePort, instance.ServicePort)
+					// The proxy config hash is covered by TestProxyConfigHash.
+					require.NotEmpty(t, instance.ServiceMeta[MetaKeyProxyConfigHash])
+					delete(instance.ServiceMeta, MetaKeyProxyConfigHash)
 					require.Equal(t, tt.expectedProxySvcInstancesMap[service][i].ServiceMeta, instance.ServiceMeta)
 					require.Equal(t, tt.expectedProxySvcInstancesMap[service][i].ServiceTags, instance.ServiceTags)
 
@@ -1247,7 +4554,7 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 					ServiceName:    "service-created",
 					ServiceAddress: "1.2.3.4",
 					ServicePort:    0,
-					ServiceMeta:    map[string]string{MetaKeyPodName: "pod1", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue},
+					ServiceMeta:    map[string]string{MetaKeyPodName: "pod1", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue, MetaKeyHostIP: "127.0.0.1"},
 					ServiceTags:    []string{},
 				},
 			},
@@ -1263,7 +4570,7 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 						LocalServiceAddress:    "",
 						LocalServicePort:       0,
 					},
-					ServiceMeta: map[string]string{MetaKeyPodName: "pod1", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue},
+					ServiceMeta: map[string]string{MetaKeyPodName: "pod1", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue, MetaKeyHostIP: "127.0.0.1"},
 					ServiceTags: []string{},
 				},
 			},
@@ -1325,7 +4632,7 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 					ServiceName:    "service-created",
 					ServiceAddress: "1.2.3.4",
 					ServicePort:    0,
-					ServiceMeta:    map[string]string{MetaKeyPodName: "pod1", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue},
+					ServiceMeta:    map[string]string{MetaKeyPodName: "pod1", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue, MetaKeyHostIP: "127.0.0.1"},
 					ServiceTags:    []string{},
 				},
 				{
@@ -1333,7 +4640,7 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 					ServiceName:    "service-created",
 					ServiceAddress: "2.2.3.4",
 					ServicePort:    0,
-					ServiceMeta:    map[string]string{MetaKeyPodName: "pod2", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue},
+					ServiceMeta:    map[string]string{MetaKeyPodName: "pod2", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue, MetaKeyHostIP: "127.0.0.1"},
 					ServiceTags:    []string{},
 				},
 			},
@@ -1349,7 +4656,7 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 						LocalServiceAddress:    "",
 						LocalServicePort:       0,
 					},
-					ServiceMeta: map[string]string{MetaKeyPodName: "pod1", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue},
+					ServiceMeta: map[string]string{MetaKeyPodName: "pod1", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue, MetaKeyHostIP: "127.0.0.1"},
 					ServiceTags: []string{},
 				},
 				{
@@ -1363,7 +4670,7 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 						LocalServiceAddress:    "",
 						LocalServicePort:       0,
 					},
-					ServiceMeta: map[string]string{MetaKeyPodName: "pod2", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue},
+					ServiceMeta: map[string]string{MetaKeyPodName: "pod2", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue, MetaKeyHostIP: "127.0.0.1"},
 					ServiceTags: []string{},
 				},
 			},
@@ -1448,7 +4755,7 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 					ServiceName:    "service-created",
 					ServiceAddress: "1.2.3.4",
 					ServicePort:    0,
-					ServiceMeta:    map[string]string{MetaKeyPodName: "pod1", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue},
+					ServiceMeta:    map[string]string{MetaKeyPodName: "pod1", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue, MetaKeyHostIP: "127.0.0.1"},
 					ServiceTags:    []string{},
 				},
 				{
@@ -1456,7 +4763,7 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 					ServiceName:    "service-created",
 					ServiceAddress: "2.2.3.4",
 					ServicePort:    0,
-					ServiceMeta:    map[string]string{MetaKeyPodName: "pod2", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue},
+					ServiceMeta:    map[string]string{MetaKeyPodName: "pod2", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue, MetaKeyHostIP: "127.0.0.1"},
 					ServiceTags:    []string{},
 				},
 			},
@@ -1472,7 +4779,7 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 						LocalServiceAddress:    "",
 						LocalServicePort:       0,
 					},
-					ServiceMeta: map[string]string{MetaKeyPodName: "pod1", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue},
+					ServiceMeta: map[string]string{MetaKeyPodName: "pod1", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue, MetaKeyHostIP: "127.0.0.1"},
 					ServiceTags: []string{},
 				},
 				{
@@ -1486,7 +4793,7 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 						LocalServiceAddress:    "",
 						LocalServicePort:       0,
 					},
-					ServiceMeta: map[string]string{MetaKeyPodName: "pod2", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue},
+					ServiceMeta: map[string]string{MetaKeyPodName: "pod2", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue, MetaKeyHostIP: "127.0.0.1"},
 					ServiceTags: []string{},
 				},
 			},
@@ -1566,6 +4873,7 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 						MetaKeyKubeServiceName: "service-created",
 						MetaKeyKubeNS:          "default",
 						MetaKeyManagedBy:       managedByValue,
+						MetaKeyHostIP:          "127.0.0.1",
 					},
 					ServiceTags: []string{"abc", "123", "pod1", "def", "456", "pod1"},
 				},
@@ -1600,6 +4908,7 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 						MetaKeyKubeServiceName: "service-created",
 						MetaKeyKubeNS:          "default",
 						MetaKeyManagedBy:       managedByValue,
+						MetaKeyHostIP:          "127.0.0.1",
 					},
 					ServiceTags: []string{"abc", "123", "pod1", "def", "456", "pod1"},
 				},
@@ -1667,7 +4976,7 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 					ServiceName:    "service-created",
 					ServiceAddress: "1.2.3.4",
 					ServicePort:    0,
-					ServiceMeta:    map[string]string{MetaKeyPodName: "pod1", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue},
+					ServiceMeta:    map[string]string{MetaKeyPodName: "pod1", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue, MetaKeyHostIP: "127.0.0.1"},
 					ServiceTags:    []string{},
 				},
 			},
@@ -1683,7 +4992,7 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 						LocalServiceAddress:    "",
 						LocalServicePort:       0,
 					},
-					ServiceMeta: map[string]string{MetaKeyPodName: "pod1", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue},
+					ServiceMeta: map[string]string{MetaKeyPodName: "pod1", MetaKeyKubeServiceName: "service-created", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue, MetaKeyHostIP: "127.0.0.1"},
 					ServiceTags: []string{},
 				},
 			},
@@ -1786,6 +5095,9 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 				require.Equal(t, tt.expectedProxySvcInstances[i].ServiceName, instance.ServiceName)
 				require.Equal(t, tt.expectedProxySvcInstances[i].ServiceAddress, instance.ServiceAddress)
 				require.Equal(t, tt.expectedProxySvcInstances[i].ServicePort, instance.ServicePort)
+				// The proxy config hash is covered by TestProxyConfigHash.
+				require.NotEmpty(t, instance.ServiceMeta[MetaKeyProxyConfigHash])
+				delete(instance.ServiceMeta, MetaKeyProxyConfigHash)
 				require.Equal(t, tt.expectedProxySvcInstances[i].ServiceMeta, instance.ServiceMeta)
 				require.Equal(t, tt.expectedProxySvcInstances[i].ServiceTags, instance.ServiceTags)
 
@@ -3023,6 +6335,7 @@ func TestReconcileUpdateEndpoint(t *testing.T) {
 						MetaKeyKubeNS:          "default",
 						MetaKeyManagedBy:       managedByValue,
 						MetaKeyPodName:         "pod1",
+						MetaKeyHostIP:          "127.0.0.1",
 					},
 				},
 			},
@@ -3036,6 +6349,7 @@ func TestReconcileUpdateEndpoint(t *testing.T) {
 						MetaKeyKubeNS:          "default",
 						MetaKeyManagedBy:       managedByValue,
 						MetaKeyPodName:         "pod1",
+						MetaKeyHostIP:          "127.0.0.1",
 					},
 				},
 			},
@@ -3363,6 +6677,22 @@ func TestReconcileDeleteEndpoint(t *testing.T) {
 			},
 			consulClientReady: true,
 		},
+		{
+			name:                      "Connect native service without a proxy",
+			consulSvcName:             "service-deleted",
+			expectServicesToBeDeleted: true,
+			initialConsulSvcs: []*api.AgentServiceRegistration{
+				{
+					ID:      "pod1-service-deleted",
+					Name:    "service-deleted",
+					Port:    80,
+					Address: "1.2.3.4",
+					Connect: &api.AgentServiceConnect{Native: true},
+					Meta:    map[string]string{"k8s-service-name": "service-deleted", "k8s-namespace": "default", MetaKeyManagedBy: managedByValue},
+				},
+			},
+			consulClientReady: true,
+		},
 		{
 			name:                      "When ACLs are enabled, the token should be deleted",
 			consulSvcName:             "service-deleted",
@@ -3551,6 +6881,71 @@ func TestReconcileDeleteEndpoint(t *testing.T) {
 	}
 }
 
+// TestDeregisterConsulService tests that only the instances of the named Consul service and its sidecar proxy are
+// deregistered.
+func TestDeregisterConsulService(t *testing.T) {
+	t.Parallel()
+	fakeClientPod := createPod("fake-consul-client", "127.0.0.1", false, true)
+	fakeClientPod.Labels = map[string]string{"component": "client", "app": "consul", "release": "consul"}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(fakeClientPod, &ns).Build()
+
+	consul, err := testutil.NewTestServerConfigT(t, nil)
+	require.NoError(t, err)
+	defer consul.Stop()
+	consul.WaitForServiceIntentions(t)
+	cfg := &api.Config{Address: consul.HTTPAddr}
+	consulClient, err := api.NewClient(cfg)
+	require.NoError(t, err)
+	addr := strings.Split(consul.HTTPAddr, ":")
+
+	meta := map[string]string{MetaKeyKubeServiceName: "k8s-svc", MetaKeyKubeNS: "default", MetaKeyManagedBy: managedByValue}
+	for _, name := range []string{"web", "api"} {
+		err = consulClient.Agent().ServiceRegister(&api.AgentServiceRegistration{
+			ID:      "pod1-" + name,
+			Name:    name,
+			Port:    80,
+			Address: "1.2.3.4",
+			Meta:    meta,
+		})
+		require.NoError(t, err)
+		err = consulClient.Agent().ServiceRegister(&api.AgentServiceRegistration{
+			Kind:    api.ServiceKindConnectProxy,
+			ID:      "pod1-" + name + "-sidecar-proxy",
+			Name:    name + "-sidecar-proxy",
+			Port:    20000,
+			Address: "1.2.3.4",
+			Proxy: &api.AgentServiceConnectProxyConfig{
+				DestinationServiceName: name,
+				DestinationServiceID:   "pod1-" + name,
+			},
+			Meta: meta,
+		})
+		require.NoError(t, err)
+	}
+
+	ep := &EndpointsController{
+		Client:           fakeClient,
+		Log:              logrtest.TestLogger{T: t},
+		ConsulClient:     consulClient,
+		ConsulPort:       addr[1],
+		ConsulScheme:     "http",
+		ReleaseName:      "consul",
+		ReleaseNamespace: "default",
+		ConsulClientCfg:  cfg,
+	}
+	err = ep.DeregisterConsulService(context.Background(), "web", "")
+	require.NoError(t, err)
+
+	services, err := consulClient.Agent().Services()
+	require.NoError(t, err)
+	var ids []string
+	for id := range services {
+		ids = append(ids, id)
+	}
+	require.ElementsMatch(t, []string{"pod1-api", "pod1-api-sidecar-proxy"}, ids)
+}
+
 // TestReconcileIgnoresServiceIgnoreLabel tests that the endpoints controller correctly ignores services
 // with the service-ignore label and deregisters services previously registered if the service-ignore
 // label is added.
@@ -3826,6 +7221,242 @@ func TestReconcile_podSpecifiesExplicitService(t *testing.T) {
 	require.Len(t, proxyServiceInstances, 1)
 }
 
+// TestReconcile_podInMultipleServices tests that a pod backing more than one Kubernetes service is registered
+// as a distinct Consul service for each of them and that each registration is deregistered independently.
+func TestReconcile_podInMultipleServices(t *testing.T) {
+	nodeName := "test-node"
+	namespace := "default"
+
+	// Set up the fake Kubernetes client with two endpoints backed by the same pod.
+	endpointsFor := func(name string) *corev1.Endpoints {
+		return &corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Subsets: []corev1.EndpointSubset{
+				{
+					Addresses: []corev1.EndpointAddress{
+						{
+							IP:       "1.2.3.4",
+							NodeName: &nodeName,
+							TargetRef: &corev1.ObjectReference{
+								Kind:      "Pod",
+								Name:      "pod1",
+								Namespace: namespace,
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	endpointA := endpointsFor("service-a")
+	endpointB := endpointsFor("service-b")
+	pod1 := createPod("pod1", "1.2.3.4", true, true)
+	fakeClientPod := createPod("fake-consul-client", "127.0.0.1", false, true)
+	fakeClientPod.Labels = map[string]string{"component": "client", "app": "consul", "release": "consul"}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	k8sObjects := []runtime.Object{endpointA, endpointB, pod1, fakeClientPod, &ns}
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(k8sObjects...).Build()
+
+	// Create test Consul server.
+	consul, err := testutil.NewTestServerConfigT(t, func(c *testutil.TestServerConfig) { c.NodeName = nodeName })
+	require.NoError(t, err)
+	defer consul.Stop()
+	consul.WaitForServiceIntentions(t)
+	cfg := &api.Config{Address: consul.HTTPAddr}
+	consulClient, err := api.NewClient(cfg)
+	require.NoError(t, err)
+	addr := strings.Split(consul.HTTPAddr, ":")
+	consulPort := addr[1]
+
+	// Create the endpoints controller.
+	ep := &EndpointsController{
+		Client:                fakeClient,
+		Log:                   logrtest.TestLogger{T: t},
+		ConsulClient:          consulClient,
+		ConsulPort:            consulPort,
+		ConsulScheme:          "http",
+		AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:  mapset.NewSetWith(),
+		ReleaseName:           "consul",
+		ReleaseNamespace:      namespace,
+		ConsulClientCfg:       cfg,
+	}
+
+	// requireRegistered checks whether the service and its proxy are registered for the pod.
+	requireRegistered := func(serviceName string, registered bool) {
+		t.Helper()
+		services, err := consulClient.Agent().Services()
+		require.NoError(t, err)
+		service, ok := services["pod1-"+serviceName]
+		require.Equal(t, registered, ok)
+		proxy, proxyOK := services["pod1-"+serviceName+"-sidecar-proxy"]
+		require.Equal(t, registered, proxyOK)
+		if registered {
+			require.Equal(t, serviceName, service.Service)
+			require.Equal(t, serviceName, service.Meta[MetaKeyKubeServiceName])
+			require.Equal(t, "pod1-"+serviceName, proxy.Proxy.DestinationServiceID)
+			require.Equal(t, serviceName, proxy.Meta[MetaKeyKubeServiceName])
+		}
+	}
+
+	// Register the pod for both services.
+	for _, endpoints := range []*corev1.Endpoints{endpointA, endpointB} {
+		namespacedName := types.NamespacedName{Namespace: endpoints.Namespace, Name: endpoints.Name}
+		resp, err := ep.Reconcile(context.Background(), ctrl.Request{NamespacedName: namespacedName})
+		require.NoError(t, err)
+		require.False(t, resp.Requeue)
+	}
+	requireRegistered(endpointA.Name, true)
+	requireRegistered(endpointB.Name, true)
+
+	// Reconciling one service again must not affect the registration of the other.
+	namespacedName := types.NamespacedName{Namespace: endpointA.Namespace, Name: endpointA.Name}
+	resp, err := ep.Reconcile(context.Background(), ctrl.Request{NamespacedName: namespacedName})
+	require.NoError(t, err)
+	require.False(t, resp.Requeue)
+	requireRegistered(endpointA.Name, true)
+	requireRegistered(endpointB.Name, true)
+
+	// Delete the first service and check that only its registration is removed.
+	require.NoError(t, fakeClient.Delete(context.Background(), endpointA))
+	resp, err = ep.Reconcile(context.Background(), ctrl.Request{NamespacedName: namespacedName})
+	require.NoError(t, err)
+	require.False(t, resp.Requeue)
+	requireRegistered(endpointA.Name, false)
+	requireRegistered(endpointB.Name, true)
+
+	// Delete the second service and check that its registration is removed too.
+	require.NoError(t, fakeClient.Delete(context.Background(), endpointB))
+	namespacedName = types.NamespacedName{Namespace: endpointB.Namespace, Name: endpointB.Name}
+	resp, err = ep.Reconcile(context.Background(), ctrl.Request{NamespacedName: namespacedName})
+	require.NoError(t, err)
+	require.False(t, resp.Requeue)
+	requireRegistered(endpointB.Name, false)
+}
+
+// TestReconcile_terminatingPod tests that a pod which is shutting down is deregistered from Consul even though it
+// is still in the Endpoints and that it isn't registered again while it terminates.
+func TestReconcile_terminatingPod(t *testing.T) {
+	nodeName := "test-node"
+	namespace := "default"
+	serviceName := "service-terminating"
+
+	// Set up the fake Kubernetes client with endpoints that still include a terminating pod.
+	endpoint := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceName,
+			Namespace: namespace,
+		},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{
+					{
+						IP:       "1.2.3.4",
+						NodeName: &nodeName,
+						TargetRef: &corev1.ObjectReference{
+							Kind:      "Pod",
+							Name:      "pod1",
+							Namespace: namespace,
+						},
+					},
+					{
+						IP:       "2.2.3.4",
+						NodeName: &nodeName,
+						TargetRef: &corev1.ObjectReference{
+							Kind:      "Pod",
+							Name:      "pod2",
+							Namespace: namespace,
+						},
+					},
+				},
+			},
+		},
+	}
+	pod1 := createPod("pod1", "1.2.3.4", true, true)
+	deletionTimestamp := metav1.Now()
+	pod1.DeletionTimestamp = &deletionTimestamp
+	pod2 := createPod("pod2", "2.2.3.4", true, true)
+	fakeClientPod := createPod("fake-consul-client", "127.0.0.1", false, true)
+	fakeClientPod.Labels = map[string]string{"component": "client", "app": "consul", "release": "consul"}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	k8sObjects := []runtime.Object{endpoint, pod1, pod2, fakeClientPod, &ns}
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(k8sObjects...).Build()
+
+	// Create test Consul server.
+	consul, err := testutil.NewTestServerConfigT(t, func(c *testutil.TestServerConfig) { c.NodeName = nodeName })
+	require.NoError(t, err)
+	defer consul.Stop()
+	consul.WaitForServiceIntentions(t)
+	cfg := &api.Config{Address: consul.HTTPAddr}
+	consulClient, err := api.NewClient(cfg)
+	require.NoError(t, err)
+	addr := strings.Split(consul.HTTPAddr, ":")
+	consulPort := addr[1]
+
+	// Create the endpoints controller.
+	ep := &EndpointsController{
+		Client:                fakeClient,
+		Log:                   logrtest.TestLogger{T: t},
+		ConsulClient:          consulClient,
+		ConsulPort:            consulPort,
+		ConsulScheme:          "http",
+		AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:  mapset.NewSetWith(),
+		ReleaseName:           "consul",
+		ReleaseNamespace:      namespace,
+		ConsulClientCfg:       cfg,
+	}
+
+	// The terminating pod was registered while it was running.
+	meta := map[string]string{
+		MetaKeyKubeNS:          namespace,
+		MetaKeyKubeServiceName: serviceName,
+		MetaKeyManagedBy:       managedByValue,
+		MetaKeyPodName:         "pod1",
+	}
+	err = consulClient.Agent().ServiceRegister(&api.AgentServiceRegistration{
+		ID:      "pod1-" + serviceName,
+		Name:    serviceName,
+		Port:    0,
+		Address: "1.2.3.4",
+		Meta:    meta,
+	})
+	require.NoError(t, err)
+	err = consulClient.Agent().ServiceRegister(&api.AgentServiceRegistration{
+		Kind:    api.ServiceKindConnectProxy,
+		ID:      "pod1-" + serviceName + "-sidecar-proxy",
+		Name:    serviceName + "-sidecar-proxy",
+		Port:    20000,
+		Address: "1.2.3.4",
+		Proxy: &api.AgentServiceConnectProxyConfig{
+			DestinationServiceName: serviceName,
+			DestinationServiceID:   "pod1-" + serviceName,
+		},
+		Meta: meta,
+	})
+	require.NoError(t, err)
+
+	// Reconcile twice to check that the terminating pod isn't registered again.
+	namespacedName := types.NamespacedName{Namespace: endpoint.Namespace, Name: endpoint.Name}
+	for i := 0; i < 2; i++ {
+		resp, err := ep.Reconcile(context.Background(), ctrl.Request{NamespacedName: namespacedName})
+		require.NoError(t, err)
+		require.False(t, resp.Requeue)
+
+		serviceInstances, _, err := consulClient.Catalog().Service(serviceName, "", nil)
+		require.NoError(t, err)
+		require.Len(t, serviceInstances, 1)
+		require.Equal(t, "pod2-"+serviceName, serviceInstances[0].ServiceID)
+		proxyServiceInstances, _, err := consulClient.Catalog().Service(serviceName+"-sidecar-proxy", "", nil)
+		require.NoError(t, err)
+		require.Len(t, proxyServiceInstances, 1)
+		require.Equal(t, "pod2-"+serviceName+"-sidecar-proxy", proxyServiceInstances[0].ServiceID)
+	}
+}
+
 func TestFilterAgentPods(t *testing.T) {
 	t.Parallel()
 	cases := map[string]struct {
@@ -4527,7 +8158,7 @@ func TestServiceInstancesForK8SServiceNameAndNamespace(t *testing.T) {
 				require.NoError(t, err)
 			}
 
-			svcs, err := serviceInstancesForK8SServiceNameAndNamespace(k8sSvc, k8sNS, consulClient)
+			svcs, err := serviceInstancesForK8SServiceNameAndNamespace(context.Background(), k8sSvc, k8sNS, "", "", consulClient)
 			require.NoError(t, err)
 			if len(svcs) > 0 {
 				require.Len(t, svcs, 2)
@@ -4554,6 +8185,7 @@ func TestCreateServiceRegistrations_withTransparentProxy(t *testing.T) {
 		service             *corev1.Service
 		expTaggedAddresses  map[string]api.ServiceAddress
 		expProxyMode        api.ProxyMode
+		expTransparentProxy *api.TransparentProxyConfig
 		expExposePaths      []api.ExposePath
 		expErr              string
 	}{
@@ -5073,9 +8705,10 @@ func TestCreateServiceRegistrations_withTransparentProxy(t *testing.T) {
 					},
 				},
 			},
-			expProxyMode:       api.ProxyModeDefault,
-			expTaggedAddresses: nil,
-			expErr:             "",
+			expProxyMode:        api.ProxyModeDefault,
+			expTransparentProxy: &api.TransparentProxyConfig{DialedDirectly: true},
+			expTaggedAddresses:  nil,
+			expErr:              "",
 		},
 		"service with an empty clusterIP": {
 			tproxyGlobalEnabled: true,
@@ -5830,13 +9463,14 @@ func TestCreateServiceRegistrations_withTransparentProxy(t *testing.T) {
 				Log:                    logrtest.TestLogger{T: t},
 			}
 
-			serviceRegistration, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(*pod, *endpoints)
+			serviceRegistration, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(context.Background(), *pod, *endpoints)
 			if c.expErr != "" {
 				require.EqualError(t, err, c.expErr)
 			} else {
 				require.NoError(t, err)
 
 				require.Equal(t, c.expProxyMode, proxyServiceRegistration.Proxy.Mode)
+				require.Equal(t, c.expTransparentProxy, proxyServiceRegistration.Proxy.TransparentProxy)
 				require.Equal(t, c.expTaggedAddresses, serviceRegistration.TaggedAddresses)
 				require.Equal(t, c.expTaggedAddresses, proxyServiceRegistration.TaggedAddresses)
 				require.Equal(t, c.expExposePaths, proxyServiceRegistration.Proxy.Expose.Paths)
@@ -5845,6 +9479,88 @@ func TestCreateServiceRegistrations_withTransparentProxy(t *testing.T) {
 	}
 }
 
+func TestConsulSchemeAndPort(t *testing.T) {
+	cases := map[string]struct {
+		consulScheme string
+		consulPort   string
+		httpAddr     string
+		httpSSL      string
+		expScheme    string
+		expPort      string
+	}{
+		"defaults": {
+			expScheme: "http",
+			expPort:   "8500",
+		},
+		"explicit fields": {
+			consulScheme: "https",
+			consulPort:   "8501",
+			expScheme:    "https",
+			expPort:      "8501",
+		},
+		"explicit fields take precedence over env vars": {
+			consulScheme: "http",
+			consulPort:   "8500",
+			httpAddr:     "https://consul.example.com:8501",
+			httpSSL:      "true",
+			expScheme:    "http",
+			expPort:      "8500",
+		},
+		"env address with scheme and port": {
+			httpAddr:  "https://consul.example.com:8501",
+			expScheme: "https",
+			expPort:   "8501",
+		},
+		"env address without scheme": {
+			httpAddr:  "consul.example.com:9500",
+			expScheme: "http",
+			expPort:   "9500",
+		},
+		"env address without port": {
+			httpAddr:  "https://consul.example.com",
+			expScheme: "https",
+			expPort:   "8500",
+		},
+		"env SSL": {
+			httpAddr:  "consul.example.com:8501",
+			httpSSL:   "true",
+			expScheme: "https",
+			expPort:   "8501",
+		},
+		"env address scheme takes precedence over env SSL": {
+			httpAddr:  "http://consul.example.com:8500",
+			httpSSL:   "true",
+			expScheme: "http",
+			expPort:   "8500",
+		},
+		"env unix socket address": {
+			httpAddr:  "unix:///var/run/consul.sock",
+			expScheme: "http",
+			expPort:   "8500",
+		},
+		"only one explicit field": {
+			consulPort: "7500",
+			httpAddr:   "https://consul.example.com:8501",
+			expScheme:  "https",
+			expPort:    "7500",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(api.HTTPAddrEnvName, c.httpAddr)
+			t.Setenv(api.HTTPSSLEnvName, c.httpSSL)
+			ep := &EndpointsController{
+				ConsulScheme: c.consulScheme,
+				ConsulPort:   c.consulPort,
+			}
+
+			scheme, port := ep.consulSchemeAndPort()
+			require.Equal(t, c.expScheme, scheme)
+			require.Equal(t, c.expPort, port)
+		})
+	}
+}
+
 func TestGetTokenMetaFromDescription(t *testing.T) {
 	t.Parallel()
 	cases := map[string]struct {
@@ -5870,11 +9586,11 @@ func TestGetTokenMetaFromDescription(t *testing.T) {
 	}
 }
 
-func TestMapAddresses(t *testing.T) {
+func TestSubsetAddresses(t *testing.T) {
 	t.Parallel()
 	cases := map[string]struct {
 		addresses corev1.EndpointSubset
-		expected  map[corev1.EndpointAddress]string
+		expected  []subsetAddress
 	}{
 		"ready and not ready addresses": {
 			addresses: corev1.EndpointSubset{
@@ -5887,11 +9603,11 @@ func TestMapAddresses(t *testing.T) {
 					{Hostname: "host4"},
 				},
 			},
-			expected: map[corev1.EndpointAddress]string{
-				{Hostname: "host1"}: api.HealthPassing,
-				{Hostname: "host2"}: api.HealthPassing,
-				{Hostname: "host3"}: api.HealthCritical,
-				{Hostname: "host4"}: api.HealthCritical,
+			expected: []subsetAddress{
+				{address: corev1.EndpointAddress{Hostname: "host1"}, ready: true},
+				{address: corev1.EndpointAddress{Hostname: "host2"}, ready: true},
+				{address: corev1.EndpointAddress{Hostname: "host3"}, ready: false},
+				{address: corev1.EndpointAddress{Hostname: "host4"}, ready: false},
 			},
 		},
 		"ready addresses only": {
@@ -5904,11 +9620,11 @@ func TestMapAddresses(t *testing.T) {
 				},
 				NotReadyAddresses: []corev1.EndpointAddress{},
 			},
-			expected: map[corev1.EndpointAddress]string{
-				{Hostname: "host1"}: api.HealthPassing,
-				{Hostname: "host2"}: api.HealthPassing,
-				{Hostname: "host3"}: api.HealthPassing,
-				{Hostname: "host4"}: api.HealthPassing,
+			expected: []subsetAddress{
+				{address: corev1.EndpointAddress{Hostname: "host1"}, ready: true},
+				{address: corev1.EndpointAddress{Hostname: "host2"}, ready: true},
+				{address: corev1.EndpointAddress{Hostname: "host3"}, ready: true},
+				{address: corev1.EndpointAddress{Hostname: "host4"}, ready: true},
 			},
 		},
 		"not ready addresses only": {
@@ -5921,18 +9637,22 @@ func TestMapAddresses(t *testing.T) {
 					{Hostname: "host4"},
 				},
 			},
-			expected: map[corev1.EndpointAddress]string{
-				{Hostname: "host1"}: api.HealthCritical,
-				{Hostname: "host2"}: api.HealthCritical,
-				{Hostname: "host3"}: api.HealthCritical,
-				{Hostname: "host4"}: api.HealthCritical,
+			expected: []subsetAddress{
+				{address: corev1.EndpointAddress{Hostname: "host1"}, ready: false},
+				{address: corev1.EndpointAddress{Hostname: "host2"}, ready: false},
+				{address: corev1.EndpointAddress{Hostname: "host3"}, ready: false},
+				{address: corev1.EndpointAddress{Hostname: "host4"}, ready: false},
 			},
 		},
+		"no addresses": {
+			addresses: corev1.EndpointSubset{},
+			expected:  []subsetAddress{},
+		},
 	}
 
 	for name, c := range cases {
 		t.Run(name, func(t *testing.T) {
-			actual := mapAddresses(c.addresses)
+			actual := subsetAddresses(c.addresses)
 			require.Equal(t, c.expected, actual)
 		})
 	}
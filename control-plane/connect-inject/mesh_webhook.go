@@ -3,10 +3,13 @@ package connectinject
 import (
 	"context"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -29,6 +32,18 @@ import (
 // "system" level namespaces and are always skipped (never injected).
 var kubeSystemNamespaces = mapset.NewSetWith(metav1.NamespaceSystem, metav1.NamespacePublic)
 
+// defaultClusterDNSPort is the port of the cluster's DNS service unless
+// overridden by ClusterDNSPort.
+const defaultClusterDNSPort = 53
+
+// defaultEnvoyAdminBindAddress is the address Envoy's admin API binds to
+// unless overridden by EnvoyAdminBindAddress.
+const defaultEnvoyAdminBindAddress = "127.0.0.1"
+
+// defaultWaitForTimeout is how long the init container waits for the services in
+// the wait-for annotation to be registered unless overridden by WaitForTimeout.
+const defaultWaitForTimeout = 5 * time.Minute
+
 // Webhook is the HTTP meshWebhook for admission webhooks.
 type MeshWebhook struct {
 	ConsulClient *api.Client
@@ -49,6 +64,16 @@ type MeshWebhook struct {
 	// See a list of args here: https://www.envoyproxy.io/docs/envoy/latest/operations/cli
 	EnvoyExtraArgs string
 
+	// EnvoyAdminBindAddress is the address Envoy's admin API binds to. It is always
+	// used by multi port pods and, when set, by single port pods too.
+	// Defaults to 127.0.0.1.
+	EnvoyAdminBindAddress string
+
+	// BootstrapFileMode is the octal file mode, e.g. "0640", the init container sets on the
+	// Envoy bootstrap file and, when an auth method is used, the ACL token file it writes
+	// into the shared volume. If empty, the files keep the default permissions of the umask.
+	BootstrapFileMode string
+
 	// RequireAnnotation means that the annotation must be given to inject.
 	// If this is false, injection is default.
 	RequireAnnotation bool
@@ -62,6 +87,27 @@ type MeshWebhook struct {
 	// If not set, will use HTTP.
 	ConsulCACert string
 
+	// ConsulCACertFile is the path to a file containing the PEM-encoded CA certificate
+	// to use when communicating with Consul clients over HTTPS, e.g. a mounted secret.
+	// Environment variables in the path are expanded. If set, LoadConsulCACert must be
+	// called at startup and the file's contents are used as ConsulCACert.
+	ConsulCACertFile string
+
+	// ConsulGRPCTLS controls whether the init container connects to the Consul
+	// client's gRPC (xDS) port over TLS, independently of HTTP. If nil, gRPC uses
+	// TLS only when HTTP does, i.e. when ConsulCACert is set.
+	ConsulGRPCTLS *bool
+
+	// ConsulHTTPProxy is the URL of the proxy the init container uses to reach
+	// Consul, e.g. an egress proxy. If set, it is rendered as the HTTP_PROXY and
+	// HTTPS_PROXY environment variables of the init container.
+	ConsulHTTPProxy string
+
+	// ConsulNoProxy is a comma-separated list of hosts, IPs or CIDRs the init
+	// container reaches without going through ConsulHTTPProxy. The host IP that
+	// CONSUL_HTTP_ADDR points to is always included.
+	ConsulNoProxy string
+
 	// ConsulPartition is the name of the Admin Partition that the controller
 	// is deployed in. It is an enterprise feature requiring Consul Enterprise 1.11+.
 	// Its value is an empty string if partitions aren't enabled.
@@ -143,6 +189,20 @@ type MeshWebhook struct {
 	// to point them to the Envoy proxy.
 	TProxyOverwriteProbes bool
 
+	// TProxyNonPrivileged stops the init container that applies traffic redirection rules from running
+	// as a privileged container. It is given the NET_ADMIN and NET_RAW capabilities instead, for clusters
+	// that forbid privileged containers. It has no effect when CNI is enabled.
+	TProxyNonPrivileged bool
+
+	// TProxyDefaultExcludeUIDs is a list of user IDs to exclude from traffic redirection
+	// on every pod with transparent proxy enabled. These are merged with any UIDs
+	// provided via the pod annotation.
+	TProxyDefaultExcludeUIDs []string
+
+	// ClusterDNSPort is the port of the cluster's DNS service. It is excluded from outbound traffic
+	// redirection on pods with the transparent-proxy-exclude-dns annotation. Defaults to 53.
+	ClusterDNSPort int
+
 	// EnableConsulDNS enables traffic redirection so that DNS requests are directed to Consul
 	// from mesh services.
 	EnableConsulDNS bool
@@ -160,6 +220,38 @@ type MeshWebhook struct {
 	// wait for a response from the API before cancelling the request.
 	ConsulAPITimeout time.Duration
 
+	// ConnectInitPollTimeout is how long the connect-init container waits for the pod's service
+	// to be registered with Consul before failing. If zero, connect-init's default of 120s is used.
+	ConnectInitPollTimeout time.Duration
+
+	// WaitForTimeout is how long the init container waits for the services in the wait-for
+	// annotation to be registered before failing. Defaults to 5 minutes.
+	WaitForTimeout time.Duration
+
+	// ConnectInitLogLevel is the log level of the connect-init command, one of trace, debug,
+	// info, warn or error. If empty, connect-init's default of info is used.
+	ConnectInitLogLevel string
+
+	// SkipCopyContainer omits the init container that copies the Consul binary into the shared
+	// volume. It can be set when the image used for the connect-init container, e.g. a
+	// consul-dataplane image, already contains the Consul binary at /bin/consul.
+	SkipCopyContainer bool
+
+	// ConsulBinaryPath is the path of the Consul binary in the connect-init container's image when
+	// SkipCopyContainer is set. If empty, /bin/consul is used.
+	ConsulBinaryPath string
+
+	// AgentlessMode is set when the sidecar bootstraps Envoy itself, e.g. in consul-dataplane deployments
+	// without Consul client agents. The connect-init container then only waits for the service to be
+	// registered and doesn't write an Envoy bootstrap config, and the copy container is only added if
+	// the Consul binary is needed to apply traffic redirection rules.
+	AgentlessMode bool
+
+	// InitContainerExtraVolumeMounts are added to the connect-init container's volume mounts, e.g. to
+	// mount custom Envoy bootstrap templates or CA bundles. The volumes they refer to must exist in the pod.
+	// Their names must be unique and must not be the name of the shared connect-inject volume.
+	InitContainerExtraVolumeMounts []corev1.VolumeMount
+
 	// Log
 	Log logr.Logger
 	// Log settings for consul-sidecar
@@ -197,6 +289,14 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 		return admission.Errored(http.StatusBadRequest, err)
 	}
 
+	// Warn about annotations which look like typos of known annotations since they
+	// would otherwise be silently ignored. The warnings are returned to the client,
+	// e.g. kubectl, with the response.
+	warnings := validateAnnotations(pod)
+	for _, warning := range warnings {
+		w.Log.Info("pod has an unrecognized annotation", "warning", warning, "request name", req.Name)
+	}
+
 	// Setup the default annotation values that are used for the container.
 	// This MUST be done before shouldInject is called since that function
 	// uses these annotations.
@@ -211,7 +311,9 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 		w.Log.Error(err, "error checking if should inject", "request name", req.Name)
 		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error checking if should inject: %s", err))
 	} else if !shouldInject {
-		return admission.Allowed(fmt.Sprintf("%s %s does not require injection", pod.Kind, pod.Name))
+		resp := admission.Allowed(fmt.Sprintf("%s %s does not require injection", pod.Kind, pod.Name))
+		resp.Warnings = warnings
+		return resp
 	}
 
 	w.Log.Info("received pod", "name", req.Name, "ns", req.Namespace)
@@ -244,10 +346,6 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 		pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, containerEnvVars...)
 	}
 
-	// Add the init container which copies the Consul binary to /consul/connect-inject/.
-	initCopyContainer := w.initCopyContainer()
-	pod.Spec.InitContainers = append(pod.Spec.InitContainers, initCopyContainer)
-
 	// A user can enable/disable tproxy for an entire namespace via a label.
 	ns, err := w.Clientset.CoreV1().Namespaces().Get(ctx, req.Namespace, metav1.GetOptions{})
 	if err != nil {
@@ -255,13 +353,46 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error getting namespace metadata for container: %s", err))
 	}
 
+	connectNative, err := connectNativeEnabled(pod)
+	if err != nil {
+		w.Log.Error(err, "error checking if pod is connect native", "request name", req.Name)
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("error checking if pod is connect native: %s", err))
+	}
+	registerProxy, err := registerProxyEnabled(pod)
+	if err != nil {
+		w.Log.Error(err, "error checking if pod registers its proxy", "request name", req.Name)
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("error checking if pod registers its proxy: %s", err))
+	}
+	// Connect native pods don't need a sidecar proxy, and pods that don't register their proxy run one
+	// which is managed separately, so neither gets the sidecar or the init container that bootstraps it.
+	sidecar := !connectNative && registerProxy
+
+	// Add the init container which copies the Consul binary to /consul/connect-inject/.
+	// It isn't needed if the connect-init image already contains the Consul binary, if
+	// connect-init doesn't use the binary, or if the pod has no sidecar and so doesn't
+	// run connect-init.
+	if sidecar {
+		copyContainer, err := w.needsCopyContainer(*ns, pod)
+		if err != nil {
+			w.Log.Error(err, "error determining if the copy container is needed", "request name", req.Name)
+			return admission.Errored(http.StatusBadRequest, fmt.Errorf("error determining if the copy container is needed: %s", err))
+		}
+		if copyContainer {
+			initCopyContainer := w.initCopyContainer()
+			pod.Spec.InitContainers = append(pod.Spec.InitContainers, initCopyContainer)
+		}
+	}
+
 	// Get service names from the annotation. If theres 0-1 service names, it's a single port pod, otherwise it's multi
 	// port.
 	annotatedSvcNames := w.annotatedServiceNames(pod)
 	multiPort := len(annotatedSvcNames) > 1
 
+	// Pods without a sidecar get neither the envoy sidecar nor the init container that bootstraps it.
 	// For single port pods, add the single init container and envoy sidecar.
-	if !multiPort {
+	if !sidecar && !multiPort {
+		w.Log.Info("skipping sidecar injection for connect native or service only pod", "request name", req.Name)
+	} else if !multiPort {
 		// Add the init container that registers the service and sets up the Envoy configuration.
 		initContainer, err := w.containerInit(*ns, pod, multiPortInfo{})
 		if err != nil {
@@ -381,8 +512,8 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 	}
 
 	// Add an annotation to the pod sets transparent-proxy-status to enabled or disabled. Used by the CNI plugin
-	// to determine if it should traffic redirect or not
-	if tproxyEnabled {
+	// to determine if it should traffic redirect or not. Pods without a sidecar have no proxy to redirect traffic to.
+	if tproxyEnabled && sidecar {
 		pod.Annotations[keyTransparentProxyStatus] = enabled
 	}
 
@@ -401,21 +532,24 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 	// from consul-k8s without Endpoints controller to consul-k8s with Endpoints controller.
 	pod.Labels[keyManagedBy] = managedByValue
 
-	// Consul-ENT only: Add the Consul destination namespace as an annotation to the pod.
+	// Consul-ENT only: Add the Consul destination namespace as an annotation to the pod
+	// unless the pod already overrides it.
 	if w.EnableNamespaces {
-		pod.Annotations[annotationConsulNamespace] = w.consulNamespace(req.Namespace)
+		pod.Annotations[annotationConsulNamespace] = w.podConsulNamespace(pod, req.Namespace)
 	}
 
-	// Overwrite readiness/liveness probes if needed.
-	err = w.overwriteProbes(*ns, &pod)
-	if err != nil {
-		w.Log.Error(err, "error overwriting readiness or liveness probes", "request name", req.Name)
-		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error overwriting readiness or liveness probes: %s", err))
+	// Overwrite readiness/liveness probes if needed. Pods without a sidecar have no proxy to expose them through.
+	if sidecar {
+		err = w.overwriteProbes(*ns, &pod)
+		if err != nil {
+			w.Log.Error(err, "error overwriting readiness or liveness probes", "request name", req.Name)
+			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error overwriting readiness or liveness probes: %s", err))
+		}
 	}
 
 	// When CNI and tproxy are enabled, we add an annotation to the pod that contains the iptables config so that the CNI
 	// plugin can apply redirect traffic rules on the pod.
-	if w.EnableCNI && tproxyEnabled {
+	if w.EnableCNI && tproxyEnabled && sidecar {
 		if err := w.addRedirectTrafficConfigAnnotation(&pod, *ns); err != nil {
 			// todo: update this error message
 			w.Log.Error(err, "error configuring annotation for CNI traffic redirection", "request name", req.Name)
@@ -441,16 +575,18 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 	// all patches are created to guarantee no errors were encountered in
 	// that process before modifying the Consul cluster.
 	if w.EnableNamespaces {
-		if _, err := namespaces.EnsureExists(w.ConsulClient, w.consulNamespace(req.Namespace), w.CrossNamespaceACLPolicy); err != nil {
+		if _, err := namespaces.EnsureExists(w.ConsulClient, w.podConsulNamespace(pod, req.Namespace), w.CrossNamespaceACLPolicy); err != nil {
 			w.Log.Error(err, "error checking or creating namespace",
-				"ns", w.consulNamespace(req.Namespace), "request name", req.Name)
+				"ns", w.podConsulNamespace(pod, req.Namespace), "request name", req.Name)
 			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error checking or creating namespace: %s", err))
 		}
 	}
 
 	// Return a Patched response along with the patches we intend on applying to the
 	// Pod received by the meshWebhook.
-	return admission.Patched(fmt.Sprintf("valid %s request", pod.Kind), patches...)
+	resp := admission.Patched(fmt.Sprintf("valid %s request", pod.Kind), patches...)
+	resp.Warnings = warnings
+	return resp
 }
 
 // shouldOverwriteProbes returns true if we need to overwrite readiness/liveness probes for this pod.
@@ -463,6 +599,38 @@ func shouldOverwriteProbes(pod corev1.Pod, globalOverwrite bool) (bool, error) {
 	return globalOverwrite, nil
 }
 
+// shouldExcludeDNS returns true if the cluster DNS port should be excluded from
+// outbound traffic redirection for the pod.
+func shouldExcludeDNS(pod corev1.Pod) (bool, error) {
+	raw, ok := pod.Annotations[annotationTProxyExcludeDNS]
+	if !ok {
+		return false, nil
+	}
+	exclude, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("%s annotation value %q is invalid: %s", annotationTProxyExcludeDNS, raw, err)
+	}
+	return exclude, nil
+}
+
+// clusterDNSPort returns the port of the cluster's DNS service.
+func (w *MeshWebhook) clusterDNSPort() string {
+	if w.ClusterDNSPort > 0 {
+		return strconv.Itoa(w.ClusterDNSPort)
+	}
+	return strconv.Itoa(defaultClusterDNSPort)
+}
+
+// connectNativeEnabled returns true if the pod's service speaks the Connect protocol natively
+// and so doesn't need a sidecar proxy.
+func connectNativeEnabled(pod corev1.Pod) (bool, error) {
+	if raw, ok := pod.Annotations[annotationConnectServiceNative]; ok {
+		return strconv.ParseBool(raw)
+	}
+
+	return false, nil
+}
+
 // overwriteProbes overwrites readiness/liveness probes of this pod when
 // both transparent proxy is enabled and overwrite probes is true for the pod.
 func (w *MeshWebhook) overwriteProbes(ns corev1.Namespace, pod *corev1.Pod) error {
@@ -546,8 +714,10 @@ func (w *MeshWebhook) defaultAnnotations(pod *corev1.Pod, podJson string) error
 		pod.Annotations = make(map[string]string)
 	}
 
-	// Default service port is the first port exported in the container
-	if _, ok := pod.ObjectMeta.Annotations[annotationPort]; !ok {
+	// Default service port is the first port exported in the container, unless the
+	// service listens on a Unix socket instead.
+	_, hasSocketPath := pod.ObjectMeta.Annotations[annotationServiceSocketPath]
+	if _, ok := pod.ObjectMeta.Annotations[annotationPort]; !ok && !hasSocketPath {
 		if cs := pod.Spec.Containers; len(cs) > 0 {
 			if ps := cs[0].Ports; len(ps) > 0 {
 				if ps[0].Name != "" {
@@ -591,18 +761,116 @@ func (w *MeshWebhook) consulNamespace(ns string) string {
 	return namespaces.ConsulNamespace(ns, w.EnableNamespaces, w.ConsulDestinationNamespace, w.EnableK8SNSMirroring, w.K8SNSMirroringPrefix)
 }
 
+// podConsulNamespace returns the Consul namespace the pod's services are registered in. The
+// consul.hashicorp.com/consul-namespace annotation overrides the namespace derived from the
+// pod's Kubernetes namespace ns.
+func (w *MeshWebhook) podConsulNamespace(pod corev1.Pod, ns string) string {
+	if !w.EnableNamespaces {
+		return ""
+	}
+	if raw, ok := pod.Annotations[annotationConsulNamespace]; ok && raw != "" {
+		return raw
+	}
+	return w.consulNamespace(ns)
+}
+
+// podConsulPartition returns the Consul Admin Partition the pod's services are registered in. The
+// consul.hashicorp.com/consul-partition annotation overrides the controller's partition.
+func (w *MeshWebhook) podConsulPartition(pod corev1.Pod) string {
+	if w.ConsulPartition == "" {
+		return ""
+	}
+	if raw, ok := pod.Annotations[annotationConsulPartition]; ok && raw != "" {
+		return raw
+	}
+	return w.ConsulPartition
+}
+
+// validConsulNamespaceName matches the names Consul accepts for namespaces.
+var validConsulNamespaceName = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,62}[a-zA-Z0-9])?$`)
+
+// validateConsulNamespaceAnnotation returns an error if the value of the Consul namespace annotation
+// is not a valid Consul namespace name.
+func validateConsulNamespaceAnnotation(raw string) error {
+	if !validConsulNamespaceName.MatchString(raw) {
+		return fmt.Errorf("%s annotation value %q is invalid: must be at most 64 alphanumeric characters or dashes and must start and end with an alphanumeric character",
+			annotationConsulNamespace, raw)
+	}
+	return nil
+}
+
+// validateConsulPartitionAnnotation returns an error if the value of the Consul partition annotation
+// is not a valid Admin Partition name. Partition names follow the same rules as namespace names.
+func validateConsulPartitionAnnotation(raw string) error {
+	if !validConsulNamespaceName.MatchString(raw) {
+		return fmt.Errorf("%s annotation value %q is invalid: must be at most 64 alphanumeric characters or dashes and must start and end with an alphanumeric character",
+			annotationConsulPartition, raw)
+	}
+	return nil
+}
+
 func (w *MeshWebhook) validatePod(pod corev1.Pod) error {
-	if _, ok := pod.Annotations[annotationProtocol]; ok {
-		return fmt.Errorf("the %q annotation is no longer supported. Instead, create a ServiceDefaults resource (see www.consul.io/docs/k8s/crds/upgrade-to-crds)",
-			annotationProtocol)
+	if _, err := serviceProtocol(pod); err != nil {
+		return err
 	}
 
 	if _, ok := pod.Annotations[annotationSyncPeriod]; ok {
 		return fmt.Errorf("the %q annotation is no longer supported because consul-sidecar is no longer injected to periodically register services", annotationSyncPeriod)
 	}
+
+	if raw, ok := pod.Annotations[annotationConsulNamespace]; ok && w.EnableNamespaces {
+		if err := validateConsulNamespaceAnnotation(raw); err != nil {
+			return err
+		}
+	}
+
+	if raw, ok := pod.Annotations[annotationConsulPartition]; ok && w.ConsulPartition != "" {
+		if err := validateConsulPartitionAnnotation(raw); err != nil {
+			return err
+		}
+	}
+
+	if _, err := serviceSocketPath(pod); err != nil {
+		return err
+	}
+
+	// The wait loop runs the Consul binary against the local client agent, which doesn't exist in agentless mode.
+	if _, ok := pod.Annotations[annotationWaitFor]; ok && w.AgentlessMode {
+		return fmt.Errorf("the %q annotation is not supported in agentless mode", annotationWaitFor)
+	}
 	return nil
 }
 
+// serviceSocketPath returns the path of the Unix socket the service listens on, or an empty
+// string if the service listens on a port. The socket path may not be set along with a port.
+func serviceSocketPath(pod corev1.Pod) (string, error) {
+	raw, ok := pod.Annotations[annotationServiceSocketPath]
+	if !ok {
+		return "", nil
+	}
+	if !filepath.IsAbs(raw) {
+		return "", fmt.Errorf("%s annotation value %q is invalid: must be an absolute path", annotationServiceSocketPath, raw)
+	}
+	if port, ok := pod.Annotations[annotationPort]; ok && port != "" {
+		return "", fmt.Errorf("%s and %s annotations may not be used together", annotationServiceSocketPath, annotationPort)
+	}
+	return raw, nil
+}
+
+// serviceProtocol returns the protocol of the service set by the connect-service-protocol annotation, or an
+// empty string if it isn't set.
+func serviceProtocol(pod corev1.Pod) (string, error) {
+	raw, ok := pod.Annotations[annotationProtocol]
+	if !ok || raw == "" {
+		return "", nil
+	}
+	switch raw {
+	case "http", "http2", "grpc", "tcp":
+		return raw, nil
+	}
+	return "", fmt.Errorf("%s annotation value %q is invalid: must be one of \"http\", \"http2\", \"grpc\", or \"tcp\"", annotationProtocol, raw)
+}
+
 func portValue(pod corev1.Pod, value string) (int32, error) {
 	value = strings.Split(value, ",")[0]
 	// First search for the named port.
@@ -686,6 +954,82 @@ func (w *MeshWebhook) checkUnsupportedMultiPortCases(ns corev1.Namespace, pod co
 	if metricsMergingEnabled {
 		return fmt.Errorf("multi port services are not compatible with metrics merging")
 	}
+	connectNative, err := connectNativeEnabled(pod)
+	if err != nil {
+		return fmt.Errorf("couldn't check if connect native is enabled: %s", err)
+	}
+	if connectNative {
+		return fmt.Errorf("multi port services are not compatible with connect native")
+	}
+	registerProxy, err := registerProxyEnabled(pod)
+	if err != nil {
+		return fmt.Errorf("couldn't check if the proxy is registered: %s", err)
+	}
+	if !registerProxy {
+		return fmt.Errorf("multi port services are not compatible with %s=false", annotationRegisterProxy)
+	}
+	return nil
+}
+
+// consulGRPCTLS returns whether the init container connects to the Consul client's
+// gRPC port over TLS. It follows the HTTP scheme unless ConsulGRPCTLS is set.
+func (w *MeshWebhook) consulGRPCTLS() bool {
+	if w.ConsulGRPCTLS != nil {
+		return *w.ConsulGRPCTLS
+	}
+	return w.ConsulCACert != ""
+}
+
+// waitForTimeout returns how long the init container waits for the services in the wait-for annotation.
+func (w *MeshWebhook) waitForTimeout() time.Duration {
+	if w.WaitForTimeout > 0 {
+		return w.WaitForTimeout
+	}
+	return defaultWaitForTimeout
+}
+
+// envoyAdminBindAddress returns the address Envoy's admin API binds to for the
+// pod. Multi port pods always render -admin-bind so they fall back to the
+// default address, while single port pods only render it when configured.
+func (w *MeshWebhook) envoyAdminBindAddress(multiPort bool) string {
+	if w.EnvoyAdminBindAddress == "" && multiPort {
+		return defaultEnvoyAdminBindAddress
+	}
+	return w.EnvoyAdminBindAddress
+}
+
+// consulNoProxy returns the NO_PROXY list for the init container given the
+// reference to the host IP in the syntax of where it is rendered. The host IP
+// comes first so that the Consul client on the node is never proxied.
+func (w *MeshWebhook) consulNoProxy(hostIP string) string {
+	noProxy := []string{hostIP}
+	for _, item := range strings.Split(w.ConsulNoProxy, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			noProxy = append(noProxy, item)
+		}
+	}
+	return strings.Join(noProxy, ",")
+}
+
+// LoadConsulCACert reads the CA certificate from ConsulCACertFile and sets it as
+// ConsulCACert. It returns an error if the file can't be read or doesn't contain
+// a PEM-encoded block. It does nothing if ConsulCACertFile is not set.
+func (w *MeshWebhook) LoadConsulCACert() error {
+	if w.ConsulCACertFile == "" {
+		return nil
+	}
+
+	path := os.ExpandEnv(w.ConsulCACertFile)
+	caCert, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading Consul's CA cert file %q: %s", path, err)
+	}
+	// The _ result below is not an error but the remaining PEM bytes.
+	if block, _ := pem.Decode(caCert); block == nil {
+		return fmt.Errorf("Consul's CA cert file %q does not contain PEM-encoded data", path)
+	}
+
+	w.ConsulCACert = string(caCert)
 	return nil
 }
 
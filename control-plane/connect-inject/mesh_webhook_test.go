@@ -3,11 +3,15 @@ package connectinject
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	mapset "github.com/deckarep/golang-set"
 	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/cert"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	"github.com/stretchr/testify/require"
 	"gomodules.xyz/jsonpatch/v2"
@@ -187,6 +191,152 @@ func TestHandlerHandle(t *testing.T) {
 			},
 		},
 
+		{
+			"connect native pod does not get a sidecar",
+			MeshWebhook{
+				Log:                   logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+				Clientset:             defaultTestClientWithNamespace(),
+			},
+			admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: namespaces.DefaultNamespace,
+					Object: encodeRaw(t, &corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								annotationConnectServiceNative: "true",
+							},
+						},
+						Spec: basicSpec,
+					}),
+				},
+			},
+			"",
+			[]jsonpatch.Operation{
+				{
+					Operation: "add",
+					Path:      "/metadata/labels",
+				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(keyInjectStatus),
+				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(annotationOriginalPod),
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/volumes",
+				},
+			},
+		},
+
+		{
+			"pod that doesn't register its proxy does not get a sidecar",
+			MeshWebhook{
+				Log:                   logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+				Clientset:             defaultTestClientWithNamespace(),
+			},
+			admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: namespaces.DefaultNamespace,
+					Object: encodeRaw(t, &corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								annotationRegisterProxy: "false",
+							},
+						},
+						Spec: basicSpec,
+					}),
+				},
+			},
+			"",
+			[]jsonpatch.Operation{
+				{
+					Operation: "add",
+					Path:      "/metadata/labels",
+				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(keyInjectStatus),
+				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(annotationOriginalPod),
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/volumes",
+				},
+			},
+		},
+
+		{
+			"connect native pod with tproxy and CNI enabled does not get traffic redirection",
+			MeshWebhook{
+				Log:                    logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet:  mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:   mapset.NewSet(),
+				EnableTransparentProxy: true,
+				TProxyOverwriteProbes:  true,
+				EnableCNI:              true,
+				decoder:                decoder,
+				Clientset:              defaultTestClientWithNamespace(),
+			},
+			admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: namespaces.DefaultNamespace,
+					Object: encodeRaw(t, &corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								annotationConnectServiceNative: "true",
+							},
+						},
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{
+								{
+									Name: "web",
+									ReadinessProbe: &corev1.Probe{
+										Handler: corev1.Handler{
+											HTTPGet: &corev1.HTTPGetAction{
+												Port: intstr.FromInt(8080),
+											},
+										},
+									},
+								},
+							},
+						},
+					}),
+				},
+			},
+			"",
+			// There is no copy container, transparent proxy status, redirect traffic config, or overwritten probe.
+			[]jsonpatch.Operation{
+				{
+					Operation: "add",
+					Path:      "/metadata/labels",
+				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(keyInjectStatus),
+				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(annotationOriginalPod),
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/volumes",
+				},
+			},
+		},
+
 		{
 			"empty pod with injection disabled",
 			MeshWebhook{
@@ -880,13 +1030,6 @@ func TestHandler_ErrorsOnDeprecatedAnnotations(t *testing.T) {
 		annotations map[string]string
 		expErr      string
 	}{
-		{
-			"default protocol annotation",
-			map[string]string{
-				annotationProtocol: "http",
-			},
-			"the \"consul.hashicorp.com/connect-service-protocol\" annotation is no longer supported. Instead, create a ServiceDefaults resource (see www.consul.io/docs/k8s/crds/upgrade-to-crds)",
-		},
 		{
 			"sync period annotation",
 			map[string]string{
@@ -939,6 +1082,143 @@ func TestHandler_ErrorsOnDeprecatedAnnotations(t *testing.T) {
 	}
 }
 
+// Test that misspelled annotations are returned as warnings with the response.
+func TestHandler_WarnsOnMisspelledAnnotations(t *testing.T) {
+	require := require.New(t)
+	s := runtime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{
+		Group:   "",
+		Version: "v1",
+	}, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(err)
+
+	webhook := MeshWebhook{
+		Log:                   logrtest.TestLogger{T: t},
+		AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:  mapset.NewSet(),
+		RequireAnnotation:     true,
+		decoder:               decoder,
+	}
+
+	request := admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Namespace: "default",
+			Object: encodeRaw(t, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"consul.hashicorp.com/connect-injct": "true",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+						},
+					},
+				},
+			}),
+		},
+	}
+
+	// The misspelled annotation doesn't enable injection, so the pod is allowed
+	// without being injected.
+	response := webhook.Handle(context.Background(), request)
+	require.True(response.Allowed)
+	require.Empty(response.Patches)
+	require.Equal([]string{
+		`unrecognized annotation "consul.hashicorp.com/connect-injct" has no effect, did you mean "consul.hashicorp.com/connect-inject"?`,
+	}, response.Warnings)
+}
+
+// Test that we error out when the Consul namespace annotation is not a valid namespace name.
+func TestHandler_ErrorsOnInvalidConsulNamespaceAnnotation(t *testing.T) {
+	require := require.New(t)
+	s := runtime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{
+		Group:   "",
+		Version: "v1",
+	}, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(err)
+
+	webhook := MeshWebhook{
+		Log:                   logrtest.TestLogger{T: t},
+		AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:  mapset.NewSet(),
+		EnableNamespaces:      true,
+		decoder:               decoder,
+	}
+
+	request := admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Namespace: "default",
+			Object: encodeRaw(t, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotationConsulNamespace: "-team-a",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+						},
+					},
+				},
+			}),
+		},
+	}
+
+	response := webhook.Handle(context.Background(), request)
+	require.False(response.Allowed)
+	require.Equal(`consul.hashicorp.com/consul-namespace annotation value "-team-a" is invalid: must be at most 64 alphanumeric characters or dashes and must start and end with an alphanumeric character`, response.Result.Message)
+}
+
+// Test that we error out when the Consul partition annotation is not a valid partition name.
+func TestHandler_ErrorsOnInvalidConsulPartitionAnnotation(t *testing.T) {
+	require := require.New(t)
+	s := runtime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{
+		Group:   "",
+		Version: "v1",
+	}, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(err)
+
+	webhook := MeshWebhook{
+		Log:                   logrtest.TestLogger{T: t},
+		AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:  mapset.NewSet(),
+		ConsulPartition:       "default",
+		decoder:               decoder,
+	}
+
+	request := admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Namespace: "default",
+			Object: encodeRaw(t, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotationConsulPartition: "-team-a",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+						},
+					},
+				},
+			}),
+		},
+	}
+
+	response := webhook.Handle(context.Background(), request)
+	require.False(response.Allowed)
+	require.Equal(`consul.hashicorp.com/consul-partition annotation value "-team-a" is invalid: must be at most 64 alphanumeric characters or dashes and must start and end with an alphanumeric character`, response.Result.Message)
+}
+
 func TestHandlerDefaultAnnotations(t *testing.T) {
 	cases := []struct {
 		Name     string
@@ -1055,6 +1335,34 @@ func TestHandlerDefaultAnnotations(t *testing.T) {
 			},
 			"",
 		},
+
+		{
+			"basic pod, with ports and socket path",
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotationServiceSocketPath: "/var/run/web.sock",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+							Ports: []corev1.ContainerPort{
+								{
+									ContainerPort: 8080,
+								},
+							},
+						},
+					},
+				},
+			},
+			map[string]string{
+				annotationServiceSocketPath: "/var/run/web.sock",
+				annotationOriginalPod:       "{\"metadata\":{\"creationTimestamp\":null,\"annotations\":{\"consul.hashicorp.com/service-socket-path\":\"/var/run/web.sock\"}},\"spec\":{\"containers\":[{\"name\":\"web\",\"ports\":[{\"containerPort\":8080}],\"resources\":{}}]},\"status\":{}}",
+			},
+			"",
+		},
 	}
 
 	for _, tt := range cases {
@@ -1954,6 +2262,16 @@ func TestHandler_checkUnsupportedMultiPortCases(t *testing.T) {
 			annotations: map[string]string{annotationEnableMetricsMerging: "true"},
 			expErr:      "multi port services are not compatible with metrics merging",
 		},
+		{
+			name:        "connect native",
+			annotations: map[string]string{annotationConnectServiceNative: "true"},
+			expErr:      "multi port services are not compatible with connect native",
+		},
+		{
+			name:        "register proxy disabled",
+			annotations: map[string]string{annotationRegisterProxy: "false"},
+			expErr:      "multi port services are not compatible with consul.hashicorp.com/register-proxy=false",
+		},
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
@@ -2061,3 +2379,165 @@ func clientWithNamespace(name string) kubernetes.Interface {
 	}
 	return fake.NewSimpleClientset(&ns)
 }
+
+func TestHandlerLoadConsulCACert(t *testing.T) {
+	_, _, caCertPem, _, err := cert.GenerateCA("Consul Agent CA - Test")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, []byte(caCertPem), 0600))
+	invalidFile := filepath.Join(dir, "invalid.pem")
+	require.NoError(t, os.WriteFile(invalidFile, []byte("not a certificate"), 0600))
+	t.Setenv("TEST_CONSUL_CA_DIR", dir)
+
+	cases := []struct {
+		name     string
+		caFile   string
+		expected string
+		expErr   string
+	}{
+		{
+			name:     "no file",
+			caFile:   "",
+			expected: "",
+		},
+		{
+			name:     "valid file",
+			caFile:   caFile,
+			expected: caCertPem,
+		},
+		{
+			name:     "environment variables are expanded",
+			caFile:   "${TEST_CONSUL_CA_DIR}/ca.pem",
+			expected: caCertPem,
+		},
+		{
+			name:   "missing file",
+			caFile: filepath.Join(dir, "missing.pem"),
+			expErr: "error reading Consul's CA cert file",
+		},
+		{
+			name:   "not PEM-encoded",
+			caFile: invalidFile,
+			expErr: fmt.Sprintf("Consul's CA cert file %q does not contain PEM-encoded data", invalidFile),
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			w := MeshWebhook{ConsulCACertFile: tt.caFile}
+			err := w.LoadConsulCACert()
+			if tt.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, w.ConsulCACert)
+		})
+	}
+}
+
+func TestServiceSocketPath(t *testing.T) {
+	cases := map[string]struct {
+		annotations map[string]string
+		expPath     string
+		expErr      string
+	}{
+		"not set": {
+			annotations: map[string]string{},
+		},
+		"socket path": {
+			annotations: map[string]string{annotationServiceSocketPath: "/var/run/web.sock"},
+			expPath:     "/var/run/web.sock",
+		},
+		"relative path": {
+			annotations: map[string]string{annotationServiceSocketPath: "web.sock"},
+			expErr:      `consul.hashicorp.com/service-socket-path annotation value "web.sock" is invalid: must be an absolute path`,
+		},
+		"socket path with port": {
+			annotations: map[string]string{annotationServiceSocketPath: "/var/run/web.sock", annotationPort: "8080"},
+			expErr:      "consul.hashicorp.com/service-socket-path and consul.hashicorp.com/connect-service-port annotations may not be used together",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: c.annotations}}
+			socketPath, err := serviceSocketPath(pod)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expPath, socketPath)
+
+			w := MeshWebhook{}
+			require.NoError(t, w.validatePod(pod))
+		})
+	}
+}
+
+func TestValidatePod_waitFor(t *testing.T) {
+	cases := map[string]struct {
+		agentlessMode bool
+		expErr        string
+	}{
+		"client agents": {},
+		"agentless mode": {
+			agentlessMode: true,
+			expErr:        `the "consul.hashicorp.com/connect-inject-wait-for" annotation is not supported in agentless mode`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{annotationWaitFor: "db"}}}
+			w := MeshWebhook{AgentlessMode: c.agentlessMode}
+			err := w.validatePod(pod)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestServiceProtocol(t *testing.T) {
+	cases := map[string]struct {
+		annotations map[string]string
+		expProtocol string
+		expErr      string
+	}{
+		"not set": {
+			annotations: map[string]string{},
+		},
+		"http": {
+			annotations: map[string]string{annotationProtocol: "http"},
+			expProtocol: "http",
+		},
+		"grpc": {
+			annotations: map[string]string{annotationProtocol: "grpc"},
+			expProtocol: "grpc",
+		},
+		"invalid": {
+			annotations: map[string]string{annotationProtocol: "udp"},
+			expErr:      `consul.hashicorp.com/connect-service-protocol annotation value "udp" is invalid: must be one of "http", "http2", "grpc", or "tcp"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: c.annotations}}
+			protocol, err := serviceProtocol(pod)
+			w := MeshWebhook{}
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				require.EqualError(t, w.validatePod(pod), c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expProtocol, protocol)
+			require.NoError(t, w.validatePod(pod))
+		})
+	}
+}
@@ -276,6 +276,19 @@ func TestMetricsConfigShouldRunMergedMetricsServer(t *testing.T) {
 			},
 			Expected: true,
 		},
+		{
+			Name: "Returns false when metrics merging is disabled by annotation even though it's enabled by default",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[annotationPort] = "1234"
+				pod.Annotations[annotationEnableMetricsMerging] = "false"
+				return pod
+			},
+			MetricsConfig: MetricsConfig{
+				DefaultEnableMetrics:        true,
+				DefaultEnableMetricsMerging: true,
+			},
+			Expected: false,
+		},
 		{
 			Name: "Returns false when service metrics port is 0",
 			Pod: func(pod *corev1.Pod) *corev1.Pod {
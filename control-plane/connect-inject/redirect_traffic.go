@@ -17,9 +17,9 @@ import (
 //   ProxyInboundPort: the service port or bind port
 //   ProxyOutboundPort: default transparent proxy outbound port or transparent proxy outbound listener port
 //   ExcludeInboundPorts: prometheus, envoy stats, expose paths, checks and excluded pod annotations
-//   ExcludeOutboundPorts: pod annotations
+//   ExcludeOutboundPorts: pod annotations and the cluster DNS port if requested
 //   ExcludeOutboundCIDRs: pod annotations
-//   ExcludeUIDs: pod annotations
+//   ExcludeUIDs: default excluded UIDs and pod annotations
 func (w *MeshWebhook) addRedirectTrafficConfigAnnotation(pod *corev1.Pod, ns corev1.Namespace) error {
 	cfg := iptables.Config{
 		ProxyUserID: strconv.Itoa(envoyUserAndGroupID),
@@ -76,12 +76,21 @@ func (w *MeshWebhook) addRedirectTrafficConfigAnnotation(pod *corev1.Pod, ns cor
 	excludeOutboundPorts := splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeOutboundPorts, *pod)
 	cfg.ExcludeOutboundPorts = append(cfg.ExcludeOutboundPorts, excludeOutboundPorts...)
 
+	// Exclude the cluster DNS port from outbound redirection if requested.
+	excludeDNS, err := shouldExcludeDNS(*pod)
+	if err != nil {
+		return err
+	}
+	if excludeDNS {
+		cfg.ExcludeOutboundPorts = append(cfg.ExcludeOutboundPorts, w.clusterDNSPort())
+	}
+
 	// Outbound CIDRs
 	excludeOutboundCIDRs := splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeOutboundCIDRs, *pod)
 	cfg.ExcludeOutboundCIDRs = append(cfg.ExcludeOutboundCIDRs, excludeOutboundCIDRs...)
 
-	// UIDs
-	excludeUIDs := splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeUIDs, *pod)
+	// UIDs excluded by default and by pod annotations
+	excludeUIDs := mergeExcludeUIDs(w.TProxyDefaultExcludeUIDs, splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeUIDs, *pod))
 	cfg.ExcludeUIDs = append(cfg.ExcludeUIDs, excludeUIDs...)
 
 	// Add init container user ID to exclude from traffic redirection.
@@ -353,6 +353,134 @@ func TestAddRedirectTrafficConfig(t *testing.T) {
 				ExcludeUIDs:          []string{"4444", "44444", strconv.Itoa(initContainersUserAndGroupID)},
 			},
 		},
+		{
+			name: "exclude dns",
+			webhook: MeshWebhook{
+				Log:                   logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						annotationTProxyExcludeOutboundPorts: "2222",
+						annotationTProxyExcludeDNS:           "true",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+						},
+					},
+				},
+			},
+			expCfg: iptables.Config{
+				ConsulDNSIP:          "",
+				ProxyUserID:          strconv.Itoa(envoyUserAndGroupID),
+				ProxyInboundPort:     proxyDefaultInboundPort,
+				ProxyOutboundPort:    iptables.DefaultTProxyOutboundPort,
+				ExcludeOutboundPorts: []string{"2222", "53"},
+				ExcludeUIDs:          []string{strconv.Itoa(initContainersUserAndGroupID)},
+			},
+		},
+		{
+			name: "exclude dns with custom cluster DNS port",
+			webhook: MeshWebhook{
+				Log:                   logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+				ClusterDNSPort:        5353,
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						annotationTProxyExcludeDNS: "true",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+						},
+					},
+				},
+			},
+			expCfg: iptables.Config{
+				ConsulDNSIP:          "",
+				ProxyUserID:          strconv.Itoa(envoyUserAndGroupID),
+				ProxyInboundPort:     proxyDefaultInboundPort,
+				ProxyOutboundPort:    iptables.DefaultTProxyOutboundPort,
+				ExcludeOutboundPorts: []string{"5353"},
+				ExcludeUIDs:          []string{strconv.Itoa(initContainersUserAndGroupID)},
+			},
+		},
+		{
+			name: "default exclude UIDs merged with annotation",
+			webhook: MeshWebhook{
+				Log:                      logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet:    mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:     mapset.NewSet(),
+				decoder:                  decoder,
+				TProxyDefaultExcludeUIDs: []string{"1234", "4444"},
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						annotationTProxyExcludeUIDs: "4444,44444",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+						},
+					},
+				},
+			},
+			expCfg: iptables.Config{
+				ConsulDNSIP:       "",
+				ProxyUserID:       strconv.Itoa(envoyUserAndGroupID),
+				ProxyInboundPort:  proxyDefaultInboundPort,
+				ProxyOutboundPort: iptables.DefaultTProxyOutboundPort,
+				ExcludeUIDs:       []string{"1234", "4444", "44444", strconv.Itoa(initContainersUserAndGroupID)},
+			},
+		},
+		{
+			name: "exclude dns with invalid annotation",
+			webhook: MeshWebhook{
+				Log:                   logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						annotationTProxyExcludeDNS: "invalid",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+						},
+					},
+				},
+			},
+			expErr: fmt.Errorf("%s annotation value %q is invalid: %s", annotationTProxyExcludeDNS, "invalid",
+				`strconv.ParseBool: parsing "invalid": invalid syntax`),
+		},
 		{
 			name:       "dns enabled",
 			dnsEnabled: true,
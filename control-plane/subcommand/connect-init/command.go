@@ -25,6 +25,9 @@ const (
 
 	// The number of times to attempt to read this service (120s).
 	defaultServicePollingRetries = 120
+
+	// The interval between attempts to read this service.
+	servicePollingInterval = 1 * time.Second
 )
 
 type Command struct {
@@ -44,7 +47,8 @@ type Command struct {
 	flagACLTokenSink                   string // Location to write the output token. Default is defaultTokenSinkFile.
 	flagProxyIDFile                    string // Location to write the output proxyID. Default is defaultProxyIDFile.
 	flagMultiPort                      bool
-	serviceRegistrationPollingAttempts uint64 // Number of times to poll for this service to be registered.
+	flagPollTimeout                    time.Duration // How long to poll for this service to be registered.
+	serviceRegistrationPollingAttempts uint64        // Number of times to poll for this service to be registered.
 
 	flagSet *flag.FlagSet
 	http    *flags.HTTPFlags
@@ -67,6 +71,8 @@ func (c *Command) init() {
 	c.flagSet.StringVar(&c.flagACLTokenSink, "acl-token-sink", defaultTokenSinkFile, "File name where where ACL token should be saved.")
 	c.flagSet.StringVar(&c.flagProxyIDFile, "proxy-id-file", defaultProxyIDFile, "File name where proxy's Consul service ID should be saved.")
 	c.flagSet.BoolVar(&c.flagMultiPort, "multiport", false, "If the pod is a multi port pod.")
+	c.flagSet.DurationVar(&c.flagPollTimeout, "poll-timeout", 0,
+		"How long to wait for the pod's service to be registered before failing, e.g. \"30s\". Defaults to 120s.")
 	c.flagSet.StringVar(&c.flagLogLevel, "log-level", "info",
 		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
 			"\"debug\", \"info\", \"warn\", and \"error\".")
@@ -95,6 +101,10 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
+	if c.flagPollTimeout > 0 {
+		c.serviceRegistrationPollingAttempts = uint64((c.flagPollTimeout + servicePollingInterval - 1) / servicePollingInterval)
+	}
+
 	// Set up logging.
 	if c.logger == nil {
 		var err error
@@ -164,7 +174,7 @@ func (c *Command) Run(args []string) int {
 			c.logger.Error("Unable to get Agent services", "error", err)
 			return err
 		}
-		// Wait for the service and the connect-proxy service to be registered.
+		// Wait for the service and the proxy service to be registered.
 		if len(serviceList) != 2 {
 			c.logger.Info("Unable to find registered services; retrying")
 			// Once every 10 times we're going to print this informational message to the pod logs so that
@@ -190,28 +200,28 @@ func (c *Command) Run(args []string) int {
 					return nil
 				}
 
-				if c.flagServiceName == "" && svc.Kind != api.ServiceKindConnectProxy && c.flagServiceAccountName != svc.Service {
+				if c.flagServiceName == "" && !isProxyKind(svc.Kind) && c.flagServiceAccountName != svc.Service {
 					// Set the error but return nil so we don't retry.
 					errServiceNameMismatch = fmt.Errorf("service account name %s doesn't match Consul service name %s", c.flagServiceAccountName, svc.Service)
 					return nil
 				}
 			}
-			if svc.Kind == api.ServiceKindConnectProxy {
-				// This is the proxy service ID.
+			if isProxyKind(svc.Kind) {
+				// This is the proxy service ID. Gateway pods are registered with a gateway instead of a sidecar proxy.
 				proxyID = svc.ID
 			}
 		}
 
 		if proxyID == "" {
 			// In theory we can't reach this point unless we have 2 services registered against
-			// this pod and neither are the connect-proxy. We don't support this case anyway, but it
+			// this pod and neither are a proxy. We don't support this case anyway, but it
 			// is necessary to return from the function.
-			return fmt.Errorf("unable to find registered connect-proxy service")
+			return fmt.Errorf("unable to find registered connect-proxy or gateway service")
 		}
 		return nil
-	}, backoff.WithMaxRetries(backoff.NewConstantBackOff(1*time.Second), c.serviceRegistrationPollingAttempts))
+	}, backoff.WithMaxRetries(backoff.NewConstantBackOff(servicePollingInterval), c.serviceRegistrationPollingAttempts))
 	if err != nil {
-		c.logger.Error("Timed out waiting for service registration", "error", err)
+		c.logger.Error("Timed out waiting for service registration", "attempts", c.serviceRegistrationPollingAttempts, "error", err)
 		return 1
 	}
 	if errServiceNameMismatch != nil {
@@ -228,6 +238,16 @@ func (c *Command) Run(args []string) int {
 	return 0
 }
 
+// isProxyKind returns true if the service kind is one the endpoints controller registers as the
+// proxy for a pod, i.e. a sidecar proxy or a gateway.
+func isProxyKind(kind api.ServiceKind) bool {
+	switch kind {
+	case api.ServiceKindConnectProxy, api.ServiceKindMeshGateway, api.ServiceKindIngressGateway, api.ServiceKindTerminatingGateway:
+		return true
+	}
+	return false
+}
+
 func (c *Command) validateFlags() error {
 	if c.flagPodName == "" {
 		return errors.New("-pod-name must be set")
@@ -238,6 +258,9 @@ func (c *Command) validateFlags() error {
 	if c.flagACLAuthMethod != "" && c.flagServiceAccountName == "" {
 		return errors.New("-service-account-name must be set when ACLs are enabled")
 	}
+	if c.flagPollTimeout < 0 {
+		return errors.New("-poll-timeout must not be negative")
+	}
 
 	if c.http.ConsulAPITimeout() <= 0 {
 		return errors.New("-consul-api-timeout must be set to a value greater than 0")
@@ -1,6 +1,7 @@
 package connectinit
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	connectinject "github.com/hashicorp/consul-k8s/control-plane/connect-inject"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/test"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
 	"github.com/hashicorp/consul/api"
@@ -58,6 +60,13 @@ func TestRun_FlagValidation(t *testing.T) {
 				"-log-level", "invalid"},
 			expErr: "unknown log level: invalid",
 		},
+		{
+			flags: []string{
+				"-pod-name", testPodName,
+				"-pod-namespace", testPodNamespace,
+				"-poll-timeout", "-5s"},
+			expErr: "-poll-timeout must not be negative",
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.expErr, func(t *testing.T) {
@@ -533,6 +542,66 @@ func TestRun_ServicePollingErrors(t *testing.T) {
 	}
 }
 
+// TestRun_GatewayServicePolling tests that the ID of a gateway registered for the pod by the
+// endpoints controller is written to the proxy ID file just like a sidecar proxy's.
+func TestRun_GatewayServicePolling(t *testing.T) {
+	t.Parallel()
+	cases := map[string]api.ServiceKind{
+		"mesh gateway":        api.ServiceKindMeshGateway,
+		"ingress gateway":     api.ServiceKindIngressGateway,
+		"terminating gateway": api.ServiceKindTerminatingGateway,
+	}
+	for name, kind := range cases {
+		kind := kind
+		t.Run(name, func(t *testing.T) {
+			proxyFile := common.WriteTempFile(t, "")
+			meta := map[string]string{
+				connectinject.MetaKeyPodName: testPodName,
+				connectinject.MetaKeyKubeNS:  testPodNamespace,
+			}
+			gatewayID := fmt.Sprintf("counting-pod-counting-%s", kind)
+			services := map[string]*api.AgentService{
+				"counting-pod-counting": {
+					ID:      "counting-pod-counting",
+					Service: "counting",
+					Meta:    meta,
+				},
+				gatewayID: {
+					Kind:    kind,
+					ID:      gatewayID,
+					Service: fmt.Sprintf("counting-%s", kind),
+					Meta:    meta,
+				},
+			}
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r != nil && r.URL.Path == "/v1/agent/services" && r.Method == "GET" {
+					json.NewEncoder(w).Encode(services)
+				}
+			}))
+			defer consulServer.Close()
+
+			ui := cli.NewMockUi()
+			cmd := Command{
+				UI:                                 ui,
+				serviceRegistrationPollingAttempts: 1,
+			}
+			flags := []string{
+				"-pod-name", testPodName,
+				"-pod-namespace", testPodNamespace,
+				"-http-addr", consulServer.URL,
+				"-proxy-id-file", proxyFile,
+				"-consul-api-timeout", "5s",
+			}
+			code := cmd.Run(flags)
+			require.Equal(t, 0, code, ui.ErrorWriter.String())
+
+			data, err := os.ReadFile(proxyFile)
+			require.NoError(t, err)
+			require.Equal(t, gatewayID, string(data))
+		})
+	}
+}
+
 // TestRun_RetryServicePolling runs the command but does not register the consul service
 // for 2 seconds and then asserts that the proxyid file gets written correctly.
 func TestRun_RetryServicePolling(t *testing.T) {
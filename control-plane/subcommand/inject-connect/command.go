@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
 	connectinject "github.com/hashicorp/consul-k8s/control-plane/connect-inject"
@@ -23,6 +24,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -43,11 +45,20 @@ type Command struct {
 	flagConsulImage           string // Docker image for Consul
 	flagEnvoyImage            string // Docker image for Envoy
 	flagConsulK8sImage        string // Docker image for consul-k8s
+	flagSkipCopyContainer     bool   // True to skip the init container that copies the Consul binary
+	flagConsulBinaryPath      string // Path of the Consul binary in the consul-k8s image if the copy container is skipped
+	flagAgentlessMode         bool   // True if the sidecar bootstraps Envoy itself
 	flagACLAuthMethod         string // Auth Method to use for ACLs, if enabled
 	flagWriteServiceDefaults  bool   // True to enable central config injection
 	flagDefaultProtocol       string // Default protocol for use with central config
 	flagConsulCACert          string // [Deprecated] Path to CA Certificate to use when communicating with Consul clients
+	flagConsulCACertFile      string // Path to the CA Certificate injected pods use when communicating with Consul clients
+	flagConsulGRPCTLS         string // Whether injected pods use TLS for Consul's gRPC port, if different from HTTP
+	flagConsulHTTPProxy       string // URL of the proxy injected pods use to reach Consul
+	flagConsulNoProxy         string // Hosts injected pods reach without going through the proxy
 	flagEnvoyExtraArgs        string // Extra envoy args when starting envoy
+	flagEnvoyAdminBindAddress string // Address Envoy's admin API binds to
+	flagBootstrapFileMode     string // File mode of the Envoy bootstrap and ACL token files
 	flagEnableWebhookCAUpdate bool
 	flagLogLevel              string
 	flagLogJSON               bool
@@ -65,8 +76,16 @@ type Command struct {
 	flagCrossNamespaceACLPolicy    string // The name of the ACL policy to add to every created namespace if ACLs are enabled
 
 	// Flags for endpoints controller.
-	flagReleaseName      string
-	flagReleaseNamespace string
+	flagReleaseName               string
+	flagReleaseNamespace          string
+	flagWarnOnMissingUpstreams    bool
+	flagCopyAllLabelsToMeta       bool
+	flagUpstreamDefaultsConfigMap string
+	flagRequeueAfterChange        time.Duration
+	flagAgentConnectRetries       int
+	flagAgentConnectRetryInterval time.Duration
+	flagGarbageCollectOnStartup   bool
+	flagReconcileTimeout          time.Duration
 
 	// Proxy resource settings.
 	flagDefaultSidecarProxyCPULimit      string
@@ -94,6 +113,12 @@ type Command struct {
 	flagInitContainerMemoryLimit   string
 	flagInitContainerMemoryRequest string
 
+	// Init container settings.
+	flagConnectInitPollTimeout         time.Duration
+	flagWaitForTimeout                 time.Duration
+	flagInitContainerExtraVolumeMounts []string
+	flagConnectInitLogLevel            string
+
 	// Server address flags.
 	flagReadServerExposeService bool
 	flagTokenServerAddresses    []string
@@ -101,6 +126,9 @@ type Command struct {
 	// Transparent proxy flags.
 	flagDefaultEnableTransparentProxy          bool
 	flagTransparentProxyDefaultOverwriteProbes bool
+	flagTransparentProxyUsePrivileged          bool
+	flagTransparentProxyDefaultExcludeUIDs     []string
+	flagClusterDNSPort                         int
 
 	// CNI flag.
 	flagEnableCNI bool
@@ -148,9 +176,19 @@ func (c *Command) init() {
 		"Docker image for Envoy.")
 	c.flagSet.StringVar(&c.flagConsulK8sImage, "consul-k8s-image", "",
 		"Docker image for consul-k8s. Used for the connect sidecar.")
+	c.flagSet.BoolVar(&c.flagSkipCopyContainer, "skip-copy-container", false,
+		"Skip the init container that copies the Consul binary. Use when the consul-k8s image, such as a dataplane image, already contains the Consul binary at /bin/consul.")
+	c.flagSet.StringVar(&c.flagConsulBinaryPath, "consul-binary-path", "",
+		"Path of the Consul binary in the consul-k8s image when -skip-copy-container is set. Defaults to /bin/consul.")
+	c.flagSet.BoolVar(&c.flagAgentlessMode, "agentless-mode", false,
+		"Don't bootstrap Envoy in the init container because the sidecar, such as consul-dataplane, bootstraps itself.")
 	c.flagSet.BoolVar(&c.flagEnablePeering, "enable-peering", false, "Enable cluster peering controllers.")
 	c.flagSet.StringVar(&c.flagEnvoyExtraArgs, "envoy-extra-args", "",
 		"Extra envoy command line args to be set when starting envoy (e.g \"--log-level debug --disable-hot-restart\").")
+	c.flagSet.StringVar(&c.flagEnvoyAdminBindAddress, "envoy-admin-bind-address", "",
+		"Address Envoy's admin API binds to. Always used by multi port pods, which default to 127.0.0.1, and by single port pods when set.")
+	c.flagSet.StringVar(&c.flagBootstrapFileMode, "bootstrap-file-mode", "",
+		"Octal file mode, e.g. 0640, the init container sets on the Envoy bootstrap file and the ACL token file. If not set, the files keep the default permissions.")
 	c.flagSet.StringVar(&c.flagACLAuthMethod, "acl-auth-method", "",
 		"The name of the Kubernetes Auth Method to use for connectInjection if ACLs are enabled.")
 	c.flagSet.BoolVar(&c.flagWriteServiceDefaults, "enable-central-config", false,
@@ -159,6 +197,17 @@ func (c *Command) init() {
 		"The default protocol to use in central config registrations.")
 	c.flagSet.StringVar(&c.flagConsulCACert, "consul-ca-cert", "",
 		"[Deprecated] Please use '-ca-file' flag instead. Path to CA certificate to use if communicating with Consul clients over HTTPS.")
+	c.flagSet.StringVar(&c.flagConsulCACertFile, "consul-ca-cert-file", "",
+		"Path to a file, e.g. a mounted secret, with the PEM-encoded CA certificate injected pods use if communicating with Consul clients over HTTPS. "+
+			"Environment variables in the path are expanded. Takes precedence over the CA certificate from '-ca-file'.")
+	c.flagSet.StringVar(&c.flagConsulGRPCTLS, "consul-grpc-tls", "",
+		"Whether injected pods connect to the gRPC port of Consul clients over TLS, \"true\" or \"false\". "+
+			"If not set, gRPC uses TLS only if HTTPS is used.")
+	c.flagSet.StringVar(&c.flagConsulHTTPProxy, "consul-http-proxy", "",
+		"URL of the proxy, e.g. an egress proxy, the init container of injected pods uses to reach Consul.")
+	c.flagSet.StringVar(&c.flagConsulNoProxy, "consul-no-proxy", "",
+		"Comma-separated list of hosts, IPs or CIDRs the init container of injected pods reaches without going "+
+			"through -consul-http-proxy. The host IP of the Consul client is always included.")
 	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagAllowK8sNamespacesList), "allow-k8s-namespace",
 		"K8s namespaces to explicitly allow. May be specified multiple times.")
 	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagDenyK8sNamespacesList), "deny-k8s-namespace",
@@ -185,6 +234,38 @@ func (c *Command) init() {
 		"Enable CNI traffic redirection for all Consul service mesh applications.")
 	c.flagSet.BoolVar(&c.flagTransparentProxyDefaultOverwriteProbes, "transparent-proxy-default-overwrite-probes", true,
 		"Overwrite Kubernetes probes to point to Envoy by default when in Transparent Proxy mode.")
+	c.flagSet.BoolVar(&c.flagTransparentProxyUsePrivileged, "transparent-proxy-use-privileged", true,
+		"Run the init container that applies Transparent Proxy traffic redirection rules as privileged. "+
+			"If false, the init container is granted the NET_ADMIN and NET_RAW capabilities instead.")
+	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagTransparentProxyDefaultExcludeUIDs), "transparent-proxy-default-exclude-uid",
+		"User ID to exclude from Transparent Proxy traffic redirection on every pod, in addition to the UIDs "+
+			"excluded by pod annotation. May be specified multiple times.")
+	c.flagSet.IntVar(&c.flagClusterDNSPort, "cluster-dns-port", 53,
+		"Port of the cluster's DNS service, excluded from Transparent Proxy traffic redirection on pods "+
+			"with the transparent-proxy-exclude-dns annotation.")
+	c.flagSet.BoolVar(&c.flagWarnOnMissingUpstreams, "warn-on-missing-upstreams", false,
+		"Log a warning when an upstream service has no instances registered in the Consul catalog. "+
+			"Enabling this adds a catalog lookup for every upstream when registering a service.")
+	c.flagSet.BoolVar(&c.flagCopyAllLabelsToMeta, "copy-all-labels-to-meta", false,
+		"Copy all pod labels into the meta of the Consul services registered for the pod. Each label key is "+
+			"prefixed with \"k8s-label-\" and characters that aren't valid in meta keys are replaced with underscores.")
+	c.flagSet.StringVar(&c.flagUpstreamDefaultsConfigMap, "upstream-defaults-configmap", "",
+		"ConfigMap whose \"upstreams\" key holds upstreams, in the format of the upstreams annotation, to add to "+
+			"every pod, in the form [<namespace>/]<name>. The namespace defaults to -release-namespace.")
+	c.flagSet.DurationVar(&c.flagRequeueAfterChange, "requeue-after-change", 0,
+		"How long to wait before reconciling a service's Endpoints again after service instances were registered, "+
+			"deregistered or changed health for it, e.g. \"10s\". This smooths out bursts of updates to the "+
+			"Endpoints. Disabled if zero.")
+	c.flagSet.IntVar(&c.flagAgentConnectRetries, "agent-connect-retries", 2,
+		"How many times to retry reaching the Consul agent local to a pod before registering its services. "+
+			"The agent is not checked before registering if zero.")
+	c.flagSet.DurationVar(&c.flagAgentConnectRetryInterval, "agent-connect-retry-interval", 1*time.Second,
+		"How long to wait between attempts to reach the Consul agent local to a pod.")
+	c.flagSet.BoolVar(&c.flagGarbageCollectOnStartup, "garbage-collect-on-startup", false,
+		"Deregister the Consul services registered for pods that no longer exist when the controller starts.")
+	c.flagSet.DurationVar(&c.flagReconcileTimeout, "reconcile-timeout", 0,
+		"How long a single reconcile of a service's Endpoints may take, including its calls to Consul, e.g. \"30s\". "+
+			"A reconcile that times out is retried. There is no timeout if zero.")
 	c.flagSet.BoolVar(&c.flagEnableConsulDNS, "enable-consul-dns", false,
 		"Enables Consul DNS lookup for services in the mesh.")
 	c.flagSet.StringVar(&c.flagResourcePrefix, "resource-prefix", "",
@@ -220,7 +301,18 @@ func (c *Command) init() {
 	c.flagSet.StringVar(&c.flagInitContainerCPURequest, "init-container-cpu-request", "50m", "Init container CPU request.")
 	c.flagSet.StringVar(&c.flagInitContainerCPULimit, "init-container-cpu-limit", "50m", "Init container CPU limit.")
 	c.flagSet.StringVar(&c.flagInitContainerMemoryRequest, "init-container-memory-request", "25Mi", "Init container memory request.")
+	c.flagSet.DurationVar(&c.flagConnectInitPollTimeout, "connect-init-poll-timeout", 0,
+		"How long the init container waits for the pod's service to be registered with Consul before failing. Defaults to 120s.")
+	c.flagSet.DurationVar(&c.flagWaitForTimeout, "wait-for-timeout", 5*time.Minute,
+		"How long the init container waits for the services in the consul.hashicorp.com/connect-inject-wait-for "+
+			"annotation to be registered with Consul before failing.")
+	c.flagSet.StringVar(&c.flagConnectInitLogLevel, "connect-init-log-level", "",
+		"Log level of the init container's connect-init command, one of \"trace\", \"debug\", \"info\", \"warn\", or \"error\". "+
+			"May be overridden with the consul.hashicorp.com/connect-init-log-level annotation. Defaults to \"info\".")
 	c.flagSet.StringVar(&c.flagInitContainerMemoryLimit, "init-container-memory-limit", "150Mi", "Init container memory limit.")
+	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagInitContainerExtraVolumeMounts), "init-container-extra-volume-mount",
+		"Volume to mount into the init container, in the form <volume name>:<mount path>[:ro]. The volume must "+
+			"exist in the pod. May be specified multiple times.")
 
 	// Consul sidecar resource setting flags.
 	c.flagSet.StringVar(&c.flagDefaultConsulSidecarCPURequest, "default-consul-sidecar-cpu-request", "20m", "Default consul sidecar CPU request.")
@@ -315,6 +407,18 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
+	initContainerExtraVolumeMounts, err := c.parseInitContainerExtraVolumeMounts()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	upstreamDefaultsConfigMap, err := c.parseUpstreamDefaultsConfigMap()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
 	// We must have an in-cluster K8S client.
 	if c.clientset == nil {
 		config, err := rest.InClusterConfig()
@@ -427,6 +531,7 @@ func (c *Command) Run(args []string) int {
 		MetricsConfig:              metricsConfig,
 		ConsulClientCfg:            cfg,
 		EnableConsulPartitions:     c.flagEnablePartitions,
+		ConsulPartition:            c.http.Partition(),
 		EnableConsulNamespaces:     c.flagEnableNamespaces,
 		ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
 		EnableNSMirroring:          c.flagEnableK8SNSMirroring,
@@ -434,6 +539,14 @@ func (c *Command) Run(args []string) int {
 		CrossNSACLPolicy:           c.flagCrossNamespaceACLPolicy,
 		EnableTransparentProxy:     c.flagDefaultEnableTransparentProxy,
 		TProxyOverwriteProbes:      c.flagTransparentProxyDefaultOverwriteProbes,
+		WarnOnMissingUpstreams:     c.flagWarnOnMissingUpstreams,
+		CopyAllLabelsToMeta:        c.flagCopyAllLabelsToMeta,
+		UpstreamDefaultsConfigMap:  upstreamDefaultsConfigMap,
+		RequeueAfterChange:         c.flagRequeueAfterChange,
+		AgentConnectRetries:        c.flagAgentConnectRetries,
+		AgentConnectRetryInterval:  c.flagAgentConnectRetryInterval,
+		GarbageCollectOnStartup:    c.flagGarbageCollectOnStartup,
+		ReconcileTimeout:           c.flagReconcileTimeout,
 		AuthMethod:                 c.flagACLAuthMethod,
 		Log:                        ctrl.Log.WithName("controller").WithName("endpoints"),
 		Scheme:                     mgr.GetScheme(),
@@ -493,44 +606,71 @@ func (c *Command) Run(args []string) int {
 
 	mgr.GetWebhookServer().CertDir = c.flagCertDir
 
-	mgr.GetWebhookServer().Register("/mutate",
-		&webhook.Admission{Handler: &connectinject.MeshWebhook{
-			Clientset:                     c.clientset,
-			ConsulClient:                  c.consulClient,
-			ImageConsul:                   c.flagConsulImage,
-			ImageEnvoy:                    c.flagEnvoyImage,
-			EnvoyExtraArgs:                c.flagEnvoyExtraArgs,
-			ImageConsulK8S:                c.flagConsulK8sImage,
-			RequireAnnotation:             !c.flagDefaultInject,
-			AuthMethod:                    c.flagACLAuthMethod,
-			ConsulCACert:                  string(consulCACert),
-			DefaultProxyCPURequest:        sidecarProxyCPURequest,
-			DefaultProxyCPULimit:          sidecarProxyCPULimit,
-			DefaultProxyMemoryRequest:     sidecarProxyMemoryRequest,
-			DefaultProxyMemoryLimit:       sidecarProxyMemoryLimit,
-			DefaultEnvoyProxyConcurrency:  c.flagDefaultEnvoyProxyConcurrency,
-			MetricsConfig:                 metricsConfig,
-			InitContainerResources:        initResources,
-			DefaultConsulSidecarResources: consulSidecarResources,
-			ConsulPartition:               c.http.Partition(),
-			AllowK8sNamespacesSet:         allowK8sNamespaces,
-			DenyK8sNamespacesSet:          denyK8sNamespaces,
-			EnableNamespaces:              c.flagEnableNamespaces,
-			ConsulDestinationNamespace:    c.flagConsulDestinationNamespace,
-			EnableK8SNSMirroring:          c.flagEnableK8SNSMirroring,
-			K8SNSMirroringPrefix:          c.flagK8SNSMirroringPrefix,
-			CrossNamespaceACLPolicy:       c.flagCrossNamespaceACLPolicy,
-			EnableTransparentProxy:        c.flagDefaultEnableTransparentProxy,
-			EnableCNI:                     c.flagEnableCNI,
-			TProxyOverwriteProbes:         c.flagTransparentProxyDefaultOverwriteProbes,
-			EnableConsulDNS:               c.flagEnableConsulDNS,
-			ResourcePrefix:                c.flagResourcePrefix,
-			EnableOpenShift:               c.flagEnableOpenShift,
-			Log:                           ctrl.Log.WithName("handler").WithName("connect"),
-			LogLevel:                      c.flagLogLevel,
-			LogJSON:                       c.flagLogJSON,
-			ConsulAPITimeout:              c.http.ConsulAPITimeout(),
-		}})
+	var consulGRPCTLS *bool
+	if c.flagConsulGRPCTLS != "" {
+		// The flag has already been validated.
+		grpcTLS, _ := strconv.ParseBool(c.flagConsulGRPCTLS)
+		consulGRPCTLS = &grpcTLS
+	}
+
+	meshWebhook := &connectinject.MeshWebhook{
+		Clientset:                      c.clientset,
+		ConsulClient:                   c.consulClient,
+		ImageConsul:                    c.flagConsulImage,
+		ImageEnvoy:                     c.flagEnvoyImage,
+		EnvoyExtraArgs:                 c.flagEnvoyExtraArgs,
+		EnvoyAdminBindAddress:          c.flagEnvoyAdminBindAddress,
+		BootstrapFileMode:              c.flagBootstrapFileMode,
+		ImageConsulK8S:                 c.flagConsulK8sImage,
+		SkipCopyContainer:              c.flagSkipCopyContainer,
+		ConsulBinaryPath:               c.flagConsulBinaryPath,
+		AgentlessMode:                  c.flagAgentlessMode,
+		ConnectInitPollTimeout:         c.flagConnectInitPollTimeout,
+		WaitForTimeout:                 c.flagWaitForTimeout,
+		ConnectInitLogLevel:            c.flagConnectInitLogLevel,
+		RequireAnnotation:              !c.flagDefaultInject,
+		AuthMethod:                     c.flagACLAuthMethod,
+		ConsulCACert:                   string(consulCACert),
+		ConsulCACertFile:               c.flagConsulCACertFile,
+		ConsulGRPCTLS:                  consulGRPCTLS,
+		ConsulHTTPProxy:                c.flagConsulHTTPProxy,
+		ConsulNoProxy:                  c.flagConsulNoProxy,
+		DefaultProxyCPURequest:         sidecarProxyCPURequest,
+		DefaultProxyCPULimit:           sidecarProxyCPULimit,
+		DefaultProxyMemoryRequest:      sidecarProxyMemoryRequest,
+		DefaultProxyMemoryLimit:        sidecarProxyMemoryLimit,
+		DefaultEnvoyProxyConcurrency:   c.flagDefaultEnvoyProxyConcurrency,
+		MetricsConfig:                  metricsConfig,
+		InitContainerResources:         initResources,
+		InitContainerExtraVolumeMounts: initContainerExtraVolumeMounts,
+		DefaultConsulSidecarResources:  consulSidecarResources,
+		ConsulPartition:                c.http.Partition(),
+		AllowK8sNamespacesSet:          allowK8sNamespaces,
+		DenyK8sNamespacesSet:           denyK8sNamespaces,
+		EnableNamespaces:               c.flagEnableNamespaces,
+		ConsulDestinationNamespace:     c.flagConsulDestinationNamespace,
+		EnableK8SNSMirroring:           c.flagEnableK8SNSMirroring,
+		K8SNSMirroringPrefix:           c.flagK8SNSMirroringPrefix,
+		CrossNamespaceACLPolicy:        c.flagCrossNamespaceACLPolicy,
+		EnableTransparentProxy:         c.flagDefaultEnableTransparentProxy,
+		EnableCNI:                      c.flagEnableCNI,
+		TProxyOverwriteProbes:          c.flagTransparentProxyDefaultOverwriteProbes,
+		TProxyNonPrivileged:            !c.flagTransparentProxyUsePrivileged,
+		TProxyDefaultExcludeUIDs:       c.flagTransparentProxyDefaultExcludeUIDs,
+		ClusterDNSPort:                 c.flagClusterDNSPort,
+		EnableConsulDNS:                c.flagEnableConsulDNS,
+		ResourcePrefix:                 c.flagResourcePrefix,
+		EnableOpenShift:                c.flagEnableOpenShift,
+		Log:                            ctrl.Log.WithName("handler").WithName("connect"),
+		LogLevel:                       c.flagLogLevel,
+		LogJSON:                        c.flagLogJSON,
+		ConsulAPITimeout:               c.http.ConsulAPITimeout(),
+	}
+	if err := meshWebhook.LoadConsulCACert(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	mgr.GetWebhookServer().Register("/mutate", &webhook.Admission{Handler: meshWebhook})
 
 	if c.flagEnableWebhookCAUpdate {
 		err := c.updateWebhookCABundle(ctx)
@@ -572,6 +712,9 @@ func (c *Command) validateFlags() error {
 	if c.flagEnvoyImage == "" {
 		return errors.New("-envoy-image must be set")
 	}
+	if c.flagConsulBinaryPath != "" && !c.flagSkipCopyContainer {
+		return errors.New("-consul-binary-path may only be set if -skip-copy-container is set")
+	}
 	if c.flagWriteServiceDefaults {
 		return errors.New("-enable-central-config is no longer supported")
 	}
@@ -587,6 +730,54 @@ func (c *Command) validateFlags() error {
 		return errors.New("-enable-partitions must be set to 'true' if -partition-name is set")
 	}
 
+	if c.flagConsulGRPCTLS != "" {
+		if _, err := strconv.ParseBool(c.flagConsulGRPCTLS); err != nil {
+			return fmt.Errorf("-consul-grpc-tls %q must be \"true\" or \"false\"", c.flagConsulGRPCTLS)
+		}
+	}
+
+	if c.flagConsulHTTPProxy != "" {
+		if proxyURL, err := url.Parse(c.flagConsulHTTPProxy); err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return fmt.Errorf("-consul-http-proxy %q must be a URL, e.g. http://proxy:3128", c.flagConsulHTTPProxy)
+		}
+	}
+
+	if c.flagConsulNoProxy != "" && c.flagConsulHTTPProxy == "" {
+		return errors.New("-consul-no-proxy may only be set if -consul-http-proxy is set")
+	}
+
+	if c.flagAgentConnectRetries < 0 {
+		return errors.New("-agent-connect-retries must be >= 0")
+	}
+
+	if c.flagReconcileTimeout < 0 {
+		return errors.New("-reconcile-timeout must be >= 0")
+	}
+
+	for _, uid := range c.flagTransparentProxyDefaultExcludeUIDs {
+		if n, err := strconv.Atoi(uid); err != nil || n < 0 {
+			return fmt.Errorf("-transparent-proxy-default-exclude-uid %q must be a user ID", uid)
+		}
+	}
+
+	if c.flagClusterDNSPort < 1 || c.flagClusterDNSPort > 65535 {
+		return errors.New("-cluster-dns-port must be between 1 and 65535")
+	}
+
+	if c.flagWaitForTimeout <= 0 {
+		return errors.New("-wait-for-timeout must be greater than 0")
+	}
+
+	switch c.flagConnectInitLogLevel {
+	case "", "trace", "debug", "info", "warn", "error":
+	default:
+		return errors.New("-connect-init-log-level must be one of \"trace\", \"debug\", \"info\", \"warn\", or \"error\"")
+	}
+
+	if connectinject.ValidateBootstrapFileMode(c.flagBootstrapFileMode) != nil {
+		return fmt.Errorf("-bootstrap-file-mode %q must be an octal file mode, e.g. 0640", c.flagBootstrapFileMode)
+	}
+
 	if c.flagDefaultEnvoyProxyConcurrency < 0 {
 		return errors.New("-default-envoy-proxy-concurrency must be >= 0 if set")
 	}
@@ -596,6 +787,49 @@ func (c *Command) validateFlags() error {
 	}
 	return nil
 }
+
+// parseInitContainerExtraVolumeMounts parses the -init-container-extra-volume-mount
+// flags, each of the form <volume name>:<mount path>[:ro], into volume mounts.
+func (c *Command) parseInitContainerExtraVolumeMounts() ([]corev1.VolumeMount, error) {
+	var mounts []corev1.VolumeMount
+	names := make(map[string]bool)
+	for _, raw := range c.flagInitContainerExtraVolumeMounts {
+		parts := strings.Split(raw, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || !strings.HasPrefix(parts[1], "/") ||
+			(len(parts) == 3 && parts[2] != "ro") {
+			return nil, fmt.Errorf("-init-container-extra-volume-mount %q is invalid: must be of the form <volume name>:<absolute mount path>[:ro]", raw)
+		}
+		if names[parts[0]] {
+			return nil, fmt.Errorf("-init-container-extra-volume-mount %q is invalid: volume %q is mounted more than once", raw, parts[0])
+		}
+		names[parts[0]] = true
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      parts[0],
+			MountPath: parts[1],
+			ReadOnly:  len(parts) == 3,
+		})
+	}
+	return mounts, nil
+}
+
+// parseUpstreamDefaultsConfigMap parses the -upstream-defaults-configmap flag of
+// the form [<namespace>/]<name>. It returns an empty name if the flag is not set.
+func (c *Command) parseUpstreamDefaultsConfigMap() (types.NamespacedName, error) {
+	if c.flagUpstreamDefaultsConfigMap == "" {
+		return types.NamespacedName{}, nil
+	}
+	parts := strings.Split(c.flagUpstreamDefaultsConfigMap, "/")
+	switch {
+	case len(parts) == 1:
+		return types.NamespacedName{Namespace: c.flagReleaseNamespace, Name: parts[0]}, nil
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return types.NamespacedName{Namespace: parts[0], Name: parts[1]}, nil
+	default:
+		return types.NamespacedName{}, fmt.Errorf("-upstream-defaults-configmap %q is invalid: must be of the form [<namespace>/]<name>",
+			c.flagUpstreamDefaultsConfigMap)
+	}
+}
+
 func (c *Command) parseAndValidateResourceFlags() (corev1.ResourceRequirements, corev1.ResourceRequirements, error) {
 	// Init container
 	var initContainerCPULimit, initContainerCPURequest, initContainerMemoryLimit, initContainerMemoryRequest resource.Quantity
@@ -7,6 +7,7 @@ import (
 	"github.com/hashicorp/consul/api"
 	"github.com/mitchellh/cli"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
@@ -36,6 +37,61 @@ func TestRun_FlagValidation(t *testing.T) {
 				"-consul-api-timeout", "5s", "-log-level", "invalid"},
 			expErr: "unknown log level \"invalid\": unrecognized level: \"invalid\"",
 		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-consul-binary-path", "/usr/local/bin/consul"},
+			expErr: "-consul-binary-path may only be set if -skip-copy-container is set",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-agent-connect-retries", "-1"},
+			expErr: "-agent-connect-retries must be >= 0",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-reconcile-timeout", "-1s"},
+			expErr: "-reconcile-timeout must be >= 0",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-transparent-proxy-default-exclude-uid", "5996", "-transparent-proxy-default-exclude-uid", "root"},
+			expErr: `-transparent-proxy-default-exclude-uid "root" must be a user ID`,
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-cluster-dns-port", "0"},
+			expErr: "-cluster-dns-port must be between 1 and 65535",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-wait-for-timeout", "0s"},
+			expErr: "-wait-for-timeout must be greater than 0",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-consul-grpc-tls", "yes"},
+			expErr: `-consul-grpc-tls "yes" must be "true" or "false"`,
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-consul-http-proxy", "proxy:3128"},
+			expErr: `-consul-http-proxy "proxy:3128" must be a URL, e.g. http://proxy:3128`,
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-consul-no-proxy", "10.0.0.0/8"},
+			expErr: "-consul-no-proxy may only be set if -consul-http-proxy is set",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-connect-init-log-level", "verbose"},
+			expErr: `-connect-init-log-level must be one of "trace", "debug", "info", "warn", or "error"`,
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-bootstrap-file-mode", "0999"},
+			expErr: `-bootstrap-file-mode "0999" must be an octal file mode, e.g. 0640`,
+		},
 		{
 			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
 				"-consul-api-timeout", "5s", "-enable-central-config", "true"},
@@ -124,6 +180,27 @@ func TestRun_FlagValidation(t *testing.T) {
 			},
 			expErr: "request must be <= limit: -init-container-memory-request value of \"50Mi\" is greater than the -init-container-memory-limit value of \"25Mi\"",
 		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-init-container-extra-volume-mount", "templates/etc/templates"},
+			expErr: `-init-container-extra-volume-mount "templates/etc/templates" is invalid: must be of the form <volume name>:<absolute mount path>[:ro]`,
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-init-container-extra-volume-mount", "templates:/etc/templates:rw"},
+			expErr: `-init-container-extra-volume-mount "templates:/etc/templates:rw" is invalid: must be of the form <volume name>:<absolute mount path>[:ro]`,
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-init-container-extra-volume-mount", "templates:/etc/templates:ro",
+				"-init-container-extra-volume-mount", "templates:/etc/other"},
+			expErr: `-init-container-extra-volume-mount "templates:/etc/other" is invalid: volume "templates" is mounted more than once`,
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-upstream-defaults-configmap", "consul/upstreams/extra"},
+			expErr: `-upstream-defaults-configmap "consul/upstreams/extra" is invalid: must be of the form [<namespace>/]<name>`,
+		},
 		{
 			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
 				"-consul-api-timeout", "5s", "-init-container-cpu-request=50m",
@@ -217,6 +294,34 @@ func TestRun_ResourceLimitDefaults(t *testing.T) {
 	require.Equal(t, cmd.flagDefaultConsulSidecarMemoryLimit, "50Mi")
 }
 
+func TestParseUpstreamDefaultsConfigMap(t *testing.T) {
+	cases := map[string]struct {
+		flag string
+		exp  types.NamespacedName
+	}{
+		"unset": {
+			flag: "",
+			exp:  types.NamespacedName{},
+		},
+		"name only": {
+			flag: "upstreams",
+			exp:  types.NamespacedName{Namespace: "consul", Name: "upstreams"},
+		},
+		"namespace and name": {
+			flag: "default/upstreams",
+			exp:  types.NamespacedName{Namespace: "default", Name: "upstreams"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			cmd := Command{flagUpstreamDefaultsConfigMap: c.flag, flagReleaseNamespace: "consul"}
+			actual, err := cmd.parseUpstreamDefaultsConfigMap()
+			require.NoError(t, err)
+			require.Equal(t, c.exp, actual)
+		})
+	}
+}
+
 func TestRun_ValidationConsulHTTPAddr(t *testing.T) {
 	k8sClient := fake.NewSimpleClientset()
 	ui := cli.NewMockUi()